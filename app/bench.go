@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/loadgen"
+)
+
+// runBench implements the bench subcommand, which load tests a running
+// server and prints latency percentiles:
+//
+//	./your_program.sh bench -c 64 -n 100000 -keepalive http://localhost:4221/echo/abc
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	conc := fs.Int("c", 16, "concurrent connections")
+	requests := fs.Int("n", 0, "total requests (0 means run for -d)")
+	duration := fs.Duration("d", 10*time.Second, "how long to run when -n is 0")
+	keepAlive := fs.Bool("keepalive", true, "reuse connections between requests")
+	payload := fs.Int("size", 0, "request body size in bytes, sent as a POST")
+	method := fs.String("method", "", "request method (default GET, or POST with -size)")
+	var headers headerFlag
+	fs.Var(&headers, "H", "extra request header as 'Key: value', may be repeated")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: bench [flags] URL")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := loadgen.Config{
+		URL:         fs.Arg(0),
+		Method:      *method,
+		Concurrency: *conc,
+		Requests:    *requests,
+		KeepAlive:   *keepAlive,
+		PayloadSize: *payload,
+		Header:      http.Header(headers),
+	}
+	if cfg.Requests == 0 {
+		cfg.Duration = *duration
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := loadgen.Run(ctx, cfg)
+	if err != nil {
+		ErrorLogger.Printf("bench: %s", err)
+		return 1
+	}
+	res.Report(os.Stdout)
+	return 0
+}
+
+type headerFlag http.Header
+
+func (h *headerFlag) String() string { return "" }
+
+func (h *headerFlag) Set(v string) error {
+	key, value, ok := strings.Cut(v, ":")
+	if !ok {
+		return fmt.Errorf("header %q is not of the form 'Key: value'", v)
+	}
+	if *h == nil {
+		*h = make(headerFlag)
+	}
+	http.Header(*h).Add(strings.TrimSpace(key), strings.TrimSpace(value))
+	return nil
+}
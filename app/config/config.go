@@ -0,0 +1,444 @@
+// Package config loads the server's configuration file. Files are written
+// in a TOML subset:
+//
+//	[[listen]]
+//	addr = ":4221"
+//
+//	[[listen]]
+//	addr = "[::]:4221"
+//	network = "tcp6"
+//	v6only = true
+//
+//	[[listen]]
+//	addr = ":4443"
+//	tls_cert = "/etc/http/cert.pem"
+//	tls_key = "/etc/http/key.pem"
+//	auto_tls = true
+//
+//	[[mount]]
+//	path = "/media/"
+//	dir = "/srv/files"
+//	quota = "1G"
+//
+//	[[cache]]
+//	pattern = "*.html"
+//	control = "no-cache"
+//
+//	[timeouts]
+//	read = "10s"
+//	idle = "1m"
+//
+//	[limits]
+//	max_body_size = "8M"
+//	require_content_length = true
+//	files_quota = "512M"
+//	min_free_space = "1G"
+//
+//	[compression]
+//	enabled = true
+//
+//	[log]
+//	level = "info"
+//	format = "json"
+//	slow_request = "1s"
+//	file = "/var/log/http/server.log"
+//	max_size = "100M"
+//	max_age = "24h"
+//	max_backups = 7
+//	access = true
+//	access_exclude = ["/healthz", "/metrics"]
+//	access_sample = ["/echo/=0.01"]
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// Config is the contents of a configuration file. Zero values mean the
+// setting was not given.
+type Config struct {
+	Listen      []Listener  `toml:"listen"`
+	Mounts      []Mount     `toml:"mount"`
+	Cache       []Cache     `toml:"cache"`
+	Timeouts    Timeouts    `toml:"timeouts"`
+	Limits      Limits      `toml:"limits"`
+	Compression Compression `toml:"compression"`
+	Log         Log         `toml:"log"`
+}
+
+// Listener is an address to accept connections on, serving HTTPS when a
+// certificate and key are given.
+type Listener struct {
+	Addr    string `toml:"addr"`
+	TLSCert string `toml:"tls_cert"`
+	TLSKey  string `toml:"tls_key"`
+
+	// Network is "tcp4" or "tcp6" to bind one address family. The default,
+	// "tcp", binds a wildcard address for both where the platform allows.
+	Network string `toml:"network"`
+
+	// V6Only sets IPV6_V6ONLY, so an IPv6 listener doesn't also accept
+	// IPv4 connections as mapped addresses; platforms differ on the
+	// default.
+	V6Only bool `toml:"v6only"`
+
+	// AutoTLS serves plaintext HTTP as well as HTTPS on a TLS listener,
+	// telling them apart by the first byte each client sends.
+	AutoTLS bool `toml:"auto_tls"`
+}
+
+// CheckNetwork reports whether l's Network and V6Only make sense together.
+func (l Listener) CheckNetwork() error {
+	switch l.Network {
+	case "", "tcp", "tcp6":
+	case "tcp4":
+		if l.V6Only {
+			return errors.New("v6only needs an IPv6 network")
+		}
+	default:
+		return fmt.Errorf("unknown network %q, want tcp, tcp4 or tcp6", l.Network)
+	}
+	return nil
+}
+
+// BuiltinRoutes are the prefix routes the server registers itself, which
+// a mount can't take.
+var BuiltinRoutes = []string{"/", "/echo/", "/files/"}
+
+// Mount serves and stores files under Dir at the URL prefix Path.
+type Mount struct {
+	Path  string `toml:"path"`
+	Dir   string `toml:"dir"`
+	Quota Size   `toml:"quota"` // caps the total size of files under Dir, none when zero
+}
+
+// Cache sets the Cache-Control sent with served files matching Pattern,
+// a path.Match pattern tried against the base name, or against the path
+// below the mount when it has a slash. "fingerprinted" matches names
+// carrying a content hash. The first matching entry wins.
+type Cache struct {
+	Pattern string `toml:"pattern"`
+	Control string `toml:"control"`
+}
+
+type Timeouts struct {
+	Read Duration `toml:"read"`
+	Idle Duration `toml:"idle"` // wait for the next keep-alive request
+}
+
+type Limits struct {
+	MaxBodySize Size `toml:"max_body_size"`
+
+	// RequireContentLength answers 411 to POST, PUT and PATCH requests
+	// without Content-Length or a chunked body, rather than serving them
+	// with an empty one.
+	RequireContentLength bool `toml:"require_content_length"`
+
+	// FilesQuota caps the total size of the files stored under the
+	// /files/ directory; mounts set their own quota.
+	FilesQuota Size `toml:"files_quota"`
+
+	// MinFreeSpace refuses uploads, to /files/ and every mount, that
+	// would leave less free disk space than this.
+	MinFreeSpace Size `toml:"min_free_space"`
+}
+
+type Compression struct {
+	Enabled *bool `toml:"enabled"` // nil leaves compression on
+}
+
+type Log struct {
+	Level       string   `toml:"level"`        // debug, info, warn or error
+	Format      string   `toml:"format"`       // text or json
+	SlowRequest Duration `toml:"slow_request"` // log requests taking longer at warn level
+
+	// File receives the logs instead of stderr. It is rotated once it
+	// would pass MaxSize or has been written to for MaxAge, keeping
+	// MaxBackups rotated files; zero values turn each off.
+	File       string   `toml:"file"`
+	MaxSize    Size     `toml:"max_size"`
+	MaxAge     Duration `toml:"max_age"`
+	MaxBackups int      `toml:"max_backups"`
+
+	// Access logs a line per request, except for paths starting with an
+	// AccessExclude prefix. AccessSample entries, "prefix=rate", log only
+	// that fraction of the requests under prefix.
+	Access        bool     `toml:"access"`
+	AccessExclude []string `toml:"access_exclude"`
+	AccessSample  []string `toml:"access_sample"`
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse parses and validates a configuration.
+func Parse(src string) (*Config, error) {
+	m, err := parseTOML(src)
+	if err != nil {
+		return nil, err
+	}
+	c := new(Config)
+	if err := decode(reflect.ValueOf(c).Elem(), m, ""); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings that can be checked without binding
+// sockets.
+func (c *Config) Validate() error {
+	for _, l := range c.Listen {
+		if _, _, err := net.SplitHostPort(l.Addr); err != nil {
+			return fmt.Errorf("listen: invalid addr %q", l.Addr)
+		}
+		if (l.TLSCert == "") != (l.TLSKey == "") {
+			return fmt.Errorf("listen %s: tls_cert and tls_key must be given together", l.Addr)
+		}
+		if l.AutoTLS && l.TLSCert == "" {
+			return fmt.Errorf("listen %s: auto_tls needs tls_cert and tls_key", l.Addr)
+		}
+		if err := l.CheckNetwork(); err != nil {
+			return fmt.Errorf("listen %s: %v", l.Addr, err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, m := range c.Mounts {
+		if !strings.HasPrefix(m.Path, "/") || !strings.HasSuffix(m.Path, "/") {
+			return fmt.Errorf("mount: path %q must start and end with /", m.Path)
+		}
+		if slices.Contains(BuiltinRoutes, m.Path) {
+			return fmt.Errorf("mount %s: path is a built-in route", m.Path)
+		}
+		if seen[m.Path] {
+			return fmt.Errorf("mount %s: path is mounted twice", m.Path)
+		}
+		seen[m.Path] = true
+		if fi, err := os.Stat(m.Dir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("mount %s: %q is not a directory", m.Path, m.Dir)
+		}
+	}
+	for _, cp := range c.Cache {
+		if _, err := path.Match(cp.Pattern, ""); err != nil || cp.Pattern == "" {
+			return fmt.Errorf("cache: invalid pattern %q", cp.Pattern)
+		}
+		if strings.TrimSpace(cp.Control) == "" {
+			return fmt.Errorf("cache %s: control must not be empty", cp.Pattern)
+		}
+	}
+	if c.Timeouts.Read < 0 {
+		return errors.New("timeouts: read must not be negative")
+	}
+	if c.Timeouts.Idle < 0 {
+		return errors.New("timeouts: idle must not be negative")
+	}
+	if c.Log.SlowRequest < 0 {
+		return errors.New("log: slow_request must not be negative")
+	}
+	if c.Log.MaxAge < 0 || c.Log.MaxBackups < 0 {
+		return errors.New("log: max_age and max_backups must not be negative")
+	}
+	if c.Log.File == "" && (c.Log.MaxSize > 0 || c.Log.MaxAge > 0 || c.Log.MaxBackups > 0) {
+		return errors.New("log: rotation needs a file")
+	}
+	if c.Log.Level != "" {
+		if _, err := c.Log.SlogLevel(); err != nil {
+			return fmt.Errorf("log: %v", err)
+		}
+	}
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		return fmt.Errorf("log: unknown format %q", c.Log.Format)
+	}
+	if _, err := c.Log.SampleRates(); err != nil {
+		return fmt.Errorf("log: access_sample: %v", err)
+	}
+	return nil
+}
+
+// SampleRates parses AccessSample.
+func (l Log) SampleRates() ([]http.SampleRate, error) {
+	var rates []http.SampleRate
+	for _, s := range l.AccessSample {
+		r, err := http.ParseSampleRate(s)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, r)
+	}
+	return rates, nil
+}
+
+// SlogLevel parses Level, defaulting to info.
+func (l Log) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if l.Level == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(l.Level))
+	return level, err
+}
+
+// Duration is a time.Duration written as a string such as "1m30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	*d = Duration(v)
+	return err
+}
+
+// Size is a byte count written as an integer or a string with a K, M or
+// G suffix, such as "512K".
+type Size int64
+
+func (s *Size) UnmarshalText(b []byte) error {
+	n, err := ParseSize(string(b))
+	*s = Size(n)
+	return err
+}
+
+// ParseSize parses a byte count with an optional K, M or G suffix, in
+// powers of 1024. A trailing B is ignored.
+func ParseSize(v string) (int64, error) {
+	s := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(v)), "B")
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	if n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("size %q is too large", v)
+	}
+	return n * mult, nil
+}
+
+var (
+	durationType        = reflect.TypeFor[Duration]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// decode stores the parsed value v into dst, following toml struct tags.
+// Unknown keys are errors so that typos don't go unnoticed.
+func decode(dst reflect.Value, v any, name string) error {
+	if dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		if s, ok := v.(string); ok {
+			if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			return nil
+		}
+		if dst.Type() == durationType {
+			return fmt.Errorf("%s: duration must be a string such as \"5s\"", name)
+		}
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected a table", name)
+		}
+		fields := map[string]reflect.Value{}
+		for i := 0; i < dst.NumField(); i++ {
+			if tag := dst.Type().Field(i).Tag.Get("toml"); tag != "" {
+				fields[tag] = dst.Field(i)
+			}
+		}
+		for k, fv := range m {
+			f, ok := fields[k]
+			if !ok {
+				return fmt.Errorf("unknown key %q", join(name, k))
+			}
+			if err := decode(f, fv, join(name, k)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		var items []any
+		switch arr := v.(type) {
+		case []any:
+			items = arr
+		case []map[string]any:
+			for _, t := range arr {
+				items = append(items, t)
+			}
+		default:
+			return fmt.Errorf("%s: expected an array", name)
+		}
+		s := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decode(s.Index(i), item, fmt.Sprintf("%s[%d]", name, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(s)
+		return nil
+	case reflect.Pointer:
+		p := reflect.New(dst.Type().Elem())
+		if err := decode(p.Elem(), v, name); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	case reflect.String:
+		if s, ok := v.(string); ok {
+			dst.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := v.(bool); ok {
+			dst.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int64:
+		if n, ok := v.(int64); ok {
+			dst.SetInt(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: expected %s, got %v", name, dst.Kind(), v)
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	src := `
+# three listeners, one IPv6 only and one with TLS
+[[listen]]
+addr = ":4221"
+
+[[listen]]
+addr = "[::1]:4221"
+network = "tcp6"
+v6only = true
+
+[[listen]]
+addr = "127.0.0.1:4443" # comment after a value
+tls_cert = "/etc/cert.pem"
+tls_key = '/etc/key.pem'
+auto_tls = true
+
+[[mount]]
+path = "/static/"
+dir = "` + dir + `"
+quota = "1G"
+
+[[cache]]
+pattern = "fingerprinted"
+control = "public, max-age=31536000, immutable"
+
+[[cache]]
+pattern = "*.html"
+control = "no-cache"
+
+[timeouts]
+read = "1m30s"
+idle = "2m"
+
+[limits]
+max_body_size = "8M"
+require_content_length = true
+files_quota = "512M"
+min_free_space = "2G"
+
+[compression]
+enabled = false
+
+[log]
+level = "debug"
+format = "json"
+slow_request = "500ms"
+access = true
+access_exclude = ["/healthz", "/metrics"]
+access_sample = ["/echo/=0.25"]
+file = "/var/log/http.log"
+max_size = "64M"
+max_age = "24h"
+max_backups = 3
+`
+	c, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Listen) != 3 || c.Listen[2].Addr != "127.0.0.1:4443" || c.Listen[2].TLSKey != "/etc/key.pem" || !c.Listen[2].AutoTLS {
+		t.Errorf("gotListen: %+v", c.Listen)
+	} else if l := c.Listen[1]; l.Network != "tcp6" || !l.V6Only {
+		t.Errorf("gotListen: %+v wantNetwork: tcp6 wantV6Only: true", l)
+	}
+	if len(c.Mounts) != 1 || c.Mounts[0].Path != "/static/" || c.Mounts[0].Dir != dir || c.Mounts[0].Quota != 1<<30 {
+		t.Errorf("gotMounts: %+v", c.Mounts)
+	}
+	if len(c.Cache) != 2 || c.Cache[1].Pattern != "*.html" || c.Cache[1].Control != "no-cache" {
+		t.Errorf("gotCache: %+v", c.Cache)
+	}
+	if time.Duration(c.Timeouts.Read) != 90*time.Second {
+		t.Errorf("gotRead: %s wantRead: 1m30s", time.Duration(c.Timeouts.Read))
+	}
+	if time.Duration(c.Timeouts.Idle) != 2*time.Minute {
+		t.Errorf("gotIdle: %s wantIdle: 2m0s", time.Duration(c.Timeouts.Idle))
+	}
+	if c.Limits.MaxBodySize != 8<<20 {
+		t.Errorf("gotMaxBodySize: %d wantMaxBodySize: %d", c.Limits.MaxBodySize, 8<<20)
+	}
+	if !c.Limits.RequireContentLength {
+		t.Errorf("gotRequireContentLength: false wantRequireContentLength: true")
+	}
+	if c.Limits.FilesQuota != 512<<20 || c.Limits.MinFreeSpace != 2<<30 {
+		t.Errorf("gotFilesQuota: %d gotMinFreeSpace: %d wantFilesQuota: %d wantMinFreeSpace: %d", c.Limits.FilesQuota, c.Limits.MinFreeSpace, 512<<20, 2<<30)
+	}
+	if c.Compression.Enabled == nil || *c.Compression.Enabled {
+		t.Errorf("gotCompression: %v wantCompression: false", c.Compression.Enabled)
+	}
+	if c.Log.Level != "debug" || c.Log.Format != "json" || time.Duration(c.Log.SlowRequest) != 500*time.Millisecond {
+		t.Errorf("gotLog: %+v", c.Log)
+	}
+	if rates, err := c.Log.SampleRates(); !c.Log.Access || len(c.Log.AccessExclude) != 2 || err != nil || len(rates) != 1 || rates[0].Prefix != "/echo/" || rates[0].Rate != 0.25 {
+		t.Errorf("gotLog: %+v gotRates: %+v", c.Log, rates)
+	}
+	if c.Log.File != "/var/log/http.log" || c.Log.MaxSize != 64<<20 || time.Duration(c.Log.MaxAge) != 24*time.Hour || c.Log.MaxBackups != 3 {
+		t.Errorf("gotLog: %+v", c.Log)
+	}
+}
+
+var parseErrorTest = []struct {
+	src, err string
+}{
+	{"[log]\nlevl = \"info\"", `unknown key "log.levl"`},
+	{"[timeouts]\nread = 5", "duration must be a string"},
+	{"[timeouts]\nread = \"soon\"", "timeouts.read"},
+	{"[limits]\nmax_body_size = \"lots\"", "invalid size"},
+	{"[log]\nlevel = \"loud\"", "log:"},
+	{"[log]\nformat = \"xml\"", "unknown format"},
+	{"[log]\naccess_sample = [\"/echo/=2\"]", "rate must be a number from 0 to 1"},
+	{"[log]\naccess_sample = [\"echo\"]", "access_sample"},
+	{"[log]\nmax_backups = 3", "rotation needs a file"},
+	{"[log]\nfile = \"a.log\"\nmax_backups = -1", "must not be negative"},
+	{"[[listen]]\naddr = \"4221\"", "invalid addr"},
+	{"[[listen]]\naddr = \":4443\"\ntls_cert = \"c.pem\"", "given together"},
+	{"[[listen]]\naddr = \":4221\"\nauto_tls = true", "auto_tls needs"},
+	{"[[listen]]\naddr = \":4221\"\nnetwork = \"udp\"", "unknown network"},
+	{"[[listen]]\naddr = \":4221\"\nnetwork = \"tcp4\"\nv6only = true", "needs an IPv6 network"},
+	{"[[mount]]\npath = \"files\"\ndir = \"/\"", "must start and end with /"},
+	{"[[cache]]\npattern = \"[\"\ncontrol = \"no-cache\"", "invalid pattern"},
+	{"[[cache]]\npattern = \"*.css\"", "control must not be empty"},
+	{"[[mount]]\npath = \"/f/\"\ndir = \"/does/not/exist\"", "not a directory"},
+	{"[[mount]]\npath = \"/echo/\"\ndir = \"/\"", "built-in route"},
+	{"[[mount]]\npath = \"/m/\"\ndir = \"/\"\n\n[[mount]]\npath = \"/m/\"\ndir = \"/tmp\"", "mounted twice"},
+	{"[log]\nlevel = \"info\"\nlevel = \"debug\"", "line 3: duplicate key"},
+	{"[log\n", "line 1: unterminated table header"},
+	{"[log]\nlevel = {a = 1}", "inline tables"},
+	{"[compression]\nenabled = \"yes\"", "expected bool"},
+	{"junk", "line 1: expected key = value"},
+}
+
+func TestParseErrors(t *testing.T) {
+	for i, tt := range parseErrorTest {
+		_, err := Parse(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("#%d: gotErr: %v wantErr containing: %q", i, err, tt.err)
+		}
+	}
+}
+
+func TestLoadMountCollision(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "server.toml")
+	src := "[[mount]]\npath = \"/files/\"\ndir = \"" + t.TempDir() + "\"\n"
+	if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(name); err == nil || !strings.Contains(err.Error(), "mount /files/: path is a built-in route") {
+		t.Errorf("gotErr: %v wantErr: mount /files/ collides with a built-in route", err)
+	}
+}
+
+var parseSizeTest = []struct {
+	in   string
+	want int64
+}{
+	{"100", 100},
+	{"512K", 512 << 10},
+	{"8m", 8 << 20},
+	{"1GB", 1 << 30},
+	{"8589934591G", 8589934591 << 30},
+}
+
+func TestParseSize(t *testing.T) {
+	for i, tt := range parseSizeTest {
+		if got, err := ParseSize(tt.in); err != nil || got != tt.want {
+			t.Errorf("#%d: gotSize: %d wantSize: %d err: %v", i, got, tt.want, err)
+		}
+	}
+	if _, err := ParseSize("-1K"); err == nil {
+		t.Errorf("expected error for negative size")
+	}
+	for _, in := range []string{"9999999999G", "8589934592G", "99999999999999999999"} {
+		if got, err := ParseSize(in); err == nil {
+			t.Errorf("%s: gotSize: %d wantErr: overflow", in, got)
+		}
+	}
+}
+
+func TestParseTOMLArrays(t *testing.T) {
+	m, err := parseTOML("list = [\n  \"a\", # first\n  'b',\n  3,\n]\nnested = [[1, 2], []]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, ok := m["list"].([]any)
+	if !ok || len(list) != 3 || list[0] != "a" || list[1] != "b" || list[2] != int64(3) {
+		t.Errorf("gotList: %#v", m["list"])
+	}
+	nested, ok := m["nested"].([]any)
+	if !ok || len(nested) != 2 {
+		t.Errorf("gotNested: %#v", m["nested"])
+	}
+}
@@ -0,0 +1,260 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML that config files need: comments,
+// [tables], [[arrays of tables]], bare and dotted keys, and values that
+// are strings, integers, booleans or arrays of those. Inline tables,
+// floats and dates are rejected.
+func parseTOML(src string) (map[string]any, error) {
+	root := map[string]any{}
+	cur := root
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, lineError(lineNo, "unterminated array table header")
+			}
+			t, err := arrayTable(root, strings.TrimSpace(line[2:len(line)-2]))
+			if err != nil {
+				return nil, lineError(lineNo, "%v", err)
+			}
+			cur = t
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, lineError(lineNo, "unterminated table header")
+			}
+			t, err := table(root, strings.TrimSpace(line[1:len(line)-1]))
+			if err != nil {
+				return nil, lineError(lineNo, "%v", err)
+			}
+			cur = t
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, lineError(lineNo, "expected key = value")
+		}
+		raw = strings.TrimSpace(raw)
+		// arrays may span lines until their brackets balance
+		for strings.HasPrefix(raw, "[") && !balanced(raw) && i+1 < len(lines) {
+			i++
+			raw += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		v, rest, err := parseValue(raw)
+		if err != nil {
+			return nil, lineError(lineNo, "%v", err)
+		}
+		if strings.TrimSpace(rest) != "" {
+			return nil, lineError(lineNo, "unexpected %q after value", rest)
+		}
+
+		path, err := splitKey(strings.TrimSpace(key))
+		if err != nil {
+			return nil, lineError(lineNo, "%v", err)
+		}
+		t, err := descend(cur, path[:len(path)-1])
+		if err != nil {
+			return nil, lineError(lineNo, "%v", err)
+		}
+		last := path[len(path)-1]
+		if _, dup := t[last]; dup {
+			return nil, lineError(lineNo, "duplicate key %q", last)
+		}
+		t[last] = v
+	}
+	return root, nil
+}
+
+func lineError(line int, format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// stripComment drops a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func balanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+func splitKey(key string) ([]string, error) {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.IndexFunc(p, func(r rune) bool {
+			return !(r == '_' || r == '-' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z')
+		}) >= 0 {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		parts[i] = p
+	}
+	return parts, nil
+}
+
+// descend walks path from t, creating tables as needed. A path through
+// an array of tables continues in its last element.
+func descend(t map[string]any, path []string) (map[string]any, error) {
+	for _, k := range path {
+		switch next := t[k].(type) {
+		case nil:
+			m := map[string]any{}
+			t[k] = m
+			t = m
+		case map[string]any:
+			t = next
+		case []map[string]any:
+			t = next[len(next)-1]
+		default:
+			return nil, fmt.Errorf("key %q is not a table", k)
+		}
+	}
+	return t, nil
+}
+
+func table(root map[string]any, name string) (map[string]any, error) {
+	path, err := splitKey(name)
+	if err != nil {
+		return nil, err
+	}
+	return descend(root, path)
+}
+
+func arrayTable(root map[string]any, name string) (map[string]any, error) {
+	path, err := splitKey(name)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := descend(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+	m := map[string]any{}
+	switch arr := parent[last].(type) {
+	case nil:
+		parent[last] = []map[string]any{m}
+	case []map[string]any:
+		parent[last] = append(arr, m)
+	default:
+		return nil, fmt.Errorf("key %q is not an array of tables", last)
+	}
+	return m, nil
+}
+
+// parseValue parses the value at the start of s and returns the rest.
+func parseValue(s string) (any, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+	switch c := s[0]; {
+	case c == '"':
+		end := 1
+		for ; end < len(s) && s[end] != '"'; end++ {
+			if s[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(s) {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		v, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		return v, s[end+1:], nil
+	case c == '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case c == '[':
+		var arr []any
+		rest := strings.TrimSpace(s[1:])
+		for {
+			if strings.HasPrefix(rest, "]") {
+				return arr, rest[1:], nil
+			}
+			v, r, err := parseValue(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			arr = append(arr, v)
+			rest = strings.TrimSpace(r)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+	case c == '{':
+		return nil, "", fmt.Errorf("inline tables are not supported")
+	}
+
+	end := strings.IndexAny(s, ",] \t")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 0, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported value %q", word)
+	}
+	return n, rest, nil
+}
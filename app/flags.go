@@ -0,0 +1,498 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/config"
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// options are the settings of the server binary, merged from the config
+// file, the environment and the command line. Later sources win:
+//
+//	defaults < config file < PORT < HTTP_SERVER_* variables < flags
+//
+// Every flag has a variable named after it, so -read-timeout can also be
+// given as HTTP_SERVER_READ_TIMEOUT and -config as HTTP_SERVER_CONFIG.
+// PORT, as set by hosting platforms that assign one, acts like
+// HTTP_SERVER_PORT.
+//
+// The listener settings -addr, -port, -network, -ipv6-only and the TLS
+// flags don't merge with the config file's listeners: -addr replaces them
+// with one per address, and without it the others change the file's only
+// listener. Given without -addr to a file with several listeners they are
+// an error, rather than dropping all but one.
+type options struct {
+	ConfigPath         string
+	Listeners          []config.Listener
+	Directory          string
+	Mounts             []config.Mount
+	CachePolicies      []http.CachePolicy
+	LogLevel           slog.Level
+	LogFormat          string
+	LogFile            string
+	LogMaxSize         int64
+	LogMaxAge          time.Duration
+	LogMaxBackups      int
+	ReadTimeout        time.Duration
+	IdleTimeout        time.Duration
+	SlowRequest        time.Duration
+	AccessLog          bool
+	AccessExclude      []string
+	AccessSamples      []http.SampleRate
+	MaxBodySize        int64
+	DisableCompression bool
+	RouteMaxFailures   int
+	StrictParsing      bool
+	RequireLength      bool
+	FilesQuota         int64
+	MinFreeSpace       int64
+	Media              bool
+	AdminAddr          string
+	AdminToken         string
+	SigningKey         string
+}
+
+const defaultAddr = ":4221"
+
+// minSigningKeyLen is the shortest -signing-key accepted.
+const minSigningKeyLen = 16
+
+// flagValues holds the raw command line, before it is merged.
+type flagValues struct {
+	config           string
+	addr             string
+	port             int
+	network          string
+	v6Only           bool
+	directory        string
+	logLevel         slog.Level
+	logFile          string
+	logMaxSize       byteSize
+	logMaxAge        time.Duration
+	logMaxBackups    int
+	readTimeout      time.Duration
+	idleTimeout      time.Duration
+	slowRequest      time.Duration
+	accessLog        bool
+	accessExclude    string
+	accessSamples    sampleRateFlag
+	tlsCert          string
+	tlsKey           string
+	autoTLS          bool
+	maxBodySize      byteSize
+	filesQuota       byteSize
+	minFreeSpace     byteSize
+	routeMaxFailures int
+	strictParsing    bool
+	requireLength    bool
+	media            bool
+	adminAddr        string
+	adminToken       string
+	signingKey       string
+}
+
+// envPrefix starts the environment variable for each flag.
+const envPrefix = "HTTP_SERVER_"
+
+// envName returns the environment variable that sets the named flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadOptions parses the command line, the environment as seen through
+// lookupEnv and the config file either of them names into options. Flags
+// may be written with one or two dashes.
+func loadOptions(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*options, error) {
+	var fv flagValues
+	fs := flag.NewFlagSet("http-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&fv.config, "config", "", "configuration `file` (TOML); flags override its settings")
+	fs.StringVar(&fv.addr, "addr", defaultAddr, "`addresses` to listen on, host:port, separated by commas")
+	fs.IntVar(&fv.port, "port", 0, "`port` to listen on, replacing the port of each -addr")
+	fs.StringVar(&fv.network, "network", "tcp", "`network` of the -addr listeners: tcp for both address families, tcp4 or tcp6")
+	fs.BoolVar(&fv.v6Only, "ipv6-only", false, "make IPv6 listeners refuse IPv4-mapped connections (IPV6_V6ONLY)")
+	fs.StringVar(&fv.directory, "directory", "", "`dir` served and written by /files/")
+	fs.TextVar(&fv.logLevel, "log-level", slog.LevelInfo, "log `level`: debug, info, warn or error")
+	fs.StringVar(&fv.logFile, "log-file", "", "write the logs, access log included, to `file` rather than stderr; reopened on SIGUSR1 for logrotate")
+	fs.Var(&fv.logMaxSize, "log-max-size", "rotate the -log-file once it would pass this size, e.g. 100M (default never)")
+	fs.DurationVar(&fv.logMaxAge, "log-max-age", 0, "rotate the -log-file after this `duration`, e.g. 24h, 0 for never")
+	fs.IntVar(&fv.logMaxBackups, "log-max-backups", 0, "rotated log files kept, the oldest removed first, 0 for all")
+	fs.DurationVar(&fv.readTimeout, "read-timeout", 0, "maximum `duration` for reading a request, 0 for none")
+	fs.DurationVar(&fv.idleTimeout, "idle-timeout", 0, "how long a keep-alive connection waits for its next request (`duration`, default -read-timeout)")
+	fs.DurationVar(&fv.slowRequest, "slow-request", 0, "log requests taking at least this `duration` at warn level, 0 for none")
+	fs.BoolVar(&fv.accessLog, "access-log", false, "log every request")
+	fs.StringVar(&fv.accessExclude, "access-log-exclude", "", "comma-separated path `prefixes` left out of the access log, e.g. /healthz,/metrics")
+	fs.Var(&fv.accessSamples, "access-log-sample", "log only a fraction of the requests under a path prefix, as '/prefix=rate', e.g. '/echo/=0.01'; may be repeated, the first match wins")
+	fs.StringVar(&fv.tlsCert, "tls-cert", "", "TLS certificate `file` (PEM), serves HTTPS together with -tls-key")
+	fs.StringVar(&fv.tlsKey, "tls-key", "", "TLS private key `file` (PEM)")
+	fs.BoolVar(&fv.autoTLS, "auto-tls", false, "serve plaintext HTTP too on the -tls-cert listeners, detected per connection")
+	fs.IntVar(&fv.routeMaxFailures, "route-max-failures", 0, "disable a route after this many panics or 5xx responses in a minute, until re-enabled via the admin API, 0 for never")
+	fs.BoolVar(&fv.strictParsing, "strict-parsing", false, "refuse requests with ambiguous framing, such as both Transfer-Encoding and Content-Length, to rule out request smuggling")
+	fs.BoolVar(&fv.requireLength, "require-content-length", false, "answer 411 to POST, PUT and PATCH requests sending neither Content-Length nor a chunked body, rather than treating the body as empty")
+	fs.BoolVar(&fv.media, "media", false, "serve /files/ and mounts for media players: byte ranges, media types, ETag and Last-Modified, no gzip for audio and video")
+	fs.StringVar(&fv.adminAddr, "admin-addr", "", "loopback `address` or unix:path serving the admin API, off when empty")
+	fs.StringVar(&fv.adminToken, "admin-token", "", "bearer `token` the admin API requires; prefer setting "+envName("admin-token"))
+	fs.StringVar(&fv.signingKey, "signing-key", "", "`key` /files/ and mounts require signed URLs for, as made by the sign subcommand, off when empty; prefer setting "+envName("signing-key"))
+	fs.Var(&fv.maxBodySize, "max-body-size", "largest request body accepted, e.g. 512K or 8M (default 1M)")
+	fs.Var(&fv.filesQuota, "files-quota", "total size the files under -directory may reach, e.g. 512M; uploads beyond it get 507 (default none)")
+	fs.Var(&fv.minFreeSpace, "min-free-space", "free disk space uploads must leave, e.g. 1G; uploads eating into it get 507 (default none)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s [flags]\n       %s bench [flags] URL\n       %s static [flags] DIR\n       %s sign [flags] PATH\n\nflags:\n", fs.Name(), fs.Name(), fs.Name(), fs.Name())
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nEach flag can also be set with an environment variable such as %s.\n"+
+			"Flags override the environment, which overrides the -config file. PORT sets -port too.\n", envName("read-timeout"))
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// variables fill in the flags not given, taking the flags' precedence
+	// over the config file
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || envErr != nil {
+			return
+		}
+		v, ok := lookupEnv(envName(f.Name))
+		if !ok || v == "" {
+			return
+		}
+		if err := f.Value.Set(v); err != nil {
+			envErr = fmt.Errorf("invalid %s %q: %v", envName(f.Name), v, err)
+			return
+		}
+		set[f.Name] = true
+	})
+	if envErr != nil {
+		return nil, usageError(fs, "%v", envErr)
+	}
+	if v, ok := lookupEnv("PORT"); ok && v != "" && !set["port"] {
+		if err := fs.Lookup("port").Value.Set(v); err != nil {
+			return nil, usageError(fs, "invalid PORT %q: %v", v, err)
+		}
+		set["port"] = true
+	}
+
+	opts := &options{
+		ConfigPath: fv.config,
+		Listeners:  []config.Listener{{Addr: defaultAddr}},
+		LogLevel:   slog.LevelInfo,
+		LogFormat:  "text",
+	}
+	if fv.config != "" {
+		cfg, err := config.Load(fv.config)
+		if err != nil {
+			return nil, err
+		}
+		if err := opts.applyConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.applyFlags(&fv, set); err != nil {
+		return nil, usageError(fs, "%v", err)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, usageError(fs, "%v", err)
+	}
+	return opts, nil
+}
+
+func (o *options) applyConfig(c *config.Config) error {
+	if len(c.Listen) > 0 {
+		o.Listeners = c.Listen
+	}
+	o.Mounts = c.Mounts
+	o.CachePolicies = nil
+	for _, cp := range c.Cache {
+		o.CachePolicies = append(o.CachePolicies, http.CachePolicy{Pattern: cp.Pattern, CacheControl: http.ParseCacheControl(cp.Control)})
+	}
+	if c.Log.Level != "" {
+		level, err := c.Log.SlogLevel()
+		if err != nil {
+			return err
+		}
+		o.LogLevel = level
+	}
+	if c.Log.Format != "" {
+		o.LogFormat = c.Log.Format
+	}
+	if c.Log.File != "" {
+		o.LogFile = c.Log.File
+	}
+	if c.Log.MaxSize > 0 {
+		o.LogMaxSize = int64(c.Log.MaxSize)
+	}
+	if c.Log.MaxAge > 0 {
+		o.LogMaxAge = time.Duration(c.Log.MaxAge)
+	}
+	if c.Log.MaxBackups > 0 {
+		o.LogMaxBackups = c.Log.MaxBackups
+	}
+	if c.Timeouts.Read > 0 {
+		o.ReadTimeout = time.Duration(c.Timeouts.Read)
+	}
+	if c.Timeouts.Idle > 0 {
+		o.IdleTimeout = time.Duration(c.Timeouts.Idle)
+	}
+	if c.Log.SlowRequest > 0 {
+		o.SlowRequest = time.Duration(c.Log.SlowRequest)
+	}
+	o.AccessLog = c.Log.Access
+	o.AccessExclude = c.Log.AccessExclude
+	samples, err := c.Log.SampleRates()
+	if err != nil {
+		return err
+	}
+	o.AccessSamples = samples
+	if c.Limits.MaxBodySize > 0 {
+		o.MaxBodySize = int64(c.Limits.MaxBodySize)
+	}
+	if c.Limits.RequireContentLength {
+		o.RequireLength = true
+	}
+	if c.Limits.FilesQuota > 0 {
+		o.FilesQuota = int64(c.Limits.FilesQuota)
+	}
+	if c.Limits.MinFreeSpace > 0 {
+		o.MinFreeSpace = int64(c.Limits.MinFreeSpace)
+	}
+	if c.Compression.Enabled != nil {
+		o.DisableCompression = !*c.Compression.Enabled
+	}
+	return nil
+}
+
+// listenerFlags are the flags that configure listeners, see options.
+var listenerFlags = []string{"addr", "port", "network", "ipv6-only", "tls-cert", "tls-key", "auto-tls"}
+
+// applyFlags overrides o with the flags given on the command line. Any of
+// the listenerFlags replaces the file's listeners with one per -addr
+// address, based on the file's listener when it has only one.
+func (o *options) applyFlags(fv *flagValues, set map[string]bool) error {
+	var given []string
+	for _, name := range listenerFlags {
+		if set[name] {
+			given = append(given, "-"+name)
+		}
+	}
+	if len(given) > 0 {
+		if !set["addr"] && len(o.Listeners) > 1 {
+			return fmt.Errorf("%s would replace the config file's %d listeners with one; give -addr as well, or set it in the file", strings.Join(given, ", "), len(o.Listeners))
+		}
+		l := o.Listeners[0]
+		addrs := []string{l.Addr}
+		if set["addr"] {
+			addrs = strings.Split(fv.addr, ",")
+		}
+		if set["network"] {
+			l.Network = fv.network
+		}
+		if set["ipv6-only"] {
+			l.V6Only = fv.v6Only
+		}
+		if set["tls-cert"] || set["tls-key"] {
+			l.TLSCert, l.TLSKey = fv.tlsCert, fv.tlsKey
+		}
+		if set["auto-tls"] {
+			l.AutoTLS = fv.autoTLS
+		}
+		o.Listeners = nil
+		for _, addr := range addrs {
+			l.Addr = strings.TrimSpace(addr)
+			if set["port"] {
+				host, _, err := net.SplitHostPort(l.Addr)
+				if err != nil {
+					return fmt.Errorf("invalid address %q: %v", l.Addr, err)
+				}
+				l.Addr = net.JoinHostPort(host, strconv.Itoa(fv.port))
+			}
+			o.Listeners = append(o.Listeners, l)
+		}
+	}
+	if set["directory"] {
+		o.Directory = fv.directory
+	}
+	if set["log-level"] {
+		o.LogLevel = fv.logLevel
+	}
+	if set["log-file"] {
+		o.LogFile = fv.logFile
+	}
+	if set["log-max-size"] {
+		o.LogMaxSize = int64(fv.logMaxSize)
+	}
+	if set["log-max-age"] {
+		o.LogMaxAge = fv.logMaxAge
+	}
+	if set["log-max-backups"] {
+		o.LogMaxBackups = fv.logMaxBackups
+	}
+	if set["read-timeout"] {
+		o.ReadTimeout = fv.readTimeout
+	}
+	if set["idle-timeout"] {
+		o.IdleTimeout = fv.idleTimeout
+	}
+	if set["slow-request"] {
+		o.SlowRequest = fv.slowRequest
+	}
+	if set["access-log"] {
+		o.AccessLog = fv.accessLog
+	}
+	if set["access-log-exclude"] {
+		o.AccessExclude = splitList(fv.accessExclude)
+	}
+	if set["access-log-sample"] {
+		o.AccessSamples = fv.accessSamples
+	}
+	if set["max-body-size"] {
+		o.MaxBodySize = int64(fv.maxBodySize)
+	}
+	if set["route-max-failures"] {
+		o.RouteMaxFailures = fv.routeMaxFailures
+	}
+	if set["strict-parsing"] {
+		o.StrictParsing = fv.strictParsing
+	}
+	if set["files-quota"] {
+		o.FilesQuota = int64(fv.filesQuota)
+	}
+	if set["min-free-space"] {
+		o.MinFreeSpace = int64(fv.minFreeSpace)
+	}
+	if set["require-content-length"] {
+		o.RequireLength = fv.requireLength
+	}
+	if set["media"] {
+		o.Media = fv.media
+	}
+	if set["admin-addr"] {
+		o.AdminAddr = fv.adminAddr
+	}
+	if set["admin-token"] {
+		o.AdminToken = fv.adminToken
+	}
+	if set["signing-key"] {
+		o.SigningKey = fv.signingKey
+	}
+	return nil
+}
+
+func (o *options) validate() error {
+	for _, l := range o.Listeners {
+		_, p, err := net.SplitHostPort(l.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid address %q: %v", l.Addr, err)
+		}
+		if n, err := strconv.Atoi(p); err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("invalid port %q", p)
+		}
+		if (l.TLSCert == "") != (l.TLSKey == "") {
+			return fmt.Errorf("a TLS certificate and key must be given together")
+		}
+		if l.AutoTLS && l.TLSCert == "" {
+			return fmt.Errorf("listener %s: -auto-tls needs a TLS certificate and key", l.Addr)
+		}
+		if err := l.CheckNetwork(); err != nil {
+			return fmt.Errorf("listener %s: %v", l.Addr, err)
+		}
+	}
+	if o.Directory != "" {
+		dir, err := filepath.Abs(o.Directory)
+		if err != nil {
+			return fmt.Errorf("invalid directory: %v", err)
+		}
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return fmt.Errorf("directory %q is not a directory", o.Directory)
+		}
+		o.Directory = dir
+	}
+	if o.ReadTimeout < 0 {
+		return fmt.Errorf("read timeout must not be negative")
+	}
+	if o.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout must not be negative")
+	}
+	if o.SlowRequest < 0 {
+		return fmt.Errorf("slow request threshold must not be negative")
+	}
+	if o.LogMaxAge < 0 {
+		return fmt.Errorf("log max age must not be negative")
+	}
+	if o.LogMaxBackups < 0 {
+		return fmt.Errorf("log max backups must not be negative")
+	}
+	if o.LogFile == "" && (o.LogMaxSize > 0 || o.LogMaxAge > 0 || o.LogMaxBackups > 0) {
+		return fmt.Errorf("log rotation needs a log file")
+	}
+	if o.RouteMaxFailures < 0 {
+		return fmt.Errorf("route max failures must not be negative")
+	}
+	if o.AdminAddr != "" && o.AdminToken == "" {
+		return fmt.Errorf("the admin API needs a token, set %s", envName("admin-token"))
+	}
+	if o.SigningKey != "" && len(o.SigningKey) < minSigningKeyLen {
+		return fmt.Errorf("the signing key must be at least %d bytes", minSigningKeyLen)
+	}
+	return nil
+}
+
+func usageError(fs *flag.FlagSet, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	fmt.Fprintln(fs.Output(), err)
+	fs.Usage()
+	return err
+}
+
+// splitList splits a comma-separated flag value, dropping empty elements.
+func splitList(v string) []string {
+	var items []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sampleRateFlag collects -access-log-sample values.
+type sampleRateFlag []http.SampleRate
+
+func (f *sampleRateFlag) String() string { return "" }
+
+func (f *sampleRateFlag) Set(v string) error {
+	r, err := http.ParseSampleRate(v)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+// byteSize is a flag value of bytes with an optional K, M or G suffix.
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(v string) error {
+	n, err := config.ParseSize(v)
+	*b = byteSize(n)
+	return err
+}
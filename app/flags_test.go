@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const twoListeners = `
+[[listen]]
+addr = ":4221"
+
+[[listen]]
+addr = ":4443"
+tls_cert = "cert.pem"
+tls_key = "key.pem"
+`
+
+var listenerFlagsTest = []struct {
+	file  string
+	args  []string
+	env   map[string]string
+	addrs string // the listeners' addresses, TLS ones marked with +tls
+	err   string
+}{
+	{twoListeners, nil, nil, ":4221 :4443+tls", ""},
+	{twoListeners, nil, map[string]string{"PORT": "8080"}, "", "give -addr as well"},
+	{twoListeners, []string{"-network", "tcp4"}, nil, "", "-network would replace"},
+	{twoListeners, []string{"-addr", ":80"}, map[string]string{"PORT": "8080"}, ":8080", ""},
+	{"[[listen]]\naddr = \":4443\"\ntls_cert = \"cert.pem\"\ntls_key = \"key.pem\"\n", nil, map[string]string{"PORT": "8443"}, ":8443+tls", ""},
+	{"", nil, map[string]string{"PORT": "8080"}, ":8080", ""},
+}
+
+func TestListenerFlags(t *testing.T) {
+	for i, tt := range listenerFlagsTest {
+		args := tt.args
+		if tt.file != "" {
+			name := filepath.Join(t.TempDir(), "server.toml")
+			if err := os.WriteFile(name, []byte(tt.file), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			args = append([]string{"-config", name}, args...)
+		}
+		lookupEnv := func(key string) (string, bool) {
+			v, ok := tt.env[key]
+			return v, ok
+		}
+		opts, err := loadOptions(args, lookupEnv, io.Discard)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("#%d: gotErr: %v wantErr containing: %q", i, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		var addrs []string
+		for _, l := range opts.Listeners {
+			if l.TLSCert != "" {
+				l.Addr += "+tls"
+			}
+			addrs = append(addrs, l.Addr)
+		}
+		if got := strings.Join(addrs, " "); got != tt.addrs {
+			t.Errorf("#%d: gotListeners: %q wantListeners: %q", i, got, tt.addrs)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAbuseWindow = time.Minute
+	defaultBanDuration = 10 * time.Minute
+
+	// maxTarpitted caps the connections held open at once, so a flood
+	// from banned clients can't exhaust file descriptors; past it they
+	// are closed straight away.
+	maxTarpitted = 64
+
+	// maxAbuseClients is the number of tracked clients past which stale
+	// records are swept.
+	maxAbuseClients = 4096
+)
+
+// AbuseGuard temporarily bans clients, by IP, that keep sending requests
+// the server can't parse or has to reject with a 4xx, as vulnerability
+// scanners do. Connections from a banned IP are dropped as soon as they
+// are accepted, before anything is read, or tarpitted. Set it as
+// Server.Abuse; a zero threshold turns that kind of counting off.
+type AbuseGuard struct {
+	// MaxParseErrors is how many unparseable requests an IP may send
+	// within Window before it is banned.
+	MaxParseErrors int
+
+	// MaxClientErrors is how many 4xx responses an IP may get within
+	// Window before it is banned.
+	MaxClientErrors int
+
+	// Window is the period errors are counted over. One minute is used
+	// when zero.
+	Window time.Duration
+
+	// BanDuration is how long a ban lasts. Ten minutes is used when zero.
+	BanDuration time.Duration
+
+	// Tarpit, if positive, holds connections from a banned IP open this
+	// long, without reading or answering, before closing them, so a
+	// scanner wastes its time rather than moving on at once.
+	Tarpit time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*abuseRecord
+	tarpitted int
+}
+
+type abuseRecord struct {
+	windowStart  time.Time
+	parseErrors  int
+	clientErrors int
+	bannedUntil  time.Time
+}
+
+// Banned reports whether ip is currently banned.
+func (g *AbuseGuard) Banned(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	rec, ok := g.clients[ip]
+	return ok && time.Now().Before(rec.bannedUntil)
+}
+
+// admit reports whether a freshly accepted conn may be served. A conn from
+// a banned IP is closed, or tarpitted and closed later.
+func (g *AbuseGuard) admit(conn net.Conn) bool {
+	if !g.Banned(remoteIP(conn.RemoteAddr())) {
+		return true
+	}
+	g.mu.Lock()
+	tarpit := g.Tarpit > 0 && g.tarpitted < maxTarpitted
+	if tarpit {
+		g.tarpitted++
+	}
+	g.mu.Unlock()
+	if !tarpit {
+		conn.Close()
+		return false
+	}
+	time.AfterFunc(g.Tarpit, func() {
+		conn.Close()
+		g.mu.Lock()
+		g.tarpitted--
+		g.mu.Unlock()
+	})
+	return false
+}
+
+// recordParseError counts a request from ip that couldn't be parsed. It
+// reports whether this banned ip.
+func (g *AbuseGuard) recordParseError(ip string) bool {
+	if g.MaxParseErrors <= 0 {
+		return false
+	}
+	return g.record(ip, func(rec *abuseRecord) bool {
+		rec.parseErrors++
+		return rec.parseErrors >= g.MaxParseErrors
+	})
+}
+
+// recordStatus counts a response sent to ip if it is a 4xx. It reports
+// whether this banned ip.
+func (g *AbuseGuard) recordStatus(ip string, code int) bool {
+	if g.MaxClientErrors <= 0 || code < 400 || code > 499 {
+		return false
+	}
+	return g.record(ip, func(rec *abuseRecord) bool {
+		rec.clientErrors++
+		return rec.clientErrors >= g.MaxClientErrors
+	})
+}
+
+// record applies count to ip's record for the current window and bans ip
+// when it returns true.
+func (g *AbuseGuard) record(ip string, count func(*abuseRecord) bool) bool {
+	now := time.Now()
+	window := g.Window
+	if window <= 0 {
+		window = defaultAbuseWindow
+	}
+	ban := g.BanDuration
+	if ban <= 0 {
+		ban = defaultBanDuration
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.clients == nil {
+		g.clients = make(map[string]*abuseRecord)
+	}
+	rec, ok := g.clients[ip]
+	if !ok {
+		if len(g.clients) >= maxAbuseClients {
+			g.sweep(now, window)
+		}
+		rec = &abuseRecord{windowStart: now}
+		g.clients[ip] = rec
+	}
+	if now.Before(rec.bannedUntil) {
+		return false
+	}
+	if now.Sub(rec.windowStart) > window {
+		*rec = abuseRecord{windowStart: now}
+	}
+	if !count(rec) {
+		return false
+	}
+	*rec = abuseRecord{windowStart: now, bannedUntil: now.Add(ban)}
+	return true
+}
+
+// sweep drops records that are neither banned nor within their window.
+func (g *AbuseGuard) sweep(now time.Time, window time.Duration) {
+	for ip, rec := range g.clients {
+		if now.After(rec.bannedUntil) && now.Sub(rec.windowStart) > window {
+			delete(g.clients, ip)
+		}
+	}
+}
+
+// remoteIP returns the host part of addr, or the whole address if it has
+// no port.
+func remoteIP(addr net.Addr) string {
+	s := addr.String()
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return s
+}
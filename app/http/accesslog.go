@@ -0,0 +1,94 @@
+package http
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// AccessLog logs a line per finished request, for Server.OnRequestEnd:
+//
+//	al := &http.AccessLog{Logger: logger, Exclude: []string{"/healthz", "/metrics"}}
+//	server.OnRequestEnd = al.Log
+//
+// Filters and sampling keep the log readable under load: health checks
+// can be left out, and high-volume routes logged only in part. Requests
+// answered with 5xx, or whose response couldn't be written, are always
+// logged unless excluded, so sampling never hides a failure.
+type AccessLog struct {
+	// Logger receives the lines at info level. slog.Default() is used
+	// when nil.
+	Logger *slog.Logger
+
+	// Include, if set, lists the path prefixes logged; others are not.
+	Include []string
+
+	// Exclude lists path prefixes never logged, such as "/healthz". It
+	// applies after Include.
+	Exclude []string
+
+	// Samples sets the fraction of requests logged by path prefix; the
+	// first match applies, and unmatched requests are all logged. Sampled
+	// lines carry a "sample" attribute with the rate, so counts can be
+	// scaled back up.
+	Samples []SampleRate
+}
+
+// SampleRate logs the fraction Rate, from 0 to 1, of the requests whose
+// path starts with Prefix.
+type SampleRate struct {
+	Prefix string
+	Rate   float64
+}
+
+// ParseSampleRate parses a sample rate written "prefix=rate", as in
+// "/echo/=0.01".
+func ParseSampleRate(s string) (SampleRate, error) {
+	prefix, rate, ok := strings.Cut(s, "=")
+	if !ok || !strings.HasPrefix(prefix, "/") {
+		return SampleRate{}, fmt.Errorf("sample rate %q is not of the form '/prefix=rate'", s)
+	}
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 || r > 1 {
+		return SampleRate{}, fmt.Errorf("sample rate %q: rate must be a number from 0 to 1", s)
+	}
+	return SampleRate{Prefix: prefix, Rate: r}, nil
+}
+
+// Log logs r as it finished, unless filtered out or left out of the
+// sample.
+func (a *AccessLog) Log(r *Request, end RequestEnd) {
+	p := r.Path
+	if r.URL != nil {
+		p = r.URL.Path
+	}
+	if len(a.Include) > 0 && !hasPathPrefix(p, a.Include) || hasPathPrefix(p, a.Exclude) {
+		return
+	}
+	attrs := []any{"method", r.Method, "path", r.Path, "route", end.Route, "status", end.StatusCode,
+		"bytes", end.BytesWritten, "duration", end.Latency, "remote", r.RemoteAddr}
+	if failed := end.StatusCode >= 500 || end.Err != nil; !failed {
+		for _, s := range a.Samples {
+			if !strings.HasPrefix(p, s.Prefix) {
+				continue
+			}
+			if rand.Float64() >= s.Rate {
+				return
+			}
+			attrs = append(attrs, "sample", s.Rate)
+			break
+		}
+	}
+	loggerOrDefault(a.Logger).Info("request", attrs...)
+}
+
+func hasPathPrefix(p string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
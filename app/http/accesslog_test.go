@@ -0,0 +1,53 @@
+package http_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+func TestAccessLog(t *testing.T) {
+	var logs strings.Builder
+	al := &http.AccessLog{
+		Logger:  slog.New(slog.NewTextHandler(&logs, nil)),
+		Include: []string{"/api/", "/healthz"},
+		Exclude: []string{"/healthz"},
+		Samples: []http.SampleRate{{Prefix: "/api/hot", Rate: 0}, {Prefix: "/api/warm", Rate: 1}},
+	}
+	tests := []struct {
+		path   string
+		status int
+		logged bool
+	}{
+		{"/api/users", 200, true},
+		{"/healthz", 200, false},
+		{"/static/app.js", 200, false},
+		{"/api/hot/1", 200, false},
+		{"/api/hot/2", 503, true},
+		{"/api/warm/1?q=1", 200, true},
+	}
+	for i, tt := range tests {
+		logs.Reset()
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		al.Log(req, http.RequestEnd{StatusCode: tt.status})
+		if got := strings.Contains(logs.String(), tt.path); got != tt.logged {
+			t.Errorf("#%d: %s gotLogged: %v wantLogged: %v (%q)", i, tt.path, got, tt.logged, logs.String())
+		}
+	}
+	if !strings.Contains(logs.String(), "sample=1") {
+		t.Errorf("sampled line without its rate: %q", logs.String())
+	}
+
+	for _, s := range []string{"/a=0.5", "/=1", "/b=0"} {
+		if _, err := http.ParseSampleRate(s); err != nil {
+			t.Errorf("%s: gotErr: %v", s, err)
+		}
+	}
+	for _, s := range []string{"a=0.5", "/a", "/a=x", "/a=1.5", "/a=-1"} {
+		if _, err := http.ParseSampleRate(s); err == nil {
+			t.Errorf("%s: gotErr: nil", s)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	stdhttp "net/http"
+	"strconv"
+)
+
+// FromStdHandler adapts a net/http Handler, such as middleware or a
+// handler from a third-party library, to run inside this server.
+//
+// The net/http handler writes into a buffer; the buffered response is
+// handed to w once the handler returns.
+func FromStdHandler(h stdhttp.Handler) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		sr, err := toStdRequest(r)
+		if err != nil {
+			w.SetStatus(StatusBadRequest, StatusText(StatusBadRequest))
+			w.SetBody([]byte(StatusText(StatusBadRequest)))
+			w.Write()
+			return
+		}
+		sw := &stdResponseWriter{header: make(stdhttp.Header)}
+		h.ServeHTTP(sw, sr)
+
+		for key, values := range sw.header {
+			if key == "Content-Length" {
+				continue
+			}
+			v, _ := relayHeaderValue(key, values)
+			w.SetHeader(key, v)
+		}
+		code := sw.code
+		if code == 0 {
+			code = StatusOK
+		}
+		w.SetStatus(code, StatusText(code))
+		w.SetBody(sw.body.Bytes())
+		w.Write()
+	})
+}
+
+func toStdRequest(r *Request) (*stdhttp.Request, error) {
+	body, _ := r.GetBody()
+	sr, err := stdhttp.NewRequestWithContext(r.Context(), r.Method, r.Path, body)
+	if err != nil {
+		return nil, err
+	}
+	sr.ContentLength = int64(len(r.Body))
+	sr.GetBody = r.GetBody
+	sr.Proto = r.Proto
+	sr.ProtoMajor, sr.ProtoMinor, _ = stdhttp.ParseHTTPVersion(r.Proto)
+	sr.Header = stdhttp.Header(r.Header.Clone())
+	sr.Host = r.Header.Get("Host")
+	sr.Header.Del("Host")
+	sr.RemoteAddr = r.RemoteAddr
+	sr.TLS = r.TLS
+	sr.RequestURI = r.Path
+	sr.Pattern = r.Pattern
+	return sr, nil
+}
+
+// stdResponseWriter collects what a net/http handler writes.
+type stdResponseWriter struct {
+	header stdhttp.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (sw *stdResponseWriter) Header() stdhttp.Header { return sw.header }
+
+func (sw *stdResponseWriter) WriteHeader(code int) {
+	if sw.code == 0 {
+		sw.code = code
+	}
+}
+
+func (sw *stdResponseWriter) Write(p []byte) (int, error) {
+	sw.WriteHeader(StatusOK)
+	return sw.body.Write(p)
+}
+
+// ToStdHandler adapts h to a net/http Handler, so handlers written for
+// this package can be mounted on a net/http server or wrapped by its
+// middleware. Request bodies are read up to MAX_BODY_SIZE; larger bodies
+// are answered with 413 without calling h.
+func ToStdHandler(h Handler) stdhttp.Handler {
+	return stdhttp.HandlerFunc(func(sw stdhttp.ResponseWriter, sr *stdhttp.Request) {
+		body, err := io.ReadAll(io.LimitReader(sr.Body, MAX_BODY_SIZE+1))
+		if err != nil {
+			stdhttp.Error(sw, StatusText(StatusBadRequest), StatusBadRequest)
+			return
+		}
+		if len(body) > MAX_BODY_SIZE {
+			stdhttp.Error(sw, StatusText(StatusRequestEntityTooLarge), StatusRequestEntityTooLarge)
+			return
+		}
+
+		header := Header(sr.Header.Clone())
+		header.Set("Host", sr.Host)
+		r := &Request{
+			Method:     sr.Method,
+			Path:       sr.URL.RequestURI(),
+			Proto:      sr.Proto,
+			Header:     header,
+			Body:       body,
+			URL:        sr.URL,
+			RemoteAddr: sr.RemoteAddr,
+			ctx:        sr.Context(),
+		}
+		h.ServeHTTP(&toStdWriter{w: sw, code: StatusOK, headers: make(map[string]string)}, r)
+	})
+}
+
+// toStdWriter implements ResponseWriter on top of a net/http one. Framing
+// and compression are left to the net/http server.
+type toStdWriter struct {
+	w       stdhttp.ResponseWriter
+	code    int
+	headers map[string]string
+	body    []byte
+	written bool
+}
+
+func (tw *toStdWriter) SetStatus(code int, text string) { tw.code = code }
+func (tw *toStdWriter) SetHeader(key, value string)     { tw.headers[key] = value }
+func (tw *toStdWriter) SetBody(body []byte)             { tw.body = body }
+func (tw *toStdWriter) GetBody() []byte                 { return tw.body }
+func (tw *toStdWriter) GetHeader(key string) string     { return tw.headers[key] }
+
+func (tw *toStdWriter) Write() error {
+	if tw.written {
+		return nil
+	}
+	tw.written = true
+	h := tw.w.Header()
+	for key, value := range tw.headers {
+		h.Set(key, value)
+	}
+	h.Set("Content-Length", strconv.Itoa(len(tw.body)))
+	tw.w.WriteHeader(tw.code)
+	_, err := tw.w.Write(tw.body)
+	return err
+}
@@ -0,0 +1,156 @@
+package http_test
+
+import (
+	"io"
+	stdhttp "net/http"
+	stdhttptest "net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+var fromStdHandlerTest = []struct {
+	name    string
+	method  string
+	target  string
+	body    string
+	handler stdhttp.HandlerFunc
+	code    int
+	header  map[string]string
+	resBody string
+}{
+	{
+		name:   "request is visible",
+		method: http.MethodPost, target: "/echo?x=1", body: "ping",
+		handler: func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			b, _ := io.ReadAll(r.Body)
+			io.WriteString(w, r.Method+" "+r.URL.Path+" "+r.URL.Query().Get("x")+" "+r.Host+" "+string(b))
+		},
+		code: http.StatusOK, resBody: "POST /echo 1 example.com ping",
+	},
+	{
+		name:   "status and headers",
+		method: http.MethodGet, target: "/",
+		handler: func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Length", "99")
+			w.WriteHeader(stdhttp.StatusTeapot)
+			w.WriteHeader(stdhttp.StatusOK)
+			io.WriteString(w, "tea")
+		},
+		code: http.StatusTeapot, header: map[string]string{"Content-Type": "text/plain", "Content-Length": ""}, resBody: "tea",
+	},
+	{
+		name:   "repeated headers",
+		method: http.MethodGet, target: "/",
+		handler: func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Accept-Language")
+			w.Header().Add("Set-Cookie", "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT")
+			w.Header().Add("Set-Cookie", "b=2")
+		},
+		code: http.StatusOK, header: map[string]string{"Vary": "Accept, Accept-Language", "Set-Cookie": "b=2"},
+	},
+}
+
+func TestFromStdHandler(t *testing.T) {
+	for i, tt := range fromStdHandlerTest {
+		req, _ := http.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		http.FromStdHandler(tt.handler).ServeHTTP(rec, req)
+		if rec.Code != tt.code || string(rec.Body) != tt.resBody || rec.Writes != 1 {
+			t.Errorf("#%d %s: gotCode: %d gotBody: %q gotWrites: %d wantCode: %d wantBody: %q",
+				i, tt.name, rec.Code, rec.Body, rec.Writes, tt.code, tt.resBody)
+		}
+		for k, want := range tt.header {
+			if got := rec.GetHeader(k); got != want {
+				t.Errorf("#%d %s: got %s: %q want: %q", i, tt.name, k, got, want)
+			}
+		}
+	}
+}
+
+var toStdHandlerTest = []struct {
+	name    string
+	method  string
+	target  string
+	body    string
+	handler http.HandlerFunc
+	code    int
+	header  map[string]string
+	resBody string
+}{
+	{
+		name:   "request is visible",
+		method: http.MethodPut, target: "/items/1?v=2", body: "data",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.SetBody([]byte(r.Method + " " + r.Path + " " + r.Header.Get("Host") + " " + string(r.Body)))
+			w.Write()
+		},
+		code: http.StatusOK, header: map[string]string{"Content-Length": "33"}, resBody: "PUT /items/1?v=2 example.com data",
+	},
+	{
+		name:   "status and headers",
+		method: http.MethodGet, target: "/",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.SetStatus(http.StatusCreated, "Created")
+			w.SetHeader("Location", "/items/2")
+			w.Write()
+			w.SetBody([]byte("late"))
+			w.Write()
+		},
+		code: http.StatusCreated, header: map[string]string{"Location": "/items/2", "Content-Length": "0"},
+	},
+	{
+		name:   "body too large",
+		method: http.MethodPost, target: "/", body: strings.Repeat("x", http.MAX_BODY_SIZE+1),
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.SetBody([]byte("handler ran"))
+			w.Write()
+		},
+		code: http.StatusRequestEntityTooLarge, resBody: "Request Entity Too Large\n",
+	},
+}
+
+func TestToStdHandler(t *testing.T) {
+	for i, tt := range toStdHandlerTest {
+		req := stdhttptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+		rec := stdhttptest.NewRecorder()
+		http.ToStdHandler(tt.handler).ServeHTTP(rec, req)
+		if rec.Code != tt.code || rec.Body.String() != tt.resBody {
+			t.Errorf("#%d %s: gotCode: %d gotBody: %q wantCode: %d wantBody: %q",
+				i, tt.name, rec.Code, rec.Body, tt.code, tt.resBody)
+		}
+		for k, want := range tt.header {
+			if got := rec.Header().Get(k); got != want {
+				t.Errorf("#%d %s: got %s: %q want: %q", i, tt.name, k, got, want)
+			}
+		}
+	}
+}
+
+func TestStdHandlerRoundTrip(t *testing.T) {
+	// a handler of this package, wrapped by net/http middleware and
+	// mounted back, behaves as if served directly
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetHeader("X-Inner", r.Header.Get("X-Outer"))
+		w.SetBody([]byte("inner"))
+		w.Write()
+	})
+	outer := func(next stdhttp.Handler) stdhttp.Handler {
+		return stdhttp.HandlerFunc(func(w stdhttp.ResponseWriter, r *stdhttp.Request) {
+			r.Header.Set("X-Outer", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := http.FromStdHandler(outer(http.ToStdHandler(inner)))
+
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || string(rec.Body) != "inner" || rec.GetHeader("X-Inner") != "yes" {
+		t.Errorf("gotCode: %d gotBody: %q gotX-Inner: %q", rec.Code, rec.Body, rec.GetHeader("X-Inner"))
+	}
+}
@@ -0,0 +1,273 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"runtime/pprof"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Admin serves runtime controls for a running Server, for operators to
+// tune it without a restart:
+//
+//	GET  /admin/stats       the server's Stats
+//	GET  /admin/loglevel    the current log level
+//	PUT  /admin/loglevel    set it from the body, e.g. "debug"
+//	GET  /admin/dump        whether wire dumping is on
+//	PUT  /admin/dump        turn it "on" or "off"
+//	GET  /admin/listeners   the listeners still accepting connections
+//	POST /admin/drain       stop accepting on the listener whose address is the body
+//	GET  /admin/goroutines  every goroutine's stack
+//	GET  /admin/routes      per-route health from Bulkhead
+//	POST /admin/enable      put the route named by the body back in service
+//
+// Every request must carry "Authorization: Bearer " followed by Token.
+// Serve it only on a private listener; see ListenAndServeAdmin.
+type Admin struct {
+	Server *Server
+
+	// Token authenticates requests. When empty every request is refused.
+	Token string
+
+	// LogLevel, if set, is the level /admin/loglevel reads and changes.
+	LogLevel *slog.LevelVar
+
+	// DumpTo is where wire dumps go once turned on. /admin/dump is
+	// unavailable when it is nil.
+	DumpTo io.Writer
+
+	// Bulkhead, if set, is the route guard /admin/routes reports on and
+	// /admin/enable re-enables routes of.
+	Bulkhead *Bulkhead
+}
+
+// ListenAndServeAdmin serves a's endpoints on addr, which is either
+// "unix:" followed by a socket path or a TCP address resolving to
+// loopback, so the controls are never exposed publicly by accident.
+func ListenAndServeAdmin(addr string, a *Admin) error {
+	if a.Token == "" {
+		return errors.New("http: admin listener needs a token")
+	}
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+	} else {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return err
+		}
+		if host != "localhost" {
+			if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+				return fmt.Errorf("http: admin listener must bind a loopback address or unix socket, got %q", addr)
+			}
+		}
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	s := &Server{Handler: a.Handler(), Logger: a.Server.loggerIfSet()}
+	return s.Serve(ln)
+}
+
+// Handler returns the handler serving a's endpoints.
+func (a *Admin) Handler() Handler {
+	mux := NewServeMux()
+	mux.Logger = a.Server.loggerIfSet()
+	mux.HandleFunc("/admin/stats", a.stats)
+	mux.HandleFunc("/admin/loglevel", a.logLevel)
+	mux.HandleFunc("/admin/dump", a.dump)
+	mux.HandleFunc("/admin/listeners", a.listeners)
+	mux.HandleFunc("/admin/drain", a.drain)
+	mux.HandleFunc("/admin/goroutines", a.goroutines)
+	mux.HandleFunc("/admin/routes", a.routes)
+	mux.HandleFunc("/admin/enable", a.enable)
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		if !a.authorized(r) {
+			w.SetHeader("WWW-Authenticate", `Bearer realm="admin"`)
+			adminReply(w, StatusUnauthorized, StatusText(StatusUnauthorized))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (a *Admin) authorized(r *Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && a.Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) == 1
+}
+
+func (a *Admin) stats(w ResponseWriter, r *Request) {
+	if !adminMethod(w, r, MethodGet) {
+		return
+	}
+	st := a.Server.Stats()
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "open conns\t%d\n", st.OpenConns)
+	fmt.Fprintf(tw, "idle conns\t%d\n", st.IdleConns)
+	fmt.Fprintf(tw, "idle reaped\t%d\n", st.IdleReaped)
+	fmt.Fprintf(tw, "active handlers\t%d\n", st.ActiveHandlers)
+	fmt.Fprintf(tw, "requests\t%d\n", st.Requests)
+	fmt.Fprintf(tw, "parse errors\t%d\n", st.ParseErrors)
+	fmt.Fprintf(tw, "client aborts\t%d\n", st.ClientAborts)
+	fmt.Fprintf(tw, "bytes read\t%d\n", st.BytesRead)
+	fmt.Fprintf(tw, "bytes written\t%d\n", st.BytesWritten)
+	if st.TLSDetected+st.PlainDetected > 0 {
+		fmt.Fprintf(tw, "tls detected\t%d\n", st.TLSDetected)
+		fmt.Fprintf(tw, "plain detected\t%d\n", st.PlainDetected)
+	}
+	fmt.Fprintf(tw, "draining\t%t\n", st.Draining)
+	for _, f := range st.InFlight {
+		fmt.Fprintf(tw, "in flight\t%s %s route=%s age=%s\n", f.Method, f.Path, f.Route, f.Age.Round(time.Millisecond))
+	}
+	tw.Flush()
+	WriteTextOrJSON(w, r, StatusOK, b.String(), st)
+}
+
+func (a *Admin) logLevel(w ResponseWriter, r *Request) {
+	if a.LogLevel == nil {
+		adminReply(w, StatusNotFound, "log level is not adjustable")
+		return
+	}
+	if !adminMethod(w, r, MethodGet, MethodPut) {
+		return
+	}
+	if r.Method == MethodPut {
+		var level slog.Level
+		if err := level.UnmarshalText(bytes.TrimSpace(r.Body)); err != nil {
+			adminReply(w, StatusBadRequest, err.Error())
+			return
+		}
+		old := a.LogLevel.Level()
+		a.LogLevel.Set(level)
+		a.Server.logger().Info("log level changed", "from", old, "to", level)
+	}
+	adminReply(w, StatusOK, a.LogLevel.Level().String())
+}
+
+func (a *Admin) dump(w ResponseWriter, r *Request) {
+	if a.DumpTo == nil {
+		adminReply(w, StatusNotFound, "wire dumping is not configured")
+		return
+	}
+	if !adminMethod(w, r, MethodGet, MethodPut) {
+		return
+	}
+	if r.Method == MethodPut {
+		switch string(bytes.TrimSpace(r.Body)) {
+		case "on":
+			a.Server.SetDump(&WireDump{W: a.DumpTo})
+		case "off":
+			a.Server.SetDump(nil)
+		default:
+			adminReply(w, StatusBadRequest, `body must be "on" or "off"`)
+			return
+		}
+		a.Server.logger().Info("wire dump toggled", "state", string(bytes.TrimSpace(r.Body)))
+	}
+	state := "off"
+	if a.Server.wireDump() != nil {
+		state = "on"
+	}
+	adminReply(w, StatusOK, state)
+}
+
+func (a *Admin) listeners(w ResponseWriter, r *Request) {
+	if !adminMethod(w, r, MethodGet) {
+		return
+	}
+	addrs := a.Server.ListenerAddrs()
+	WriteTextOrJSON(w, r, StatusOK, strings.Join(addrs, "\n"), addrs)
+}
+
+func (a *Admin) drain(w ResponseWriter, r *Request) {
+	if !adminMethod(w, r, MethodPost) {
+		return
+	}
+	addr := string(bytes.TrimSpace(r.Body))
+	if err := a.Server.DrainListener(addr); err != nil {
+		adminReply(w, StatusNotFound, err.Error())
+		return
+	}
+	a.Server.logger().Info("listener drained", "addr", addr)
+	adminReply(w, StatusOK, "drained "+addr)
+}
+
+func (a *Admin) goroutines(w ResponseWriter, r *Request) {
+	if !adminMethod(w, r, MethodGet) {
+		return
+	}
+	var b bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&b, 2)
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "text/plain")
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+func (a *Admin) routes(w ResponseWriter, r *Request) {
+	if a.Bulkhead == nil {
+		adminReply(w, StatusNotFound, "no bulkhead is configured")
+		return
+	}
+	if !adminMethod(w, r, MethodGet) {
+		return
+	}
+	routes := a.Bulkhead.Routes()
+	var b bytes.Buffer
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "route\trequests\tpanics\terrors\tstate")
+	for _, rt := range routes {
+		state := "enabled"
+		if rt.Disabled {
+			state = "disabled"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", rt.Route, rt.Requests, rt.Panics, rt.Errors, state)
+	}
+	tw.Flush()
+	WriteTextOrJSON(w, r, StatusOK, b.String(), routes)
+}
+
+func (a *Admin) enable(w ResponseWriter, r *Request) {
+	if a.Bulkhead == nil {
+		adminReply(w, StatusNotFound, "no bulkhead is configured")
+		return
+	}
+	if !adminMethod(w, r, MethodPost) {
+		return
+	}
+	route := string(bytes.TrimSpace(r.Body))
+	if err := a.Bulkhead.Enable(route); err != nil {
+		adminReply(w, StatusNotFound, err.Error())
+		return
+	}
+	a.Server.logger().Info("route enabled", "route", route)
+	adminReply(w, StatusOK, "enabled "+route)
+}
+
+// adminMethod answers 405 and reports false unless r uses one of methods.
+func adminMethod(w ResponseWriter, r *Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+	w.SetHeader("Allow", strings.Join(methods, ", "))
+	adminReply(w, StatusMethodNotAllowed, StatusText(StatusMethodNotAllowed))
+	return false
+}
+
+func adminReply(w ResponseWriter, code int, msg string) {
+	w.SetStatus(code, StatusText(code))
+	w.SetHeader("Content-Type", "text/plain")
+	w.SetBody([]byte(msg + "\n"))
+	w.Write()
+}
@@ -0,0 +1,51 @@
+package http_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestAdmin(t *testing.T) {
+	var level slog.LevelVar
+	var dumps strings.Builder
+	admin := (&http.Admin{Server: &http.Server{}, Token: "secret", LogLevel: &level, DumpTo: &dumps}).Handler()
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		method, path, token, body string
+		code                      int
+		want                      string
+	}{
+		{http.MethodGet, "/admin/stats", "", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/admin/stats", "wrong", "", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/admin/stats", "secret", "", http.StatusOK, "requests"},
+		{http.MethodPost, "/admin/stats", "secret", "", http.StatusMethodNotAllowed, ""},
+		{http.MethodPut, "/admin/loglevel", "secret", "debug", http.StatusOK, "DEBUG"},
+		{http.MethodPut, "/admin/loglevel", "secret", "loud", http.StatusBadRequest, ""},
+		{http.MethodGet, "/admin/dump", "secret", "", http.StatusOK, "off"},
+		{http.MethodPut, "/admin/dump", "secret", "on", http.StatusOK, "on"},
+		{http.MethodPost, "/admin/drain", "secret", "127.0.0.1:1", http.StatusNotFound, "no listener"},
+		{http.MethodGet, "/admin/goroutines", "secret", "", http.StatusOK, "goroutine"},
+	}
+	for i, tt := range tests {
+		rec := do(tt.method, tt.path, tt.token, tt.body)
+		if rec.Code != tt.code || !strings.Contains(string(rec.Body), tt.want) {
+			t.Errorf("#%d: gotCode: %d gotBody: %q wantCode: %d wantBody: %q", i, rec.Code, rec.Body, tt.code, tt.want)
+		}
+	}
+	if level.Level() != slog.LevelDebug {
+		t.Errorf("gotLevel: %v wantLevel: %v", level.Level(), slog.LevelDebug)
+	}
+}
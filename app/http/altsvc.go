@@ -0,0 +1,54 @@
+package http
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AltService is an alternative service for Server.AltSvc, an endpoint
+// where clients may reach the same origin over another protocol, per
+// RFC 7838.
+type AltService struct {
+	// Protocol is the ALPN protocol ID, such as "h2" or "h3".
+	Protocol string
+
+	// Host is the host to connect to; empty means the origin's own.
+	Host string
+
+	// Port is the port to connect to.
+	Port int
+
+	// MaxAge is how long clients may remember the alternative. When zero
+	// the header carries no ma parameter and clients use 24 hours.
+	MaxAge time.Duration
+}
+
+// String formats a as an Alt-Svc entry, such as `h3=":443"; ma=3600`.
+func (a AltService) String() string {
+	var b strings.Builder
+	b.WriteString(a.Protocol)
+	b.WriteString(`="`)
+	if a.Host != "" {
+		b.WriteString(strings.TrimSuffix(net.JoinHostPort(a.Host, ""), ":"))
+	}
+	b.WriteByte(':')
+	b.WriteString(strconv.Itoa(a.Port))
+	b.WriteByte('"')
+	if a.MaxAge > 0 {
+		b.WriteString("; ma=")
+		b.WriteString(strconv.FormatInt(int64(a.MaxAge/time.Second), 10))
+	}
+	return b.String()
+}
+
+// altSvc returns the Alt-Svc value advertising s.AltSvc, or "" if it is
+// empty.
+func (s *Server) altSvc() string {
+	entries := make([]string, len(s.AltSvc))
+	for i, a := range s.AltSvc {
+		entries[i] = a.String()
+	}
+	return strings.Join(entries, ", ")
+}
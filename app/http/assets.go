@@ -0,0 +1,239 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// assetHashLen is how many hex digits of a file's SHA-256 go into its
+// fingerprinted name.
+const assetHashLen = 12
+
+// Assets serves the files of a directory under fingerprinted names, which
+// carry a hash of their contents, such as "css/app.3f9a2c1b7d0e.css" for
+// "css/app.css". A new version of a file gets a new name, so hashed URLs
+// can be cached forever. Pages link to assets through URL, or the "asset"
+// template function of Funcs:
+//
+//	assets, err := http.NewAssets("/assets/", os.DirFS("public"))
+//	rr := http.NewRenderer(os.DirFS("templates"))
+//	rr.Funcs = assets.Funcs()
+//	mux.Handle("/assets/", assets)
+//
+//	<link rel="stylesheet" href="{{asset "css/app.css"}}">
+//
+// Hashes are computed by Scan; call it again after files change, e.g.
+// from a Watcher.
+type Assets struct {
+	// FS holds the assets.
+	FS fs.FS
+
+	// Prefix is the URL path the assets are mounted at, e.g. "/assets/".
+	Prefix string
+
+	// CacheControl is sent with files requested by their hashed name. A
+	// year of public, immutable caching is used when nil. Logical names
+	// are still served, for links that bypass URL, with no-cache.
+	CacheControl CacheControl
+
+	// Logger receives file system errors. slog.Default() is used when nil.
+	Logger *slog.Logger
+
+	mu       sync.RWMutex
+	byName   map[string]string // logical name to hashed name
+	byHashed map[string]string // hashed name to logical name
+	hashes   map[string]string // logical name to full hex hash, for ETags
+}
+
+// NewAssets returns the Assets in fsys, mounted at prefix, with their
+// hashes computed.
+func NewAssets(prefix string, fsys fs.FS) (*Assets, error) {
+	a := &Assets{FS: fsys, Prefix: prefix}
+	if err := a.Scan(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Scan hashes every file in FS, replacing the names known so far. Files
+// and directories starting with a dot are skipped.
+func (a *Assets) Scan() error {
+	byName := make(map[string]string)
+	byHashed := make(map[string]string)
+	hashes := make(map[string]string)
+	err := fs.WalkDir(a.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		sum, err := a.hashFile(name)
+		if err != nil {
+			return err
+		}
+		hashed := fingerprintName(name, sum[:assetHashLen])
+		byName[name], byHashed[hashed], hashes[name] = hashed, name, sum
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.byName, a.byHashed, a.hashes = byName, byHashed, hashes
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *Assets) hashFile(name string) (string, error) {
+	f, err := a.FS.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprintName inserts hash before the extension of name.
+func fingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// URL returns the URL path of the asset name, such as "css/app.css",
+// under its fingerprinted name. A name Scan didn't find is returned under
+// the plain name, so pages still link somewhere sensible.
+func (a *Assets) URL(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	a.mu.RLock()
+	hashed, ok := a.byName[name]
+	a.mu.RUnlock()
+	if !ok {
+		return a.Prefix + name
+	}
+	return a.Prefix + hashed
+}
+
+// Funcs returns the template functions for linking to assets, for
+// Renderer.Funcs: "asset" maps a logical name to its URL.
+func (a *Assets) Funcs() template.FuncMap {
+	return template.FuncMap{"asset": a.URL}
+}
+
+// ServeHTTP serves an asset by its hashed or logical name for GET and
+// HEAD.
+func (a *Assets) ServeHTTP(w ResponseWriter, r *Request) {
+	if r.Method != MethodGet && r.Method != MethodHead {
+		w.SetHeader("Allow", "GET, HEAD")
+		a.respond(w, StatusMethodNotAllowed)
+		return
+	}
+	p := r.Path
+	if r.URL != nil {
+		p = r.URL.Path
+	}
+	rel, ok := strings.CutPrefix(p, a.Prefix)
+	if !ok {
+		a.respond(w, StatusNotFound)
+		return
+	}
+	rel = strings.TrimPrefix(path.Clean("/"+rel), "/")
+
+	a.mu.RLock()
+	name, hashed := a.byHashed[rel]
+	if !hashed {
+		name = rel
+	}
+	sum, known := a.hashes[name]
+	a.mu.RUnlock()
+	if !known {
+		a.respond(w, StatusNotFound)
+		return
+	}
+
+	f, err := a.FS.Open(name)
+	if err != nil {
+		a.logger().Error("opening asset", "name", name, "err", err)
+		a.respond(w, StatusNotFound)
+		return
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		a.respond(w, StatusNotFound)
+		return
+	}
+	ct := typeByExtension(name)
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	w.SetHeader("Content-Type", ct)
+	w.SetHeader("Cache-Control", a.cacheControl(hashed).String())
+	tag := `"` + sum[:2*assetHashLen] + `"`
+	w.SetHeader("ETag", tag)
+	if etagMatch(r.Header.Get("If-None-Match"), tag) {
+		f.Close()
+		w.SetStatus(StatusNotModified, StatusText(StatusNotModified))
+		w.SetBody(nil)
+		w.Write()
+		return
+	}
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	if sw, ok := w.(interface{ SetBodyReader(io.Reader, int64) }); ok {
+		sw.SetBodyReader(f, fi.Size())
+		w.Write()
+		return
+	}
+	contents, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		a.logger().Error("reading asset", "name", name, "err", err)
+		a.respond(w, StatusInternalServerError)
+		return
+	}
+	w.SetBody(contents)
+	w.Write()
+}
+
+func (a *Assets) cacheControl(hashed bool) CacheControl {
+	if !hashed {
+		cc := CacheControl{}
+		cc.Set(CacheNoCache)
+		return cc
+	}
+	if a.CacheControl != nil {
+		return a.CacheControl
+	}
+	cc := CacheControl{}
+	cc.Set(CachePublic, CacheImmutable)
+	cc.SetDuration(CacheMaxAge, 365*24*time.Hour)
+	return cc
+}
+
+func (a *Assets) respond(w ResponseWriter, code int) {
+	w.SetStatus(code, StatusText(code))
+	w.SetBody([]byte(StatusText(code)))
+	w.Write()
+}
+
+func (a *Assets) logger() *slog.Logger {
+	return loggerOrDefault(a.Logger)
+}
@@ -0,0 +1,71 @@
+package http_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestAssets(t *testing.T) {
+	fsys := fstest.MapFS{
+		"css/app.css":     {Data: []byte("body{}")},
+		"js/app.min.js":   {Data: []byte("run()")},
+		".git/HEAD":       {Data: []byte("ref")},
+		"pages/home.html": {Data: []byte(`<link href="{{asset "css/app.css"}}"><script src="{{asset "/nope.js"}}"></script>`)},
+	}
+	assets, err := http.NewAssets("/assets/", fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	css := assets.URL("css/app.css")
+	if !strings.HasPrefix(css, "/assets/css/app.") || !strings.HasSuffix(css, ".css") || !http.IsFingerprinted(css) {
+		t.Fatalf("gotURL: %q", css)
+	}
+
+	get := func(p string, headers ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, p, nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		assets.ServeHTTP(rec, req)
+		return rec
+	}
+	hashed := get(css)
+	tag := hashed.GetHeader("ETag")
+	tests := []struct {
+		rec          *httptest.ResponseRecorder
+		code         int
+		body         string
+		cacheControl string
+	}{
+		{hashed, 200, "body{}", "public, max-age=31536000, immutable"},
+		{get("/assets/css/app.css"), 200, "body{}", "no-cache"},
+		{get(css, "If-None-Match", tag), 304, "", "public, max-age=31536000, immutable"},
+		{get("/assets/css/app.0123456789ab.css"), 404, "Not Found", ""},
+		{get("/assets/.git/HEAD"), 404, "Not Found", ""},
+		{get("/static/css/app.css"), 404, "Not Found", ""},
+	}
+	for i, tt := range tests {
+		if tt.rec.Code != tt.code || string(tt.rec.Body) != tt.body || tt.rec.GetHeader("Cache-Control") != tt.cacheControl {
+			t.Errorf("#%d: gotCode: %d gotBody: %q gotCacheControl: %q wantCode: %d wantBody: %q wantCacheControl: %q",
+				i, tt.rec.Code, tt.rec.Body, tt.rec.GetHeader("Cache-Control"), tt.code, tt.body, tt.cacheControl)
+		}
+	}
+	if ct := hashed.GetHeader("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("gotContentType: %q", ct)
+	}
+
+	rr := http.NewRenderer(fsys)
+	rr.Funcs = assets.Funcs()
+	rec := httptest.NewRecorder()
+	if err := rr.Render(rec, 200, "pages/home.html", nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<link href="` + css + `"><script src="/assets/nope.js"></script>`; string(rec.Body) != want {
+		t.Errorf("gotPage: %q wantPage: %q", rec.Body, want)
+	}
+}
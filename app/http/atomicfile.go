@@ -0,0 +1,39 @@
+package http
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeFileAtomic creates or replaces the file name with what write
+// produces. It writes to a temporary file in the same directory, syncs it
+// and renames it over name, so concurrent readers see either the old file
+// or the whole new one, never a partial write, and a write that fails
+// leaves name as it was and no temporary file behind. The temporary name
+// starts with a dot, keeping it out of listings and Watchers.
+func writeFileAtomic(name string, mode os.FileMode, write func(io.Writer) error) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAuditMaxBody is how many body bytes a BodyAudit records per
+// request when MaxBody is zero.
+const DefaultAuditMaxBody = 64 << 10
+
+// BodyAudit tees request bodies to W as they are read off the connection,
+// one JSON AuditRecord per line, for compliance logging. Handlers see the
+// body as usual. Set it as Server.Audit.
+type BodyAudit struct {
+	W io.Writer
+
+	// MaxBody caps the body bytes recorded per request; longer bodies are
+	// cut short and marked truncated. Zero means DefaultAuditMaxBody.
+	MaxBody int
+
+	// ContentTypes lists the media types whose bodies are recorded, such
+	// as "application/json" or "application/x-www-form-urlencoded";
+	// parameters are ignored. When empty every body is recorded.
+	ContentTypes []string
+
+	mu sync.Mutex // serializes records from concurrent connections
+}
+
+// AuditRecord is one request body as written by BodyAudit.
+type AuditRecord struct {
+	Time        time.Time `json:"time"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"` // the whole body, including what was cut
+	Body        string    `json:"body"`
+	Truncated   bool      `json:"truncated,omitempty"`
+}
+
+// wants reports whether bodies of contentType are recorded.
+func (a *BodyAudit) wants(contentType string) bool {
+	if len(a.ContentTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(a.ContentTypes, func(t string) bool { return strings.EqualFold(t, mediaType) })
+}
+
+// tee returns r wrapped so that what is read through it is captured, and
+// a func writing the record once the body has been read.
+func (a *BodyAudit) tee(r io.Reader, req *Request, remoteAddr string) (io.Reader, func()) {
+	limit := a.MaxBody
+	if limit <= 0 {
+		limit = DefaultAuditMaxBody
+	}
+	c := &auditCapture{limit: limit}
+	done := func() {
+		a.write(AuditRecord{
+			Time:        time.Now(),
+			RemoteAddr:  remoteAddr,
+			Method:      req.Method,
+			Path:        req.Path,
+			ContentType: req.Header.Get("Content-Type"),
+			Size:        c.size,
+			Body:        c.buf.String(),
+			Truncated:   c.size > int64(c.buf.Len()),
+		})
+	}
+	return io.TeeReader(r, c), done
+}
+
+func (a *BodyAudit) write(rec AuditRecord) {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.W.Write(line)
+}
+
+// auditCapture keeps the first limit bytes written to it and counts the
+// rest. It never fails, so it can't disturb the read it is teed from.
+type auditCapture struct {
+	buf   bytes.Buffer
+	limit int
+	size  int64
+}
+
+func (c *auditCapture) Write(p []byte) (int, error) {
+	c.size += int64(len(p))
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
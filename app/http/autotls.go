@@ -0,0 +1,79 @@
+package http
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// recordTypeHandshake is the first byte of every TLS connection: the
+// record type of the ClientHello. No HTTP method starts with it.
+const recordTypeHandshake = 0x16
+
+// NewAutoTLSListener returns a listener serving TLS and plaintext HTTP on
+// the same port, like tls.NewListener but without turning plaintext
+// clients away. The server peeks at the first byte each client sends:
+// a TLS handshake record gets a TLS connection using config, anything
+// else is served as plain HTTP. It suits internal tooling and clients
+// misconfigured to use the wrong scheme; Stats counts which path each
+// connection took. Connections are only told apart once the Server
+// serving the listener reads from them, so Accept never blocks on a slow
+// client.
+func NewAutoTLSListener(ln net.Listener, config *tls.Config) net.Listener {
+	return &autoTLSListener{Listener: ln, config: config}
+}
+
+type autoTLSListener struct {
+	net.Listener
+	config *tls.Config
+}
+
+func (l *autoTLSListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &autoTLSConn{Conn: c, config: l.config}, nil
+}
+
+// autoTLSConn is a connection whose protocol is not yet known; see
+// Server.detectTLS.
+type autoTLSConn struct {
+	net.Conn
+	config *tls.Config
+}
+
+// detectTLS reads the first byte of c and returns the connection to serve
+// HTTP on: a TLS server connection if the client started a handshake, c
+// itself otherwise, with the byte put back either way.
+func (s *Server) detectTLS(c *autoTLSConn) (net.Conn, error) {
+	if s.ReadTimeout > 0 {
+		c.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+	}
+	var first [1]byte
+	if _, err := c.Conn.Read(first[:]); err != nil {
+		return nil, err
+	}
+	conn := &prefixConn{Conn: c.Conn, prefix: first[:]}
+	if first[0] != recordTypeHandshake {
+		s.stats.plainDetected.Add(1)
+		return conn, nil
+	}
+	s.stats.tlsDetected.Add(1)
+	return tls.Server(conn, c.config), nil
+}
+
+// prefixConn returns prefix before reading on from the connection.
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	if len(c.prefix) > 0 {
+		n := copy(p, c.prefix)
+		c.prefix = c.prefix[n:]
+		return n, nil
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,189 @@
+package http
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultBulkheadWindow is used when Bulkhead.Window is zero.
+const defaultBulkheadWindow = time.Minute
+
+// Bulkhead guards the routes of a ServeMux: it recovers handler panics,
+// answering 500 instead of crashing the server, counts panics and 5xx
+// responses per route and, once MaxFailures is set and crossed, disables
+// the route, answering 503 until Enable is called, as the admin API's
+// /admin/enable does. It is a crude way to keep one buggy handler from
+// taking the rest of the server down with it.
+type Bulkhead struct {
+	// MaxFailures is how many panics and 5xx responses a route may have
+	// within Window before it is disabled. Zero only counts them.
+	MaxFailures int
+
+	// Window is the period failures are counted over. One minute is used
+	// when zero.
+	Window time.Duration
+
+	// Logger receives recovered panics and routes being disabled.
+	// slog.Default() is used when nil.
+	Logger *slog.Logger
+
+	mu     sync.Mutex
+	routes map[string]*routeHealth
+}
+
+// RouteHealth reports the requests a Bulkhead has seen for one route.
+type RouteHealth struct {
+	Route    string `json:"route"`
+	Requests uint64 `json:"requests"`
+	Panics   uint64 `json:"panics"`
+	Errors   uint64 `json:"errors"` // 5xx responses, panics included
+	Disabled bool   `json:"disabled"`
+}
+
+type routeHealth struct {
+	RouteHealth
+	windowStart time.Time
+	failures    int // within the current window
+}
+
+// Handler returns mux guarded by b. Routes are told apart by their
+// Request.RouteName.
+func (b *Bulkhead) Handler(mux *ServeMux) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if b.disabled(route) {
+			w.SetHeader("Retry-After", drainRetryAfter)
+			w.SetStatus(StatusServiceUnavailable, StatusText(StatusServiceUnavailable))
+			w.SetBody([]byte(StatusText(StatusServiceUnavailable)))
+			w.Write()
+			return
+		}
+
+		bw := &bulkheadWriter{BaseResponseWriter: BaseResponseWriter{w}, code: StatusOK}
+		panicked := true
+		defer func() {
+			if panicked {
+				err := recover()
+				b.logger().Error("handler panicked", "route", route, "path", r.Path, "panic", err, "stack", string(debug.Stack()))
+				if wr, ok := AsResponseWriter[interface{ Written() bool }](w); !ok || !wr.Written() {
+					w.SetStatus(StatusInternalServerError, StatusText(StatusInternalServerError))
+					w.SetBody([]byte(StatusText(StatusInternalServerError)))
+					w.Write()
+				}
+			}
+			b.record(route, panicked, panicked || bw.code >= 500)
+		}()
+		mux.ServeHTTP(bw, r)
+		panicked = false
+	})
+}
+
+// Routes reports the health of every route that has served a request or
+// been disabled, sorted by route.
+func (b *Bulkhead) Routes() []RouteHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	routes := make([]RouteHealth, 0, len(b.routes))
+	for _, h := range b.routes {
+		routes = append(routes, h.RouteHealth)
+	}
+	slices.SortFunc(routes, func(a, b RouteHealth) int { return strings.Compare(a.Route, b.Route) })
+	return routes
+}
+
+// Enable puts a disabled route back in service and clears its failures.
+func (b *Bulkhead) Enable(route string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.routes[route]
+	if !ok || !h.Disabled {
+		return fmt.Errorf("http: route %q is not disabled", route)
+	}
+	h.Disabled = false
+	h.failures = 0
+	h.windowStart = time.Now()
+	return nil
+}
+
+// Disable takes route out of service, as though it had failed too often.
+func (b *Bulkhead) Disable(route string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.healthLocked(route).Disabled = true
+}
+
+func (b *Bulkhead) disabled(route string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h, ok := b.routes[route]
+	return ok && h.Disabled
+}
+
+func (b *Bulkhead) record(route string, panicked, failed bool) {
+	window := b.Window
+	if window <= 0 {
+		window = defaultBulkheadWindow
+	}
+	now := time.Now()
+
+	b.mu.Lock()
+	h := b.healthLocked(route)
+	h.Requests++
+	if panicked {
+		h.Panics++
+	}
+	if !failed {
+		b.mu.Unlock()
+		return
+	}
+	h.Errors++
+	if now.Sub(h.windowStart) > window {
+		h.windowStart, h.failures = now, 0
+	}
+	h.failures++
+	disable := b.MaxFailures > 0 && !h.Disabled && h.failures >= b.MaxFailures
+	if disable {
+		h.Disabled = true
+	}
+	b.mu.Unlock()
+
+	if disable {
+		b.logger().Error("route disabled", "route", route, "failures", b.MaxFailures, "window", window)
+	}
+}
+
+func (b *Bulkhead) healthLocked(route string) *routeHealth {
+	if b.routes == nil {
+		b.routes = make(map[string]*routeHealth)
+	}
+	h, ok := b.routes[route]
+	if !ok {
+		h = &routeHealth{RouteHealth: RouteHealth{Route: route}, windowStart: time.Now()}
+		b.routes[route] = h
+	}
+	return h
+}
+
+func (b *Bulkhead) logger() *slog.Logger {
+	return loggerOrDefault(b.Logger)
+}
+
+// bulkheadWriter notes the status the handler sends.
+type bulkheadWriter struct {
+	BaseResponseWriter
+	code int
+}
+
+func (bw *bulkheadWriter) SetStatus(code int, text string) {
+	bw.code = code
+	bw.ResponseWriter.SetStatus(code, text)
+}
@@ -0,0 +1,59 @@
+package http_test
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestBulkhead(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) { w.Write() })
+	var logs strings.Builder
+	b := &http.Bulkhead{MaxFailures: 2, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	h := b.Handler(mux)
+	get := func(path string) int {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	want := []struct {
+		path string
+		code int
+	}{
+		{"/panic", http.StatusInternalServerError},
+		{"/ok", http.StatusOK},
+		{"/panic", http.StatusInternalServerError},
+		{"/panic", http.StatusServiceUnavailable}, // disabled after two failures
+		{"/ok", http.StatusOK},
+		{"/missing", http.StatusNotFound},
+	}
+	for i, tt := range want {
+		if got := get(tt.path); got != tt.code {
+			t.Errorf("#%d: %s gotCode: %d wantCode: %d", i, tt.path, got, tt.code)
+		}
+	}
+	routes := b.Routes()
+	if len(routes) != 2 || routes[1].Route != "/panic" || routes[1].Panics != 2 || !routes[1].Disabled {
+		t.Errorf("gotRoutes: %+v", routes)
+	}
+	if !strings.Contains(logs.String(), "route disabled") {
+		t.Errorf("gotLogs: %s", logs.String())
+	}
+
+	if err := b.Enable("/panic"); err != nil {
+		t.Fatal(err)
+	}
+	if got := get("/panic"); got != http.StatusInternalServerError {
+		t.Errorf("after Enable gotCode: %d", got)
+	}
+	if err := b.Enable("/ok"); err == nil {
+		t.Errorf("enabling a route that isn't disabled succeeded")
+	}
+}
@@ -0,0 +1,281 @@
+package http
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache is an in-memory cache for GET responses. Only responses
+// with explicit freshness (Cache-Control max-age or s-maxage, or Expires)
+// are stored; no-store, private and Vary: * responses never are. Requests
+// sending Cache-Control: no-cache or no-store bypass the cache, and those
+// sending max-age only get entries at most that old.
+//
+// Entries are keyed by request target, e.g. "/files/a.txt?x=1", with the
+// request's Host and the headers named by Vary selecting the variant.
+type ResponseCache struct {
+	// MaxEntries bounds the number of cached targets; 1024 when zero.
+	// The oldest entry is evicted first.
+	MaxEntries int
+
+	// MaxBodySize skips caching of larger bodies; 1 MiB when zero.
+	MaxBodySize int
+
+	mu      sync.Mutex
+	entries map[string][]*cacheEntry // target -> variants
+	order   []string                 // targets, oldest first
+}
+
+type cacheEntry struct {
+	vary    map[string]string // request header values the variant was built for
+	code    int
+	text    string
+	headers map[string]string
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// Handler returns middleware serving cached responses for next.
+func (c *ResponseCache) Handler(next Handler) Handler {
+	return wrapHandler("ResponseCache", next, func(w ResponseWriter, r *Request) {
+		if r.Method != MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reqCC := ParseCacheControl(r.Header.Get("Cache-Control"))
+		noStore := reqCC.Has(CacheNoStore)
+		if !reqCC.Has(CacheNoCache) && !noStore {
+			maxAge, ok := reqCC.Duration(CacheMaxAge)
+			if !ok {
+				maxAge = -1
+			}
+			if e := c.lookup(r, maxAge); e != nil {
+				for k, v := range e.headers {
+					w.SetHeader(k, v)
+				}
+				w.SetHeader("Age", strconv.Itoa(int(time.Since(e.stored).Seconds())))
+				w.SetStatus(e.code, e.text)
+				w.SetBody(e.body)
+				w.Write()
+				return
+			}
+		}
+
+		cw := &cacheWriter{BaseResponseWriter: BaseResponseWriter{w}, headers: make(map[string]string), code: StatusOK}
+		next.ServeHTTP(cw, r)
+		if cw.written && !noStore {
+			c.store(r, cw)
+		}
+	})
+}
+
+// Invalidate drops every variant cached for target.
+func (c *ResponseCache) Invalidate(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(func(k string) bool { return k == target })
+}
+
+// InvalidatePrefix drops every target starting with prefix, e.g.
+// "/files/" after a bulk upload.
+func (c *ResponseCache) InvalidatePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remove(func(k string) bool { return strings.HasPrefix(k, prefix) })
+}
+
+// remove deletes the matching targets; c.mu must be held.
+func (c *ResponseCache) remove(match func(string) bool) {
+	kept := c.order[:0]
+	for _, k := range c.order {
+		if match(k) {
+			delete(c.entries, k)
+			continue
+		}
+		kept = append(kept, k)
+	}
+	c.order = kept
+}
+
+// lookup returns a fresh entry for r no older than maxAge, if maxAge is
+// not negative.
+func (c *ResponseCache) lookup(r *Request, maxAge time.Duration) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, e := range c.entries[r.Path] {
+		if maxAge >= 0 && now.Sub(e.stored) > maxAge {
+			continue
+		}
+		if now.Before(e.expires) && e.matches(r) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (e *cacheEntry) matches(r *Request) bool {
+	for name, v := range e.vary {
+		if r.Header.Get(name) != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ResponseCache) store(r *Request, cw *cacheWriter) {
+	if !cacheableStatus(cw.code) || len(cw.body) > c.maxBodySize() {
+		return
+	}
+	// replaying one client's cookie to everyone would leak its session
+	if _, ok := cw.headers["Set-Cookie"]; ok {
+		return
+	}
+	ttl, ok := freshness(cw.headers, time.Now())
+	if !ok || ttl <= 0 {
+		return
+	}
+
+	// Host is always part of the variant so virtual hosts never mix
+	vary := map[string]string{"Host": r.Header.Get("Host")}
+	for _, name := range strings.Split(cw.headers["Vary"], ",") {
+		name = strings.TrimSpace(name)
+		if name == "*" {
+			return
+		}
+		if name != "" {
+			vary[name] = r.Header.Get(name)
+		}
+	}
+
+	now := time.Now()
+	e := &cacheEntry{
+		vary:    vary,
+		code:    cw.code,
+		text:    cw.text,
+		headers: cw.headers,
+		body:    cw.body,
+		stored:  now,
+		expires: now.Add(ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]*cacheEntry)
+	}
+	variants, exists := c.entries[r.Path]
+	kept := variants[:0]
+	for _, v := range variants {
+		if !sameVary(v.vary, vary) && now.Before(v.expires) {
+			kept = append(kept, v)
+		}
+	}
+	c.entries[r.Path] = append(kept, e)
+	if !exists {
+		c.order = append(c.order, r.Path)
+		if len(c.order) > c.maxEntries() {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+}
+
+func sameVary(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ResponseCache) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return 1024
+}
+
+func (c *ResponseCache) maxBodySize() int {
+	if c.MaxBodySize > 0 {
+		return c.MaxBodySize
+	}
+	return 1 << 20
+}
+
+// cacheableStatus lists the codes a shared cache may store, per RFC 9110
+// section 15.1, when freshness is explicit.
+func cacheableStatus(code int) bool {
+	switch code {
+	case StatusOK, StatusNonAuthoritativeInfo, StatusNoContent, StatusMultipleChoices,
+		StatusMovedPermanently, StatusNotFound, StatusMethodNotAllowed, StatusGone,
+		StatusRequestURITooLong, StatusNotImplemented, StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// freshness returns how long a response stays fresh for a shared cache.
+// s-maxage wins over max-age, which wins over Expires.
+func freshness(headers map[string]string, now time.Time) (time.Duration, bool) {
+	cc := ParseCacheControl(headers["Cache-Control"])
+	for _, d := range []string{CacheNoStore, CachePrivate, CacheNoCache} {
+		if cc.Has(d) {
+			return 0, false
+		}
+	}
+	for _, d := range []string{CacheSMaxAge, CacheMaxAge} {
+		if cc.Has(d) {
+			return cc.Duration(d)
+		}
+	}
+	if exp := headers["Expires"]; exp != "" {
+		t, err := time.Parse(TimeFormat, exp)
+		if err != nil {
+			// an invalid Expires means already expired
+			return 0, false
+		}
+		return t.Sub(now), true
+	}
+	return 0, false
+}
+
+// cacheWriter records what the handler sends so it can be stored.
+type cacheWriter struct {
+	BaseResponseWriter
+	code    int
+	text    string
+	headers map[string]string
+	body    []byte
+	written bool
+}
+
+func (cw *cacheWriter) SetStatus(code int, text string) {
+	cw.code, cw.text = code, text
+	cw.ResponseWriter.SetStatus(code, text)
+}
+
+func (cw *cacheWriter) SetHeader(key, value string) {
+	cw.headers[key] = value
+	cw.ResponseWriter.SetHeader(key, value)
+}
+
+func (cw *cacheWriter) SetBody(body []byte) {
+	cw.body = body
+	cw.ResponseWriter.SetBody(body)
+}
+
+func (cw *cacheWriter) Write() error {
+	cw.written = true
+	if cw.text == "" {
+		cw.text = StatusText(cw.code)
+	}
+	return cw.ResponseWriter.Write()
+}
@@ -0,0 +1,80 @@
+package http_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestResponseCache(t *testing.T) {
+	calls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.SetHeader("Cache-Control", r.Header.Get("X-Cache-Control"))
+		w.SetHeader("Vary", "Accept")
+		w.SetBody([]byte(strconv.Itoa(calls)))
+		w.Write()
+	})
+	c := &http.ResponseCache{}
+	cached := c.Handler(h)
+
+	get := func(target, accept, cc string) string {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("X-Cache-Control", cc)
+		rec := httptest.NewRecorder()
+		cached.ServeHTTP(rec, req)
+		return string(rec.Body)
+	}
+
+	tests := []struct {
+		target, accept, cc, want string
+	}{
+		{"/a", "text/plain", "max-age=60", "1"},
+		{"/a", "text/plain", "max-age=60", "1"},       // hit
+		{"/a", "application/json", "max-age=60", "2"}, // other variant
+		{"/a", "application/json", "max-age=60", "2"},
+		{"/b", "text/plain", "no-store", "3"},
+		{"/b", "text/plain", "no-store", "4"},
+		{"/c", "text/plain", "", "5"}, // no explicit freshness
+		{"/c", "text/plain", "", "6"},
+	}
+	for i, tt := range tests {
+		if got := get(tt.target, tt.accept, tt.cc); got != tt.want {
+			t.Errorf("#%d: gotBody: %q wantBody: %q", i, got, tt.want)
+		}
+	}
+
+	c.InvalidatePrefix("/")
+	if got := get("/a", "text/plain", "max-age=60"); got != "7" {
+		t.Errorf("after invalidation gotBody: %q wantBody: %q", got, "7")
+	}
+}
+
+func TestResponseCacheRequestMaxAge(t *testing.T) {
+	calls := 0
+	cached := (&http.ResponseCache{}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.SetHeader("Cache-Control", "max-age=60")
+		w.SetBody([]byte(strconv.Itoa(calls)))
+		w.Write()
+	}))
+	tests := []struct {
+		cc, want string
+	}{
+		{"", "1"},
+		{"max-age=30", "1"},
+		{"max-age=0", "2"},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set("Cache-Control", tt.cc)
+		rec := httptest.NewRecorder()
+		cached.ServeHTTP(rec, req)
+		if string(rec.Body) != tt.want {
+			t.Errorf("#%d: gotBody: %q wantBody: %q", i, rec.Body, tt.want)
+		}
+	}
+}
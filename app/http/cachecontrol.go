@@ -0,0 +1,131 @@
+package http
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache-Control directives, RFC 9111 section 5.2 and RFC 8246.
+const (
+	CacheMaxAge          = "max-age"
+	CacheMaxStale        = "max-stale"
+	CacheMinFresh        = "min-fresh"
+	CacheSMaxAge         = "s-maxage"
+	CacheNoCache         = "no-cache"
+	CacheNoStore         = "no-store"
+	CacheNoTransform     = "no-transform"
+	CacheOnlyIfCached    = "only-if-cached"
+	CacheMustRevalidate  = "must-revalidate"
+	CacheProxyRevalidate = "proxy-revalidate"
+	CachePrivate         = "private"
+	CachePublic          = "public"
+	CacheImmutable       = "immutable"
+)
+
+// CacheControl holds the directives of a Cache-Control header, keyed by
+// lowercase name, with "" for directives without an argument. It parses
+// request headers and builds response ones:
+//
+//	cc := http.CacheControl{}
+//	cc.Set(http.CachePublic)
+//	cc.SetDuration(http.CacheMaxAge, time.Hour)
+//	w.SetHeader("Cache-Control", cc.String()) // "public, max-age=3600"
+type CacheControl map[string]string
+
+// ParseCacheControl parses a Cache-Control value. Quoted arguments are
+// unquoted; malformed elements are skipped.
+func ParseCacheControl(v string) CacheControl {
+	cc := make(CacheControl)
+	for part := range strings.SplitSeq(v, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		cc[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return cc
+}
+
+// Has reports whether directive is present.
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// Duration returns the delta-seconds argument of directive, such as
+// max-age. It reports false when the directive is absent or its argument
+// isn't a number of seconds.
+func (cc CacheControl) Duration(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok {
+		return 0, false
+	}
+	sec, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || sec < 0 {
+		return 0, false
+	}
+	return time.Duration(sec) * time.Second, true
+}
+
+// Set adds directives that take no argument, such as no-store.
+func (cc CacheControl) Set(directives ...string) {
+	for _, d := range directives {
+		cc[d] = ""
+	}
+}
+
+// SetDuration sets a delta-seconds directive, such as max-age, to d
+// rounded down to whole seconds.
+func (cc CacheControl) SetDuration(directive string, d time.Duration) {
+	cc[directive] = strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+// cacheDirectiveOrder lists the usual directives in the order String
+// writes them; others follow alphabetically.
+var cacheDirectiveOrder = []string{
+	CachePublic, CachePrivate, CacheNoCache, CacheNoStore, CacheNoTransform,
+	CacheMaxAge, CacheSMaxAge, CacheMustRevalidate, CacheProxyRevalidate,
+	CacheImmutable, CacheMaxStale, CacheMinFresh, CacheOnlyIfCached,
+}
+
+// String formats cc as a header value, in a stable order.
+func (cc CacheControl) String() string {
+	names := make([]string, 0, len(cc))
+	for name := range cc {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		ia, ib := directiveRank(a), directiveRank(b)
+		if ia != ib {
+			return ia - ib
+		}
+		return strings.Compare(a, b)
+	})
+
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		if arg := cc[name]; arg != "" {
+			b.WriteByte('=')
+			if strings.ContainsAny(arg, " ,;=\"") {
+				b.WriteString(strconv.Quote(arg))
+			} else {
+				b.WriteString(arg)
+			}
+		}
+	}
+	return b.String()
+}
+
+func directiveRank(name string) int {
+	if i := slices.Index(cacheDirectiveOrder, name); i >= 0 {
+		return i
+	}
+	return len(cacheDirectiveOrder)
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"testing"
+	"time"
+)
+
+var parseCacheControlTest = []struct {
+	header    string
+	directive string
+	has       bool
+	age       time.Duration
+	ageOK     bool
+}{
+	{"no-cache", CacheNoCache, true, 0, false},
+	{"No-Store, max-age=0", CacheNoStore, true, 0, false},
+	{"No-Store, max-age=0", CacheMaxAge, true, 0, true},
+	{"max-age=60", CacheMaxAge, true, time.Minute, true},
+	{`max-age="60"`, CacheMaxAge, true, time.Minute, true},
+	{"max-age = 60 ", CacheMaxAge, true, time.Minute, true},
+	{"max-age=soon", CacheMaxAge, true, 0, false},
+	{"max-age=-1", CacheMaxAge, true, 0, false},
+	{"public, , private", CachePrivate, true, 0, false},
+	{"public", CacheMaxAge, false, 0, false},
+}
+
+func TestParseCacheControl(t *testing.T) {
+	for i, tt := range parseCacheControlTest {
+		cc := ParseCacheControl(tt.header)
+		age, ok := cc.Duration(tt.directive)
+		if cc.Has(tt.directive) != tt.has || age != tt.age || ok != tt.ageOK {
+			t.Errorf("#%d: %q %s gotHas: %v gotAge: %v, %v wantHas: %v wantAge: %v, %v",
+				i, tt.header, tt.directive, cc.Has(tt.directive), age, ok, tt.has, tt.age, tt.ageOK)
+		}
+	}
+}
+
+func TestCacheControlString(t *testing.T) {
+	cc := CacheControl{}
+	cc.SetDuration(CacheMaxAge, 365*24*time.Hour)
+	cc.Set(CacheImmutable, CachePublic)
+	cc["community"] = "UCI team"
+	want := `public, max-age=31536000, immutable, community="UCI team"`
+	if got := cc.String(); got != want {
+		t.Errorf("gotString: %q wantString: %q", got, want)
+	}
+	if got := ParseCacheControl(want).String(); got != want {
+		t.Errorf("round trip gotString: %q wantString: %q", got, want)
+	}
+}
+
+var cachePolicyTest = []struct {
+	rel  string
+	want string
+}{
+	{"app.3f9a2c1b.js", "public, max-age=31536000, immutable"},
+	{"css/main-0a1b2c3d4e.css", "public, max-age=31536000, immutable"},
+	{"index.html", "no-cache"},
+	{"docs/guide.html", "no-cache"},
+	{"assets/logo.png", "max-age=60"},
+	{"app.min.js", "max-age=3600"},
+	{"deadbeefcafe.js", "max-age=3600"}, // letters only: a word, not a hash
+	{"readme.txt", ""},
+}
+
+func TestCachePolicies(t *testing.T) {
+	assets, scripts := CacheControl{}, CacheControl{}
+	assets.SetDuration(CacheMaxAge, time.Minute)
+	scripts.SetDuration(CacheMaxAge, time.Hour)
+	h := &FilesHandler{
+		Root:          "/srv",
+		CachePolicies: append(StaticCachePolicies(), CachePolicy{"assets/*", assets}, CachePolicy{"*.js", scripts}),
+	}
+	for i, tt := range cachePolicyTest {
+		if got := h.cacheControl("/srv/" + tt.rel).String(); got != tt.want {
+			t.Errorf("#%d: %s: gotCacheControl: %q wantCacheControl: %q", i, tt.rel, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// CachePolicy assigns a Cache-Control to the files a FilesHandler serves
+// whose path matches Pattern, such as immutable caching for hashed assets
+// and revalidation for HTML.
+type CachePolicy struct {
+	// Pattern is a path.Match pattern. Without a slash it is matched
+	// against the file's base name, as in "*.html"; with one, against its
+	// path below the mount, as in "assets/*.js". The special pattern
+	// "fingerprinted" matches names carrying a content hash; see
+	// IsFingerprinted.
+	Pattern string
+
+	CacheControl CacheControl
+}
+
+// PatternFingerprinted is the CachePolicy pattern matching every file
+// whose name carries a content hash.
+const PatternFingerprinted = "fingerprinted"
+
+// match reports whether rel, a slash-separated path below the mount
+// without a leading slash, falls under p.
+func (p CachePolicy) match(rel string) bool {
+	if p.Pattern == PatternFingerprinted {
+		return IsFingerprinted(rel)
+	}
+	name := rel
+	if !strings.Contains(p.Pattern, "/") {
+		name = path.Base(rel)
+	}
+	ok, _ := path.Match(p.Pattern, name)
+	return ok
+}
+
+// StaticCachePolicies returns the usual policies for a static site:
+// fingerprinted assets cached for a year and never revalidated, since
+// new contents get a new name, and HTML revalidated on every use, so a
+// deploy is seen at once. Other files fall through to
+// FilesHandler.CacheControl.
+func StaticCachePolicies() []CachePolicy {
+	immutable := CacheControl{}
+	immutable.Set(CachePublic, CacheImmutable)
+	immutable.SetDuration(CacheMaxAge, 365*24*time.Hour)
+	revalidate := CacheControl{}
+	revalidate.Set(CacheNoCache)
+	return []CachePolicy{
+		{Pattern: PatternFingerprinted, CacheControl: immutable},
+		{Pattern: "*.html", CacheControl: revalidate},
+	}
+}
+
+// minFingerprintLen is the fewest hex digits taken for a content hash, so
+// names like "app.min.js" or "v1.2.js" aren't mistaken for hashed ones.
+const minFingerprintLen = 8
+
+// IsFingerprinted reports whether the base name of name carries a content
+// hash as a dot- or dash-separated element before its extension, as in
+// "app.3f9a2c1b.js" or "main-3f9a2c1b.css".
+func IsFingerprinted(name string) bool {
+	base := path.Base(name)
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	for _, part := range strings.FieldsFunc(stem, func(r rune) bool { return r == '.' || r == '-' }) {
+		if isFingerprint(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFingerprint reports whether s looks like a hex content hash: long
+// enough, hex digits only, and not only letters, which words can be.
+func isFingerprint(s string) bool {
+	if len(s) < minFingerprintLen {
+		return false
+	}
+	digit := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digit = true
+		case 'a' <= c && c <= 'f':
+		default:
+			return false
+		}
+	}
+	return digit
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net"
+	"strings"
+)
+
+// CanonicalHost redirects requests that arrive on a non-canonical Host,
+// keeping the path and query intact, so every page has one URL.
+type CanonicalHost struct {
+	// Host is the canonical host, optionally with a port. When empty, the
+	// request's own host is normalized with the rules below instead.
+	Host string
+
+	// StripWWW removes a leading "www." from the request host.
+	StripWWW bool
+
+	// Scheme for the redirect target; "http" when empty.
+	Scheme string
+}
+
+// Handler returns middleware enforcing the canonical host before next.
+// Hosts are always compared and redirected in lowercase. GET and HEAD get
+// 301 Moved Permanently; other methods get 308 so the method and body are
+// repeated on the canonical host.
+func (c *CanonicalHost) Handler(next Handler) Handler {
+	return wrapHandler("CanonicalHost", next, func(w ResponseWriter, r *Request) {
+		host := r.Header.Get("Host")
+		want := c.canonical(host)
+		if host == "" || want == host {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme := c.Scheme
+		if scheme == "" {
+			scheme = "http"
+		}
+		code := StatusMovedPermanently
+		if r.Method != MethodGet && r.Method != MethodHead {
+			code = StatusPermanentRedirect
+		}
+		w.SetStatus(code, StatusText(code))
+		w.SetHeader("Location", scheme+"://"+want+r.Path)
+		w.SetBody(nil)
+		w.Write()
+	})
+}
+
+func (c *CanonicalHost) canonical(host string) string {
+	if c.Host != "" {
+		return strings.ToLower(c.Host)
+	}
+	h := strings.ToLower(host)
+	if c.StripWWW {
+		name, port, err := net.SplitHostPort(h)
+		if err != nil {
+			name, port = h, ""
+		}
+		if trimmed, ok := strings.CutPrefix(name, "www."); ok && trimmed != "" {
+			name = trimmed
+		}
+		if port != "" {
+			return net.JoinHostPort(name, port)
+		}
+		return name
+	}
+	return h
+}
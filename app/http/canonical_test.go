@@ -0,0 +1,33 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write() })
+	tests := []struct {
+		c            *http.CanonicalHost
+		method, host string
+		code         int
+		location     string
+	}{
+		{&http.CanonicalHost{StripWWW: true}, http.MethodGet, "www.Example.com", 301, "http://example.com/p?q=1"},
+		{&http.CanonicalHost{StripWWW: true}, http.MethodGet, "www.example.com:8080", 301, "http://example.com:8080/p?q=1"},
+		{&http.CanonicalHost{StripWWW: true}, http.MethodGet, "example.com", 200, ""},
+		{&http.CanonicalHost{Host: "example.com", Scheme: "https"}, http.MethodPost, "other.org", 308, "https://example.com/p?q=1"},
+		{&http.CanonicalHost{}, http.MethodGet, "EXAMPLE.com", 301, "http://example.com/p?q=1"},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "/p?q=1", nil)
+		req.Header.Set("Host", tt.host)
+		rec := httptest.NewRecorder()
+		tt.c.Handler(ok).ServeHTTP(rec, req)
+		if rec.Code != tt.code || rec.HeaderMap["Location"] != tt.location {
+			t.Errorf("#%d: got (%d, %q) want (%d, %q)", i, rec.Code, rec.HeaderMap["Location"], tt.code, tt.location)
+		}
+	}
+}
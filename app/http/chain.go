@@ -0,0 +1,38 @@
+package http
+
+import "time"
+
+// middlewareHandler is the Handler this package's middleware return. It
+// remembers the handler it wraps, so RoutesHandler can show the chain of
+// middleware a route's requests pass through.
+type middlewareHandler struct {
+	name    string
+	next    Handler
+	timeout time.Duration // the time limit TimeoutHandler sets
+	serve   HandlerFunc
+}
+
+func (m *middlewareHandler) ServeHTTP(w ResponseWriter, r *Request) { m.serve(w, r) }
+
+// wrapHandler returns the middleware called name, serving requests for
+// next with serve.
+func wrapHandler(name string, next Handler, serve HandlerFunc) Handler {
+	return &middlewareHandler{name: name, next: next, serve: serve}
+}
+
+// unwrapHandler follows h through the middleware wrapping it. It returns
+// their names, outermost first, the shortest time limit among them, zero
+// for none, and the handler at the end of the chain.
+func unwrapHandler(h Handler) (chain []string, timeout time.Duration, end Handler) {
+	for {
+		m, ok := h.(*middlewareHandler)
+		if !ok {
+			return chain, timeout, h
+		}
+		chain = append(chain, m.name)
+		if m.timeout > 0 && (timeout == 0 || m.timeout < timeout) {
+			timeout = m.timeout
+		}
+		h = m.next
+	}
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxChunkLineLength bounds a chunk-size line, extensions included.
+const maxChunkLineLength = 4096
+
+// maxChunkExtBytes bounds the chunk extensions of a whole body in strict
+// mode, so a client can't make the server chew through megabytes of
+// extensions around one-byte chunks.
+const maxChunkExtBytes = 4096
+
+// chunkedReader decodes a "Transfer-Encoding: chunked" body as described in
+// RFC 9112 section 7.1. Chunk extensions are ignored and trailer fields are
+// read and discarded, so the underlying reader is left positioned at the
+// next message.
+type chunkedReader struct {
+	r   *bufio.Reader
+	n   int64 // bytes left in the current chunk
+	err error
+	hdr bool // a chunk has been read and its trailing CRLF is pending
+
+	// strict requires CRLF line endings and a chunk-size of hex digits
+	// alone, and checks extension syntax and volume; see
+	// Server.StrictParsing.
+	strict   bool
+	extBytes int // extension bytes seen so far
+}
+
+func newChunkedReader(r *bufio.Reader) *chunkedReader {
+	return &chunkedReader{r: r}
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	for cr.n == 0 && cr.err == nil {
+		cr.nextChunk()
+	}
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if int64(len(p)) > cr.n {
+		p = p[:cr.n]
+	}
+	n, err := cr.r.Read(p)
+	cr.n -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		cr.err = err
+	}
+	return n, err
+}
+
+// nextChunk consumes the CRLF ending the previous chunk and the next
+// chunk-size line. A zero-size chunk ends the body.
+func (cr *chunkedReader) nextChunk() {
+	if cr.hdr {
+		if line, err := cr.readLine(); err != nil || line != "" {
+			cr.setErr(err)
+			return
+		}
+	}
+	line, err := cr.readLine()
+	if err != nil {
+		cr.setErr(err)
+		return
+	}
+	size, ext, hasExt := strings.Cut(line, ";")
+	if cr.strict {
+		cr.extBytes += len(ext)
+		if !isHex(size) || hasExt && !validChunkExt(ext) || cr.extBytes > maxChunkExtBytes {
+			cr.err = ErrMalformedChunk
+			return
+		}
+	}
+	size = strings.TrimRight(size, " \t")
+	n, err := strconv.ParseUint(size, 16, 63)
+	if err != nil || size == "" {
+		cr.err = ErrMalformedChunk
+		return
+	}
+	cr.hdr = true
+	if n == 0 {
+		cr.readTrailer()
+		return
+	}
+	cr.n = int64(n)
+}
+
+// readTrailer skips trailer fields up to the blank line ending the body.
+func (cr *chunkedReader) readTrailer() {
+	for {
+		line, err := cr.readLine()
+		if err != nil {
+			cr.setErr(err)
+			return
+		}
+		if line == "" {
+			cr.err = io.EOF
+			return
+		}
+	}
+}
+
+func (cr *chunkedReader) readLine() (string, error) {
+	line, err := cr.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxChunkLineLength {
+		return "", ErrMalformedChunk
+	}
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(string(line), "\n")
+	if cr.strict && !strings.HasSuffix(s, "\r") {
+		return "", ErrMalformedChunk
+	}
+	return strings.TrimSuffix(s, "\r"), nil
+}
+
+// isHex reports whether s is a non-empty run of hex digits of at most 16,
+// which is all a strict chunk-size may be.
+func isHex(s string) bool {
+	if s == "" || len(s) > 16 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// validChunkExt reports whether ext, the part of a chunk-size line after
+// its first semicolon, is a list of name or name=value extensions
+// (RFC 9112 section 7.1.1) without the optional whitespace.
+func validChunkExt(ext string) bool {
+	for _, e := range strings.Split(ext, ";") {
+		name, val, hasVal := strings.Cut(e, "=")
+		if !validHeaderKey([]byte(name)) || name == "" {
+			return false
+		}
+		if !hasVal {
+			continue
+		}
+		if unq, ok := strings.CutPrefix(val, `"`); ok {
+			unq, ok = strings.CutSuffix(unq, `"`)
+			if !ok || strings.ContainsAny(unq, "\"\r\n") {
+				return false
+			}
+		} else if val == "" || !validHeaderKey([]byte(val)) {
+			return false
+		}
+	}
+	return true
+}
+
+// setErr records err, treating a premature end of input as truncation.
+func (cr *chunkedReader) setErr(err error) {
+	if err == nil {
+		err = ErrMalformedChunk
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	cr.err = err
+}
+
+// isChunked reports whether chunked is the final transfer coding in the
+// Transfer-Encoding header values.
+func isChunked(te []string) bool {
+	if len(te) == 0 {
+		return false
+	}
+	codings := strings.Split(te[len(te)-1], ",")
+	return strings.EqualFold(strings.TrimSpace(codings[len(codings)-1]), "chunked")
+}
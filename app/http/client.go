@@ -0,0 +1,365 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ClientResponse is a response received by a Client. The caller must close
+// Body when done reading it.
+type ClientResponse struct {
+	Status        string // e.g. "200 OK"
+	StatusCode    int
+	Proto         string
+	Header        Header
+	Body          io.ReadCloser
+	ContentLength int64 // -1 when unknown, e.g. for chunked or compressed bodies
+	Request       *Request
+
+	// TransferEncoding lists the transfer codings of the body, outermost
+	// first. Body is already decoded.
+	TransferEncoding []string
+
+	// Uncompressed reports that the transport transparently decoded a gzip
+	// body; Content-Encoding and Content-Length were removed from Header.
+	Uncompressed bool
+}
+
+// Client sends requests and reads responses using the same Request and
+// Header types the server parses, so services built on this package can
+// make outbound calls without importing net/http.
+type Client struct {
+	// Transport sends individual requests. DefaultTransport is used when
+	// nil.
+	Transport *Transport
+
+	// Middleware wraps every request sent through Transport, retries and
+	// redirect hops each passing through on their own. The first listed
+	// sees the request first and the response last.
+	Middleware []ClientMiddleware
+
+	// CheckRedirect is consulted before following a redirect, with the
+	// upcoming request and the requests made so far, oldest first. A
+	// returned error stops the redirect chain; ErrUseLastResponse returns
+	// the redirect response itself with its body unread. When nil, the
+	// client follows up to MaxRedirects redirects.
+	CheckRedirect func(req *Request, via []*Request) error
+
+	// MaxRedirects bounds the default redirect policy. DefaultMaxRedirects
+	// is used when zero.
+	MaxRedirects int
+
+	// Retry, if set, retries idempotent requests that fail or come back
+	// with a retryable status. Each redirect hop is retried on its own.
+	Retry *RetryPolicy
+
+	// Timeout limits the whole exchange, from dialing through redirects to
+	// reading the last response body. Zero means no timeout; the request's
+	// own context still applies.
+	Timeout time.Duration
+
+	// Jar, if set, stores the cookies responses set and adds them to
+	// later requests, redirect hops included, after any Cookie header the
+	// caller set.
+	Jar *CookieJar
+}
+
+// DefaultClient is used by the package-level Get and Post.
+var DefaultClient = &Client{}
+
+var errMissingHost = errors.New("http: no Host in request URL")
+
+// Do sends req and returns the response with its body unread. The caller
+// must read the body to EOF and close it for the connection to be reused.
+func (c *Client) Do(req *Request) (*ClientResponse, error) {
+	if req.URL == nil {
+		return nil, errors.New("http: nil Request.URL")
+	}
+	if req.URL.Host == "" {
+		return nil, errMissingHost
+	}
+	if req.Header == nil {
+		req.Header = make(Header)
+	}
+
+	if c.Timeout > 0 {
+		ctx, cancel := context.WithTimeoutCause(req.Context(), c.Timeout, errClientTimeout)
+		req = req.WithContext(ctx)
+		res, err := c.do(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		// the deadline keeps covering body reads until the caller closes it
+		res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+		return res, nil
+	}
+	return c.do(req)
+}
+
+var errClientTimeout = errors.New("Client.Timeout exceeded")
+
+func (c *Client) do(req *Request) (*ClientResponse, error) {
+	var via []*Request
+	for {
+		res, err := c.roundTrip(c.withCookies(req))
+		if err != nil {
+			return nil, err
+		}
+		if c.Jar != nil {
+			c.Jar.SetCookies(req.URL, res.Header["Set-Cookie"])
+		}
+		next, err := c.redirect(req, res, via)
+		switch {
+		case errors.Is(err, ErrUseLastResponse):
+			return res, nil
+		case err != nil:
+			res.Body.Close()
+			return nil, err
+		case next == nil:
+			return res, nil
+		}
+		// drain so the connection can carry the redirected request
+		io.Copy(io.Discard, io.LimitReader(res.Body, 2<<10))
+		res.Body.Close()
+		via = append(via, req)
+		req = next
+	}
+}
+
+// withCookies returns req with the jar's cookies for it added, copying it
+// so they don't carry over into redirects built from req.
+func (c *Client) withCookies(req *Request) *Request {
+	if c.Jar == nil {
+		return req
+	}
+	cookies := c.Jar.cookieHeader(req.URL)
+	if cookies == "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	if old := req.Header.Get("Cookie"); old != "" {
+		cookies = old + "; " + cookies
+	}
+	req.Header.Set("Cookie", cookies)
+	return req
+}
+
+func (c *Client) transport() *Transport {
+	if c.Transport != nil {
+		return c.Transport
+	}
+	return DefaultTransport
+}
+
+// Get issues a GET to url.
+func (c *Client) Get(url string) (*ClientResponse, error) {
+	req, err := NewRequest(MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// Post issues a POST to url with the given body and Content-Type.
+func (c *Client) Post(url, contentType string, body []byte) (*ClientResponse, error) {
+	req, err := NewRequest(MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return c.Do(req)
+}
+
+// Get issues a GET with DefaultClient.
+func Get(url string) (*ClientResponse, error) { return DefaultClient.Get(url) }
+
+// Post issues a POST with DefaultClient.
+func Post(url, contentType string, body []byte) (*ClientResponse, error) {
+	return DefaultClient.Post(url, contentType, body)
+}
+
+// Write writes r in wire format, as a client sends it. The Host header
+// and Content-Length are derived from URL and Body.
+func (r *Request) Write(w io.Writer) error {
+	return r.write(w, nil)
+}
+
+// write is Write with extra header fields added by the transport, which
+// must not leak into the caller's Request.
+func (r *Request) write(w io.Writer, extra Header) error {
+	target := r.Path
+	if r.URL != nil {
+		target = r.URL.RequestURI()
+	}
+	if target == "" {
+		target = "/"
+	}
+	proto := r.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+
+	host := r.Header.Get("Host")
+	if host == "" && r.URL != nil {
+		host = r.URL.Host
+	}
+	// a CR or LF in a field would let it end the header early, so check
+	// every field before writing any of the request
+	if !httpguts.ValidHostHeader(host) {
+		return fmt.Errorf("http: invalid Host header %q", host)
+	}
+	for _, h := range []Header{r.Header, extra} {
+		for key, values := range h {
+			if !httpguts.ValidHeaderFieldName(key) {
+				return fmt.Errorf("http: invalid header field name %q", key)
+			}
+			for _, v := range values {
+				if !httpguts.ValidHeaderFieldValue(v) {
+					return fmt.Errorf("http: invalid header field value for %q", key)
+				}
+			}
+		}
+	}
+
+	bw := newBufioWriter(w)
+	defer putBufioWriter(bw)
+	fmt.Fprintf(bw, "%s %s %s\r\n", r.Method, target, proto)
+	fmt.Fprintf(bw, "Host: %s\r\n", host)
+	for _, h := range []Header{r.Header, extra} {
+		for key, values := range h {
+			if key == "Host" || key == "Content-Length" {
+				continue
+			}
+			for _, v := range values {
+				fmt.Fprintf(bw, "%s: %s\r\n", key, v)
+			}
+		}
+	}
+	if len(r.Body) > 0 || r.Method == MethodPost || r.Method == MethodPut || r.Method == MethodPatch {
+		fmt.Fprintf(bw, "Content-Length: %d\r\n", len(r.Body))
+	}
+	bw.WriteString("\r\n")
+	bw.Write(r.Body)
+	if err := bw.Flush(); err != nil {
+		bw.Reset(nil)
+		return err
+	}
+	return nil
+}
+
+// ReadResponse reads a response to req from b. The returned Body reads
+// from b, the caller is responsible for the underlying connection.
+func ReadResponse(b *bufio.Reader, req *Request) (*ClientResponse, error) {
+	tp := textproto.NewReader(b)
+	line, err := tp.ReadLine()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	res := &ClientResponse{Request: req}
+	var ok bool
+	res.Proto, res.Status, ok = strings.Cut(line, " ")
+	if !ok || !strings.HasPrefix(res.Proto, "HTTP/") {
+		return nil, badStringErr("malformed HTTP response", line)
+	}
+	code, _, _ := strings.Cut(res.Status, " ")
+	if res.StatusCode, err = strconv.Atoi(code); err != nil || len(code) != 3 {
+		return nil, badStringErr("malformed HTTP status code", code)
+	}
+
+	mimeHeader, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	res.Header = Header(mimeHeader)
+
+	res.ContentLength = -1
+	if te := res.Header["Transfer-Encoding"]; len(te) > 0 {
+		res.TransferEncoding = te
+	}
+	chunked := isChunked(res.TransferEncoding)
+	if cl := res.Header.Get("Content-Length"); cl != "" && !chunked {
+		n, err := strconv.ParseInt(cl, 10, 64)
+		if err != nil || n < 0 {
+			return nil, badStringErr("bad Content-Length", cl)
+		}
+		res.ContentLength = n
+	}
+
+	switch {
+	case !responseHasBody(req, res.StatusCode):
+		res.ContentLength = 0
+		res.Body = noBody{}
+	case chunked:
+		// a sender using chunked must not rely on Content-Length
+		res.Header.Del("Content-Length")
+		res.Body = io.NopCloser(newChunkedReader(b))
+	case res.ContentLength >= 0:
+		res.Body = io.NopCloser(&lengthReader{r: b, n: res.ContentLength})
+	default:
+		res.Body = io.NopCloser(b)
+	}
+	return res, nil
+}
+
+// responseHasBody reports whether a response to req with the given status
+// can carry a body, per RFC 9112 section 6.3.
+func responseHasBody(req *Request, code int) bool {
+	if req != nil && req.Method == MethodHead {
+		return false
+	}
+	return !(code >= 100 && code < 200) && code != StatusNoContent && code != StatusNotModified
+}
+
+// lengthReader reads exactly n bytes, reporting a connection that closes
+// early as io.ErrUnexpectedEOF rather than a complete body.
+type lengthReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *lengthReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if err == io.EOF && l.n > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// cancelBody releases the Client.Timeout context once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+type noBody struct{}
+
+func (noBody) Read([]byte) (int, error) { return 0, io.EOF }
+func (noBody) Close() error             { return nil }
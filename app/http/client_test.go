@@ -0,0 +1,555 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var readResponseTest = []struct {
+	raw, method string
+	code        int
+	body        string
+}{
+	{"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhelloextra", MethodGet, 200, "hello"},
+	{"HTTP/1.1 404 Not Found\r\n\r\nuntil close", MethodGet, 404, "until close"},
+	{"HTTP/1.1 204 No Content\r\nContent-Length: 5\r\n\r\nhello", MethodGet, 204, ""},
+	{"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello", MethodHead, 200, ""},
+	{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6;ext=1\r\n world\r\n0\r\nX-Trailer: v\r\n\r\n", MethodGet, 200, "hello world"},
+	{"HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\nContent-Length: 2\r\n\r\n3\r\nabc\r\n0\r\n\r\n", MethodGet, 200, "abc"},
+}
+
+func TestReadResponse(t *testing.T) {
+	for i, tt := range readResponseTest {
+		req := &Request{Method: tt.method}
+		res, err := ReadResponse(bufio.NewReader(strings.NewReader(tt.raw)), req)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if res.StatusCode != tt.code {
+			t.Errorf("#%d: gotCode: %d wantCode: %d", i, res.StatusCode, tt.code)
+		}
+		body, _ := io.ReadAll(res.Body)
+		if string(body) != tt.body {
+			t.Errorf("#%d: gotBody: %q wantBody: %q", i, body, tt.body)
+		}
+	}
+}
+
+func TestReadResponseMalformed(t *testing.T) {
+	for i, raw := range []string{
+		"HTTP/1.1\r\n\r\n",
+		"HTTP/1.1 2000 OK\r\n\r\n",
+		"SPDY/3 200 OK\r\n\r\n",
+		"HTTP/1.1 200 OK\r\nContent-Length: -1\r\n\r\n",
+	} {
+		if _, err := ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil); err == nil {
+			t.Errorf("#%d: expected error for %q", i, raw)
+		}
+	}
+}
+
+var requestWriteInvalidTest = []struct {
+	key, value string
+}{
+	{"X-Evil", "a\r\nInjected: 1"},
+	{"X-Evil", "a\nb"},
+	{"X-Evil\r\nInjected", "1"},
+	{"X Evil", "1"},
+	{"", "1"},
+	{"Host", "example.com\r\nInjected: 1"},
+}
+
+func TestRequestWriteInvalidHeader(t *testing.T) {
+	for i, tt := range requestWriteInvalidTest {
+		req, _ := NewRequest(MethodGet, "http://example.com/", nil)
+		req.Header[tt.key] = []string{tt.value}
+		var b strings.Builder
+		if err := req.Write(&b); err == nil || b.Len() != 0 {
+			t.Errorf("#%d: %q: %q: gotErr: %v gotWritten: %q wantErr before writing", i, tt.key, tt.value, err, b.String())
+		}
+	}
+	req, _ := NewRequest(MethodGet, "http://example.com/", nil)
+	if err := req.write(io.Discard, Header{"X-Evil": {"a\r\nb"}}); err == nil {
+		t.Errorf("extra header: gotErr: nil wantErr: invalid header field value")
+	}
+}
+
+var redirectBehaviorTest = []struct {
+	method    string
+	code      int
+	newMethod string
+	keepBody  bool
+	ok        bool
+}{
+	{MethodPost, StatusMovedPermanently, MethodGet, false, true},
+	{MethodPost, StatusSeeOther, MethodGet, false, true},
+	{MethodHead, StatusSeeOther, MethodHead, false, true},
+	{MethodPut, StatusTemporaryRedirect, MethodPut, true, true},
+	{MethodPost, StatusPermanentRedirect, MethodPost, true, true},
+	{MethodGet, StatusNotModified, "", false, false},
+	{MethodGet, StatusOK, "", false, false},
+}
+
+func TestRedirectBehavior(t *testing.T) {
+	for i, tt := range redirectBehaviorTest {
+		method, keepBody, ok := redirectBehavior(tt.method, tt.code)
+		if method != tt.newMethod || keepBody != tt.keepBody || ok != tt.ok {
+			t.Errorf("#%d: got (%q, %t, %t) want (%q, %t, %t)", i, method, keepBody, ok, tt.newMethod, tt.keepBody, tt.ok)
+		}
+	}
+}
+
+var redirectCredentialsTest = []struct {
+	from, location string
+	kept           bool
+}{
+	{"https://example.com/a", "/b", true},
+	{"https://example.com/a", "https://EXAMPLE.com:443/b", true},
+	{"http://example.com/a", "http://example.com:80/b", true},
+	{"https://example.com/a", "http://example.com/b", false}, // downgrade
+	{"http://example.com/a", "https://example.com/b", false},
+	{"https://example.com/a", "https://example.com:8443/b", false},
+	{"http://example.com:8080/a", "http://example.com:8081/b", false},
+	{"https://example.com/a", "https://api.example.com/b", false},
+}
+
+func TestRedirectCredentials(t *testing.T) {
+	c := &Client{}
+	for i, tt := range redirectCredentialsTest {
+		req, _ := NewRequest(MethodGet, tt.from, nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Cookie", "session=1")
+		res := &ClientResponse{StatusCode: StatusFound, Header: Header{"Location": {tt.location}}}
+		next, err := c.redirect(req, res, nil)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		for _, h := range []string{"Authorization", "Cookie"} {
+			if kept := next.Header.Get(h) != ""; kept != tt.kept {
+				t.Errorf("#%d: %s -> %s got%sKept: %t want: %t", i, tt.from, tt.location, h, kept, tt.kept)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		v    string
+		want time.Duration
+		ok   bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"Mon, 01 Jan 2024 00:00:30 GMT", 30 * time.Second, true},
+		{"Sun, 31 Dec 2023 23:59:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for i, tt := range tests {
+		got, ok := retryAfter(tt.v, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("#%d: got (%v, %t) want (%v, %t)", i, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestReplayBody(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	mux := NewServeMux()
+	mux.HandleFunc("/redirect", func(w ResponseWriter, r *Request) {
+		w.SetHeader("Location", "/echo")
+		w.SetStatus(StatusTemporaryRedirect, StatusText(StatusTemporaryRedirect))
+		w.Write()
+	})
+	mux.HandleFunc("/retry", func(w ResponseWriter, r *Request) {
+		if attempts++; attempts == 1 {
+			w.SetStatus(StatusServiceUnavailable, StatusText(StatusServiceUnavailable))
+			w.Write()
+			return
+		}
+		w.SetBody(r.Body)
+		w.Write()
+	})
+	mux.HandleFunc("/echo", func(w ResponseWriter, r *Request) {
+		w.SetBody(r.Body)
+		w.Write()
+	})
+	s := &Server{Handler: mux}
+	go s.Serve(ln)
+	defer func() {
+		// the client keeps its connections open; close them right away
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.Shutdown(ctx)
+	}()
+
+	c := &Client{Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+	for _, path := range []string{"/redirect", "/retry"} {
+		req, _ := NewRequest(MethodPost, "http://"+ln.Addr().String()+path, strings.NewReader("payload"))
+		req.Header.Set("Idempotency-Key", "k")
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != StatusOK || string(body) != "payload" {
+			t.Errorf("%s: gotCode: %d gotBody: %q wantBody: %q", path, res.StatusCode, body, "payload")
+		}
+	}
+}
+
+var cookieJarTests = []struct {
+	set, setURL string // a Set-Cookie value and the URL that set it
+	getURL      string
+	want        string
+}{
+	{"a=1", "http://example.com/", "http://example.com/x", "a=1"},
+	{"a=1", "http://example.com/", "http://www.example.com/", ""}, // host-only
+	{"a=1; Domain=.example.com", "http://www.example.com/", "http://api.example.com/", "a=1"},
+	{"a=1; Domain=example.com", "http://example.com/", "http://www.example.com/", "a=1"},
+	{"a=1; Domain=localhost", "http://localhost/", "http://localhost/", "a=1"},
+	{"a=1; Domain=127.0.0.1", "http://127.0.0.1/", "http://127.0.0.1/", "a=1"},
+	{"a=1; Domain=other.com", "http://example.com/", "http://other.com/", ""},
+	{"a=1; Domain=com", "http://example.com/", "http://other.com/", ""},
+	{"a=1; Path=/app", "http://example.com/", "http://example.com/app/x", "a=1"},
+	{"a=1; Path=/app", "http://example.com/", "http://example.com/apple", ""},
+	{"a=1", "http://example.com/app/login", "http://example.com/app", "a=1"},
+	{"a=1", "http://example.com/app/login", "http://example.com/", ""},
+	{"a=1; Max-Age=0", "http://example.com/", "http://example.com/", ""},
+	{"a=1; Expires=Thu, 01 Jan 1970 00:00:00 GMT", "http://example.com/", "http://example.com/", ""},
+	{"a=1; Max-Age=60", "http://example.com/", "http://example.com/", "a=1"},
+	{"a=1; Secure", "https://example.com/", "https://example.com/", "a=1"},
+	{"a=1; Secure", "https://example.com/", "http://example.com/", ""},
+	{"a=1; Secure", "http://example.com/", "https://example.com/", ""},
+	{"=1", "http://example.com/", "http://example.com/", ""},
+}
+
+func TestCookieJar(t *testing.T) {
+	for i, tt := range cookieJarTests {
+		jar := &CookieJar{}
+		setURL, _ := url.Parse(tt.setURL)
+		getURL, _ := url.Parse(tt.getURL)
+		jar.SetCookies(setURL, []string{tt.set})
+		if got := jar.cookieHeader(getURL); got != tt.want {
+			t.Errorf("#%d: gotCookie: %q wantCookie: %q", i, got, tt.want)
+		}
+	}
+
+	// longer paths first, and a later Max-Age=0 deletes
+	jar := &CookieJar{}
+	u, _ := url.Parse("http://example.com/app/page")
+	jar.SetCookies(u, []string{"root=1; Path=/", "app=2; Path=/app", "gone=3"})
+	jar.SetCookies(u, []string{"gone=; Max-Age=0"})
+	if got, want := jar.cookieHeader(u), "app=2; root=1"; got != want {
+		t.Errorf("gotCookie: %q wantCookie: %q", got, want)
+	}
+}
+
+func TestClientJar(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := NewServeMux()
+	mux.HandleFunc("/login", func(w ResponseWriter, r *Request) {
+		w.SetHeader("Set-Cookie", "session=s3cret; Path=/; HttpOnly")
+		w.SetHeader("Location", "/me")
+		w.SetStatus(StatusFound, StatusText(StatusFound))
+		w.Write()
+	})
+	mux.HandleFunc("/me", func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(r.Header.Get("Cookie")))
+		w.Write()
+	})
+	s := &Server{Handler: mux}
+	go s.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.Shutdown(ctx)
+	}()
+
+	c := &Client{Jar: &CookieJar{}}
+	get := func(path, cookie string) string {
+		t.Helper()
+		req, _ := NewRequest(MethodGet, "http://"+ln.Addr().String()+path, nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", cookie)
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	if got, want := get("/login", ""), "session=s3cret"; got != want {
+		t.Errorf("after redirect: gotCookie: %q wantCookie: %q", got, want)
+	}
+	if got, want := get("/me", "theme=dark"), "theme=dark; session=s3cret"; got != want {
+		t.Errorf("later request: gotCookie: %q wantCookie: %q", got, want)
+	}
+}
+
+// countingResolver answers lookups for "known" and counts them.
+type countingResolver struct{ lookups int }
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lookups++
+	if host != "known" {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return []string{"127.0.0.1"}, nil
+}
+
+func TestCachingResolver(t *testing.T) {
+	counter := &countingResolver{}
+	r := &CachingResolver{Resolver: counter, Hosts: map[string][]string{"pinned": {"10.0.0.1"}}}
+	ctx := context.Background()
+	for range 3 {
+		if addrs, err := r.LookupHost(ctx, "known"); err != nil || addrs[0] != "127.0.0.1" {
+			t.Fatalf("known: got (%v, %v)", addrs, err)
+		}
+		if _, err := r.LookupHost(ctx, "missing"); err == nil {
+			t.Fatal("missing: got nil error")
+		}
+		if addrs, _ := r.LookupHost(ctx, "Pinned."); addrs[0] != "10.0.0.1" {
+			t.Fatalf("pinned: got %v", addrs)
+		}
+	}
+	if counter.lookups != 2 {
+		t.Errorf("gotLookups: %d wantLookups: %d", counter.lookups, 2)
+	}
+	r.Flush()
+	r.LookupHost(ctx, "known")
+	if counter.lookups != 3 {
+		t.Errorf("after Flush: gotLookups: %d wantLookups: %d", counter.lookups, 3)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(r.Header.Get("Host")))
+		w.Write()
+	})}
+	go s.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.Shutdown(ctx)
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	c := &Client{Transport: &Transport{Resolver: &CachingResolver{
+		Hosts: map[string][]string{"service.test": {"127.0.0.1"}},
+	}}}
+	res, err := c.Get("http://service.test:" + port + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if want := "service.test:" + port; string(body) != want {
+		t.Errorf("gotHost: %q wantHost: %q", body, want)
+	}
+}
+
+var interleaveAddrsTests = []struct {
+	in, want string
+}{
+	{"10.0.0.1 10.0.0.2 ::1 ::2", "::1 10.0.0.1 ::2 10.0.0.2"},
+	{"::1 ::2 ::3 10.0.0.1", "::1 10.0.0.1 ::2 ::3"},
+	{"10.0.0.1 bogus", "10.0.0.1"},
+}
+
+// staticResolver answers every lookup with its addresses.
+type staticResolver []string
+
+func (r staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return r, nil
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	for i, tt := range interleaveAddrsTests {
+		if got := strings.Join(interleaveAddrs(strings.Fields(tt.in)), " "); got != tt.want {
+			t.Errorf("#%d: gotOrder: %q wantOrder: %q", i, got, tt.want)
+		}
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	// the documentation-only IPv6 address either fails outright or hangs;
+	// either way the IPv4 address must win without waiting it out
+	tr := &Transport{Resolver: staticResolver{"127.0.0.1", "2001:db8::1"}, FallbackDelay: 20 * time.Millisecond}
+	u, _ := url.Parse("http://dual.test:" + port)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	conn, err := tr.dialTCP(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if got := conn.RemoteAddr().String(); got != ln.Addr().String() {
+		t.Errorf("gotAddr: %s wantAddr: %s", got, ln.Addr())
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dial took %v", elapsed)
+	}
+}
+
+// TestStaleConnRetry serves one request per connection and then closes
+// the next one it reads without answering, as a server timing out an idle
+// connection just as the client reuses it does.
+func TestStaleConnRetry(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	var mu sync.Mutex
+	var seen []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for i := 0; ; i++ {
+					req, err := ReadRequest(br)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					seen = append(seen, req.Method+" "+req.Path)
+					mu.Unlock()
+					if i > 0 {
+						return
+					}
+					io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+				}
+			}()
+		}
+	}()
+
+	tr := &Transport{}
+	base := "http://" + ln.Addr().String()
+	send := func(method, path string) error {
+		req, _ := NewRequest(method, base+path, strings.NewReader("body"))
+		res, err := tr.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		io.ReadAll(res.Body)
+		return res.Body.Close()
+	}
+	for i, tt := range []struct {
+		method, path string
+		ok           bool
+	}{
+		{MethodGet, "/a", true},   // dials
+		{MethodPost, "/b", false}, // reuses the connection, which dies
+		{MethodGet, "/c", true},   // dials
+		{MethodGet, "/d", true},   // reuses the connection, dies, dials again
+	} {
+		if err := send(tt.method, tt.path); (err == nil) != tt.ok {
+			t.Errorf("#%d: %s %s gotErr: %v", i, tt.method, tt.path, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"GET /a", "POST /b", "GET /c", "GET /d", "GET /d"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("gotRequests: %q wantRequests: %q", seen, want)
+	}
+}
+
+func TestClientMiddleware(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(r.Header.Get("Authorization")))
+		w.Write()
+	})}
+	go s.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.Shutdown(ctx)
+	}()
+
+	var calls []string
+	logging := func(next RoundTripper) RoundTripper {
+		return RoundTripperFunc(func(req *Request) (*ClientResponse, error) {
+			calls = append(calls, "log "+req.Header.Get("Authorization"))
+			res, err := next.RoundTrip(req)
+			if err == nil {
+				calls = append(calls, "logged "+res.Status)
+			}
+			return res, err
+		})
+	}
+	auth := func(next RoundTripper) RoundTripper {
+		return RoundTripperFunc(func(req *Request) (*ClientResponse, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer t")
+			calls = append(calls, "auth")
+			return next.RoundTrip(req)
+		})
+	}
+	c := &Client{Middleware: []ClientMiddleware{logging, auth}}
+	req, _ := NewRequest(MethodGet, "http://"+ln.Addr().String()+"/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "Bearer t" {
+		t.Errorf("gotAuthorization: %q wantAuthorization: %q", body, "Bearer t")
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("middleware changed the caller's request")
+	}
+	if got, want := strings.Join(calls, ", "), "log , auth, logged 200 OK"; got != want {
+		t.Errorf("gotCalls: %q wantCalls: %q", got, want)
+	}
+}
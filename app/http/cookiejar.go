@@ -0,0 +1,228 @@
+package http
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie is an HTTP cookie as set by a Set-Cookie response header.
+type Cookie struct {
+	Name  string
+	Value string
+
+	Domain   string    // the Domain attribute, without a leading dot
+	Path     string    // the Path attribute
+	Expires  time.Time // from Max-Age or Expires; zero for a session cookie
+	MaxAge   int       // the Max-Age attribute; negative when it is 0 or less, 0 when absent
+	Secure   bool
+	HttpOnly bool
+}
+
+// String formats c for a Cookie request header.
+func (c Cookie) String() string {
+	return c.Name + "=" + c.Value
+}
+
+// setCookieDateFormats are the Expires formats seen in practice, besides
+// TimeFormat.
+var setCookieDateFormats = []string{TimeFormat, "Mon, 02-Jan-2006 15:04:05 GMT", time.RFC850, time.ANSIC}
+
+// ParseSetCookie parses the value of a Set-Cookie header. Unknown
+// attributes are ignored; an invalid Expires is treated as absent.
+func ParseSetCookie(line string) (Cookie, error) {
+	parts := strings.Split(line, ";")
+	name, value, ok := strings.Cut(parts[0], "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || !validHeaderKey([]byte(name)) {
+		return Cookie{}, errors.New("http: invalid Set-Cookie " + strconv.Quote(line))
+	}
+	c := Cookie{Name: name, Value: strings.Trim(strings.TrimSpace(value), `"`)}
+	for _, attr := range parts[1:] {
+		key, val, _ := strings.Cut(strings.TrimSpace(attr), "=")
+		val = strings.TrimSpace(val)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "domain":
+			c.Domain = strings.ToLower(strings.TrimPrefix(val, "."))
+		case "path":
+			if strings.HasPrefix(val, "/") {
+				c.Path = val
+			}
+		case "max-age":
+			secs, err := strconv.Atoi(val)
+			if err != nil {
+				continue
+			}
+			if secs <= 0 {
+				c.MaxAge = -1
+			} else {
+				c.MaxAge = secs
+			}
+		case "expires":
+			for _, layout := range setCookieDateFormats {
+				if t, err := time.Parse(layout, val); err == nil {
+					c.Expires = t.UTC()
+					break
+				}
+			}
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HttpOnly = true
+		}
+	}
+	return c, nil
+}
+
+// CookieJar stores the cookies servers set and returns those to send
+// with later requests, following the storage model of RFC 6265 section 5:
+// domain and path matching, expiry by Max-Age or Expires, and Secure
+// cookies sent only over HTTPS and set only by HTTPS responses. There is
+// no public suffix list, so only a Domain with at least one dot is
+// accepted. Set it as Client.Jar; the zero value is an empty jar.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies map[string]jarCookie // keyed by domain, path and name
+	seq     uint64               // creation order, for sorting
+}
+
+type jarCookie struct {
+	Cookie
+	hostOnly bool
+	seq      uint64
+}
+
+// SetCookies stores the cookies of the Set-Cookie header values lines,
+// received in reply to a request for u. Invalid or foreign cookies are
+// ignored.
+func (j *CookieJar) SetCookies(u *url.URL, lines []string) {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, line := range lines {
+		c, err := ParseSetCookie(line)
+		if err != nil {
+			continue
+		}
+		if c.Secure && u.Scheme != "https" {
+			continue
+		}
+		// per RFC 6265 section 5.3 a Domain attribute, even one naming the
+		// host itself, also sends the cookie to subdomains; only a dotless
+		// or IP host, which has none, keeps it host-only
+		jc := jarCookie{Cookie: c}
+		switch {
+		case c.Domain == "", c.Domain == host && (!strings.Contains(host, ".") || net.ParseIP(host) != nil):
+			jc.hostOnly = true
+			jc.Domain = host
+		case !strings.Contains(c.Domain, ".") || net.ParseIP(host) != nil || !domainMatch(host, c.Domain):
+			continue
+		}
+		if jc.Path == "" {
+			jc.Path = defaultCookiePath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			jc.Expires = now
+		case c.MaxAge > 0:
+			jc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		key := jc.Domain + ";" + jc.Path + ";" + jc.Name
+		if !jc.Expires.IsZero() && !jc.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if old, ok := j.cookies[key]; ok {
+			jc.seq = old.seq
+		} else {
+			j.seq++
+			jc.seq = j.seq
+		}
+		if j.cookies == nil {
+			j.cookies = make(map[string]jarCookie)
+		}
+		j.cookies[key] = jc
+	}
+}
+
+// Cookies returns the cookies to send with a request for u, those with
+// longer paths first and otherwise oldest first, as RFC 6265 section
+// 5.4 recommends. Expired cookies are dropped.
+func (j *CookieJar) Cookies(u *url.URL) []Cookie {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+	j.mu.Lock()
+	var matched []jarCookie
+	for key, c := range j.cookies {
+		if !c.Expires.IsZero() && !c.Expires.After(now) {
+			delete(j.cookies, key)
+			continue
+		}
+		if c.hostOnly && host != c.Domain || !c.hostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(path, c.Path) || c.Secure && u.Scheme != "https" {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	j.mu.Unlock()
+
+	slices.SortFunc(matched, func(a, b jarCookie) int {
+		if len(a.Path) != len(b.Path) {
+			return len(b.Path) - len(a.Path)
+		}
+		return int(a.seq) - int(b.seq)
+	})
+	cookies := make([]Cookie, len(matched))
+	for i, c := range matched {
+		cookies[i] = c.Cookie
+	}
+	return cookies
+}
+
+// cookieHeader returns the Cookie header value for a request for u, or ""
+// when j has nothing to send.
+func (j *CookieJar) cookieHeader(u *url.URL) string {
+	cookies := j.Cookies(u)
+	pairs := make([]string, len(cookies))
+	for i, c := range cookies {
+		pairs[i] = c.String()
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// domainMatch reports whether host is domain or a subdomain of it.
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether the request path falls under the cookie path,
+// per RFC 6265 section 5.1.4.
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath is the path of a cookie set without one: the request
+// path up to, but not including, its last slash.
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
@@ -0,0 +1,281 @@
+package http
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// NewDebugServeMux returns a mux exposing runtime profiles under
+// /debug/pprof/ and published expvars under /debug/vars. It is opt-in and
+// should only be reachable from trusted networks; see ListenAndServeDebug.
+func NewDebugServeMux() *ServeMux {
+	mux := NewServeMux()
+	mux.HandleFunc("/debug/vars", debugVars)
+	mux.HandleFunc("/debug/pprof/", debugPprofIndex)
+	mux.HandleFunc("/debug/pprof/cmdline", debugCmdline)
+	mux.HandleFunc("/debug/pprof/profile", debugCPUProfile)
+	mux.HandleFunc("/debug/pprof/trace", debugTrace)
+	return mux
+}
+
+// ListenAndServeDebug serves NewDebugServeMux on addr, which must resolve
+// to a loopback address so profiles are never exposed publicly by accident.
+// When srv is non-nil its configuration is also served at /debug/routes.
+func ListenAndServeDebug(addr string, srv *Server) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return fmt.Errorf("http: debug listener must bind a loopback address, got %q", addr)
+		}
+	}
+	mux := NewDebugServeMux()
+	if srv != nil {
+		mux.Handle("/debug/routes", RoutesHandler(srv))
+	}
+	s := &Server{Addr: addr, Handler: mux, Logger: srv.loggerIfSet()}
+	return s.ListenAndServe()
+}
+
+func (s *Server) loggerIfSet() *slog.Logger {
+	if s == nil {
+		return nil
+	}
+	return s.Logger
+}
+
+// RoutesHandler renders srv's configuration and, when its Handler is a
+// *ServeMux, the live route table as plain text, or as JSON for clients
+// accepting it. Each route lists the middleware wrapping its handler,
+// outermost first, the time limit a TimeoutHandler among them sets, and
+// for a FilesHandler the directory mounted.
+func RoutesHandler(srv *Server) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		var info routesInfo
+		info.Server.Addr = srv.Addr
+		info.Server.Handler = handlerName(srv.Handler)
+		info.Server.Tracer = srv.Tracer != nil
+		info.Server.ReadTimeout = srv.ReadTimeout.String()
+		info.Server.IdleTimeout = srv.idleTimeout().String()
+		info.Server.MaxBodySize = srv.maxBodySize()
+
+		var b bytes.Buffer
+		tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "server:")
+		fmt.Fprintf(tw, "  addr\t%s\n", info.Server.Addr)
+		fmt.Fprintf(tw, "  handler\t%s\n", info.Server.Handler)
+		fmt.Fprintf(tw, "  tracer\t%t\n", info.Server.Tracer)
+		fmt.Fprintf(tw, "  read timeout\t%s\n", info.Server.ReadTimeout)
+		fmt.Fprintf(tw, "  idle timeout\t%s\n", info.Server.IdleTimeout)
+		fmt.Fprintf(tw, "  max body size\t%d\n", info.Server.MaxBodySize)
+
+		if mux, ok := srv.Handler.(*ServeMux); ok || srv.Handler == nil {
+			if mux == nil {
+				mux = DefaultServeMux
+			}
+			fmt.Fprintln(tw, "\nroutes:")
+			fmt.Fprintln(tw, "  pattern\tname\tkind\thandler\tmiddleware\ttimeout\tdir")
+			for _, rt := range mux.Routes() {
+				ri := routeInfo{Pattern: rt.Pattern, Name: rt.Name, Kind: "exact"}
+				if rt.Prefix {
+					ri.Kind = "prefix"
+				}
+				chain, timeout, end := unwrapHandler(rt.Handler)
+				ri.Handler = handlerName(end)
+				ri.Middleware = chain
+				if timeout > 0 {
+					ri.Timeout = timeout.String()
+				}
+				if fh, ok := end.(*FilesHandler); ok {
+					ri.Dir = fh.Root
+				}
+				info.Routes = append(info.Routes, ri)
+				fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", ri.Pattern, orDash(ri.Name), ri.Kind, ri.Handler,
+					orDash(strings.Join(chain, " > ")), orDash(ri.Timeout), orDash(ri.Dir))
+			}
+		}
+		tw.Flush()
+
+		WriteTextOrJSON(w, r, StatusOK, b.String(), info)
+	})
+}
+
+// routesInfo is the JSON form of RoutesHandler's report.
+type routesInfo struct {
+	Server struct {
+		Addr        string `json:"addr"`
+		Handler     string `json:"handler"`
+		Tracer      bool   `json:"tracer"`
+		ReadTimeout string `json:"read_timeout"`
+		IdleTimeout string `json:"idle_timeout"`
+		MaxBodySize int64  `json:"max_body_size"`
+	} `json:"server"`
+	Routes []routeInfo `json:"routes,omitempty"`
+}
+
+type routeInfo struct {
+	Pattern    string   `json:"pattern"`
+	Name       string   `json:"name"`
+	Kind       string   `json:"kind"`
+	Handler    string   `json:"handler"`
+	Middleware []string `json:"middleware,omitempty"` // outermost first
+	Timeout    string   `json:"timeout,omitempty"`
+	Dir        string   `json:"dir,omitempty"` // the directory a FilesHandler serves
+}
+
+// orDash fills an empty column of RoutesHandler's table.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// handlerName names h by its function for HandlerFuncs, and by its type
+// otherwise.
+func handlerName(h Handler) string {
+	switch f := h.(type) {
+	case nil:
+		return "DefaultServeMux"
+	case HandlerFunc:
+		if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", h)
+}
+
+// splitQuery separates the raw request target into path and parsed query.
+func splitQuery(target string) (string, url.Values) {
+	path, rawQuery, _ := strings.Cut(target, "?")
+	q, _ := url.ParseQuery(rawQuery)
+	return path, q
+}
+
+func debugVars(w ResponseWriter, r *Request) {
+	var b bytes.Buffer
+	b.WriteString("{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if !first {
+			b.WriteString(",\n")
+		}
+		first = false
+		fmt.Fprintf(&b, "%q: %s", kv.Key, kv.Value)
+	})
+	b.WriteString("\n}\n")
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "application/json; charset=utf-8")
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+// debugPprofIndex lists the available profiles, or writes the named one
+// for /debug/pprof/<name>.
+func debugPprofIndex(w ResponseWriter, r *Request) {
+	path, q := splitQuery(r.Path)
+	name := strings.TrimPrefix(path, "/debug/pprof/")
+	if name == "" {
+		var b bytes.Buffer
+		b.WriteString("profiles:\n")
+		for _, p := range pprof.Profiles() {
+			fmt.Fprintf(&b, "%d\t%s\n", p.Count(), p.Name())
+		}
+		b.WriteString("\nprofile?seconds=N\ntrace?seconds=N\ncmdline\n")
+		w.SetStatus(StatusOK, StatusText(StatusOK))
+		w.SetBody(b.Bytes())
+		w.Write()
+		return
+	}
+
+	p := pprof.Lookup(name)
+	if p == nil {
+		debugError(w, StatusNotFound, "unknown profile: "+name)
+		return
+	}
+	debug, _ := strconv.Atoi(q.Get("debug"))
+	var b bytes.Buffer
+	if err := p.WriteTo(&b, debug); err != nil {
+		debugError(w, StatusInternalServerError, err.Error())
+		return
+	}
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	if debug == 0 {
+		w.SetHeader("Content-Type", "application/octet-stream")
+		w.SetHeader("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	}
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+func debugCmdline(w ResponseWriter, r *Request) {
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetBody([]byte(strings.Join(os.Args, "\x00")))
+	w.Write()
+}
+
+func debugCPUProfile(w ResponseWriter, r *Request) {
+	var b bytes.Buffer
+	if err := pprof.StartCPUProfile(&b); err != nil {
+		// only one CPU profile may run at a time
+		debugError(w, StatusInternalServerError, err.Error())
+		return
+	}
+	sleepSeconds(r, 30)
+	pprof.StopCPUProfile()
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "application/octet-stream")
+	w.SetHeader("Content-Disposition", `attachment; filename="profile"`)
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+func debugTrace(w ResponseWriter, r *Request) {
+	var b bytes.Buffer
+	if err := trace.Start(&b); err != nil {
+		debugError(w, StatusInternalServerError, err.Error())
+		return
+	}
+	sleepSeconds(r, 1)
+	trace.Stop()
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "application/octet-stream")
+	w.SetHeader("Content-Disposition", `attachment; filename="trace"`)
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+// sleepSeconds waits for the ?seconds= duration, or def seconds, unless the
+// request is cancelled first.
+func sleepSeconds(r *Request, def int) {
+	_, q := splitQuery(r.Path)
+	sec, err := strconv.Atoi(q.Get("seconds"))
+	if err != nil || sec <= 0 {
+		sec = def
+	}
+	select {
+	case <-time.After(time.Duration(sec) * time.Second):
+	case <-r.Context().Done():
+	}
+}
+
+func debugError(w ResponseWriter, code int, msg string) {
+	w.SetStatus(code, StatusText(code))
+	w.SetBody([]byte(msg))
+	w.Write()
+}
@@ -0,0 +1,132 @@
+package http_test
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+var debugServeMuxTest = []struct {
+	target      string
+	code        int
+	contentType string
+	body        string
+}{
+	{"/debug/vars", 200, "application/json; charset=utf-8", `"memstats": {`},
+	{"/debug/pprof/", 200, "", "goroutine\n"},
+	{"/debug/pprof/goroutine?debug=1", 200, "", "goroutine profile: total"},
+	{"/debug/pprof/goroutine", 200, "application/octet-stream", ""},
+	{"/debug/pprof/nope", 404, "", "unknown profile: nope"},
+	{"/debug/pprof/cmdline", 200, "", os.Args[0]},
+}
+
+func TestDebugServeMux(t *testing.T) {
+	mux := http.NewDebugServeMux()
+	for i, tt := range debugServeMuxTest {
+		req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		if rec.Code != tt.code || rec.HeaderMap["Content-Type"] != tt.contentType || !strings.Contains(string(rec.Body), tt.body) {
+			t.Errorf("#%d: %s gotCode: %d gotContentType: %q gotBody: %.200q wantCode: %d wantContentType: %q wantBody: %q",
+				i, tt.target, rec.Code, rec.HeaderMap["Content-Type"], rec.Body, tt.code, tt.contentType, tt.body)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/debug/vars", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if !json.Valid(rec.Body) {
+		t.Errorf("/debug/vars is not JSON: %.200q", rec.Body)
+	}
+}
+
+var listenAndServeDebugTest = []struct {
+	addr string
+	err  string
+}{
+	{":6060", "must bind a loopback address"},
+	{"0.0.0.0:6060", "must bind a loopback address"},
+	{"[::]:6060", "must bind a loopback address"},
+	{"192.0.2.1:6060", "must bind a loopback address"},
+	{"example.com:6060", "must bind a loopback address"},
+	{"127.0.0.1", "missing port"},
+}
+
+func TestListenAndServeDebugLoopbackOnly(t *testing.T) {
+	for i, tt := range listenAndServeDebugTest {
+		if err := http.ListenAndServeDebug(tt.addr, nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("#%d: %s gotErr: %v wantErr: %q", i, tt.addr, err, tt.err)
+		}
+	}
+}
+
+func TestRoutesHandler(t *testing.T) {
+	dir := t.TempDir()
+	mux := http.NewServeMux()
+	signer := &http.SignedURLs{Key: []byte("0123456789abcdef")}
+	mux.HandleNamed("/files/{name}", "/files/", signer.Handler(http.TimeoutHandler(http.NewFilesHandler("/files/", dir), 5*time.Second, "")))
+	mux.Handle("/echo/", http.ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write() })))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) { w.Write() })
+	srv := &http.Server{Addr: ":4221", Handler: mux, ReadTimeout: 10 * time.Second}
+	h := http.RoutesHandler(srv)
+
+	req, _ := http.NewRequest(http.MethodGet, "/debug/routes", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var info struct {
+		Server struct {
+			Addr        string `json:"addr"`
+			ReadTimeout string `json:"read_timeout"`
+			IdleTimeout string `json:"idle_timeout"`
+		} `json:"server"`
+		Routes []struct {
+			Pattern    string   `json:"pattern"`
+			Name       string   `json:"name"`
+			Kind       string   `json:"kind"`
+			Handler    string   `json:"handler"`
+			Middleware []string `json:"middleware"`
+			Timeout    string   `json:"timeout"`
+			Dir        string   `json:"dir"`
+		} `json:"routes"`
+	}
+	if err := json.Unmarshal(rec.Body, &info); err != nil {
+		t.Fatalf("unexpected error: %v in %s", err, rec.Body)
+	}
+	if info.Server.Addr != ":4221" || info.Server.ReadTimeout != "10s" || info.Server.IdleTimeout != "10s" {
+		t.Errorf("gotServer: %+v", info.Server)
+	}
+	if len(info.Routes) != 3 {
+		t.Fatalf("gotRoutes: %+v wantRoutes: 3", info.Routes)
+	}
+	if rt := info.Routes[0]; rt.Pattern != "/" || rt.Kind != "exact" || len(rt.Middleware) != 0 || rt.Timeout != "" || rt.Dir != "" {
+		t.Errorf("gotRoute: %+v", rt)
+	}
+	if rt := info.Routes[1]; rt.Pattern != "/echo/" || rt.Kind != "prefix" || strings.Join(rt.Middleware, ",") != "ETag" || !strings.Contains(rt.Handler, "TestRoutesHandler") {
+		t.Errorf("gotRoute: %+v", rt)
+	}
+	rt := info.Routes[2]
+	if rt.Pattern != "/files/" || rt.Name != "/files/{name}" || rt.Handler != "*http.FilesHandler" || rt.Dir != dir ||
+		strings.Join(rt.Middleware, ",") != "SignedURLs,TimeoutHandler" || rt.Timeout != "5s" {
+		t.Errorf("gotRoute: %+v", rt)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/debug/routes", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var files []string
+	for line := range strings.Lines(string(rec.Body)) {
+		if f := strings.Fields(line); len(f) > 0 && f[0] == "/files/" {
+			files = f
+		}
+	}
+	if want := []string{"/files/", "/files/{name}", "prefix", "*http.FilesHandler", "SignedURLs", ">", "TimeoutHandler", "5s", dir}; !slices.Equal(files, want) {
+		t.Errorf("gotLine: %q wantLine: %q in %s", files, want, rec.Body)
+	}
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+)
+
+// DefaultFallbackDelay is used when Transport.FallbackDelay is zero; it
+// is the connection attempt delay RFC 8305 recommends.
+const DefaultFallbackDelay = 250 * time.Millisecond
+
+// dialTCP connects to u's host. Names are looked up through Resolver, or
+// the OS resolver, and their addresses raced as described by RFC 8305
+// ("Happy Eyeballs"), so an unreachable IPv6 route costs a fraction of a
+// second instead of a full connect timeout.
+func (t *Transport) dialTCP(ctx context.Context, u *url.URL) (net.Conn, error) {
+	addr := hostPort(u)
+	host, port, _ := net.SplitHostPort(addr)
+	if net.ParseIP(host) != nil {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	var resolver Resolver = net.DefaultResolver
+	if t.Resolver != nil {
+		resolver = t.Resolver
+	}
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs = interleaveAddrs(addrs)
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+	return t.raceDial(ctx, addrs, port)
+}
+
+// raceDial dials addrs in order, starting the next attempt whenever the
+// previous one fails or FallbackDelay passes, and returns the first
+// connection made. The attempts still in flight are abandoned.
+func (t *Transport) raceDial(ctx context.Context, addrs []string, port string) (net.Conn, error) {
+	delay := t.FallbackDelay
+	if delay == 0 {
+		delay = DefaultFallbackDelay
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	var d net.Dialer
+	next, pending := 0, 0
+	var timer *time.Timer
+	var fallback <-chan time.Time // nil when attempts run one at a time
+	start := func() {
+		addr := net.JoinHostPort(addrs[next], port)
+		next++
+		pending++
+		go func() {
+			conn, err := d.DialContext(ctx, "tcp", addr)
+			results <- result{conn, err}
+		}()
+		if delay > 0 && next < len(addrs) {
+			if timer == nil {
+				timer = time.NewTimer(delay)
+				fallback = timer.C
+			} else {
+				timer.Reset(delay)
+			}
+		}
+	}
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	start()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// attempts that connect after all are closed, not leaked
+				go func(n int) {
+					for range n {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) && ctx.Err() == nil {
+				start()
+			}
+		case <-fallback:
+			if next < len(addrs) {
+				start()
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// interleaveAddrs orders addrs as RFC 8305 section 4 describes: IPv6 and
+// IPv4 addresses alternate, IPv6 first, each family keeping the order the
+// resolver gave. Entries that aren't IP addresses are dropped.
+func interleaveAddrs(addrs []string) []string {
+	var v6, v4 []string
+	for _, a := range addrs {
+		ip := net.ParseIP(a)
+		switch {
+		case ip == nil:
+		case ip.To4() != nil:
+			v4 = append(v4, a)
+		default:
+			v6 = append(v6, a)
+		}
+	}
+	out := make([]string, 0, len(v6)+len(v4))
+	for i := range max(len(v6), len(v4)) {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i])
+		}
+	}
+	return out
+}
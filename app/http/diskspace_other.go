@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package http
+
+// freeSpace reports that free space is unknown on this system.
+func freeSpace(dir string) (int64, bool) {
+	return 0, false
+}
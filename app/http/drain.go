@@ -0,0 +1,117 @@
+package http
+
+import (
+	"net"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDrainReportInterval is used when Server.DrainReportInterval is
+// zero.
+const defaultDrainReportInterval = 5 * time.Second
+
+// maxDrainReportRequests caps how many in-flight requests one drain report
+// logs individually, oldest first.
+const maxDrainReportRequests = 10
+
+// InFlight describes a request whose handler is still running.
+type InFlight struct {
+	RemoteAddr string        `json:"remote_addr"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Route      string        `json:"route,omitempty"` // the RouteName, once a ServeMux has matched it
+	Age        time.Duration `json:"age"`             // since the handler started
+	ConnAge    time.Duration `json:"conn_age"`        // since the connection was accepted
+}
+
+// connState is what the server tracks per open connection.
+type connState struct {
+	accepted time.Time
+	remote   string
+	req      atomic.Pointer[activeRequest] // nil while no handler runs
+	idle     atomic.Int64                  // UnixNano since the connection became idle, 0 while busy
+}
+
+// activeRequest is the request a connection's handler is serving. The
+// route is filled in by ServeMux once it matches, from the handler's
+// goroutine, while Shutdown may be reading it.
+type activeRequest struct {
+	method, path string
+	start        time.Time
+	route        atomic.Pointer[string]
+}
+
+// setRoute records the matched route; r may be nil for requests the
+// server isn't tracking, such as those built by a test.
+func (r *activeRequest) setRoute(name string) {
+	if r != nil {
+		r.route.Store(&name)
+	}
+}
+
+// InFlight lists the requests whose handlers are running, oldest first,
+// to tell what a slow Shutdown is waiting on.
+func (s *Server) InFlight() []InFlight {
+	now := time.Now()
+	s.mu.Lock()
+	var reqs []InFlight
+	for _, cs := range s.conns {
+		ar := cs.req.Load()
+		if ar == nil {
+			continue
+		}
+		f := InFlight{
+			RemoteAddr: cs.remote,
+			Method:     ar.method,
+			Path:       ar.path,
+			Age:        now.Sub(ar.start),
+			ConnAge:    now.Sub(cs.accepted),
+		}
+		if route := ar.route.Load(); route != nil {
+			f.Route = *route
+		}
+		reqs = append(reqs, f)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(reqs, func(a, b InFlight) int { return int(b.Age - a.Age) })
+	return reqs
+}
+
+// reportDrain logs how much of the server is left to drain: the open
+// connections and every request still being handled. It reports false
+// when nothing is left.
+func (s *Server) reportDrain(started time.Time) bool {
+	open := s.stats.openConns.Load()
+	reqs := s.InFlight()
+	if open == 0 && len(reqs) == 0 {
+		return false
+	}
+	log := s.logger()
+	log.Info("draining", "elapsed", time.Since(started).Round(time.Millisecond), "conns", open, "requests", len(reqs))
+	for i, f := range reqs {
+		if i == maxDrainReportRequests {
+			log.Info("draining: more requests in flight", "omitted", len(reqs)-i)
+			break
+		}
+		log.Info("draining: request in flight", "method", f.Method, "path", f.Path, "route", f.Route,
+			"age", f.Age.Round(time.Millisecond), "remote", f.RemoteAddr)
+	}
+	return true
+}
+
+// beginRequest marks req as being handled on the connection cs, until
+// endRequest.
+func (cs *connState) beginRequest(req *Request) {
+	ar := &activeRequest{method: req.Method, path: req.Path, start: time.Now()}
+	req.active = ar
+	cs.req.Store(ar)
+}
+
+func (cs *connState) endRequest() {
+	cs.req.Store(nil)
+}
+
+func newConnState(conn net.Conn) *connState {
+	return &connState{accepted: time.Now(), remote: conn.RemoteAddr().String()}
+}
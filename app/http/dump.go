@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultDumpMaxBody is how many body bytes a WireDump shows per message
+// when MaxBody is zero.
+const DefaultDumpMaxBody = 1024
+
+// WireDump tees the raw bytes of every request and response served to W,
+// for diagnosing client interoperability problems. Credentials are
+// redacted and bodies truncated before anything is written.
+type WireDump struct {
+	W io.Writer
+
+	// MaxBody caps the body bytes shown per message. Zero means
+	// DefaultDumpMaxBody, negative omits bodies entirely.
+	MaxBody int
+
+	// Redact lists additional header names whose values are replaced.
+	// Authorization, Proxy-Authorization, Cookie and Set-Cookie are always
+	// redacted.
+	Redact []string
+
+	mu sync.Mutex // serializes exchanges from concurrent connections
+}
+
+// SetDump replaces the server's WireDump, nil turning dumping off, so it
+// can be toggled while serving. Connections accepted from then on use it.
+func (s *Server) SetDump(d *WireDump) {
+	s.dump.Store(d)
+	s.dumpSet.Store(true)
+}
+
+func (s *Server) wireDump() *WireDump {
+	if s.dumpSet.Load() {
+		return s.dump.Load()
+	}
+	return s.Dump
+}
+
+var alwaysRedacted = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// dumpConn records everything read from and written to a connection until
+// the exchange is flushed to the WireDump.
+type dumpConn struct {
+	net.Conn
+	d      *WireDump
+	rd, wr bytes.Buffer
+}
+
+func (c *dumpConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.rd.Write(p[:n])
+	return n, err
+}
+
+func (c *dumpConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.wr.Write(p[:n])
+	return n, err
+}
+
+func (c *dumpConn) writeBuffers(bufs net.Buffers) (int64, error) {
+	// WriteTo consumes bufs, so record from a copy of the slice
+	orig := append(net.Buffers(nil), bufs...)
+	n, err := writeBuffers(c.Conn, bufs)
+	for rem := n; rem > 0 && len(orig) > 0; orig = orig[1:] {
+		b := orig[0][:min(int64(len(orig[0])), rem)]
+		c.wr.Write(b)
+		rem -= int64(len(b))
+	}
+	return n, err
+}
+
+// flush dumps one exchange: the first reqLen bytes read and everything
+// written since the last flush. Bytes read beyond reqLen belong to the next
+// pipelined request and are kept.
+func (c *dumpConn) flush(reqLen int64) {
+	if reqLen > int64(c.rd.Len()) {
+		reqLen = int64(c.rd.Len())
+	}
+	req := c.rd.Next(int(reqLen))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- request from %s\n", c.RemoteAddr())
+	c.d.writeMessage(&b, req)
+	fmt.Fprintf(&b, "--- response to %s\n", c.RemoteAddr())
+	c.d.writeMessage(&b, c.wr.Bytes())
+	c.wr.Reset()
+
+	c.d.mu.Lock()
+	c.d.W.Write(b.Bytes())
+	c.d.mu.Unlock()
+}
+
+// writeMessage writes the head of msg with sensitive header values
+// replaced, followed by the possibly truncated body.
+func (d *WireDump) writeMessage(b *bytes.Buffer, msg []byte) {
+	head, body, found := bytes.Cut(msg, []byte("\r\n\r\n"))
+	for i, line := range bytes.Split(head, []byte("\r\n")) {
+		if name, _, ok := bytes.Cut(line, []byte(":")); ok && i > 0 && d.redacted(string(name)) {
+			line = append(name, ": [redacted]"...)
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+	if !found || len(body) == 0 {
+		return
+	}
+	b.WriteString("\n")
+
+	max := d.MaxBody
+	if max == 0 {
+		max = DefaultDumpMaxBody
+	}
+	switch {
+	case max < 0:
+		fmt.Fprintf(b, "[%d body bytes omitted]\n", len(body))
+		return
+	case len(body) > max:
+		fmt.Fprintf(b, "%s\n[%d more body bytes truncated]\n", printable(body[:max]), len(body)-max)
+	default:
+		fmt.Fprintf(b, "%s\n", printable(body))
+	}
+}
+
+func (d *WireDump) redacted(name string) bool {
+	return redactedHeader(name, d.Redact)
+}
+
+// redactedHeader reports whether the header name is always redacted or
+// listed in extra.
+func redactedHeader(name string, extra []string) bool {
+	name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+	for _, list := range [][]string{alwaysRedacted, extra} {
+		for _, r := range list {
+			if textproto.CanonicalMIMEHeaderKey(r) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// printable keeps text bodies readable and avoids writing compressed or
+// binary payloads to a terminal.
+func printable(body []byte) string {
+	if utf8.Valid(body) {
+		return string(body)
+	}
+	return fmt.Sprintf("[%d bytes of binary data]", len(body))
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var redactedHeaderTest = []struct {
+	name  string
+	extra []string
+	want  bool
+}{
+	{"Authorization", nil, true},
+	{"authorization", nil, true},
+	{" AUTHORIZATION ", nil, true},
+	{"Proxy-Authorization", nil, true},
+	{"Cookie", nil, true},
+	{"set-cookie", nil, true},
+	{"X-Api-Key", nil, false},
+	{"x-api-key", []string{"X-API-Key"}, true},
+	{"Content-Type", []string{"X-API-Key"}, false},
+}
+
+func TestRedactedHeader(t *testing.T) {
+	for i, tt := range redactedHeaderTest {
+		if got := redactedHeader(tt.name, tt.extra); got != tt.want {
+			t.Errorf("#%d: %q gotRedacted: %v wantRedacted: %v", i, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWireDumpRedacts(t *testing.T) {
+	var dump bytes.Buffer
+	s := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.SetHeader("Set-Cookie", "session=s3cr3t-session; Path=/")
+			w.SetHeader("X-Visible", "response-visible")
+			w.SetBody([]byte(strings.Repeat("b", 40)))
+			w.Write()
+		}),
+		Dump: &WireDump{W: &dump, MaxBody: 16, Redact: []string{"x-api-key"}},
+	}
+	stream := "POST /login HTTP/1.1\r\nHost: example.com\r\n" +
+		"Authorization: Bearer s3cr3t-token\r\n" +
+		"proxy-authorization: Basic s3cr3t-proxy\r\n" +
+		"Cookie: id=s3cr3t-cookie\r\n" +
+		"X-Api-Key: s3cr3t-key\r\n" +
+		"X-Visible: request-visible\r\n" +
+		"Content-Length: 5\r\nConnection: close\r\n\r\nhello"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+
+	out := dump.String()
+	if strings.Contains(out, "s3cr3t") {
+		t.Errorf("credentials not redacted:\n%s", out)
+	}
+	for _, want := range []string{
+		"Authorization: [redacted]\n",
+		"proxy-authorization: [redacted]\n",
+		"Cookie: [redacted]\n",
+		"X-Api-Key: [redacted]\n",
+		"Set-Cookie: [redacted]\n",
+		"X-Visible: request-visible\n",
+		"X-Visible: response-visible\n",
+		"\nhello\n",
+		strings.Repeat("b", 16) + "\n[24 more body bytes truncated]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("gotDump:\n%s\nwant: %q", out, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package http
+
+import "errors"
+
+// The errors the server and client report for failures callers may want
+// to tell apart with errors.Is. Errors from the network itself, such as
+// timeouts, are passed through unwrapped.
+var (
+	// ErrServerClosed is returned by Serve and ListenAndServe once
+	// Shutdown has been called.
+	ErrServerClosed = errors.New("http: Server closed")
+
+	// ErrListenerDrained is returned by Serve when its listener was closed
+	// by DrainListener.
+	ErrListenerDrained = errors.New("http: listener drained")
+
+	// ErrHandlerTimeout is returned by the Write of a ResponseWriter
+	// passed through TimeoutHandler once the handler has run out of time.
+	ErrHandlerTimeout = errors.New("http: Handler timeout")
+
+	// ErrBodyTooLarge is returned when a request body is larger than the
+	// server's MaxBodySize; the request is answered with 413.
+	ErrBodyTooLarge = errors.New("http: request body too large")
+
+	// ErrLengthRequired is returned, when Server.RequireContentLength is
+	// set, for a POST, PUT or PATCH sending neither Content-Length nor a
+	// chunked body; the request is answered with 411.
+	ErrLengthRequired = errors.New("http: length required")
+
+	// ErrHeaderTooLarge is returned when a request or header line doesn't
+	// fit in the connection's read buffer; the request is answered with
+	// 431.
+	ErrHeaderTooLarge = errors.New("http: request header too large")
+
+	// ErrUnsupportedVersion is returned for a well-formed request line
+	// whose major protocol version isn't 1, such as HTTP/2.0 sent in the
+	// clear; the request is answered with 505.
+	ErrUnsupportedVersion = errors.New("http: unsupported protocol version")
+
+	// ErrUnsupportedTransferEncoding is returned for a request whose
+	// Transfer-Encoding is anything but chunked, which is the only coding
+	// the server decodes; the request is answered with 501.
+	ErrUnsupportedTransferEncoding = errors.New("http: unsupported transfer encoding")
+
+	// ErrMalformedMessage is matched by every error reporting a request
+	// or response that couldn't be parsed, such as a bad request line,
+	// header line or chunk; malformed requests are answered with 400.
+	ErrMalformedMessage = errors.New("http: malformed message")
+
+	// ErrMalformedChunk is returned for a chunked body that doesn't follow
+	// RFC 9112 section 7.1. It matches ErrMalformedMessage.
+	ErrMalformedChunk error = &malformedError{"http: malformed chunked encoding", "malformed_chunk"}
+)
+
+// malformedError describes a message that couldn't be parsed. It matches
+// ErrMalformedMessage.
+type malformedError struct {
+	msg  string
+	code string // for ParseErrorCode; "" means "bad_request"
+}
+
+func (e *malformedError) Error() string { return e.msg }
+
+func (e *malformedError) Is(target error) bool { return target == ErrMalformedMessage }
+
+func badStringErr(what, val string) error { return &malformedError{msg: what + ": " + val} }
+
+// badRequestLine reports a request line that couldn't be parsed.
+func badRequestLine(what, val string) error {
+	return &malformedError{what + ": " + val, "malformed_request_line"}
+}
+
+// ParseErrorCode returns a short machine-readable name for a request
+// parse error, such as "malformed_request_line", "malformed_header",
+// "body_too_large" or, for anything more obscure, "bad_request". The
+// server sends it in JSON parse-error bodies; see Server.JSONParseErrors.
+func ParseErrorCode(err error) string {
+	var me *malformedError
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return "body_too_large"
+	case errors.Is(err, ErrLengthRequired):
+		return "length_required"
+	case errors.Is(err, ErrHeaderTooLarge):
+		return "header_too_large"
+	case errors.Is(err, ErrUnsupportedVersion):
+		return "unsupported_version"
+	case errors.Is(err, ErrUnsupportedTransferEncoding):
+		return "unsupported_transfer_encoding"
+	case errors.As(err, &me) && me.code != "":
+		return me.code
+	}
+	return "bad_request"
+}
+
+// parseErrorStatus returns the status answering a request that failed to
+// parse with err.
+func parseErrorStatus(err error) (code int, text string) {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return StatusRequestEntityTooLarge, "Payload Too Large" // RFC 9110's name
+	case errors.Is(err, ErrLengthRequired):
+		code = StatusLengthRequired
+	case errors.Is(err, ErrHeaderTooLarge):
+		code = StatusRequestHeaderFieldsTooLarge
+	case errors.Is(err, ErrUnsupportedVersion):
+		code = StatusHTTPVersionNotSupported
+	case errors.Is(err, ErrUnsupportedTransferEncoding):
+		code = StatusNotImplemented
+	default:
+		code = StatusBadRequest
+	}
+	return code, StatusText(code)
+}
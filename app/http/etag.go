@@ -0,0 +1,95 @@
+package http
+
+import (
+	"crypto/sha256"
+	"strings"
+)
+
+// ETagMaxBodySize is the largest body ETag hashes; bigger responses are
+// passed through untouched.
+const ETagMaxBodySize = 64 << 10
+
+// ETag is middleware that tags small successful GET and HEAD responses
+// with a strong ETag computed from the body, and answers requests whose
+// If-None-Match already holds it with 304 Not Modified. Handlers that set
+// their own ETag are left alone.
+func ETag(next Handler) Handler {
+	return wrapHandler("ETag", next, func(w ResponseWriter, r *Request) {
+		if r.Method != MethodGet && r.Method != MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(&etagWriter{BaseResponseWriter: BaseResponseWriter{w}, r: r, code: StatusOK}, r)
+	})
+}
+
+type etagWriter struct {
+	BaseResponseWriter
+	r       *Request
+	code    int
+	hasETag bool
+}
+
+func (ew *etagWriter) SetStatus(code int, text string) {
+	ew.code = code
+	ew.ResponseWriter.SetStatus(code, text)
+}
+
+func (ew *etagWriter) SetHeader(key, value string) {
+	if key == "Etag" || key == "ETag" {
+		ew.hasETag = true
+	}
+	ew.ResponseWriter.SetHeader(key, value)
+}
+
+func (ew *etagWriter) Write() error {
+	body := ew.GetBody()
+	if ew.code != StatusOK || ew.hasETag || len(body) > ETagMaxBodySize {
+		return ew.ResponseWriter.Write()
+	}
+
+	sum := sha256.Sum256(body)
+	tag := hashETag(sum[:])
+	ew.ResponseWriter.SetHeader("ETag", tag)
+	if etagMatch(ew.r.Header.Get("If-None-Match"), tag) {
+		ew.ResponseWriter.SetStatus(StatusNotModified, StatusText(StatusNotModified))
+		ew.ResponseWriter.SetBody(nil)
+	}
+	return ew.ResponseWriter.Write()
+}
+
+// etagMatch reports whether an If-None-Match list matches tag using the
+// weak comparison RFC 9110 section 13.1.2 requires.
+func etagMatch(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(t), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// strongMatch reports whether an If-Match list matches tag using the
+// strong comparison RFC 9110 section 13.1.1 requires: weak tags never
+// match, and "*" matches any current tag.
+func strongMatch(ifMatch, tag string) bool {
+	if strings.TrimSpace(ifMatch) == "*" {
+		return tag != ""
+	}
+	if strings.HasPrefix(tag, "W/") {
+		return false
+	}
+	for _, t := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(t) == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestETag(t *testing.T) {
+	h := http.ETag(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetBody([]byte("stable body"))
+		w.Write()
+	}))
+
+	req, _ := http.NewRequest(http.MethodGet, "/poll", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	tag := rec.HeaderMap["ETag"]
+	if rec.Code != http.StatusOK || tag == "" {
+		t.Fatalf("gotCode: %d gotETag: %q", rec.Code, tag)
+	}
+
+	for i, inm := range []string{tag, "W/" + tag, `"other", ` + tag, "*"} {
+		req.Header.Set("If-None-Match", inm)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified || len(rec.Body) != 0 {
+			t.Errorf("#%d: gotCode: %d gotBody: %q", i, rec.Code, rec.Body)
+		}
+	}
+
+	req.Header.Set("If-None-Match", `"other"`)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("mismatch gotCode: %d wantCode: %d", rec.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultListMaxKeys is how many entries a listing page holds when the
+// request gives no max-keys.
+const DefaultListMaxKeys = 1000
+
+// maxListMaxKeys caps max-keys, however many a client asks for.
+const maxListMaxKeys = 10000
+
+// FileList is the JSON document FilesHandler.ListAPI answers with.
+type FileList struct {
+	Prefix         string      `json:"prefix"`
+	Delimiter      string      `json:"delimiter,omitempty"`
+	Marker         string      `json:"marker,omitempty"`
+	Entries        []FileEntry `json:"entries"`
+	CommonPrefixes []string    `json:"common_prefixes,omitempty"`
+	Truncated      bool        `json:"truncated"`
+	NextMarker     string      `json:"next_marker,omitempty"` // the marker of the next page, when Truncated
+}
+
+// FileEntry is one file in a FileList.
+type FileEntry struct {
+	Name  string    `json:"name"` // slash-separated, relative to the mount
+	Size  int64     `json:"size"`
+	MTime time.Time `json:"mtime"`
+	ETag  string    `json:"etag"` // as sent with the file and matched by If-Match
+}
+
+// serveList answers GET Prefix?list with the files under Root as a
+// FileList, in the manner of an object store listing: names are keys
+// relative to the mount, sorted bytewise, filtered by the prefix
+// parameter and paged by marker, the last key of the previous page, and
+// max-keys. With a delimiter, usually "/", keys with the delimiter after
+// the prefix are rolled up into CommonPrefixes, listing one directory
+// level at a time. Dot files are left out.
+func (h *FilesHandler) serveList(w ResponseWriter, r *Request) {
+	q := r.URL.Query()
+	list := FileList{
+		Prefix:    q.Get("prefix"),
+		Delimiter: q.Get("delimiter"),
+		Marker:    q.Get("marker"),
+		Entries:   []FileEntry{},
+	}
+	maxKeys := DefaultListMaxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			h.respond(w, StatusBadRequest)
+			return
+		}
+		maxKeys = min(n, maxListMaxKeys)
+	}
+
+	entries, err := h.listFiles(list.Prefix)
+	if err != nil {
+		h.logger().Error("listing files", "root", h.Root, "err", err)
+		h.respond(w, StatusInternalServerError)
+		return
+	}
+	n := 0
+	for _, e := range entries {
+		if e.Name <= list.Marker {
+			continue
+		}
+		if list.Delimiter != "" {
+			rest := e.Name[len(list.Prefix):]
+			if i := strings.Index(rest, list.Delimiter); i >= 0 {
+				cp := list.Prefix + rest[:i+len(list.Delimiter)]
+				if cp <= list.Marker || slices.Contains(list.CommonPrefixes, cp) {
+					continue
+				}
+				if n == maxKeys {
+					list.Truncated = true
+					break
+				}
+				list.CommonPrefixes = append(list.CommonPrefixes, cp)
+				list.NextMarker = cp
+				n++
+				continue
+			}
+		}
+		if n == maxKeys {
+			list.Truncated = true
+			break
+		}
+		list.Entries = append(list.Entries, e)
+		list.NextMarker = e.Name
+		n++
+	}
+	if !list.Truncated {
+		list.NextMarker = ""
+	}
+
+	b, err := json.Marshal(list)
+	if err != nil {
+		h.respond(w, StatusInternalServerError)
+		return
+	}
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "application/json")
+	w.SetBody(b)
+	w.Write()
+}
+
+// listFiles returns the files under Root whose names start with prefix,
+// sorted by name. The walk starts at the deepest directory the prefix
+// names, so listing one directory of a large tree stays cheap.
+func (h *FilesHandler) listFiles(prefix string) ([]FileEntry, error) {
+	start := h.Root
+	if dir := path.Dir(prefix); strings.Contains(prefix, "/") && dir != "." {
+		// cleaning as an absolute path keeps the walk inside Root
+		start = filepath.Join(h.Root, filepath.FromSlash(path.Clean("/"+dir)))
+	}
+	var entries []FileEntry
+	err := filepath.WalkDir(start, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if name != start && errors.Is(err, fs.ErrNotExist) {
+				// removed since it was listed
+				return nil
+			}
+			return err
+		}
+		if name != start && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(h.Root, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			// removed since it was listed
+			return nil
+		}
+		tag, err := h.tags.get(name, fi)
+		if err != nil {
+			// removed since it was listed
+			return nil
+		}
+		entries = append(entries, FileEntry{Name: rel, Size: fi.Size(), MTime: fi.ModTime().UTC(), ETag: tag})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		// nothing under the prefix's directory
+		err = nil
+	}
+	slices.SortFunc(entries, func(a, b FileEntry) int { return strings.Compare(a.Name, b.Name) })
+	return entries, err
+}
@@ -0,0 +1,68 @@
+package http_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestFilesListAPI(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "docs/b.md", "docs/c.md", "docs/old/d.md", "e.txt", ".hidden", "docs/.tmp"} {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+		os.WriteFile(filepath.Join(root, name), []byte(name), 0644)
+	}
+	files := &http.FilesHandler{Root: root, Prefix: "/files/", ListAPI: true}
+
+	tests := []struct {
+		query    string
+		entries  []string
+		prefixes []string
+		next     string
+	}{
+		{"list", []string{"a.txt", "docs/b.md", "docs/c.md", "docs/old/d.md", "e.txt"}, nil, ""},
+		{"list&delimiter=/", []string{"a.txt", "e.txt"}, []string{"docs/"}, ""},
+		{"list&prefix=docs/&delimiter=/", []string{"docs/b.md", "docs/c.md"}, []string{"docs/old/"}, ""},
+		{"list&prefix=docs/c", []string{"docs/c.md"}, nil, ""},
+		{"list&prefix=nope/", nil, nil, ""},
+		{"list&max-keys=2", []string{"a.txt", "docs/b.md"}, nil, "docs/b.md"},
+		{"list&max-keys=2&marker=docs/b.md", []string{"docs/c.md", "docs/old/d.md"}, nil, "docs/old/d.md"},
+		{"list&max-keys=2&delimiter=/", []string{"a.txt"}, []string{"docs/"}, "docs/"},
+		{"list&max-keys=2&delimiter=/&marker=docs/", []string{"e.txt"}, nil, ""},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/files/?"+tt.query, nil)
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		var list http.FileList
+		if err := json.Unmarshal(rec.Body, &list); err != nil || rec.Code != 200 {
+			t.Errorf("#%d: gotCode: %d gotBody: %q", i, rec.Code, rec.Body)
+			continue
+		}
+		var names []string
+		for _, e := range list.Entries {
+			names = append(names, e.Name)
+		}
+		if !slices.Equal(names, tt.entries) || !slices.Equal(list.CommonPrefixes, tt.prefixes) || list.NextMarker != tt.next || list.Truncated != (tt.next != "") {
+			t.Errorf("#%d: %s gotEntries: %q gotPrefixes: %q gotNext: %q wantEntries: %q wantPrefixes: %q wantNext: %q",
+				i, tt.query, names, list.CommonPrefixes, list.NextMarker, tt.entries, tt.prefixes, tt.next)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/files/?list", nil)
+	rec := httptest.NewRecorder()
+	files.ServeHTTP(rec, req)
+	var list http.FileList
+	json.Unmarshal(rec.Body, &list)
+	get, _ := http.NewRequest(http.MethodGet, "/files/a.txt", nil)
+	got := httptest.NewRecorder()
+	(&http.FilesHandler{Root: root, Prefix: "/files/", Validators: true}).ServeHTTP(got, get)
+	if e := list.Entries[0]; e.Size != 5 || e.ETag != got.GetHeader("ETag") || e.MTime.IsZero() {
+		t.Errorf("gotEntry: %+v wantETag: %q", e, got.GetHeader("ETag"))
+	}
+}
@@ -0,0 +1,41 @@
+package http
+
+import "sync"
+
+// pathLocks hands out a mutex per file path. Entries are dropped once no
+// one holds or waits for them, so the map only grows with the number of
+// paths being written at once. The zero value is ready to use.
+type pathLocks struct {
+	mu sync.Mutex
+	m  map[string]*pathLock
+}
+
+type pathLock struct {
+	sync.Mutex
+	refs int // holders and waiters, guarded by pathLocks.mu
+}
+
+// lock blocks until name is free, then returns the func releasing it.
+func (l *pathLocks) lock(name string) func() {
+	l.mu.Lock()
+	if l.m == nil {
+		l.m = make(map[string]*pathLock)
+	}
+	pl := l.m[name]
+	if pl == nil {
+		pl = new(pathLock)
+		l.m[name] = pl
+	}
+	pl.refs++
+	l.mu.Unlock()
+
+	pl.Lock()
+	return func() {
+		pl.Unlock()
+		l.mu.Lock()
+		if pl.refs--; pl.refs == 0 {
+			delete(l.m, name)
+		}
+		l.mu.Unlock()
+	}
+}
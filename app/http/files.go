@@ -0,0 +1,779 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FilesHandler serves the files under Root for GET and HEAD, naming
+// files by the request path after Prefix. Unless ReadOnly, POST and PUT
+// store the body as the file, PATCH appends it and DELETE removes the
+// file. Paths are cleaned so requests cannot reach outside Root. Uploads
+// must give their length, by Content-Length or a chunked body, or they
+// get 411 Length Required; stored files are replaced atomically, so
+// readers see either the old contents or the whole new ones, and writes
+// to the same path are serialized.
+type FilesHandler struct {
+	Root   string
+	Prefix string
+
+	// ReadOnly rejects POST, PUT, PATCH and DELETE with 405 Method Not
+	// Allowed.
+	ReadOnly bool
+
+	// FileMode is the permission of created files; 0644 when zero.
+	FileMode os.FileMode
+
+	// MaxFileSize, if positive, answers upload bodies larger than this with
+	// 413: by their Content-Length before any of the body is read, and for
+	// chunked bodies once that much has streamed in. Bodies larger than
+	// the Server's MaxBodySize never reach the handler.
+	MaxFileSize int64
+
+	// CacheControl, if set, is sent with every file served that no
+	// CachePolicies entry matches.
+	CacheControl CacheControl
+
+	// CachePolicies picks the Cache-Control of each file by its path; the
+	// first matching policy wins. See StaticCachePolicies.
+	CachePolicies []CachePolicy
+
+	// Index, if set, is the file served for a request naming a directory,
+	// such as "index.html".
+	Index string
+
+	// ListDirs answers requests for directories without an Index file
+	// with an HTML listing of their entries. Dot files are left out.
+	ListDirs bool
+
+	// Fallback, if set, is the file served with 200 OK for GET and HEAD
+	// requests naming nothing under Root, such as "index.html" for a
+	// single-page app whose client-side routes must work when deep-linked.
+	// Paths whose last element has an extension, like a missing
+	// "/app.js", and paths under FallbackExclude still get 404.
+	Fallback string
+
+	// FallbackExclude lists path prefixes, such as "/api/", that never
+	// fall back, so API clients see a real 404.
+	FallbackExclude []string
+
+	// Ranges serves byte ranges, as media players seeking need: responses
+	// carry Accept-Ranges, and a request for a single Range gets 206
+	// Partial Content, uncompressed, unless an If-Range names an older
+	// version of the file. Requests for several ranges get the whole file.
+	Ranges bool
+
+	// Validators sends ETag, a hash of the file's contents, and
+	// Last-Modified, and answers 304 Not Modified to If-None-Match and
+	// If-Modified-Since requests the file still matches.
+	Validators bool
+
+	// NoCompress lists Content-Type prefixes, such as "video/", that are
+	// already compressed and sent as is.
+	NoCompress []string
+
+	// ContentTypes sets Content-Type from the file extension, falling back
+	// to application/octet-stream, rather than always sending that.
+	ContentTypes bool
+
+	// ListAPI answers GET Prefix?list with a JSON FileList of the files
+	// under Root, paged and filtered like an object store listing; see
+	// serveList for the parameters.
+	ListAPI bool
+
+	// Quota, if positive, caps the total size of the files under Root; a
+	// write that would take them past it gets 507 Insufficient Storage.
+	// Usage is measured by walking Root on every write that grows it.
+	Quota int64
+
+	// MinFreeSpace, if positive, answers 507 to writes that would leave
+	// less than this many bytes free on Root's file system, so one client
+	// can't fill the disk. It is not checked on systems where free space
+	// can't be read.
+	MinFreeSpace int64
+
+	// FileLock also takes an flock(2) on the file's directory around each
+	// write, serializing writers in other processes that do the same, such
+	// as a second server on the same Root. Writers within the process are
+	// always serialized per file. It has no effect on systems without
+	// flock.
+	FileLock bool
+
+	// Logger receives file system errors. slog.Default() is used when nil.
+	Logger *slog.Logger
+
+	locks   pathLocks
+	quotaMu sync.Mutex // held from the Quota check to the write
+	tags    fileTags
+}
+
+// NewFilesHandler returns a FilesHandler for the files under root, mounted
+// at prefix, for example "/files/".
+func NewFilesHandler(prefix, root string) *FilesHandler {
+	return &FilesHandler{Root: root, Prefix: prefix}
+}
+
+// NewMediaHandler returns a FilesHandler for root, mounted at prefix, set
+// up for audio and video played by HTML5 media elements: byte ranges so
+// players can seek, media types by extension, ETag and Last-Modified, no
+// gzip for media that is compressed already, and a day of public
+// caching. Change the fields for other policies.
+func NewMediaHandler(prefix, root string) *FilesHandler {
+	cc := CacheControl{}
+	cc.Set(CachePublic)
+	cc.SetDuration(CacheMaxAge, 24*time.Hour)
+	return &FilesHandler{
+		Root:         root,
+		Prefix:       prefix,
+		CacheControl: cc,
+		Ranges:       true,
+		Validators:   true,
+		ContentTypes: true,
+		NoCompress:   []string{"video/", "audio/", "image/"},
+	}
+}
+
+// mediaTypes fills gaps in mime.TypeByExtension, whose built-in table
+// lacks most audio and video formats when the system has no mime.types.
+var mediaTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".weba": "audio/webm",
+	".vtt":  "text/vtt; charset=utf-8",
+	".m3u8": "application/vnd.apple.mpegurl",
+}
+
+func (h *FilesHandler) ServeHTTP(w ResponseWriter, r *Request) {
+	if h.ListAPI && r.Method == MethodGet && r.URL != nil && r.URL.Path == h.Prefix && r.URL.Query().Has("list") {
+		h.serveList(w, r)
+		return
+	}
+	name, ok := h.filePath(r)
+	if !ok {
+		h.respond(w, StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case MethodGet, MethodHead:
+		if h.fallsBack(r, name) {
+			name = h.fallbackPath()
+		}
+		h.serveFile(w, r, name)
+	case MethodPost, MethodPut, MethodPatch, MethodDelete:
+		if h.ReadOnly {
+			h.notAllowed(w)
+			return
+		}
+		if r.Method != MethodDelete {
+			if code := h.checkUpload(r); code != 0 {
+				h.respond(w, code)
+				return
+			}
+		}
+		unlock, err := h.lock(name)
+		if err != nil {
+			h.logger().Error("locking file", "path", name, "err", err)
+			h.respond(w, StatusInternalServerError)
+			return
+		}
+		defer unlock()
+		if h.Quota > 0 {
+			// writes to other paths would see usage before this one
+			h.quotaMu.Lock()
+			defer h.quotaMu.Unlock()
+		}
+		if h.preconditionFailed(r, name) {
+			h.respond(w, StatusPreconditionFailed)
+			return
+		}
+		switch r.Method {
+		case MethodPatch:
+			h.appendFile(w, name, r)
+		case MethodDelete:
+			h.deleteFile(w, name)
+		default:
+			h.storeFile(w, r.Method, name, r)
+		}
+	default:
+		h.notAllowed(w)
+	}
+}
+
+// filePath maps the request to a file under Root. It fails for a request
+// naming the mount itself, unless directories are served.
+func (h *FilesHandler) filePath(r *Request) (string, bool) {
+	p := r.Path
+	if r.URL != nil {
+		p = r.URL.Path
+	}
+	rel, ok := strings.CutPrefix(p, h.Prefix)
+	if !ok {
+		return "", false
+	}
+	// cleaning as an absolute path drops any leading ".." elements
+	rel = path.Clean("/" + rel)
+	if rel == "/" && !h.servesDirs() {
+		return "", false
+	}
+	return filepath.Join(h.Root, filepath.FromSlash(rel)), true
+}
+
+func (h *FilesHandler) servesDirs() bool {
+	return h.Index != "" || h.ListDirs || h.Fallback != ""
+}
+
+// fallsBack reports whether the request for name gets the Fallback file.
+func (h *FilesHandler) fallsBack(r *Request, name string) bool {
+	if h.Fallback == "" || filepath.Ext(name) != "" {
+		return false
+	}
+	p := r.Path
+	if r.URL != nil {
+		p = r.URL.Path
+	}
+	for _, prefix := range h.FallbackExclude {
+		if strings.HasPrefix(p, prefix) {
+			return false
+		}
+	}
+	_, err := os.Stat(name)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+func (h *FilesHandler) fallbackPath() string {
+	return filepath.Join(h.Root, filepath.FromSlash(h.Fallback))
+}
+
+func (h *FilesHandler) serveFile(w ResponseWriter, r *Request, name string) {
+	f, err := os.Open(name)
+	if err != nil {
+		h.logger().Debug("file not found", "path", name, "err", err)
+		h.respond(w, StatusNotFound)
+		return
+	}
+	fi, err := f.Stat()
+	if err == nil && fi.IsDir() {
+		f.Close()
+		h.serveDir(w, r, name)
+		return
+	}
+	if err != nil {
+		f.Close()
+		h.respond(w, StatusNotFound)
+		return
+	}
+
+	ct := h.contentType(name)
+	w.SetHeader("Content-Type", ct)
+	if cc := h.cacheControl(name); len(cc) > 0 {
+		w.SetHeader("Cache-Control", cc.String())
+	}
+	var tag string
+	if h.Validators {
+		if tag, err = h.tags.get(name, fi); err != nil {
+			f.Close()
+			h.logger().Error("hashing file", "path", name, "err", err)
+			h.respond(w, StatusInternalServerError)
+			return
+		}
+		w.SetHeader("ETag", tag)
+		w.SetHeader("Last-Modified", fi.ModTime().UTC().Format(TimeFormat))
+		if notModified(r, tag, fi.ModTime()) {
+			f.Close()
+			w.SetStatus(StatusNotModified, StatusText(StatusNotModified))
+			w.SetBody(nil)
+			w.Write()
+			return
+		}
+	}
+	if h.noCompress(ct) {
+		w.SetHeader("Content-Encoding", "identity")
+	}
+
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	size := fi.Size()
+	if h.Ranges {
+		w.SetHeader("Accept-Ranges", "bytes")
+		br, ok, err := parseRange(r.Header.Get("Range"), size)
+		switch {
+		case err != nil:
+			f.Close()
+			w.SetHeader("Content-Type", "text/plain")
+			w.SetHeader("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+			h.respond(w, StatusRequestedRangeNotSatisfiable)
+			return
+		case ok && ifRange(r, tag, fi.ModTime()):
+			if _, err := f.Seek(br.start, io.SeekStart); err != nil {
+				f.Close()
+				h.logger().Error("seeking file", "path", name, "err", err)
+				h.respond(w, StatusInternalServerError)
+				return
+			}
+			w.SetStatus(StatusPartialContent, StatusText(StatusPartialContent))
+			w.SetHeader("Content-Range", br.contentRange(size))
+			// offsets are into the file as stored, so never compress
+			w.SetHeader("Content-Encoding", "identity")
+			size = br.length
+		}
+	}
+	// stream the file when the writer can, closing it once sent
+	if sw, ok := w.(interface{ SetBodyReader(io.Reader, int64) }); ok {
+		sw.SetBodyReader(f, size)
+		w.Write()
+		return
+	}
+	contents, err := io.ReadAll(io.LimitReader(f, size))
+	f.Close()
+	if err != nil {
+		h.logger().Error("reading file", "path", name, "err", err)
+		h.respond(w, StatusInternalServerError)
+		return
+	}
+	w.SetBody(contents)
+	w.Write()
+}
+
+// notModified reports whether r's conditional headers say the client's
+// copy, last modified at modTime, is current. If-None-Match takes
+// precedence over If-Modified-Since, per RFC 9110 section 13.2.2.
+func notModified(r *Request, tag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatch(inm, tag)
+	}
+	t, err := time.Parse(TimeFormat, r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(t)
+}
+
+// preconditionFailed reports whether r's If-Match or If-None-Match rules
+// out writing name: If-Match needs the file to exist with a listed ETag,
+// and If-None-Match needs it not to, as in "If-None-Match: *" to create a
+// file only if it is new. The caller holds the lock on name, so the file
+// can't change between this check and the write.
+func (h *FilesHandler) preconditionFailed(r *Request, name string) bool {
+	im, inm := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if im == "" && inm == "" {
+		return false
+	}
+	var tag string
+	if fi, err := os.Stat(name); err == nil && !fi.IsDir() {
+		tag, _ = h.tags.get(name, fi)
+	}
+	if im != "" && !strongMatch(im, tag) {
+		return true
+	}
+	return inm != "" && tag != "" && etagMatch(inm, tag)
+}
+
+// setETag records sum, the hash of what was just written to name, and
+// sends the ETag it makes.
+func (h *FilesHandler) setETag(w ResponseWriter, name string, sum hash.Hash) {
+	if fi, err := os.Stat(name); err == nil {
+		w.SetHeader("ETag", h.tags.put(name, fi, sum))
+	}
+}
+
+// ifRange reports whether a Range request may be answered with a part of
+// the file: there is no If-Range, or it names the current version, by a
+// strong ETag or the exact Last-Modified date.
+func ifRange(r *Request, tag string, modTime time.Time) bool {
+	v := r.Header.Get("If-Range")
+	if v == "" {
+		return true
+	}
+	if strings.HasPrefix(v, `"`) {
+		return v == tag
+	}
+	t, err := time.Parse(TimeFormat, v)
+	return err == nil && modTime.Truncate(time.Second).Equal(t)
+}
+
+// cacheControl returns the Cache-Control for the file name under Root.
+func (h *FilesHandler) cacheControl(name string) CacheControl {
+	if len(h.CachePolicies) > 0 {
+		if rel, err := filepath.Rel(h.Root, name); err == nil {
+			rel = filepath.ToSlash(rel)
+			for _, p := range h.CachePolicies {
+				if p.match(rel) {
+					return p.CacheControl
+				}
+			}
+		}
+	}
+	return h.CacheControl
+}
+
+// noCompress reports whether files of the media type ct are sent as is.
+func (h *FilesHandler) noCompress(ct string) bool {
+	for _, prefix := range h.NoCompress {
+		if strings.HasPrefix(ct, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDir answers a request for the directory dir with its Index file or
+// a listing. Directory URLs must end in a slash, so relative links in the
+// page resolve inside it; other requests are redirected there.
+func (h *FilesHandler) serveDir(w ResponseWriter, r *Request, dir string) {
+	if !h.servesDirs() {
+		h.respond(w, StatusNotFound)
+		return
+	}
+	p := r.Path
+	if r.URL != nil {
+		p = r.URL.Path
+	}
+	if !strings.HasSuffix(p, "/") {
+		target := path.Base(p) + "/"
+		if r.URL != nil && r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		w.SetHeader("Location", target)
+		h.respond(w, StatusMovedPermanently)
+		return
+	}
+	if h.Index != "" {
+		index := filepath.Join(dir, h.Index)
+		if fi, err := os.Stat(index); err == nil && !fi.IsDir() {
+			h.serveFile(w, r, index)
+			return
+		}
+	}
+	if !h.ListDirs {
+		if h.Fallback != "" && dir != h.fallbackPath() {
+			h.serveFile(w, r, h.fallbackPath())
+			return
+		}
+		h.respond(w, StatusNotFound)
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		h.logger().Error("listing directory", "path", dir, "err", err)
+		h.respond(w, StatusInternalServerError)
+		return
+	}
+	var b bytes.Buffer
+	title := html.EscapeString(p)
+	b.WriteString("<!doctype html>\n<title>" + title + "</title>\n<h1>" + title + "</h1>\n<ul>\n")
+	if p != h.Prefix {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, e := range entries {
+		entry := e.Name()
+		if strings.HasPrefix(entry, ".") {
+			continue
+		}
+		if e.IsDir() {
+			entry += "/"
+		}
+		// "./" keeps a name such as "a:b" from reading as a scheme
+		href := "./" + (&url.URL{Path: entry}).EscapedPath()
+		b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(entry) + "</a></li>\n")
+	}
+	b.WriteString("</ul>\n")
+	w.SetStatus(StatusOK, StatusText(StatusOK))
+	w.SetHeader("Content-Type", "text/html; charset=utf-8")
+	w.SetBody(b.Bytes())
+	w.Write()
+}
+
+// contentType is the Content-Type name is served with.
+func (h *FilesHandler) contentType(name string) string {
+	if h.ContentTypes {
+		if ct := typeByExtension(name); ct != "" {
+			return ct
+		}
+	}
+	return "application/octet-stream"
+}
+
+// typeByExtension returns the media type of files named like name, or ""
+// if the extension is unknown.
+func typeByExtension(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ct, ok := mediaTypes[ext]; ok {
+		return ct
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// storeFile writes the body of r as the file name. POST always answers
+// 201 Created; PUT answers 204 No Content when it replaced a file.
+func (h *FilesHandler) storeFile(w ResponseWriter, method, name string, r *Request) {
+	fi, err := os.Stat(name)
+	if err == nil && fi.IsDir() {
+		h.notAllowed(w)
+		return
+	}
+	var old int64
+	if fi != nil {
+		old = fi.Size()
+	}
+	size := uploadSize(r)
+	if size >= 0 {
+		if code := h.checkSpace(size-old, size, 0); code != 0 {
+			h.respond(w, code)
+			return
+		}
+	}
+	mode := h.FileMode
+	if mode == 0 {
+		mode = 0644
+	}
+	refused := 0
+	sum := sha256.New()
+	err = writeFileAtomic(name, mode, func(w io.Writer) error {
+		n, err := io.Copy(io.MultiWriter(w, sum), h.uploadBody(r))
+		if err != nil {
+			return err
+		}
+		// a chunked body's size is only known now, with it on disk
+		if size < 0 {
+			if refused = h.checkSpace(n-old, n, n); refused != 0 {
+				return errUploadRefused
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.uploadFailed(w, name, refused, err)
+		return
+	}
+	code := StatusCreated
+	if method == MethodPut && fi != nil {
+		code = StatusNoContent
+	}
+	h.setETag(w, name, sum)
+	w.SetStatus(code, StatusText(code))
+	w.SetBody(nil)
+	w.Write()
+}
+
+// appendFile adds the body of r to the end of the existing file name,
+// rewriting it whole so readers never see the old contents half extended.
+func (h *FilesHandler) appendFile(w ResponseWriter, name string, r *Request) {
+	fi, err := os.Stat(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		h.respond(w, StatusNotFound)
+		return
+	case err != nil:
+		h.logger().Error("appending to file", "path", name, "err", err)
+		h.respond(w, StatusInternalServerError)
+		return
+	case fi.IsDir():
+		h.notAllowed(w)
+		return
+	}
+	size := uploadSize(r)
+	if size >= 0 {
+		if code := h.checkSpace(size, fi.Size()+size, 0); code != 0 {
+			h.respond(w, code)
+			return
+		}
+	}
+	// the existing content is copied across rather than read into memory,
+	// since appending is how large files get built up a piece at a time
+	refused := 0
+	sum := sha256.New()
+	err = writeFileAtomic(name, fi.Mode().Perm(), func(w io.Writer) error {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close() // before the rename replaces it
+		w = io.MultiWriter(w, sum)
+		old, err := io.Copy(w, f)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(w, h.uploadBody(r))
+		if err != nil {
+			return err
+		}
+		if size < 0 {
+			total := old + n
+			if refused = h.checkSpace(n, total, total); refused != 0 {
+				return errUploadRefused
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		h.uploadFailed(w, name, refused, err)
+		return
+	}
+	h.setETag(w, name, sum)
+	w.SetStatus(StatusNoContent, StatusText(StatusNoContent))
+	w.SetBody(nil)
+	w.Write()
+}
+
+func (h *FilesHandler) deleteFile(w ResponseWriter, name string) {
+	fi, err := os.Stat(name)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		h.respond(w, StatusNotFound)
+		return
+	case err == nil && fi.IsDir():
+		h.notAllowed(w)
+		return
+	}
+	if err := os.Remove(name); err != nil {
+		h.logger().Error("removing file", "path", name, "err", err)
+		h.respond(w, StatusInternalServerError)
+		return
+	}
+	h.tags.forget(name)
+	w.SetStatus(StatusNoContent, StatusText(StatusNoContent))
+	w.SetBody(nil)
+	w.Write()
+}
+
+// lock serializes writes to name, returning the func releasing it.
+func (h *FilesHandler) lock(name string) (func(), error) {
+	unlock := h.locks.lock(name)
+	if !h.FileLock {
+		return unlock, nil
+	}
+	unlockDir, err := lockDir(filepath.Dir(name))
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return func() {
+		unlockDir()
+		unlock()
+	}, nil
+}
+
+// streamsBody has the server leave upload bodies unread, so checkUpload
+// can refuse one before it arrives and storeFile writes it to disk as it
+// streams in instead of holding it in memory first.
+func (h *FilesHandler) streamsBody(r *Request) bool {
+	return !h.ReadOnly && (r.Method == MethodPost || r.Method == MethodPut || r.Method == MethodPatch)
+}
+
+// checkUpload returns the status refusing the upload r, or 0 to store it:
+// 411 for a body sent without framing, which can't be told from an empty
+// file, and 413 for one whose Content-Length is over MaxFileSize.
+func (h *FilesHandler) checkUpload(r *Request) int {
+	cl := r.Header.Get("Content-Length")
+	if cl == "" && r.Header.Get("Transfer-Encoding") == "" {
+		return StatusLengthRequired
+	}
+	size := uploadSize(r)
+	if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+		size = max(size, n)
+	}
+	if h.MaxFileSize > 0 && size > h.MaxFileSize {
+		return StatusRequestEntityTooLarge
+	}
+	return 0
+}
+
+var (
+	// errUploadTooLarge ends a chunked upload as it passes MaxFileSize.
+	errUploadTooLarge = errors.New("http: upload larger than MaxFileSize")
+
+	// errUploadRefused abandons an upload checkSpace refused once written.
+	errUploadRefused = errors.New("http: upload refused")
+)
+
+// uploadSize returns the size of the body of r, or -1 for a chunked body
+// still to be read.
+func uploadSize(r *Request) int64 {
+	if r.body == nil {
+		return int64(len(r.Body))
+	}
+	if n, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64); err == nil {
+		return n
+	}
+	return -1
+}
+
+// uploadBody returns a reader over the body of r that fails with
+// errUploadTooLarge past MaxFileSize.
+func (h *FilesHandler) uploadBody(r *Request) io.Reader {
+	if h.MaxFileSize <= 0 {
+		return r.BodyReader()
+	}
+	return &uploadLimit{r: r.BodyReader(), n: h.MaxFileSize}
+}
+
+type uploadLimit struct {
+	r io.Reader
+	n int64 // bytes still allowed
+}
+
+func (l *uploadLimit) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	if l.n -= int64(n); l.n < 0 {
+		return n, errUploadTooLarge
+	}
+	return n, err
+}
+
+// uploadFailed answers an upload that writeFileAtomic abandoned: with the
+// status refusing it, 413 for one that outgrew MaxFileSize, 400 for a body
+// that ended early or was malformed, and 500 for anything else.
+func (h *FilesHandler) uploadFailed(w ResponseWriter, name string, refused int, err error) {
+	switch {
+	case refused != 0:
+		h.respond(w, refused)
+	case errors.Is(err, errUploadTooLarge), errors.Is(err, ErrBodyTooLarge):
+		h.respond(w, StatusRequestEntityTooLarge)
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, ErrMalformedMessage):
+		h.respond(w, StatusBadRequest)
+	default:
+		h.logger().Error("writing file", "path", name, "err", err)
+		h.respond(w, StatusInternalServerError)
+	}
+}
+
+func (h *FilesHandler) notAllowed(w ResponseWriter) {
+	allow := "GET, HEAD, POST, PUT, PATCH, DELETE"
+	if h.ReadOnly {
+		allow = "GET, HEAD"
+	}
+	w.SetHeader("Allow", allow)
+	h.respond(w, StatusMethodNotAllowed)
+}
+
+func (h *FilesHandler) respond(w ResponseWriter, code int) {
+	w.SetStatus(code, StatusText(code))
+	w.SetBody([]byte(StatusText(code)))
+	w.Write()
+}
+
+func (h *FilesHandler) logger() *slog.Logger {
+	return loggerOrDefault(h.Logger)
+}
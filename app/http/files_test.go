@@ -0,0 +1,370 @@
+package http_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestFilesHandler(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("hello"), 0644)
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	files := http.NewFilesHandler("/files/", root)
+	readOnly := &http.FilesHandler{Root: root, Prefix: "/ro/", ReadOnly: true}
+	site := &http.FilesHandler{Root: root, Prefix: "/site/", Index: "index.html", ListDirs: true, ContentTypes: true}
+	os.WriteFile(filepath.Join(root, "sub", "index.html"), []byte("idx"), 0644)
+	spa := &http.FilesHandler{Root: root, Prefix: "/app/", ReadOnly: true, Fallback: "sub/index.html", FallbackExclude: []string{"/app/api/"}}
+
+	tests := []struct {
+		h            http.Handler
+		method, path string
+		body         string
+		code         int
+		want         string
+	}{
+		{files, http.MethodGet, "/files/a.txt", "", 200, "hello"},
+		{files, http.MethodGet, "/files/missing", "", 404, "Not Found"},
+		{files, http.MethodGet, "/files/sub", "", 404, "Not Found"},
+		{files, http.MethodGet, "/files/", "", 404, "Not Found"},
+		{files, http.MethodGet, "/files/../files/a.txt", "", 404, "Not Found"},
+		{files, http.MethodGet, "/files/sub/../a.txt", "", 200, "hello"},
+		{files, http.MethodPost, "/files/b.txt", "posted", 201, ""},
+		{files, http.MethodGet, "/files/b.txt", "", 200, "posted"},
+		{files, http.MethodPut, "/files/b.txt", "put", 204, ""},
+		{files, http.MethodPatch, "/files/b.txt", "+patched", 204, ""},
+		{files, http.MethodGet, "/files/b.txt", "", 200, "put+patched"},
+		{files, http.MethodDelete, "/files/b.txt", "", 204, ""},
+		{files, http.MethodDelete, "/files/b.txt", "", 404, "Not Found"},
+		{files, http.MethodPatch, "/files/b.txt", "x", 404, "Not Found"},
+		{files, http.MethodPut, "/files/c.txt", "new", 201, ""},
+		{files, http.MethodDelete, "/files/sub", "", 405, "Method Not Allowed"},
+		{files, http.MethodOptions, "/files/a.txt", "", 405, "Method Not Allowed"},
+		{readOnly, http.MethodGet, "/ro/a.txt", "", 200, "hello"},
+		{readOnly, http.MethodPost, "/ro/d.txt", "x", 405, "Method Not Allowed"},
+		{readOnly, http.MethodDelete, "/ro/a.txt", "", 405, "Method Not Allowed"},
+		{site, http.MethodGet, "/site/sub/", "", 200, "idx"},
+		{site, http.MethodGet, "/site/sub", "", 301, "Moved Permanently"},
+		{site, http.MethodPost, "/site/sub", "x", 405, "Method Not Allowed"},
+		{spa, http.MethodGet, "/app/", "", 200, "idx"},
+		{spa, http.MethodGet, "/app/users/42", "", 200, "idx"},
+		{spa, http.MethodGet, "/app/a.txt", "", 200, "hello"},
+		{spa, http.MethodGet, "/app/missing.js", "", 404, "Not Found"},
+		{spa, http.MethodGet, "/app/api/users", "", 404, "Not Found"},
+		{spa, http.MethodPost, "/app/users/42", "x", 405, "Method Not Allowed"},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		tt.h.ServeHTTP(rec, req)
+		if rec.Code != tt.code || string(rec.Body) != tt.want {
+			t.Errorf("#%d: gotCode: %d gotBody: %q wantCode: %d wantBody: %q", i, rec.Code, rec.Body, tt.code, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "d.txt")); err == nil {
+		t.Errorf("read-only handler stored a file")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/site/", nil)
+	rec := httptest.NewRecorder()
+	site.ServeHTTP(rec, req)
+	if body := string(rec.Body); rec.Code != 200 || !strings.Contains(body, `<a href="./a.txt">a.txt</a>`) || !strings.Contains(body, `<a href="./sub/">sub/</a>`) {
+		t.Errorf("listing: gotCode: %d gotBody: %q", rec.Code, body)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "/site/sub/", nil)
+	rec = httptest.NewRecorder()
+	site.ServeHTTP(rec, req)
+	if ct := rec.GetHeader("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("index: gotContentType: %q", ct)
+	}
+}
+
+func TestFilesUpload(t *testing.T) {
+	root := t.TempDir()
+	files := &http.FilesHandler{Root: root, Prefix: "/files/", MaxFileSize: 4}
+	tests := []struct {
+		name    string
+		body    string
+		headers []string
+		code    int
+		stored  string
+	}{
+		{"a.txt", "abc", nil, 201, "abc"},
+		{"a.txt", "ab", nil, 201, "ab"},
+		{"b.txt", "", []string{"Content-Length", ""}, 411, ""},
+		{"c.txt", "abc", []string{"Content-Length", "", "Transfer-Encoding", "chunked"}, 201, "abc"},
+		{"d.txt", "abcde", nil, 413, ""},
+		{"e.txt", "abc", []string{"Content-Length", "100"}, 413, ""},
+		{"f.txt", "", nil, 201, ""},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "/files/"+tt.name, strings.NewReader(tt.body))
+		for j := 0; j < len(tt.headers); j += 2 {
+			if tt.headers[j+1] == "" {
+				req.Header.Del(tt.headers[j])
+			} else {
+				req.Header.Set(tt.headers[j], tt.headers[j+1])
+			}
+		}
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		stored, err := os.ReadFile(filepath.Join(root, tt.name))
+		if rec.Code != tt.code || string(stored) != tt.stored || (tt.code != 201) != (err != nil) {
+			t.Errorf("#%d: gotCode: %d gotStored: %q (%v) wantCode: %d wantStored: %q", i, rec.Code, stored, err, tt.code, tt.stored)
+		}
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 3 {
+		t.Errorf("gotEntries: %v wantEntries: a.txt c.txt f.txt", entries)
+	}
+}
+
+var filesStreamedUploadTest = []struct {
+	name   string
+	stream string // sent after the request line, up to the end of the headers
+	body   string // sent once the response to the headers is in, if any
+	status string
+	stored string // "" for no file
+	reused bool   // the connection serves a request after the upload
+}{
+	{"a.txt", "Content-Length: 5\r\n\r\nhello", "", "201 Created", "hello", true},
+	{"b.txt", "Transfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n", "", "201 Created", "abcde", true},
+	// refused by Content-Length before the body is sent at all
+	{"c.txt", "Content-Length: 9\r\n\r\n", "too large", "413 Request Entity Too Large", "", false},
+	{"d.txt", "Transfer-Encoding: chunked\r\n\r\n5\r\nabcde\r\n5\r\nfghij\r\n0\r\n\r\n", "", "413 Request Entity Too Large", "", false},
+	{"e.txt", "Transfer-Encoding: chunked\r\n\r\n3\r\nabc\r\nzz\r\n", "", "400 Bad Request", "", false},
+	{"f.txt", "Transfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n", "", "507 Insufficient Storage", "", true},
+}
+
+func TestFilesStreamedUpload(t *testing.T) {
+	root := t.TempDir()
+	mux := http.NewServeMux()
+	mux.Handle("/files/", &http.FilesHandler{Root: root, Prefix: "/files/", MaxFileSize: 8})
+	mux.Handle("/quota/", &http.FilesHandler{Root: root, Prefix: "/quota/", Quota: 14})
+	mux.HandleFunc("/next", func(w http.ResponseWriter, r *http.Request) { w.Write() })
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for i, tt := range filesStreamedUploadTest {
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		prefix := "/files/"
+		if strings.HasPrefix(tt.status, "507") {
+			prefix = "/quota/"
+		}
+		fmt.Fprintf(conn, "POST %s%s HTTP/1.1\r\nHost: a\r\n%s", prefix, tt.name, tt.stream)
+		br := bufio.NewReader(conn)
+		res, err := http.ReadResponse(br, &http.Request{Method: http.MethodPost})
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			conn.Close()
+			continue
+		}
+		if got := fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)); got != tt.status {
+			t.Errorf("#%d: gotStatus: %q wantStatus: %q", i, got, tt.status)
+		}
+		io.Copy(io.Discard, res.Body)
+		if tt.body != "" {
+			io.WriteString(conn, tt.body)
+		}
+		stored, err := os.ReadFile(filepath.Join(root, tt.name))
+		if string(stored) != tt.stored || (tt.stored == "") != (err != nil) {
+			t.Errorf("#%d: gotStored: %q (%v) wantStored: %q", i, stored, err, tt.stored)
+		}
+
+		io.WriteString(conn, "GET /next HTTP/1.1\r\nHost: a\r\n\r\n")
+		res, err = http.ReadResponse(br, &http.Request{Method: http.MethodGet})
+		if reused := err == nil && res.StatusCode == 200; reused != tt.reused {
+			t.Errorf("#%d: gotReused: %t (%v) wantReused: %t", i, reused, err, tt.reused)
+		}
+		conn.Close()
+	}
+	// abandoned uploads leave no temporary files behind
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 2 {
+		t.Errorf("gotEntries: %v wantEntries: a.txt b.txt", entries)
+	}
+}
+
+func TestFilesUploadBehindTimeout(t *testing.T) {
+	root := t.TempDir()
+	files := &http.FilesHandler{Root: root, Prefix: "/files/"}
+	srv := httptest.NewServer(http.TimeoutHandler(files, time.Second, ""))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/files/a.txt", strings.NewReader("hello"))
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	stored, err := os.ReadFile(filepath.Join(root, "a.txt"))
+	if res.StatusCode != http.StatusCreated || string(stored) != "hello" {
+		t.Errorf("gotCode: %d gotStored: %q (%v) wantStored: %q", res.StatusCode, stored, err, "hello")
+	}
+}
+
+func TestFilesConcurrentWrites(t *testing.T) {
+	for _, flock := range []bool{false, true} {
+		root := t.TempDir()
+		os.WriteFile(filepath.Join(root, "log.txt"), nil, 0644)
+		files := &http.FilesHandler{Root: root, Prefix: "/files/", FileLock: flock}
+		const writers = 50
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest(http.MethodPatch, "/files/log.txt", strings.NewReader("line\n"))
+				rec := httptest.NewRecorder()
+				files.ServeHTTP(rec, req)
+				if rec.Code != 204 {
+					t.Errorf("gotCode: %d wantCode: 204", rec.Code)
+				}
+			}()
+		}
+		wg.Wait()
+		if b, _ := os.ReadFile(filepath.Join(root, "log.txt")); string(b) != strings.Repeat("line\n", writers) {
+			t.Errorf("flock %v: gotLines: %d wantLines: %d", flock, strings.Count(string(b), "\n"), writers)
+		}
+	}
+}
+
+func TestFilesQuota(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("12345"), 0644)
+	files := &http.FilesHandler{Root: root, Prefix: "/files/", Quota: 10}
+	tests := []struct {
+		method, name, body string
+		code               int
+	}{
+		{http.MethodPost, "b.txt", "123456", 507},
+		{http.MethodPost, "b.txt", "12345", 201},
+		{http.MethodPatch, "b.txt", "6", 507},
+		{http.MethodPut, "a.txt", "123", 204},
+		{http.MethodPatch, "b.txt", "67", 204},
+		{http.MethodPut, "a.txt", "1234", 507},
+		{http.MethodDelete, "b.txt", "", 204},
+		{http.MethodPut, "a.txt", "1234567890", 204},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "/files/"+tt.name, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("#%d: %s %s: gotCode: %d wantCode: %d", i, tt.method, tt.name, rec.Code, tt.code)
+		}
+	}
+
+	full := &http.FilesHandler{Root: root, Prefix: "/files/", MinFreeSpace: 1 << 62}
+	req, _ := http.NewRequest(http.MethodPost, "/files/c.txt", strings.NewReader("x"))
+	rec := httptest.NewRecorder()
+	full.ServeHTTP(rec, req)
+	if rec.Code != 507 || string(rec.Body) != "Insufficient Storage" {
+		t.Errorf("min free space: gotCode: %d gotBody: %q", rec.Code, rec.Body)
+	}
+}
+
+func TestFilesConditionalWrites(t *testing.T) {
+	files := &http.FilesHandler{Root: t.TempDir(), Prefix: "/files/"}
+	var tags []string
+	do := func(method, name, body string, headers ...string) int {
+		req, _ := http.NewRequest(method, "/files/"+name, strings.NewReader(body))
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		if tag := rec.GetHeader("ETag"); tag != "" {
+			tags = append(tags, tag)
+		}
+		return rec.Code
+	}
+	tag := func(i int) string {
+		if i < len(tags) {
+			return tags[i]
+		}
+		return `"missing"`
+	}
+
+	tests := []struct {
+		code int
+		got  func() int
+	}{
+		{201, func() int { return do(http.MethodPut, "a.txt", "v1", "If-None-Match", "*") }},
+		{412, func() int { return do(http.MethodPut, "a.txt", "v2", "If-None-Match", "*") }},
+		{204, func() int { return do(http.MethodPut, "a.txt", "v22", "If-Match", tag(0)) }},
+		{412, func() int { return do(http.MethodPut, "a.txt", "v333", "If-Match", tag(0)) }},
+		{412, func() int { return do(http.MethodPatch, "a.txt", "+", "If-None-Match", tag(1)) }},
+		{204, func() int { return do(http.MethodPatch, "a.txt", "+", "If-None-Match", tag(0)) }},
+		{412, func() int { return do(http.MethodDelete, "a.txt", "", "If-Match", "W/"+tag(2)) }},
+		{204, func() int { return do(http.MethodDelete, "a.txt", "", "If-Match", `"other", `+tag(2)) }},
+		{412, func() int { return do(http.MethodPut, "b.txt", "new", "If-Match", "*") }},
+	}
+	for i, tt := range tests {
+		if code := tt.got(); code != tt.code {
+			t.Errorf("#%d: gotCode: %d wantCode: %d (tags %q)", i, code, tt.code, tags)
+		}
+	}
+	if len(tags) != 3 || tags[0] == tags[1] || tags[1] == tags[2] {
+		t.Errorf("gotTags: %q", tags)
+	}
+}
+
+func TestMediaHandler(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "clip.webm"), []byte("0123456789"), 0644)
+	media := http.NewMediaHandler("/media/", root)
+
+	get := func(headers ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/media/clip.webm", nil)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		rec := httptest.NewRecorder()
+		media.ServeHTTP(rec, req)
+		return rec
+	}
+	full := get()
+	if full.Code != 200 || full.GetHeader("Content-Type") != "video/webm" || full.GetHeader("Accept-Ranges") != "bytes" || full.GetHeader("Content-Encoding") != "identity" {
+		t.Errorf("full: gotCode: %d gotHeader: %v", full.Code, full.HeaderMap)
+	}
+	tag, modified := full.GetHeader("ETag"), full.GetHeader("Last-Modified")
+
+	tests := []struct {
+		headers []string
+		code    int
+		body    string
+		rng     string
+	}{
+		{[]string{"Range", "bytes=2-5"}, 206, "2345", "bytes 2-5/10"},
+		{[]string{"Range", "bytes=-3"}, 206, "789", "bytes 7-9/10"},
+		{[]string{"Range", "bytes=10-"}, 416, "Requested Range Not Satisfiable", "bytes */10"},
+		{[]string{"Range", "bytes=0-1", "If-Range", tag}, 206, "01", "bytes 0-1/10"},
+		{[]string{"Range", "bytes=0-1", "If-Range", modified}, 206, "01", "bytes 0-1/10"},
+		{[]string{"Range", "bytes=0-1", "If-Range", `"stale"`}, 200, "0123456789", ""},
+		{[]string{"If-None-Match", tag}, 304, "", ""},
+		{[]string{"If-Modified-Since", modified}, 304, "", ""},
+		{[]string{"If-None-Match", `"stale"`, "If-Modified-Since", modified}, 200, "0123456789", ""},
+	}
+	for i, tt := range tests {
+		rec := get(tt.headers...)
+		if rec.Code != tt.code || string(rec.Body) != tt.body || rec.GetHeader("Content-Range") != tt.rng {
+			t.Errorf("#%d: gotCode: %d gotBody: %q gotRange: %q wantCode: %d wantBody: %q wantRange: %q",
+				i, rec.Code, rec.Body, rec.GetHeader("Content-Range"), tt.code, tt.body, tt.rng)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileTags caches the ETags of a FilesHandler's files: a hash of their
+// contents, so a tag only changes when the bytes do. Uploads record the
+// hash taken while they were written; files changed some other way are
+// hashed again the first time their size or modification time is seen to
+// differ.
+type fileTags struct {
+	mu sync.Mutex
+	m  map[string]fileTag
+}
+
+type fileTag struct {
+	size    int64
+	modTime time.Time
+	tag     string
+}
+
+// hashETag formats a SHA-256 sum as a strong ETag.
+func hashETag(sum []byte) string {
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// get returns the ETag of the file name, which fi describes.
+func (t *fileTags) get(name string, fi os.FileInfo) (string, error) {
+	t.mu.Lock()
+	e, ok := t.m[name]
+	t.mu.Unlock()
+	if ok && e.size == fi.Size() && e.modTime.Equal(fi.ModTime()) {
+		return e.tag, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return t.put(name, fi, h), nil
+}
+
+// put records the hash h of the contents of name, which fi describes,
+// and returns the resulting ETag.
+func (t *fileTags) put(name string, fi os.FileInfo, h hash.Hash) string {
+	tag := hashETag(h.Sum(nil))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.m == nil {
+		t.m = make(map[string]fileTag)
+	}
+	t.m[name] = fileTag{size: fi.Size(), modTime: fi.ModTime(), tag: tag}
+	return tag
+}
+
+// forget drops the ETag of a file that was removed.
+func (t *fileTags) forget(name string) {
+	t.mu.Lock()
+	delete(t.m, name)
+	t.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package http_test
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func contentETag(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+var fileETagTest = []struct {
+	method   string
+	body     string
+	external string // written behind the handler's back first, if set
+	code     int
+	contents string // the file's contents afterwards, which the ETag hashes
+}{
+	{http.MethodPut, "hello", "", 201, "hello"},
+	{http.MethodPut, "hello", "", 204, "hello"}, // rewritten, same tag
+	{http.MethodPut, "world", "", 204, "world"}, // same size, new tag
+	{http.MethodPatch, "!", "", 204, "world!"},
+	{http.MethodGet, "", "", 200, "world!"},
+	{http.MethodGet, "", "changed", 200, "changed"},
+	{http.MethodGet, "", "CHANGED", 200, "CHANGED"}, // same size
+}
+
+func TestFileETag(t *testing.T) {
+	root := t.TempDir()
+	name := filepath.Join(root, "a.txt")
+	files := &http.FilesHandler{Root: root, Prefix: "/files/", Validators: true}
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, tt := range fileETagTest {
+		if tt.external != "" {
+			os.WriteFile(name, []byte(tt.external), 0644)
+			// distinct mtimes, as a second apart as coarse file systems
+			// record them
+			os.Chtimes(name, mtime.Add(time.Duration(i)*time.Second), mtime.Add(time.Duration(i)*time.Second))
+		}
+		req, _ := http.NewRequest(tt.method, "/files/a.txt", strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		files.ServeHTTP(rec, req)
+		if want := contentETag(tt.contents); rec.Code != tt.code || rec.GetHeader("ETag") != want {
+			t.Errorf("#%d: %s %q gotCode: %d gotETag: %q wantCode: %d wantETag: %q",
+				i, tt.method, tt.body, rec.Code, rec.GetHeader("ETag"), tt.code, want)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/files/a.txt", nil)
+	req.Header.Set("If-None-Match", contentETag("CHANGED"))
+	rec := httptest.NewRecorder()
+	files.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: gotCode: %d wantCode: %d", rec.Code, http.StatusNotModified)
+	}
+}
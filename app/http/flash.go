@@ -0,0 +1,73 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// FlashCookie is the cookie carrying pending flash messages. There is no
+// server-side session store in this package, so flashes travel with the
+// client between the redirect and the page that shows them.
+const FlashCookie = "_flash"
+
+// Flash is a one-shot message shown on the next page a client loads,
+// typically after a POST redirects to a GET.
+type Flash struct {
+	Kind    string `json:"k"` // e.g. "success", "error"
+	Message string `json:"m"`
+}
+
+// SetFlash queues flashes for the client's next request. It replaces any
+// flashes set earlier in the same response.
+func SetFlash(w ResponseWriter, flashes ...Flash) {
+	b, _ := json.Marshal(flashes)
+	w.SetHeader("Set-Cookie", FlashCookie+"="+base64.RawURLEncoding.EncodeToString(b)+"; Path=/; HttpOnly; SameSite=Lax")
+}
+
+// Flashes returns the flashes queued for r and clears them, so each one
+// is only ever shown once. Tampered or unreadable cookies yield no
+// flashes.
+func Flashes(w ResponseWriter, r *Request) []Flash {
+	v, ok := cookieValue(r, FlashCookie)
+	if !ok {
+		return nil
+	}
+	w.SetHeader("Set-Cookie", FlashCookie+"=; Path=/; Max-Age=0; HttpOnly; SameSite=Lax")
+
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil
+	}
+	var flashes []Flash
+	if json.Unmarshal(b, &flashes) != nil {
+		return nil
+	}
+	return flashes
+}
+
+// cookieValue returns the value of the named cookie sent with r.
+func cookieValue(r *Request, name string) (string, bool) {
+	for _, line := range r.Header["Cookie"] {
+		for _, pair := range strings.Split(line, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && k == name {
+				return strings.Trim(v, `"`), true
+			}
+		}
+	}
+	return "", false
+}
+
+// Page is the data RenderPage hands to templates: the handler's own data
+// plus the flashes consumed for this request.
+type Page struct {
+	Data    any
+	Flashes []Flash
+}
+
+// RenderPage is Render with the request's pending flashes consumed and
+// passed to the template as .Flashes, next to the handler's data in .Data.
+func (rr *Renderer) RenderPage(w ResponseWriter, r *Request, status int, name string, data any) error {
+	return rr.Render(w, status, name, Page{Data: data, Flashes: Flashes(w, r)})
+}
@@ -0,0 +1,89 @@
+package http_test
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func flashCookie(s string) string {
+	return http.FlashCookie + "=" + base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+var flashesTest = []struct {
+	cookie  string
+	flashes []http.Flash
+	cleared bool
+}{
+	{"", nil, false},
+	{"session=abc", nil, false},
+	{flashCookie(`[{"k":"success","m":"Saved"}]`), []http.Flash{{"success", "Saved"}}, true},
+	{"session=abc; " + flashCookie(`[{"k":"error","m":"a"},{"k":"info","m":"b"}]`), []http.Flash{{"error", "a"}, {"info", "b"}}, true},
+	{http.FlashCookie + "=!!not-base64", nil, true},
+	{flashCookie(`not json`), nil, true},
+}
+
+func TestFlashes(t *testing.T) {
+	for i, tt := range flashesTest {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if tt.cookie != "" {
+			req.Header.Set("Cookie", tt.cookie)
+		}
+		rec := httptest.NewRecorder()
+		got := http.Flashes(rec, req)
+		if !reflect.DeepEqual(got, tt.flashes) {
+			t.Errorf("#%d: gotFlashes: %v wantFlashes: %v", i, got, tt.flashes)
+		}
+		setCookie := rec.GetHeader("Set-Cookie")
+		if cleared := strings.HasPrefix(setCookie, http.FlashCookie+"=;") && strings.Contains(setCookie, "Max-Age=0"); cleared != tt.cleared {
+			t.Errorf("#%d: gotSetCookie: %q wantCleared: %t", i, setCookie, tt.cleared)
+		}
+	}
+}
+
+func TestFlashPostRedirectGet(t *testing.T) {
+	rr := http.NewRenderer(fstest.MapFS{
+		"page.html": {Data: []byte(`{{range .Flashes}}[{{.Kind}}: {{.Message}}]{{end}}{{.Data}}`)},
+	})
+
+	// the POST queues a flash and redirects
+	add := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetFlash(w, http.Flash{Kind: "success", Message: "Item <added>"})
+		w.SetStatus(http.StatusSeeOther, http.StatusText(http.StatusSeeOther))
+		w.SetHeader("Location", "/items")
+		w.Write()
+	})
+	post, _ := http.NewRequest(http.MethodPost, "/items", nil)
+	rec := httptest.NewRecorder()
+	add.ServeHTTP(rec, post)
+	setCookie := rec.GetHeader("Set-Cookie")
+	cookie, _, _ := strings.Cut(setCookie, ";")
+	if rec.Code != http.StatusSeeOther || !strings.HasPrefix(cookie, http.FlashCookie+"=") || !strings.Contains(setCookie, "HttpOnly") {
+		t.Fatalf("gotCode: %d gotSetCookie: %q", rec.Code, setCookie)
+	}
+
+	// the GET it redirects to shows it once
+	for i, want := range []string{"[success: Item &lt;added&gt;]items", "items"} {
+		get, _ := http.NewRequest(http.MethodGet, "/items", nil)
+		if cookie != "" {
+			get.Header.Set("Cookie", cookie)
+		}
+		rec := httptest.NewRecorder()
+		if err := rr.RenderPage(rec, get, 200, "page.html", "items"); err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if string(rec.Body) != want {
+			t.Errorf("#%d: gotBody: %q wantBody: %q", i, rec.Body, want)
+		}
+		// the browser applies the clearing cookie
+		cookie, _, _ = strings.Cut(rec.GetHeader("Set-Cookie"), ";")
+		if cookie == http.FlashCookie+"=" {
+			cookie = ""
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package http
+
+// lockDir does nothing where there is no flock; writers are only
+// serialized within the process.
+func lockDir(dir string) (func(), error) {
+	return func() {}, nil
+}
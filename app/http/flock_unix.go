@@ -0,0 +1,25 @@
+//go:build unix
+
+package http
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockDir takes an exclusive flock(2) on the directory dir, blocking until
+// other holders release it, and returns the func releasing it.
+func lockDir(dir string) (func(), error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
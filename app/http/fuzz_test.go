@@ -0,0 +1,141 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets below run their seeds as part of go test; explore
+// further with, for example:
+//
+//	go test ./app/http -run '^$' -fuzz FuzzChunkedReader -fuzztime 1m
+
+func FuzzParseRequestLine(f *testing.F) {
+	for _, s := range []string{"GET / HTTP/1.1", "OPTIONS * HTTP/1.1", "GET  / HTTP/1.1", "GET /", "", "A B C D"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, line string) {
+		method, uri, proto, ok := parseRequestLine(line)
+		if ok && method+" "+uri+" "+proto != line {
+			t.Errorf("parseRequestLine(%q) = %q %q %q, which doesn't join back", line, method, uri, proto)
+		}
+		if major, minor, ok := parseHTTPVersion(proto); ok && (major > 9 || minor > 9) {
+			t.Errorf("parseHTTPVersion(%q) = %d.%d", proto, major, minor)
+		}
+	})
+}
+
+func FuzzReadHeader(f *testing.F) {
+	for _, s := range []string{
+		"Host: a\r\n\r\n",
+		"Accept: a\r\naccept: b\r\nCookie: x=1\r\nCookie: y=2\r\n\r\n",
+		"X: a\r\n folded\r\n\r\n",
+		"Content-Length: 5\r\nContent-Length: 6\r\n\r\n",
+		"Bad Header\r\n\r\n",
+		"X: a\rb\r\n\r\n",
+	} {
+		f.Add(s, false)
+		f.Add(s, true)
+	}
+	f.Fuzz(func(t *testing.T, raw string, strict bool) {
+		h := make(Header)
+		if _, err := readHeader(bufio.NewReader(strings.NewReader(raw)), h, nil, true, strict); err != nil {
+			return
+		}
+		for key, values := range h {
+			if !validHeaderKey([]byte(key)) || key != canonicalHeaderKey([]byte(key)) {
+				t.Errorf("bad key %q from %q", key, raw)
+			}
+			for _, v := range values {
+				if strings.ContainsAny(v, "\r\n") && strict {
+					t.Errorf("value %q of %s holds a line break in strict mode", v, key)
+				}
+			}
+		}
+	})
+}
+
+func FuzzChunkedReader(f *testing.F) {
+	for _, s := range []string{
+		"0\r\n\r\n",
+		"3\r\nabc\r\n0\r\n\r\n",
+		"5;ext=1\r\nhello\r\n6;q=\"x\"\r\n world\r\n0\r\nX-Trailer: v\r\n\r\n",
+		"3\nabc\n0\n\n",
+		"ffffffffffffffff\r\n",
+		"0x3\r\nabc\r\n0\r\n\r\n",
+	} {
+		f.Add(s, false)
+		f.Add(s, true)
+	}
+	f.Fuzz(func(t *testing.T, raw string, strict bool) {
+		cr := &chunkedReader{r: bufio.NewReader(strings.NewReader(raw)), strict: strict}
+		body, err := io.ReadAll(io.LimitReader(cr, 1<<20))
+		if err != nil || int64(len(body)) >= 1<<20 {
+			return
+		}
+		if len(body) > len(raw) {
+			t.Fatalf("decoded %d bytes from %d", len(body), len(raw))
+		}
+		// what decodes must re-encode to the same bytes
+		var enc bytes.Buffer
+		if len(body) > 0 {
+			fmt.Fprintf(&enc, "%x\r\n%s\r\n", len(body), body)
+		}
+		enc.WriteString("0\r\n\r\n")
+		again, err := io.ReadAll(&chunkedReader{r: bufio.NewReader(&enc), strict: true})
+		if err != nil || !bytes.Equal(again, body) {
+			t.Errorf("round trip of %q: got (%q, %v)", body, again, err)
+		}
+	})
+}
+
+func FuzzReadRequest(f *testing.F) {
+	for _, s := range []string{
+		"GET / HTTP/1.1\r\nHost: a\r\n\r\n",
+		"POST /p?q=1 HTTP/1.1\r\nContent-Length: 3\r\n\r\nabc",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nContent-Length: 6\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+		"GET / HTTP/2.0\r\n\r\n",
+		"OPTIONS * HTTP/1.1\r\n\r\n",
+	} {
+		f.Add(s, false)
+		f.Add(s, true)
+	}
+	f.Fuzz(func(t *testing.T, raw string, strict bool) {
+		const maxBody = 1 << 10
+		req, err := readRequest(bufio.NewReader(strings.NewReader(raw)), new(Request), readOptions{maxBody: maxBody, strict: strict})
+		if err != nil {
+			return
+		}
+		if len(req.Body) > maxBody {
+			t.Errorf("body of %d bytes exceeds the limit", len(req.Body))
+		}
+		if req.URL == nil || req.Method == "" {
+			t.Errorf("incomplete request from %q: %+v", raw, req)
+		}
+		if strict && req.Header.Get("Transfer-Encoding") != "" && req.Header.Get("Content-Length") != "" {
+			t.Errorf("strict mode kept both framing headers of %q", raw)
+		}
+	})
+}
+
+// FuzzParseRange checks that every range parseRange accepts lies within
+// the representation.
+func FuzzParseRange(f *testing.F) {
+	for _, s := range []string{"bytes=0-9", "bytes=-5", "bytes=5-", "bytes=9-3", "bytes=0-1,2-3", "bytes=99999999999999999999-"} {
+		f.Add(s, int64(10))
+	}
+	f.Fuzz(func(t *testing.T, header string, size int64) {
+		if size < 0 {
+			return
+		}
+		br, ok, err := parseRange(header, size)
+		if ok && (err != nil || br.start < 0 || br.length <= 0 || br.start+br.length > size) {
+			t.Errorf("parseRange(%q, %d) = %+v, %v outside the representation", header, size, br, err)
+		}
+	})
+}
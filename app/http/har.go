@@ -0,0 +1,304 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultHARMaxEntries is how many exchanges a HARRecorder keeps when
+	// MaxEntries is zero.
+	DefaultHARMaxEntries = 1000
+
+	// DefaultHARMaxBody is how many body bytes a HARRecorder keeps per
+	// message when MaxBody is zero.
+	DefaultHARMaxBody = 64 << 10
+)
+
+// HARRecorder is middleware that records the requests it serves and the
+// responses handlers give them, for saving as an HTTP Archive (HAR 1.2)
+// file to open in browser dev tools or HAR viewers when chasing client
+// interoperability problems. Credentials are redacted as by WireDump and
+// bodies truncated. Response headers are those the handler set; framing
+// headers the server adds on the wire are not included.
+//
+//	har := &http.HARRecorder{}
+//	srv.Handler = har.Handler(mux)
+//	// later
+//	har.WriteFile("/tmp/session.har")
+type HARRecorder struct {
+	// MaxEntries caps the exchanges kept, dropping the oldest first. Zero
+	// means DefaultHARMaxEntries.
+	MaxEntries int
+
+	// MaxBody caps the body bytes kept per message. Zero means
+	// DefaultHARMaxBody, negative omits bodies entirely.
+	MaxBody int
+
+	// Redact lists additional header names whose values are replaced.
+	Redact []string
+
+	mu      sync.Mutex
+	entries []harEntry
+}
+
+// Handler returns next wrapped so that every exchange is recorded.
+func (h *HARRecorder) Handler(next Handler) Handler {
+	return wrapHandler("HARRecorder", next, func(w ResponseWriter, r *Request) {
+		hw := &harWriter{BaseResponseWriter: BaseResponseWriter{w}, code: StatusOK, headers: map[string]string{}}
+		start := time.Now()
+		next.ServeHTTP(hw, r)
+		h.add(h.entry(r, hw, start, time.Since(start)))
+	})
+}
+
+// WriteTo writes the recorded exchanges to w as a HAR document.
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	h.mu.Lock()
+	doc := harDocument{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "codecrafters-http-server-go", Version: "1"},
+		Entries: slices.Clone(h.entries),
+	}}
+	h.mu.Unlock()
+	if doc.Log.Entries == nil {
+		doc.Log.Entries = []harEntry{}
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(b, '\n'))
+	return int64(n), err
+}
+
+// WriteFile saves the recorded exchanges as a HAR file named name,
+// replacing any previous one atomically.
+func (h *HARRecorder) WriteFile(name string) error {
+	return writeFileAtomic(name, 0644, func(w io.Writer) error {
+		_, err := h.WriteTo(w)
+		return err
+	})
+}
+
+// Reset drops the recorded exchanges.
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	h.entries = nil
+	h.mu.Unlock()
+}
+
+func (h *HARRecorder) add(e harEntry) {
+	limit := h.MaxEntries
+	if limit <= 0 {
+		limit = DefaultHARMaxEntries
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) >= limit {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-limit+1)
+	}
+	h.entries = append(h.entries, e)
+}
+
+func (h *HARRecorder) entry(r *Request, hw *harWriter, start time.Time, elapsed time.Duration) harEntry {
+	url := "http://" + r.Header.Get("Host") + r.Path
+	req := harRequest{
+		Method:      r.Method,
+		URL:         url,
+		HTTPVersion: r.Proto,
+		Cookies:     []harPair{},
+		Headers:     h.pairs(r.Header),
+		QueryString: []harPair{},
+		HeadersSize: -1,
+		BodySize:    len(r.Body),
+	}
+	if r.URL != nil {
+		q := r.URL.Query()
+		for _, name := range slices.Sorted(maps.Keys(q)) {
+			for _, v := range q[name] {
+				req.QueryString = append(req.QueryString, harPair{name, v})
+			}
+		}
+	}
+	if len(r.Body) > 0 {
+		text, encoding, comment := h.body(r.Body)
+		if encoding != "" {
+			// postData has no encoding field, so say so in the comment
+			comment = strings.TrimPrefix(comment+"; "+encoding+" encoded", "; ")
+		}
+		req.PostData = &harPostData{MimeType: r.Header.Get("Content-Type"), Text: text, Comment: comment}
+	}
+
+	headers := make(Header, len(hw.headers))
+	for k, v := range hw.headers {
+		headers.Set(k, v)
+	}
+	text, encoding, comment := h.body(hw.body)
+	res := harResponse{
+		Status:      hw.code,
+		StatusText:  hw.text,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []harPair{},
+		Headers:     h.pairs(headers),
+		Content: harContent{
+			Size:     len(hw.body),
+			MimeType: headers.Get("Content-Type"),
+			Text:     text,
+			Encoding: encoding,
+			Comment:  comment,
+		},
+		RedirectURL: headers.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(hw.body),
+	}
+	if res.StatusText == "" {
+		res.StatusText = StatusText(res.Status)
+	}
+
+	ms := float64(elapsed) / float64(time.Millisecond)
+	return harEntry{
+		StartedDateTime: start.UTC().Format(time.RFC3339Nano),
+		Time:            ms,
+		Request:         req,
+		Response:        res,
+		Cache:           struct{}{},
+		Timings:         harTimings{Send: 0, Wait: ms, Receive: 0},
+	}
+}
+
+// pairs lists hdr in name order with sensitive values redacted.
+func (h *HARRecorder) pairs(hdr Header) []harPair {
+	pairs := []harPair{}
+	for _, name := range slices.Sorted(maps.Keys(hdr)) {
+		for _, v := range hdr[name] {
+			if redactedHeader(name, h.Redact) {
+				v = "[redacted]"
+			}
+			pairs = append(pairs, harPair{name, v})
+		}
+	}
+	return pairs
+}
+
+// body returns the text HAR stores for body, base64 encoded if it isn't
+// UTF-8, and a comment noting any truncation.
+func (h *HARRecorder) body(body []byte) (text, encoding, comment string) {
+	limit := h.MaxBody
+	if limit == 0 {
+		limit = DefaultHARMaxBody
+	}
+	if limit < 0 {
+		if len(body) > 0 {
+			comment = fmt.Sprintf("%d body bytes omitted", len(body))
+		}
+		return "", "", comment
+	}
+	if len(body) > limit {
+		comment = fmt.Sprintf("truncated from %d bytes", len(body))
+		body = body[:limit]
+	}
+	if utf8.Valid(body) {
+		return string(body), "", comment
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64", comment
+}
+
+// harWriter records what the handler sends.
+type harWriter struct {
+	BaseResponseWriter
+	code    int
+	text    string
+	headers map[string]string
+	body    []byte
+}
+
+func (hw *harWriter) SetStatus(code int, text string) {
+	hw.code, hw.text = code, text
+	hw.ResponseWriter.SetStatus(code, text)
+}
+
+func (hw *harWriter) SetHeader(key, value string) {
+	hw.headers[key] = value
+	hw.ResponseWriter.SetHeader(key, value)
+}
+
+func (hw *harWriter) Write() error {
+	hw.body = hw.GetBody()
+	return hw.ResponseWriter.Write()
+}
+
+// The HAR 1.2 document, as far as a server can fill it in.
+type (
+	harDocument struct {
+		Log harLog `json:"log"`
+	}
+	harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	harEntry struct {
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+	}
+	harRequest struct {
+		Method      string       `json:"method"`
+		URL         string       `json:"url"`
+		HTTPVersion string       `json:"httpVersion"`
+		Cookies     []harPair    `json:"cookies"`
+		Headers     []harPair    `json:"headers"`
+		QueryString []harPair    `json:"queryString"`
+		PostData    *harPostData `json:"postData,omitempty"`
+		HeadersSize int          `json:"headersSize"`
+		BodySize    int          `json:"bodySize"`
+	}
+	harResponse struct {
+		Status      int        `json:"status"`
+		StatusText  string     `json:"statusText"`
+		HTTPVersion string     `json:"httpVersion"`
+		Cookies     []harPair  `json:"cookies"`
+		Headers     []harPair  `json:"headers"`
+		Content     harContent `json:"content"`
+		RedirectURL string     `json:"redirectURL"`
+		HeadersSize int        `json:"headersSize"`
+		BodySize    int        `json:"bodySize"`
+	}
+	harPair struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+		Comment  string `json:"comment,omitempty"`
+	}
+	harContent struct {
+		Size     int    `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+	harTimings struct {
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+	}
+)
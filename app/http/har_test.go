@@ -0,0 +1,67 @@
+package http_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestHARRecorder(t *testing.T) {
+	har := &http.HARRecorder{MaxEntries: 2, MaxBody: 4}
+	h := har.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetStatus(http.StatusCreated, "Created")
+		w.SetHeader("Content-Type", "text/plain")
+		w.SetHeader("Set-Cookie", "session=abc")
+		w.SetBody([]byte("created " + r.Path))
+		w.Write()
+	}))
+	for _, path := range []string{"/dropped", "/a?x=1&x=2", "/b"} {
+		req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader("payload"))
+		req.Header.Set("Authorization", "Bearer secret")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var out strings.Builder
+	if _, err := har.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Log struct {
+			Version string
+			Entries []struct {
+				Request struct {
+					URL         string
+					Headers     []struct{ Name, Value string }
+					QueryString []struct{ Name, Value string }
+					PostData    struct{ Text, Comment string }
+				}
+				Response struct {
+					Status  int
+					Content struct{ Text string }
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out.String()), &doc); err != nil {
+		t.Fatalf("invalid HAR: %v\n%s", err, out.String())
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 2 {
+		t.Fatalf("gotVersion: %q gotEntries: %d", doc.Log.Version, len(doc.Log.Entries))
+	}
+	e := doc.Log.Entries[0]
+	if e.Request.URL != "http://example.com/a?x=1&x=2" || len(e.Request.QueryString) != 2 {
+		t.Errorf("gotURL: %q gotQuery: %v", e.Request.URL, e.Request.QueryString)
+	}
+	if e.Request.PostData.Text != "payl" || e.Request.PostData.Comment != "truncated from 7 bytes" {
+		t.Errorf("gotPostData: %+v", e.Request.PostData)
+	}
+	if e.Response.Status != http.StatusCreated || e.Response.Content.Text != "crea" {
+		t.Errorf("gotResponse: %+v", e.Response)
+	}
+	if strings.Contains(out.String(), "secret") || strings.Contains(out.String(), "session=abc") {
+		t.Errorf("credentials not redacted:\n%s", out.String())
+	}
+}
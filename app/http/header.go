@@ -2,6 +2,10 @@ package http
 
 import "net/textproto"
 
+// TimeFormat is the time format used in HTTP headers such as Date,
+// Last-Modified and Retry-After. The time must be in UTC.
+const TimeFormat = "Mon, 02 Jan 2006 15:04:05 GMT"
+
 // Header represents the key-value pair in an HTTP header
 type Header map[string][]string
 
@@ -15,3 +19,23 @@ func (h Header) Get(key string) string {
 func (h Header) Set(key, value string) {
 	textproto.MIMEHeader(h).Set(key, value)
 }
+
+func (h Header) Add(key, value string) {
+	textproto.MIMEHeader(h).Add(key, value)
+}
+
+func (h Header) Del(key string) {
+	textproto.MIMEHeader(h).Del(key)
+}
+
+// Clone returns a deep copy of h, or nil if h is nil.
+func (h Header) Clone() Header {
+	if h == nil {
+		return nil
+	}
+	h2 := make(Header, len(h))
+	for k, v := range h {
+		h2[k] = append([]string(nil), v...)
+	}
+	return h2
+}
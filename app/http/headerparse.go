@@ -0,0 +1,251 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+)
+
+var errMalformedHeader error = &malformedError{"http: malformed header line", "malformed_header"}
+
+// errObsFold is returned in strict mode for a header line continuing the
+// previous one, as RFC 9112 section 5.2 lets a server reject.
+var errObsFold error = &malformedError{"http: obsolete line folding", "malformed_header"}
+
+// errBareLF is returned for a line ending in LF without the CR that RFC
+// 9112 section 2.2 requires, unless bare LF is allowed.
+var errBareLF = errors.New("http: line not terminated by CRLF")
+
+// commonHeaderKeys are canonicalized by table lookup instead of building a
+// new string, which covers nearly every header real clients send.
+var commonHeaderKeys = []string{
+	"Accept",
+	"Accept-Encoding",
+	"Accept-Language",
+	"Authorization",
+	"Cache-Control",
+	"Connection",
+	"Content-Length",
+	"Content-Type",
+	"Cookie",
+	"Expect",
+	"Host",
+	"If-Modified-Since",
+	"If-None-Match",
+	"Origin",
+	"Pragma",
+	"Range",
+	"Referer",
+	"Traceparent",
+	"Transfer-Encoding",
+	"Upgrade",
+	"User-Agent",
+	"X-Forwarded-For",
+}
+
+// commonHeaderValues are interned so that the usual values of Connection,
+// Accept and friends don't allocate per request.
+var commonHeaderValues = []string{
+	"*/*",
+	"close",
+	"keep-alive",
+	"gzip",
+	"gzip, deflate",
+	"gzip, deflate, br",
+	"chunked",
+	"no-cache",
+	"application/json",
+	"text/plain",
+}
+
+// A headerPolicy says what readHeader does when a field appears more than
+// once in a header section.
+type headerPolicy int
+
+const (
+	// keepRepeats keeps each occurrence as its own value, in order. It
+	// applies to fields missing from headerPolicies.
+	keepRepeats headerPolicy = iota
+	// singleton fields may appear once; a repeat is an error.
+	singleton
+	// sameValue fields may repeat only with identical values, which are
+	// collapsed to one (RFC 9112 section 6.3 for Content-Length).
+	sameValue
+	// commaList fields are combined into one comma-separated value, as
+	// RFC 9110 section 5.3 allows for list-based fields.
+	commaList
+	// cookieList fields are combined with "; ", per RFC 6265 section 5.4.
+	cookieList
+)
+
+var headerPolicies = map[string]headerPolicy{
+	"Host":                singleton,
+	"Authorization":       singleton,
+	"Proxy-Authorization": singleton,
+	"Content-Type":        singleton,
+	"If-Modified-Since":   singleton,
+	"If-Unmodified-Since": singleton,
+	"Range":               singleton,
+
+	"Content-Length": sameValue,
+
+	"Accept":            commaList,
+	"Accept-Encoding":   commaList,
+	"Accept-Language":   commaList,
+	"Cache-Control":     commaList,
+	"Connection":        commaList,
+	"Expect":            commaList,
+	"Forwarded":         commaList,
+	"If-Match":          commaList,
+	"If-None-Match":     commaList,
+	"Pragma":            commaList,
+	"Te":                commaList,
+	"Trailer":           commaList,
+	"Transfer-Encoding": commaList,
+	"Upgrade":           commaList,
+	"Via":               commaList,
+	"X-Forwarded-For":   commaList,
+
+	"Cookie": cookieList,
+}
+
+// readLine returns the next line from b without its CRLF, or without a bare
+// LF when allowBareLF is set. The slice is only valid until the next read
+// from b.
+func readLine(b *bufio.Reader, allowBareLF bool) ([]byte, error) {
+	line, err := b.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, ErrHeaderTooLarge
+	}
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	} else if !allowBareLF {
+		return nil, errBareLF
+	}
+	return line, nil
+}
+
+// readHeader parses header fields up to the blank line ending the header
+// section into h. Values are sliced from vals, a backing array the caller
+// may reuse across requests; the grown array is returned. In strict mode
+// obsolete line folding and control characters in values are errors.
+func readHeader(b *bufio.Reader, h Header, vals []string, allowBareLF, strict bool) ([]string, error) {
+	var lastKey string
+	for {
+		line, err := readLine(b, allowBareLF)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return vals, err
+		}
+		if len(line) == 0 {
+			return vals, nil
+		}
+
+		// obsolete line folding continues the previous field's value
+		if line[0] == ' ' || line[0] == '\t' {
+			if strict {
+				return vals, errObsFold
+			}
+			if lastKey == "" {
+				return vals, errMalformedHeader
+			}
+			prev := h[lastKey]
+			prev[len(prev)-1] += " " + string(bytes.Trim(line, " \t"))
+			continue
+		}
+
+		k, v, ok := bytes.Cut(line, []byte(":"))
+		if !ok || len(k) == 0 || !validHeaderKey(k) {
+			// also rejects whitespace between the field name and colon,
+			// see RFC 9112 section 5.1
+			return vals, errMalformedHeader
+		}
+		if strict && !validHeaderValue(v) {
+			return vals, errMalformedHeader
+		}
+		key := canonicalHeaderKey(k)
+		value := internHeaderValue(bytes.Trim(v, " \t"))
+
+		if existing, ok := h[key]; ok {
+			last := len(existing) - 1
+			switch headerPolicies[key] {
+			case singleton:
+				return vals, fmt.Errorf("http: repeated %s header", key)
+			case sameValue:
+				if existing[last] != value {
+					return vals, fmt.Errorf("http: conflicting %s headers", key)
+				}
+			case commaList:
+				existing[last] += ", " + value
+			case cookieList:
+				existing[last] += "; " + value
+			default:
+				h[key] = append(existing, value)
+			}
+		} else {
+			vals = append(vals, value)
+			h[key] = vals[len(vals)-1 : len(vals) : len(vals)]
+		}
+		lastKey = key
+	}
+}
+
+func validHeaderKey(k []byte) bool {
+	for _, c := range k {
+		if !isTokenChar(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderValue reports whether v is free of control characters other
+// than horizontal tab, such as a bare CR or NUL that a proxy in front of
+// the server might split lines on.
+func validHeaderValue(v []byte) bool {
+	for _, c := range v {
+		if c < ' ' && c != '\t' || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenChar reports whether c may appear in an RFC 9110 token.
+func isTokenChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return c != 0 && bytes.IndexByte([]byte("!#$%&'*+-.^_`|~"), c) >= 0
+}
+
+func canonicalHeaderKey(k []byte) string {
+	for _, common := range commonHeaderKeys {
+		if len(common) == len(k) && bytes.EqualFold([]byte(common), k) {
+			return common
+		}
+	}
+	return textproto.CanonicalMIMEHeaderKey(string(k))
+}
+
+func internHeaderValue(v []byte) string {
+	for _, common := range commonHeaderValues {
+		if common == string(v) {
+			return common
+		}
+	}
+	return string(v)
+}
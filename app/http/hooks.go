@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net"
+	"time"
+)
+
+// RequestEnd describes a finished request for Server.OnRequestEnd.
+type RequestEnd struct {
+	Route        string // Request.RouteName, empty if no route matched
+	StatusCode   int
+	Latency      time.Duration // from the request being parsed to the handler returning
+	BytesRead    int64         // request bytes consumed from the connection
+	BytesWritten int64         // bytes written to the connection for the response
+	Err          error         // from writing the response, if it failed; see IsClientAbort
+}
+
+// requestHooks times a request and reports it to the server's hooks.
+type requestHooks struct {
+	s     *Server
+	start time.Time
+}
+
+// beginRequest fires OnRequestStart.
+func (s *Server) beginRequest(req *Request) requestHooks {
+	if s.OnRequestStart != nil {
+		s.OnRequestStart(req)
+	}
+	return requestHooks{s: s, start: time.Now()}
+}
+
+// end fires OnRequestEnd and logs the request if it was slow.
+func (h requestHooks) end(req *Request, res *Response) {
+	latency := time.Since(h.start)
+	if t := h.s.SlowRequestThreshold; t > 0 && latency >= t {
+		h.s.logger().Warn("slow request", "method", req.Method, "path", req.Path, "route", req.RouteName,
+			"duration", latency, "status", res.StatusCode, "bytes", res.BytesWritten())
+	}
+	if h.s.OnRequestEnd == nil {
+		return
+	}
+	h.s.OnRequestEnd(req, RequestEnd{
+		Route:        req.RouteName,
+		StatusCode:   res.StatusCode,
+		Latency:      latency,
+		BytesRead:    req.BytesRead(),
+		BytesWritten: res.BytesWritten(),
+		Err:          res.writeErr,
+	})
+}
+
+func (s *Server) parseError(conn net.Conn, err error) {
+	if s.OnParseError != nil {
+		s.OnParseError(conn.RemoteAddr(), err)
+	}
+}
@@ -0,0 +1,80 @@
+// Package httptest provides utilities for testing handlers written against
+// the app/http package.
+package httptest
+
+import (
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// ResponseRecorder is an http.ResponseWriter that records what a handler
+// set, for inspection in tests. Nothing is encoded or framed: Body holds
+// exactly what the handler passed to SetBody.
+type ResponseRecorder struct {
+	Code       int
+	StatusText string
+	HeaderMap  map[string]string
+	Body       []byte
+
+	// Writes counts calls to Write; a well-behaved handler writes once.
+	Writes int
+}
+
+// NewRecorder returns a ResponseRecorder defaulting to 200 OK, like the
+// server's own writer.
+func NewRecorder() *ResponseRecorder {
+	return &ResponseRecorder{
+		Code:       http.StatusOK,
+		StatusText: http.StatusText(http.StatusOK),
+		HeaderMap:  make(map[string]string),
+	}
+}
+
+func (rw *ResponseRecorder) SetStatus(code int, text string) {
+	rw.Code = code
+	rw.StatusText = text
+}
+
+func (rw *ResponseRecorder) SetHeader(key, value string) {
+	if rw.HeaderMap == nil {
+		rw.HeaderMap = make(map[string]string)
+	}
+	rw.HeaderMap[key] = value
+}
+
+func (rw *ResponseRecorder) SetBody(body []byte) {
+	rw.Body = body
+}
+
+func (rw *ResponseRecorder) GetBody() []byte {
+	return rw.Body
+}
+
+// GetHeader returns the recorded value of the header key.
+func (rw *ResponseRecorder) GetHeader(key string) string {
+	return rw.HeaderMap[key]
+}
+
+func (rw *ResponseRecorder) Write() error {
+	rw.Writes++
+	return nil
+}
+
+// Status returns the recorded status code.
+func (rw *ResponseRecorder) Status() int {
+	return rw.Code
+}
+
+// BytesWritten returns the body length once Write has been called. Unlike
+// the server's writer it excludes the status line and headers, which a
+// recorder never encodes.
+func (rw *ResponseRecorder) BytesWritten() int64 {
+	if rw.Writes == 0 {
+		return 0
+	}
+	return int64(len(rw.Body))
+}
+
+// Written reports whether the handler called Write.
+func (rw *ResponseRecorder) Written() bool {
+	return rw.Writes > 0
+}
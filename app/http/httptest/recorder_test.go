@@ -0,0 +1,51 @@
+package httptest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetStatus(http.StatusCreated, "Created")
+		w.SetHeader("X-Test", "yes")
+		w.SetBody([]byte("done"))
+		w.Write()
+	})
+	req, err := http.NewRequest(http.MethodPost, "/", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("gotCode: %d wantCode: %d", rec.Code, http.StatusCreated)
+	}
+	if rec.HeaderMap["X-Test"] != "yes" {
+		t.Errorf("gotHeader: %q wantHeader: %q", rec.HeaderMap["X-Test"], "yes")
+	}
+	if string(rec.Body) != "done" || rec.Writes != 1 {
+		t.Errorf("gotBody: %q gotWrites: %d", rec.Body, rec.Writes)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetBody([]byte(r.Path))
+		w.Write()
+	}))
+	defer s.Close()
+
+	res, err := s.Client().Get(s.URL + "/ping")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "/ping" {
+		t.Errorf("gotBody: %q wantBody: %q", body, "/ping")
+	}
+}
@@ -0,0 +1,47 @@
+package httptest
+
+import (
+	"net"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// Server is an HTTP server listening on an ephemeral loopback port, for
+// end-to-end tests of a Handler.
+type Server struct {
+	URL      string // base URL of the form http://127.0.0.1:port, without trailing slash
+	Listener net.Listener
+	Config   *http.Server
+
+	client *http.Client
+}
+
+// NewServer starts a Server serving handler. The caller should Close it
+// when finished.
+func NewServer(handler http.Handler) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("httptest: failed to listen on a port: " + err.Error())
+	}
+	s := &Server{
+		URL:      "http://" + ln.Addr().String(),
+		Listener: ln,
+		Config:   &http.Server{Handler: handler},
+		client:   &http.Client{Transport: &http.Transport{}},
+	}
+	go s.Config.Serve(ln)
+	return s
+}
+
+// Client returns a client whose connection pool belongs to s, so closing
+// the server also drops idle client connections.
+func (s *Server) Client() *http.Client {
+	return s.client
+}
+
+// Close stops the server from accepting connections and closes the
+// client's idle connections.
+func (s *Server) Close() {
+	s.Listener.Close()
+	s.client.Transport.CloseIdleConnections()
+}
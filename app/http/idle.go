@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net"
+	"time"
+)
+
+// minReapInterval bounds how often the idle reaper sweeps, however short
+// the idle timeout.
+const minReapInterval = 10 * time.Millisecond
+
+// startIdleReaper starts, once per server, the goroutine that closes
+// connections idle beyond the idle timeout. There is nothing to reap when
+// neither IdleTimeout nor ReadTimeout is set.
+func (s *Server) startIdleReaper() {
+	idle := s.idleTimeout()
+	if idle <= 0 {
+		return
+	}
+	s.reaperOnce.Do(func() { go s.reapIdle(idle) })
+}
+
+// reapIdle sweeps the open connections every half idle timeout until
+// Shutdown, closing those that have waited for their next request for
+// longer than idle. The read deadline set by awaitRequest normally gets
+// there first; the reaper catches connections whose deadline didn't fire.
+func (s *Server) reapIdle(idle time.Duration) {
+	interval := max(idle/2, minReapInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	done := s.shutdownDone()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		cutoff := time.Now().Add(-idle).UnixNano()
+		var stale []net.Conn
+		s.mu.Lock()
+		for conn, cs := range s.conns {
+			if since := cs.idle.Load(); since != 0 && since < cutoff {
+				stale = append(stale, conn)
+			}
+		}
+		s.mu.Unlock()
+		for _, conn := range stale {
+			conn.Close()
+			s.stats.idleReaped.Add(1)
+		}
+		if len(stale) > 0 {
+			s.logger().Debug("reaped idle connections", "count", len(stale), "idle", idle)
+		}
+	}
+}
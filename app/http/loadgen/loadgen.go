@@ -0,0 +1,271 @@
+// Package loadgen is a small load generator for measuring the server's
+// parser and writer, in the spirit of wrk. It speaks HTTP/1.1 over raw
+// connections so that client overhead stays out of the numbers.
+package loadgen
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// Config describes a load run. Either Requests or Duration bounds the run;
+// when both are set whichever is reached first ends it.
+type Config struct {
+	URL         string
+	Method      string // defaults to GET, or POST when PayloadSize > 0
+	Concurrency int    // number of connections, defaults to 1
+	Requests    int    // total requests across all connections
+	Duration    time.Duration
+	KeepAlive   bool // reuse connections instead of dialing per request
+	PayloadSize int  // request body size in bytes
+	Header      http.Header
+}
+
+// Result summarizes a load run.
+type Result struct {
+	Requests  int
+	Errors    int
+	Elapsed   time.Duration
+	BytesRead int64
+	Statuses  map[int]int
+
+	// latencies of successful requests, sorted ascending
+	latencies []time.Duration
+}
+
+// Percentile returns the latency at or below which p percent of
+// successful requests completed.
+func (r *Result) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.latencies))*p/100+0.5) - 1
+	return r.latencies[max(0, min(i, len(r.latencies)-1))]
+}
+
+// RequestsPerSecond is the throughput of the run.
+func (r *Result) RequestsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Elapsed.Seconds()
+}
+
+// Report writes a human readable summary of r to w.
+func (r *Result) Report(w io.Writer) {
+	fmt.Fprintf(w, "%d requests in %s, %d errors, %.1f req/s, %d bytes read\n",
+		r.Requests, r.Elapsed.Round(time.Millisecond), r.Errors, r.RequestsPerSecond(), r.BytesRead)
+	fmt.Fprintf(w, "latency p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+		r.Percentile(50), r.Percentile(90), r.Percentile(99), r.Percentile(99.9), r.Percentile(100))
+	codes := make([]int, 0, len(r.Statuses))
+	for code := range r.Statuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %d: %d\n", code, r.Statuses[code])
+	}
+}
+
+var errNoBound = errors.New("loadgen: Requests or Duration must be set")
+
+// Run hammers c.URL until the configured bound or ctx is done.
+func Run(ctx context.Context, c Config) (*Result, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("loadgen: unsupported scheme %q", u.Scheme)
+	}
+	if c.Requests <= 0 && c.Duration <= 0 {
+		return nil, errNoBound
+	}
+	if c.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Duration)
+		defer cancel()
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "80")
+	}
+	raw, req := buildRequest(u, c)
+
+	// every worker takes tickets until none are left; a negative budget
+	// means the run is bounded by time only
+	var mu sync.Mutex
+	remaining := c.Requests
+	if remaining <= 0 {
+		remaining = -1
+	}
+	take := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if remaining == 0 || ctx.Err() != nil {
+			return false
+		}
+		if remaining > 0 {
+			remaining--
+		}
+		return true
+	}
+
+	workers := max(c.Concurrency, 1)
+	results := make([]Result, workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range results {
+		wg.Add(1)
+		go func(r *Result) {
+			defer wg.Done()
+			(&worker{addr: addr, raw: raw, req: req, keepAlive: c.KeepAlive}).run(ctx, take, r)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	total := &Result{Elapsed: time.Since(start), Statuses: make(map[int]int)}
+	for _, r := range results {
+		total.Requests += r.Requests
+		total.Errors += r.Errors
+		total.BytesRead += r.BytesRead
+		total.latencies = append(total.latencies, r.latencies...)
+		for code, n := range r.Statuses {
+			total.Statuses[code] += n
+		}
+	}
+	slices.Sort(total.latencies)
+	return total, nil
+}
+
+// buildRequest renders the request once so workers only copy bytes.
+func buildRequest(u *url.URL, c Config) ([]byte, *http.Request) {
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+		if c.PayloadSize > 0 {
+			method = http.MethodPost
+		}
+	}
+	target := u.RequestURI()
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, target, u.Host)
+	for key, values := range c.Header {
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s: %s\r\n", key, v)
+		}
+	}
+	if !c.KeepAlive {
+		b.WriteString("Connection: close\r\n")
+	}
+	if c.PayloadSize > 0 {
+		b.WriteString("Content-Type: application/octet-stream\r\n")
+		b.WriteString("Content-Length: " + strconv.Itoa(c.PayloadSize) + "\r\n")
+	}
+	b.WriteString("\r\n")
+	b.Write(bytes.Repeat([]byte("x"), c.PayloadSize))
+
+	return b.Bytes(), &http.Request{Method: method, Path: target}
+}
+
+type worker struct {
+	addr      string
+	raw       []byte
+	req       *http.Request
+	keepAlive bool
+
+	mu   sync.Mutex // guards conn against the end-of-run deadline
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func (w *worker) run(ctx context.Context, take func() bool, r *Result) {
+	r.Statuses = make(map[int]int)
+	defer w.close()
+
+	// unblock a pending read when the run ends
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if w.conn != nil {
+			w.conn.SetDeadline(time.Now())
+		}
+	})
+	defer stop()
+
+	for take() {
+		start := time.Now()
+		code, n, err := w.do(ctx)
+		r.Requests++
+		r.BytesRead += n
+		if err != nil {
+			if ctx.Err() != nil {
+				// cut off by the end of the run, not a server failure
+				r.Requests--
+				return
+			}
+			r.Errors++
+			w.close()
+			continue
+		}
+		r.latencies = append(r.latencies, time.Since(start))
+		r.Statuses[code]++
+		if !w.keepAlive {
+			w.close()
+		}
+	}
+}
+
+func (w *worker) do(ctx context.Context) (code int, n int64, err error) {
+	if w.conn == nil {
+		conn, err := net.Dial("tcp", w.addr)
+		if err != nil {
+			return 0, 0, err
+		}
+		w.mu.Lock()
+		w.conn, w.br = conn, bufio.NewReader(conn)
+		w.mu.Unlock()
+		// the run may have ended while dialing, after the deadline fired
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if _, err := w.conn.Write(w.raw); err != nil {
+		return 0, 0, err
+	}
+	res, err := http.ReadResponse(w.br, w.req)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err = io.Copy(io.Discard, res.Body)
+	if err != nil {
+		return 0, n, err
+	}
+	if res.ContentLength < 0 || res.Header.Get("Connection") == "close" {
+		w.close()
+	}
+	return res.StatusCode, n, nil
+}
+
+func (w *worker) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+		w.br = nil
+	}
+}
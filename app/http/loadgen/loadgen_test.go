@@ -0,0 +1,96 @@
+package loadgen
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+var runTest = []struct {
+	cfg      Config
+	requests int
+}{
+	{Config{Concurrency: 4, Requests: 200, KeepAlive: true}, 200},
+	{Config{Concurrency: 2, Requests: 50}, 50},
+	{Config{Concurrency: 3, Requests: 30, KeepAlive: true, PayloadSize: 4096}, 30},
+}
+
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetHeader("Content-Encoding", "")
+		w.SetBody(r.Body)
+		w.Write()
+	}))
+	defer srv.Close()
+
+	for i, tt := range runTest {
+		tt.cfg.URL = srv.URL + "/"
+		res, err := Run(context.Background(), tt.cfg)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if res.Requests != tt.requests || res.Errors != 0 || res.Statuses[200] != tt.requests {
+			t.Errorf("#%d: gotRequests: %d gotErrors: %d gotStatuses: %v wantRequests: %d", i, res.Requests, res.Errors, res.Statuses, tt.requests)
+		}
+		if want := int64(tt.requests * tt.cfg.PayloadSize); res.BytesRead != want {
+			t.Errorf("#%d: gotBytesRead: %d wantBytesRead: %d", i, res.BytesRead, want)
+		}
+		if p50, p99 := res.Percentile(50), res.Percentile(99); p50 <= 0 || p99 < p50 {
+			t.Errorf("#%d: gotP50: %s gotP99: %s", i, p50, p99)
+		}
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	// requests after the first 20 hang, so only the time bound can end
+	// the run
+	var served atomic.Int64
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served.Add(1) > 20 {
+			<-release
+		}
+		w.Write()
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	res, err := Run(context.Background(), Config{URL: srv.URL, Concurrency: 2, KeepAlive: true, Duration: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Errorf("run took %s, want about 100ms", elapsed)
+	}
+	if res.Requests != 20 || res.Errors != 0 || res.Statuses[200] != 20 {
+		t.Errorf("gotRequests: %d gotErrors: %d gotStatuses: %v wantRequests: 20", res.Requests, res.Errors, res.Statuses)
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var served atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served.Add(1) == 50 {
+			cancel()
+		}
+		w.Write()
+	}))
+	defer srv.Close()
+
+	res, err := Run(ctx, Config{URL: srv.URL, Concurrency: 2, KeepAlive: true, Duration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the responses in flight on both connections race the cancellation
+	if res.Requests < 48 || int64(res.Requests) > served.Load() || res.Errors != 0 || res.Statuses[200] != res.Requests {
+		t.Errorf("gotRequests: %d gotErrors: %d gotStatuses: %v served: %d", res.Requests, res.Errors, res.Statuses, served.Load())
+	}
+}
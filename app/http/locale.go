@@ -0,0 +1,40 @@
+package http
+
+// LocaleKey holds the locale Locales chose for a request, one of its
+// Supported tags:
+//
+//	locale, _ := http.LocaleKey.Get(r)
+//	rr.Render(w, http.StatusOK, "index.html", map[string]any{"Locale": locale})
+var LocaleKey = NewKey[string]("locale")
+
+// Locales is middleware choosing each request's locale from its
+// Accept-Language header. The choice is stored under LocaleKey and sent
+// as Content-Language, and Accept-Language is added to Vary.
+type Locales struct {
+	// Supported lists the application's locales, e.g. "en", "de",
+	// "pt-BR", most preferred first.
+	Supported []string
+
+	// Default is used when the client accepts none of Supported; the first
+	// of Supported when empty.
+	Default string
+}
+
+// Handler returns middleware setting the locale for next.
+func (l *Locales) Handler(next Handler) Handler {
+	return wrapHandler("Locales", next, func(w ResponseWriter, r *Request) {
+		locale := NegotiateLanguage(r.Header.Get("Accept-Language"), l.Supported...)
+		if locale == "" {
+			locale = l.Default
+		}
+		if locale == "" && len(l.Supported) > 0 {
+			locale = l.Supported[0]
+		}
+		LocaleKey.Set(r, locale)
+		AddVary(w, "Accept-Language")
+		if locale != "" {
+			w.SetHeader("Content-Language", locale)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
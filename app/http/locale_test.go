@@ -0,0 +1,32 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestLocales(t *testing.T) {
+	var got string
+	h := (&http.Locales{Supported: []string{"en", "de"}}).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = http.LocaleKey.Get(r)
+		w.Write()
+	}))
+	tests := []struct {
+		accept, want string
+	}{
+		{"", "en"},
+		{"de-CH, en;q=0.5", "de"},
+		{"fr", "en"},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", tt.accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got != tt.want || rec.HeaderMap["Content-Language"] != tt.want || rec.HeaderMap["Vary"] != "Accept-Language" {
+			t.Errorf("#%d: gotLocale: %q gotHeaders: %v wantLocale: %q", i, got, rec.HeaderMap, tt.want)
+		}
+	}
+}
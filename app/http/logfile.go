@@ -0,0 +1,166 @@
+package http
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedSuffix dates the name a RotatingFile moves a full file to. It
+// has no colons, which some file systems refuse, and sorts by time.
+const rotatedSuffix = "20060102T150405.000"
+
+// RotatingFile writes a log to the file at Path, for a slog handler or
+// other logger, moving the file aside once it grows past MaxSize or has
+// been written to for MaxAge and starting a fresh one, so a long-running
+// server doesn't fill the disk:
+//
+//	out := &http.RotatingFile{Path: "/var/log/http/server.log", MaxSize: 100 << 20, MaxBackups: 7}
+//	logger := slog.New(slog.NewJSONHandler(out, nil))
+//
+// Rotated files are named after Path with the time of the rotation
+// appended, as in server.log.20261014T093000.000. A slog handler writes
+// each record with a single Write, so rotation never splits a line.
+//
+// With MaxSize and MaxAge zero nothing is rotated here, which suits
+// an external logrotate: have it move the file, then call Reopen, on
+// SIGUSR1 say, to start writing to a new one at Path.
+type RotatingFile struct {
+	Path string
+
+	// MaxSize rotates the file before a write would take it past this
+	// many bytes. A single larger write still goes to a file of its own.
+	MaxSize int64
+
+	// MaxAge rotates the file once it has been open this long.
+	MaxAge time.Duration
+
+	// MaxBackups is how many rotated files are kept; older ones are
+	// removed. All are kept when zero.
+	MaxBackups int
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+// Write appends p to the file, opening it first if it isn't, and rotating
+// it if p wouldn't fit or it is due.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	full := rf.MaxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.MaxSize
+	old := rf.MaxAge > 0 && time.Since(rf.opened) >= rf.MaxAge
+	if full || old {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate moves the current file aside and starts a new one now.
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		if err := rf.open(); err != nil {
+			return err
+		}
+	}
+	return rf.rotate()
+}
+
+// Reopen closes the file and opens Path again, creating it if it was
+// moved or removed, as logrotate does.
+func (rf *RotatingFile) Reopen() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f != nil {
+		rf.f.Close()
+		rf.f = nil
+	}
+	return rf.open()
+}
+
+// Close closes the file. A later Write opens it again.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	if rf.Path == "" {
+		return errors.New("http: RotatingFile has no Path")
+	}
+	f, err := os.OpenFile(rf.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size, rf.opened = f, fi.Size(), time.Now()
+	return nil
+}
+
+func (rf *RotatingFile) rotate() error {
+	rf.f.Close()
+	rf.f = nil
+	if err := os.Rename(rf.Path, rf.Path+"."+time.Now().Format(rotatedSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	if rf.MaxBackups > 0 {
+		backups := rf.backups()
+		for _, name := range backups[min(rf.MaxBackups, len(backups)):] {
+			os.Remove(name)
+		}
+	}
+	return nil
+}
+
+// backups returns the rotated files, newest first.
+func (rf *RotatingFile) backups() []string {
+	dir, base := filepath.Split(rf.Path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), base+".")
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		if _, err := time.Parse(rotatedSuffix, suffix); err != nil {
+			continue
+		}
+		names = append(names, filepath.Join(dir, e.Name()))
+	}
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names
+}
@@ -0,0 +1,64 @@
+package http_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "server.log")
+	rf := &http.RotatingFile{Path: name, MaxSize: 10, MaxBackups: 2}
+	defer rf.Close()
+	for i := range 5 {
+		if _, err := rf.Write([]byte("line " + strconv.Itoa(i) + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		// rotated names carry the time to the millisecond
+		time.Sleep(2 * time.Millisecond)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "line 4\n" {
+		t.Errorf("gotLog: %q wantLog: %q", b, "line 4\n")
+	}
+	backups, _ := filepath.Glob(name + ".*")
+	if len(backups) != 2 {
+		t.Fatalf("gotBackups: %q wantBackups: 2", backups)
+	}
+	slices.Sort(backups)
+	for i, want := range []string{"line 2\n", "line 3\n"} {
+		if b, _ := os.ReadFile(backups[i]); string(b) != want {
+			t.Errorf("#%d: gotBackup: %q wantBackup: %q", i, b, want)
+		}
+	}
+
+	// logrotate moves the file, then has it reopened
+	moved := filepath.Join(dir, "server.log.1")
+	if err := os.Rename(name, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := rf.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	rf.Write([]byte("line 5\n"))
+	if b, _ := os.ReadFile(name); string(b) != "line 5\n" {
+		t.Errorf("gotLog: %q wantLog: %q", b, "line 5\n")
+	}
+	if b, _ := os.ReadFile(moved); string(b) != "line 4\n" {
+		t.Errorf("gotMoved: %q wantMoved: %q", b, "line 4\n")
+	}
+
+	aged := &http.RotatingFile{Path: filepath.Join(dir, "aged.log"), MaxAge: time.Millisecond}
+	defer aged.Close()
+	aged.Write([]byte("old\n"))
+	time.Sleep(5 * time.Millisecond)
+	aged.Write([]byte("new\n"))
+	if b, _ := os.ReadFile(aged.Path); string(b) != "new\n" {
+		t.Errorf("gotAged: %q wantAged: %q", b, "new\n")
+	}
+}
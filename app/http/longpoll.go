@@ -0,0 +1,42 @@
+package http
+
+import "time"
+
+// DefaultLongPollTimeout is the poll window LongPoll uses when given zero.
+const DefaultLongPollTimeout = 30 * time.Second
+
+// LongPoll parks a long-polling handler until ready delivers a value, then
+// answers with respond. If ready is closed, timeout passes first, the
+// request's context is done or the server starts shutting down, it
+// answers 204 No Content instead, telling the client to poll again;
+// resolving early on shutdown keeps Shutdown from waiting out whole poll
+// windows.
+//
+//	func events(w http.ResponseWriter, r *http.Request) {
+//		http.LongPoll(w, r, broker.Subscribe(), 0, func(w http.ResponseWriter, ev Event) error {
+//			return http.WriteTextOrJSON(w, r, http.StatusOK, ev.Text, ev)
+//		})
+//	}
+func LongPoll[T any](w ResponseWriter, r *Request, ready <-chan T, timeout time.Duration, respond func(ResponseWriter, T) error) error {
+	if timeout <= 0 {
+		timeout = DefaultLongPollTimeout
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	var shutdown <-chan struct{}
+	if r.srv != nil {
+		shutdown = r.srv.shutdownDone()
+	}
+
+	select {
+	case v, ok := <-ready:
+		if ok {
+			return respond(w, v)
+		}
+	case <-t.C:
+	case <-r.Context().Done():
+	case <-shutdown:
+	}
+	w.SetStatus(StatusNoContent, StatusText(StatusNoContent))
+	return w.Write()
+}
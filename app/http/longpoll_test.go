@@ -0,0 +1,73 @@
+package http_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestLongPoll(t *testing.T) {
+	respond := func(w http.ResponseWriter, v string) error {
+		w.SetBody([]byte(v))
+		return w.Write()
+	}
+	ready := make(chan string, 1)
+	ready <- "event"
+	req, _ := http.NewRequest(http.MethodGet, "/events", nil)
+
+	rec := httptest.NewRecorder()
+	http.LongPoll(rec, req, ready, time.Second, respond)
+	if rec.Code != http.StatusOK || string(rec.Body) != "event" {
+		t.Errorf("with a value gotCode: %d gotBody: %q", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	start := time.Now()
+	http.LongPoll(rec, req, ready, 20*time.Millisecond, respond)
+	if rec.Code != http.StatusNoContent || time.Since(start) < 20*time.Millisecond {
+		t.Errorf("on timeout gotCode: %d after %v", rec.Code, time.Since(start))
+	}
+}
+
+func TestLongPollShutdownBehindTimeout(t *testing.T) {
+	entered := make(chan struct{})
+	poll := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		http.LongPoll(w, r, make(chan string), 5*time.Second, func(w http.ResponseWriter, v string) error {
+			return w.Write()
+		})
+	})
+	srv := httptest.NewServer(http.TimeoutHandler(poll, 10*time.Second, "timed out"))
+	defer srv.Close()
+
+	type result struct {
+		code int
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := srv.Client().Get(srv.URL + "/events")
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		res.Body.Close()
+		done <- result{code: res.StatusCode}
+	}()
+	<-entered
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go srv.Config.Shutdown(ctx)
+
+	select {
+	case r := <-done:
+		if r.err != nil || r.code != http.StatusNoContent {
+			t.Errorf("gotCode: %d gotErr: %v wantCode: %d", r.code, r.err, http.StatusNoContent)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("the poll outlived the shutdown")
+	}
+}
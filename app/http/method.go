@@ -1,5 +1,7 @@
 package http
 
+import "slices"
+
 // Common HTTP methods.
 // Unless otherwise noted, these are defined in RFC 7231 section 4.3.
 const (
@@ -13,3 +15,12 @@ const (
 	MethodOptions = "OPTIONS"
 	MethodTrace   = "TRACE"
 )
+
+// StandardMethods returns the methods a Server accepts when
+// RestrictMethods is set and AllowedMethods is empty. The slice is new on
+// every call, so it can be appended to for Server.AllowedMethods.
+func StandardMethods() []string {
+	return slices.Clone(standardMethods)
+}
+
+var standardMethods = []string{MethodGet, MethodHead, MethodPost, MethodPut, MethodDelete, MethodPatch, MethodOptions}
@@ -0,0 +1,187 @@
+package http
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// NegotiateEncoding picks the content coding to apply to a response, given
+// the request's Accept-Encoding header and the codings the server can
+// produce, in order of preference. It implements RFC 9110 section 12.5.3:
+// codings are weighted by their q-value or that of "*", q=0 rules a coding
+// out, and ties go to the earlier offer. It returns "" when the response
+// should be sent unencoded, including when no Accept-Encoding was sent.
+func NegotiateEncoding(acceptEncoding string, offers ...string) string {
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := encodingQ(acceptEncoding, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// encodingQ returns the quality acceptEncoding gives coding.
+func encodingQ(acceptEncoding, coding string) float64 {
+	exact, wild := -1.0, -1.0
+	for rest := acceptEncoding; rest != ""; {
+		var item string
+		var q float64
+		item, q, rest = nextQItem(rest)
+		switch {
+		case strings.EqualFold(item, coding):
+			exact = q
+		case item == "*":
+			wild = q
+		}
+	}
+	if exact >= 0 {
+		return exact
+	}
+	if wild >= 0 {
+		return wild
+	}
+	return 0
+}
+
+// NegotiateContentType picks the media type to respond with, given the
+// request's Accept header and the types the handler can produce, in order
+// of preference. Each offer takes the q-value of the most specific media
+// range matching it (RFC 9110 section 12.5.1) and ties go to the earlier
+// offer. With no Accept header the first offer is returned; "" means none
+// is acceptable.
+func NegotiateContentType(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := mediaQ(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaQ returns the quality accept gives the media type offer, taken from
+// the most specific matching range.
+func mediaQ(accept, offer string) float64 {
+	typ, _, _ := strings.Cut(offer, "/")
+	q, specificity := 0.0, -1
+	for rest := accept; rest != ""; {
+		var item string
+		var itemQ float64
+		item, itemQ, rest = nextQItem(rest)
+		s := -1
+		switch {
+		case strings.EqualFold(item, offer):
+			s = 2
+		case strings.EqualFold(item, typ+"/*"):
+			s = 1
+		case item == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = itemQ, s
+		}
+	}
+	return q
+}
+
+// WriteTextOrJSON answers with v encoded as JSON if the request's Accept
+// header prefers application/json, and with text as text/plain otherwise,
+// so diagnostic endpoints stay readable with curl and parseable by tools.
+func WriteTextOrJSON(w ResponseWriter, r *Request, status int, text string, v any) error {
+	w.SetStatus(status, StatusText(status))
+	AddVary(w, "Accept")
+	if NegotiateContentType(r.Header.Get("Accept"), "text/plain", "application/json") == "application/json" {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.SetHeader("Content-Type", "application/json")
+		w.SetBody(b)
+	} else {
+		w.SetHeader("Content-Type", "text/plain")
+		w.SetBody([]byte(text))
+	}
+	return w.Write()
+}
+
+// nextQItem splits the first element off a comma-separated list of values
+// with optional parameters, such as Accept or Accept-Encoding, returning
+// the value, its q parameter and the rest of the list. The q-value
+// defaults to 1; one that doesn't parse as a qvalue gives 0, so the
+// element is never chosen.
+func nextQItem(list string) (item string, q float64, rest string) {
+	elem, rest, _ := strings.Cut(list, ",")
+	item, params, _ := strings.Cut(elem, ";")
+	item = strings.TrimSpace(item)
+	q = 1
+	for params != "" {
+		var param string
+		param, params, _ = strings.Cut(params, ";")
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if !strings.EqualFold(strings.TrimSpace(name), "q") {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || v < 0 || v > 1 {
+			v = 0
+		}
+		q = v
+	}
+	return item, q, rest
+}
+
+// NegotiateLanguage picks the language tag from supported, in order of
+// preference, that best fits an Accept-Language header. Tags are matched
+// case-insensitively: a range matches a tag equal to it or extending it
+// ("en" matches "en-US"), a tag the range extends is a fallback ("en-GB"
+// matches "en") and "*" matches any tag. Each tag takes the q-value of its
+// most specific match; ties go to the earlier tag. It returns "" when
+// nothing in supported is acceptable.
+func NegotiateLanguage(acceptLanguage string, supported ...string) string {
+	best, bestQ := "", 0.0
+	for _, tag := range supported {
+		if q := languageQ(acceptLanguage, tag); q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+func languageQ(acceptLanguage, tag string) float64 {
+	q, specificity := 0.0, -1
+	for rest := acceptLanguage; rest != ""; {
+		var item string
+		var itemQ float64
+		item, itemQ, rest = nextQItem(rest)
+		s := -1
+		switch {
+		case strings.EqualFold(item, tag):
+			s = 3
+		case hasSubtagPrefix(tag, item):
+			s = 2
+		case hasSubtagPrefix(item, tag):
+			s = 1
+		case item == "*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = itemQ, s
+		}
+	}
+	return q
+}
+
+// hasSubtagPrefix reports whether the language tag has prefix as its
+// leading subtags, e.g. "en" for "en-US" but not for "eng".
+func hasSubtagPrefix(tag, prefix string) bool {
+	return prefix != "" && len(tag) > len(prefix) && tag[len(prefix)] == '-' &&
+		strings.EqualFold(tag[:len(prefix)], prefix)
+}
@@ -0,0 +1,177 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+var negotiateEncodingTest = []struct {
+	accept string
+	offers []string
+	want   string
+}{
+	{"", []string{"gzip"}, ""},
+	{"gzip", []string{"gzip"}, "gzip"},
+	{"GZIP", []string{"gzip"}, "gzip"},
+	{"deflate, gzip", []string{"gzip"}, "gzip"},
+	{"gzip;q=0, br, identity", []string{"gzip"}, ""},
+	{"gzip ; q=0.5", []string{"gzip"}, "gzip"},
+	{"gzip;q=0.000", []string{"gzip"}, ""},
+	{"gzip;q=2", []string{"gzip"}, ""},
+	{"*", []string{"gzip"}, "gzip"},
+	{"*;q=0", []string{"gzip"}, ""},
+	{"gzip, *;q=0", []string{"gzip"}, "gzip"},
+	{"br", []string{"gzip"}, ""},
+	{"gzip;q=0.5, br", []string{"gzip", "br"}, "br"},
+	{"gzip, br", []string{"gzip", "br"}, "gzip"},
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for i, tt := range negotiateEncodingTest {
+		if got := NegotiateEncoding(tt.accept, tt.offers...); got != tt.want {
+			t.Errorf("#%d: %q gotEncoding: %q wantEncoding: %q", i, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestEchoCompression(t *testing.T) {
+	for i, tt := range []struct {
+		accept string
+		gzip   bool
+	}{
+		{"gzip", true},
+		{"invalid-1, gzip, invalid-2", true},
+		{"gzip;q=0, br, identity", false},
+		{"invalid-1", false},
+	} {
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader("GET /echo/abc HTTP/1.1\r\nAccept-Encoding: " + tt.accept + "\r\n\r\n")}}
+		benchServer().handleConn(conn)
+		if got := strings.Contains(conn.w.String(), "Content-Encoding: gzip\r\n"); got != tt.gzip {
+			t.Errorf("#%d: %q gotResponse: %q wantGzip: %v", i, tt.accept, conn.w.String(), tt.gzip)
+		}
+	}
+}
+
+var negotiateContentTypeTest = []struct {
+	accept string
+	want   string
+}{
+	{"", "text/plain"},
+	{"*/*", "text/plain"},
+	{"application/json", "application/json"},
+	{"application/*", "application/json"},
+	{"text/html", ""},
+	{"application/json, text/plain;q=0.5", "application/json"},
+	{"text/plain, application/json", "text/plain"},
+	{"*/*;q=0.1, application/json", "application/json"},
+	{"text/*;q=0, */*", "application/json"},
+	{"text/plain;q=0.9, text/*;q=0.1, application/json;q=0.5", "text/plain"},
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	for i, tt := range negotiateContentTypeTest {
+		if got := NegotiateContentType(tt.accept, "text/plain", "application/json"); got != tt.want {
+			t.Errorf("#%d: %q gotType: %q wantType: %q", i, tt.accept, got, tt.want)
+		}
+	}
+}
+
+var mergeVaryTest = []struct {
+	vary   string
+	fields []string
+	want   string
+}{
+	{"", []string{"Accept-Encoding"}, "Accept-Encoding"},
+	{"Accept", []string{"Accept-Encoding"}, "Accept, Accept-Encoding"},
+	{"Accept, accept-encoding", []string{"Accept-Encoding"}, "Accept, accept-encoding"},
+	{"Accept", []string{"Accept", "Accept-Language"}, "Accept, Accept-Language"},
+	{"*", []string{"Accept"}, "*"},
+	{"Accept", []string{"*"}, "*"},
+}
+
+func TestWriteTextOrJSON(t *testing.T) {
+	tests := []struct {
+		accept, contentType, body string
+	}{
+		{"", "text/plain", "curl/8.0"},
+		{"*/*", "text/plain", "curl/8.0"},
+		{"application/json", "application/json", `{"user_agent":"curl/8.0"}`},
+		{"text/html", "text/plain", "curl/8.0"},
+	}
+	for i, tt := range tests {
+		req, _ := NewRequest(MethodGet, "/user-agent", nil)
+		req.Header.Set("Accept", tt.accept)
+		conn := &bufConn{}
+		WriteTextOrJSON(NewResponse(conn, req), req, StatusOK, "curl/8.0", map[string]string{"user_agent": "curl/8.0"})
+		res, err := ReadResponse(bufio.NewReader(&conn.w), req)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		body, _ := io.ReadAll(res.Body)
+		if res.Header.Get("Content-Type") != tt.contentType || string(body) != tt.body {
+			t.Errorf("#%d: gotContentType: %q gotBody: %q wantContentType: %q wantBody: %q",
+				i, res.Header.Get("Content-Type"), body, tt.contentType, tt.body)
+		}
+	}
+}
+
+func TestMergeVary(t *testing.T) {
+	for i, tt := range mergeVaryTest {
+		if got := mergeVary(tt.vary, tt.fields...); got != tt.want {
+			t.Errorf("#%d: gotVary: %q wantVary: %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestVaryAcceptEncoding(t *testing.T) {
+	for i, tt := range []struct {
+		s       *Server
+		request string
+		want    string
+	}{
+		{benchServer(), "GET /echo/a HTTP/1.1\r\n\r\n", "Vary: Accept-Encoding\r\n"},
+		{benchServer(), "GET /echo/a HTTP/1.1\r\nAccept-Encoding: gzip\r\n\r\n", "Vary: Accept-Encoding\r\n"},
+		{&Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			AddVary(w, "Accept")
+			w.Write()
+		})}, "GET / HTTP/1.1\r\n\r\n", "Vary: Accept, Accept-Encoding\r\n"},
+		{&Server{Handler: benchServer().Handler, DisableCompression: true}, "GET /echo/a HTTP/1.1\r\n\r\n", ""},
+	} {
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.request)}}
+		tt.s.handleConn(conn)
+		out := conn.w.String()
+		if tt.want == "" && strings.Contains(out, "Vary") || !strings.Contains(out, tt.want) {
+			t.Errorf("#%d: gotResponse: %q wantVary: %q", i, out, tt.want)
+		}
+	}
+}
+
+var negotiateLanguageTest = []struct {
+	accept string
+	want   string
+}{
+	{"", ""},
+	{"de", "de"},
+	{"DE-at", "de"},
+	{"pt", "pt-BR"},
+	{"pt-br", "pt-BR"},
+	{"fr, de;q=0.5", "de"},
+	{"en;q=0.5, de;q=0.8", "de"},
+	{"en, de", "en"},
+	{"en-GB;q=0.9, de", "de"},
+	{"*", "en"},
+	{"*;q=0.1, de", "de"},
+	{"en;q=0, *", "de"},
+	{"eng", ""},
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	for i, tt := range negotiateLanguageTest {
+		if got := NegotiateLanguage(tt.accept, "en", "de", "pt-BR"); got != tt.want {
+			t.Errorf("#%d: %q gotLanguage: %q wantLanguage: %q", i, tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/url"
+	"strings"
+)
+
+// overridableMethods are the methods a POST may be rewritten to. Turning
+// a POST into a GET or HEAD would let a form trigger a safe-method handler
+// with a body, so those are not allowed.
+var overridableMethods = map[string]bool{
+	MethodPut:    true,
+	MethodPatch:  true,
+	MethodDelete: true,
+}
+
+// MethodOverride is opt-in middleware letting HTML forms, which can only
+// POST, reach PUT, PATCH and DELETE handlers. The method is taken from the
+// X-HTTP-Method-Override header, or from a _method field of a
+// application/x-www-form-urlencoded body. Only POST requests are
+// rewritten, and r.Method is changed before next routes the request.
+func MethodOverride(next Handler) Handler {
+	return wrapHandler("MethodOverride", next, func(w ResponseWriter, r *Request) {
+		if r.Method == MethodPost {
+			if m := overrideMethod(r); overridableMethods[m] {
+				r.Method = m
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func overrideMethod(r *Request) string {
+	if m := r.Header.Get("X-Http-Method-Override"); m != "" {
+		return strings.ToUpper(strings.TrimSpace(m))
+	}
+	ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	if !strings.EqualFold(strings.TrimSpace(ct), "application/x-www-form-urlencoded") {
+		return ""
+	}
+	form, err := url.ParseQuery(string(r.Body))
+	if err != nil {
+		return ""
+	}
+	return strings.ToUpper(form.Get("_method"))
+}
@@ -0,0 +1,37 @@
+package http_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestMethodOverride(t *testing.T) {
+	var got string
+	h := http.MethodOverride(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method
+		w.Write()
+	}))
+	tests := []struct {
+		method, header, ct, body, want string
+	}{
+		{http.MethodPost, "DELETE", "", "", http.MethodDelete},
+		{http.MethodPost, "", "application/x-www-form-urlencoded", "name=a&_method=put", http.MethodPut},
+		{http.MethodPost, "", "text/plain", "_method=put", http.MethodPost},
+		{http.MethodPost, "GET", "", "", http.MethodPost},
+		{http.MethodGet, "DELETE", "", "", http.MethodGet},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, "/files/a", strings.NewReader(tt.body))
+		if tt.header != "" {
+			req.Header.Set("X-HTTP-Method-Override", tt.header)
+		}
+		req.Header.Set("Content-Type", tt.ct)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tt.want {
+			t.Errorf("#%d: gotMethod: %q wantMethod: %q", i, got, tt.want)
+		}
+	}
+}
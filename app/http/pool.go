@@ -0,0 +1,79 @@
+package http
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+)
+
+// Buffered readers and writers are recycled across connections and
+// responses to keep allocations flat under high connection churn. Each
+// pool holds buffers of the default bufio size only.
+var (
+	bufioReaderPool sync.Pool
+	bufioWriterPool sync.Pool
+)
+
+// Requests and Responses are recycled once the handler has returned and
+// the exchange is finished, which is why handlers must not keep either
+// after returning.
+var (
+	requestPool  = sync.Pool{New: func() any { return new(Request) }}
+	responsePool = sync.Pool{New: func() any { return &Response{Headers: make(map[string]string)} }}
+)
+
+func getRequest() *Request {
+	return requestPool.Get().(*Request)
+}
+
+func putRequest(req *Request) {
+	h, vals := req.Header, req.headerVals
+	clear(h)
+	clear(vals)
+	*req = Request{Header: h, headerVals: vals[:0]}
+	requestPool.Put(req)
+}
+
+func getResponse(conn net.Conn, req *Request) *Response {
+	res := responsePool.Get().(*Response)
+	res.reset(conn, req)
+	return res
+}
+
+func putResponse(res *Response) {
+	// drop references so pooled responses don't pin bodies or conns
+	res.reset(nil, nil)
+	responsePool.Put(res)
+}
+
+func newBufioReader(r io.Reader) *bufio.Reader {
+	if v := bufioReaderPool.Get(); v != nil {
+		br := v.(*bufio.Reader)
+		br.Reset(r)
+		return br
+	}
+	return bufio.NewReader(r)
+}
+
+// putBufioReader returns br to the pool. Any buffered bytes are dropped,
+// so only call it once the connection is done.
+func putBufioReader(br *bufio.Reader) {
+	br.Reset(nil)
+	bufioReaderPool.Put(br)
+}
+
+func newBufioWriter(w io.Writer) *bufio.Writer {
+	if v := bufioWriterPool.Get(); v != nil {
+		bw := v.(*bufio.Writer)
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriter(w)
+}
+
+// putBufioWriter returns bw to the pool. It must have been flushed.
+func putBufioWriter(bw *bufio.Writer) {
+	bw.Reset(nil)
+	bufioWriterPool.Put(bw)
+}
@@ -0,0 +1,372 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// hopHeaders are connection-specific and must not be forwarded by a proxy.
+// See RFC 9110 section 7.6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// ProxyRequest is handed to ReverseProxy.Rewrite. In is the request as
+// received and must not be modified; Out is the request that will be sent
+// upstream.
+type ProxyRequest struct {
+	In  *Request
+	Out *Request
+}
+
+// SetURL routes Out to target, joining target's path with the incoming
+// path and merging the query strings. The Host header is rewritten to
+// target's host.
+func (pr *ProxyRequest) SetURL(target *url.URL) {
+	u := *target
+	u.Path, u.RawPath = joinURLPath(target, pr.Out.URL)
+	if target.RawQuery == "" || pr.Out.URL.RawQuery == "" {
+		u.RawQuery = target.RawQuery + pr.Out.URL.RawQuery
+	} else {
+		u.RawQuery = target.RawQuery + "&" + pr.Out.URL.RawQuery
+	}
+	pr.Out.URL = &u
+	pr.Out.Path = u.RequestURI()
+	pr.Out.Header.Del("Host")
+}
+
+// SetXForwarded sets X-Forwarded-For, X-Forwarded-Host and
+// X-Forwarded-Proto on Out, the last being https for requests that
+// arrived over TLS. An X-Forwarded-For sent by the client is appended to,
+// so a chain of proxies is preserved.
+func (pr *ProxyRequest) SetXForwarded() {
+	if ip, _, err := net.SplitHostPort(pr.In.RemoteAddr); err == nil {
+		if prior := pr.In.Header["X-Forwarded-For"]; len(prior) > 0 {
+			ip = strings.Join(prior, ", ") + ", " + ip
+		}
+		pr.Out.Header.Set("X-Forwarded-For", ip)
+	} else {
+		pr.Out.Header.Del("X-Forwarded-For")
+	}
+	pr.Out.Header.Set("X-Forwarded-Host", pr.In.Header.Get("Host"))
+	proto := "http"
+	if pr.In.TLS != nil {
+		proto = "https"
+	}
+	pr.Out.Header.Set("X-Forwarded-Proto", proto)
+}
+
+// ReverseProxy is a Handler that forwards requests to an upstream server
+// and relays the response back to the client.
+//
+// Bodies are relayed whole: the incoming body has already been read by the
+// server, and the upstream response is read in full before it is written,
+// since the ResponseWriter in this package is buffered.
+type ReverseProxy struct {
+	// Rewrite modifies the outbound request. It typically calls SetURL and
+	// SetXForwarded. Hop-by-hop headers are removed before it runs.
+	Rewrite func(*ProxyRequest)
+
+	// Director is the older single-request form of Rewrite, used when
+	// Rewrite is nil. It also receives the request after hop-by-hop
+	// headers were removed, and must set URL.
+	Director func(*Request)
+
+	// Upstreams, if set, picks the upstream for each request. Out.URL is
+	// already pointed at it when Rewrite or Director runs.
+	Upstreams *UpstreamPool
+
+	// Transport carries outbound requests. DefaultTransport is used when
+	// nil. Redirects are never followed, they are relayed to the client.
+	Transport *Transport
+
+	// ModifyResponse, if set, can alter the upstream response before it is
+	// relayed. A returned error is handed to ErrorHandler.
+	ModifyResponse func(*ClientResponse) error
+
+	// ErrorHandler replies when the upstream cannot be reached or
+	// ModifyResponse fails. By default it logs and answers 502.
+	ErrorHandler func(ResponseWriter, *Request, error)
+
+	// Logger is used by the default ErrorHandler; slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+// NewSingleHostReverseProxy returns a ReverseProxy sending every request to
+// target, with X-Forwarded-* headers set.
+func NewSingleHostReverseProxy(target *url.URL) *ReverseProxy {
+	return &ReverseProxy{
+		Rewrite: func(pr *ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+		},
+	}
+}
+
+func (p *ReverseProxy) ServeHTTP(w ResponseWriter, r *Request) {
+	var up *Upstream
+	if p.Upstreams != nil {
+		var err error
+		if up, err = p.Upstreams.acquire(); err != nil {
+			p.error(w, r, err)
+			return
+		}
+	}
+
+	out, err := p.outRequest(r, up)
+	if err != nil {
+		if up != nil {
+			p.Upstreams.release(up, false)
+		}
+		p.error(w, r, err)
+		return
+	}
+
+	t := p.Transport
+	if t == nil {
+		t = DefaultTransport
+	}
+	res, err := t.RoundTrip(out)
+	// the request on up lasts until its body has been relayed, and a body
+	// cut short counts against it like a failed round trip
+	body := &relayBody{}
+	if up != nil {
+		failed := upstreamFailed(res, err)
+		defer func() { p.Upstreams.release(up, failed || body.err != nil) }()
+	}
+	if err != nil {
+		p.error(w, r, err)
+		return
+	}
+	defer res.Body.Close()
+
+	if p.ModifyResponse != nil {
+		if err := p.ModifyResponse(res); err != nil {
+			p.error(w, r, err)
+			return
+		}
+	}
+	body.r = res.Body
+	// relay the body without holding it in memory when the writer can
+	// stream; a body of unknown length is staged so it can be framed
+	sw, stream := w.(interface{ SetBodyReader(io.Reader, int64) })
+	var buf []byte
+	var spill *SpillBuffer
+	switch {
+	case stream && res.ContentLength < 0:
+		spill = new(SpillBuffer)
+		if _, err := io.Copy(spill, body); err != nil {
+			spill.Close()
+			p.error(w, r, err)
+			return
+		}
+	case !stream:
+		if buf, err = io.ReadAll(body); err != nil {
+			p.error(w, r, err)
+			return
+		}
+	}
+
+	removeHopHeaders(res.Header)
+	// repeated fields that can't be joined, Set-Cookie above all, go out
+	// line by line when the writer can add values
+	aw, canAdd := w.(interface{ AddHeader(key, value string) })
+	for key, values := range res.Header {
+		switch key {
+		case "Content-Length", "Content-Encoding":
+			// the writer frames and compresses the relayed body itself
+			continue
+		}
+		v, ok := relayHeaderValue(key, values)
+		switch {
+		case ok:
+			w.SetHeader(key, v)
+		case canAdd:
+			w.SetHeader(key, values[0])
+			for _, v := range values[1:] {
+				aw.AddHeader(key, v)
+			}
+		default:
+			loggerOrDefault(p.Logger).Warn("reverse proxy relayed only the last of a repeated response header", "path", r.Path, "header", key, "values", len(values))
+			w.SetHeader(key, v)
+		}
+	}
+	_, text, _ := strings.Cut(res.Status, " ")
+	w.SetStatus(res.StatusCode, text)
+	switch {
+	case spill != nil:
+		sw.SetBodyReader(spill, spill.Len())
+	case stream:
+		sw.SetBodyReader(body, res.ContentLength)
+	default:
+		w.SetBody(buf)
+	}
+	w.Write()
+}
+
+// relayBody reads an upstream response body and remembers the first error
+// other than io.EOF, so a body the upstream cut short can be told apart
+// from a client that went away.
+type relayBody struct {
+	r   io.Reader
+	err error
+}
+
+func (b *relayBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// outRequest builds the upstream request from r and runs the rewrite hook.
+func (p *ReverseProxy) outRequest(r *Request, up *Upstream) (*Request, error) {
+	if r.URL == nil {
+		return nil, errors.New("http: ReverseProxy request has no URL")
+	}
+	out := r.Clone(r.Context())
+	out.Proto = "HTTP/1.1"
+	out.RemoteAddr, out.TLS, out.Pattern, out.RouteName = "", nil, "", ""
+	out.srv = nil
+	if out.Header == nil {
+		out.Header = make(Header)
+	}
+	removeHopHeaders(out.Header)
+	// the transport sends the trace context of the request's span, which
+	// is a child of the one the client sent
+	if _, ok := TraceContextFromContext(out.Context()); ok {
+		out.Header.Del("Traceparent")
+		out.Header.Del("Tracestate")
+	}
+	// let the transport negotiate and decode compression, so the body we
+	// relay is plain and our own writer can encode it for the client
+	out.Header.Del("Accept-Encoding")
+
+	pr := &ProxyRequest{In: r, Out: out}
+	if up != nil {
+		pr.SetURL(up.URL)
+	}
+	switch {
+	case p.Rewrite != nil:
+		p.Rewrite(pr)
+	case p.Director != nil:
+		p.Director(out)
+	case up != nil:
+	default:
+		return nil, errors.New("http: ReverseProxy has neither Rewrite nor Director")
+	}
+	if out.URL.Host == "" {
+		return nil, errMissingHost
+	}
+	return out, nil
+}
+
+func (p *ReverseProxy) error(w ResponseWriter, r *Request, err error) {
+	if p.ErrorHandler != nil {
+		p.ErrorHandler(w, r, err)
+		return
+	}
+	loggerOrDefault(p.Logger).Error("reverse proxy error", "path", r.Path, "err", err)
+	code := StatusBadGateway
+	if errors.Is(err, ErrNoUpstream) {
+		code = StatusServiceUnavailable
+	}
+	w.SetStatus(code, StatusText(code))
+	w.SetBody([]byte(StatusText(code)))
+	w.Write()
+}
+
+// listHeaders are the response fields defined as comma-separated lists
+// (RFC 9110 section 5.3), whose repeated lines can be joined into one.
+var listHeaders = map[string]bool{
+	"Accept-Patch":                  true,
+	"Accept-Ranges":                 true,
+	"Access-Control-Allow-Headers":  true,
+	"Access-Control-Allow-Methods":  true,
+	"Access-Control-Expose-Headers": true,
+	"Allow":                         true,
+	"Alt-Svc":                       true,
+	"Cache-Control":                 true,
+	"Content-Language":              true,
+	"Link":                          true,
+	"Pragma":                        true,
+	"Server-Timing":                 true,
+	"Vary":                          true,
+	"Via":                           true,
+	"Warning":                       true,
+	"Www-Authenticate":              true,
+}
+
+// relayHeaderValue returns the single value a ResponseWriter, which
+// holds one per header unless it has AddHeader, gets for a header
+// received with values. Fields that are lists are joined; Set-Cookie,
+// whose values hold commas of their own, keeps only the last, as does any
+// other field, since joining would change what it means. ok is false when
+// values are dropped.
+func relayHeaderValue(key string, values []string) (v string, ok bool) {
+	switch {
+	case len(values) == 1:
+		return values[0], true
+	case len(values) == 0:
+		return "", true
+	case listHeaders[key]:
+		return strings.Join(values, ", "), true
+	}
+	return values[len(values)-1], false
+}
+
+// removeHopHeaders deletes hop-by-hop headers from h, including any
+// named in its Connection header.
+func removeHopHeaders(h Header) {
+	for _, v := range h["Connection"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// joinURLPath joins the target's base path and the request path with
+// exactly one slash between them.
+func joinURLPath(a, b *url.URL) (path, rawpath string) {
+	if a.RawPath == "" && b.RawPath == "" {
+		return singleJoiningSlash(a.Path, b.Path), ""
+	}
+	apath, bpath := a.EscapedPath(), b.EscapedPath()
+	aslash, bslash := strings.HasSuffix(apath, "/"), strings.HasPrefix(bpath, "/")
+	switch {
+	case aslash && bslash:
+		return a.Path + b.Path[1:], apath + bpath[1:]
+	case !aslash && !bslash:
+		return a.Path + "/" + b.Path, apath + "/" + bpath
+	}
+	return a.Path + b.Path, apath + bpath
+}
+
+func singleJoiningSlash(a, b string) string {
+	aslash, bslash := strings.HasSuffix(a, "/"), strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
@@ -0,0 +1,297 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var setXForwardedTest = []struct {
+	remote string
+	prior  []string // X-Forwarded-For sent by the client
+	tls    bool
+	for_   string
+	proto  string
+}{
+	{"203.0.113.7:1234", nil, false, "203.0.113.7", "http"},
+	{"203.0.113.7:1234", nil, true, "203.0.113.7", "https"},
+	{"[2001:db8::1]:443", nil, true, "2001:db8::1", "https"},
+	{"203.0.113.7:1234", []string{"198.51.100.1"}, false, "198.51.100.1, 203.0.113.7", "http"},
+	{"203.0.113.7:1234", []string{"198.51.100.1", "198.51.100.2"}, false, "198.51.100.1, 198.51.100.2, 203.0.113.7", "http"},
+	{"", []string{"198.51.100.1"}, false, "", "http"},
+}
+
+func TestSetXForwarded(t *testing.T) {
+	for i, tt := range setXForwardedTest {
+		in, _ := NewRequest(MethodGet, "/a", nil)
+		in.Header.Set("Host", "example.com")
+		in.RemoteAddr = tt.remote
+		if tt.prior != nil {
+			in.Header["X-Forwarded-For"] = tt.prior
+		}
+		if tt.tls {
+			in.TLS = &tls.ConnectionState{}
+		}
+		out := in.Clone(in.Context())
+		out.Header = in.Header.Clone()
+		pr := &ProxyRequest{In: in, Out: out}
+		pr.SetXForwarded()
+		if got := out.Header.Get("X-Forwarded-For"); got != tt.for_ {
+			t.Errorf("#%d: gotFor: %q wantFor: %q", i, got, tt.for_)
+		}
+		if got := out.Header.Get("X-Forwarded-Proto"); got != tt.proto {
+			t.Errorf("#%d: gotProto: %q wantProto: %q", i, got, tt.proto)
+		}
+		if got := out.Header.Get("X-Forwarded-Host"); got != "example.com" {
+			t.Errorf("#%d: gotHost: %q wantHost: %q", i, got, "example.com")
+		}
+	}
+}
+
+func TestReverseProxyBehindTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(r.Header.Get("X-Forwarded-Proto")))
+		w.Write()
+	})}
+	go s.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		s.Shutdown(ctx)
+	}()
+
+	target, _ := url.Parse("http://" + ln.Addr().String())
+	p := NewSingleHostReverseProxy(target)
+	p.Transport = &Transport{DisableCompression: true}
+	h := TimeoutHandler(p, time.Second, "timed out")
+	for i, state := range []*tls.ConnectionState{nil, {}} {
+		req, _ := NewRequest(MethodGet, "/a", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		req.TLS = state
+		conn := &bufConn{}
+		h.ServeHTTP(NewResponse(conn, req), req)
+		res, err := ReadResponse(bufio.NewReader(&conn.w), req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		want := "http"
+		if state != nil {
+			want = "https"
+		}
+		if string(body) != want {
+			t.Errorf("#%d: gotProto: %q wantProto: %q", i, body, want)
+		}
+	}
+}
+
+var relayHeaderValueTest = []struct {
+	key    string
+	values []string
+	want   string
+	ok     bool
+}{
+	{"Content-Type", []string{"text/plain"}, "text/plain", true},
+	{"Vary", []string{"Accept", "Accept-Encoding"}, "Accept, Accept-Encoding", true},
+	{"Cache-Control", []string{"no-cache", "private"}, "no-cache, private", true},
+	{"Set-Cookie", []string{"a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT", "b=2"}, "b=2", false},
+	{"Content-Type", []string{"text/plain", "text/html"}, "text/html", false},
+	{"Location", []string{"/a", "/b"}, "/b", false},
+}
+
+func TestRelayHeaderValue(t *testing.T) {
+	for i, tt := range relayHeaderValueTest {
+		got, ok := relayHeaderValue(tt.key, tt.values)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("#%d: %s %q got (%q, %t) want (%q, %t)", i, tt.key, tt.values, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+var reverseProxyHeadersTest = []struct {
+	name     string
+	request  map[string][]string // sent to the proxy
+	upstream string              // response head the upstream sends
+	sent     map[string]string   // upstream should see, "" for absent
+	relayed  map[string]string   // client should see, lines joined by \n, "" for absent
+}{
+	{
+		name: "hop-by-hop request headers",
+		request: map[string][]string{
+			"Connection":          {"X-Trace, keep-alive"},
+			"X-Trace":             {"1"},
+			"Keep-Alive":          {"timeout=5"},
+			"Proxy-Authorization": {"Basic c2VjcmV0"},
+			"Te":                  {"trailers"},
+			"Upgrade":             {"websocket"},
+			"X-End-To-End":        {"kept"},
+		},
+		upstream: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n",
+		sent: map[string]string{
+			"Connection": "", "X-Trace": "", "Keep-Alive": "", "Proxy-Authorization": "", "Te": "", "Upgrade": "",
+			"X-End-To-End": "kept",
+		},
+	},
+	{
+		name: "hop-by-hop response headers",
+		upstream: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: X-Upstream-Hop\r\nX-Upstream-Hop: 1\r\n" +
+			"Keep-Alive: timeout=5\r\nProxy-Authenticate: Basic\r\nX-End-To-End: kept\r\n",
+		relayed: map[string]string{
+			"X-Upstream-Hop": "", "Keep-Alive": "", "Proxy-Authenticate": "",
+			"X-End-To-End": "kept",
+		},
+	},
+	{
+		name: "repeated response headers",
+		upstream: "HTTP/1.1 200 OK\r\nContent-Length: 0\r\nVary: Accept\r\nVary: Accept-Language\r\n" +
+			"Set-Cookie: a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT\r\nSet-Cookie: b=2; Path=/\r\n" +
+			"Cache-Control: private\r\nCache-Control: max-age=60\r\n",
+		relayed: map[string]string{
+			"Vary":          "Accept, Accept-Language, Accept-Encoding", // merged with the server's own
+			"Set-Cookie":    "a=1; Expires=Wed, 21 Oct 2026 07:28:00 GMT\nb=2; Path=/",
+			"Cache-Control": "private, max-age=60",
+		},
+	},
+}
+
+func TestReverseProxyHeaders(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type exchange struct {
+		head string
+		got  chan Header
+	}
+	exchanges := make(chan exchange)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			ex := <-exchanges
+			req, err := ReadRequest(bufio.NewReader(conn))
+			if err == nil {
+				ex.got <- req.Header.Clone()
+				io.WriteString(conn, ex.head+"Connection: close\r\n\r\n")
+			} else {
+				ex.got <- nil
+			}
+			conn.Close()
+		}
+	}()
+
+	target, _ := url.Parse("http://" + ln.Addr().String())
+	p := NewSingleHostReverseProxy(target)
+	p.Transport = &Transport{DisableCompression: true}
+	p.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	for i, tt := range reverseProxyHeadersTest {
+		req, _ := NewRequest(MethodGet, "/a", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		for k, v := range tt.request {
+			req.Header[k] = v
+		}
+		ex := exchange{tt.upstream, make(chan Header, 1)}
+		go func() { exchanges <- ex }()
+		conn := &bufConn{}
+		res := NewResponse(conn, &Request{Method: MethodGet, Header: Header{}})
+		p.ServeHTTP(res, req)
+
+		sent := <-ex.got
+		for k, want := range tt.sent {
+			if got := strings.Join(sent[k], ", "); got != want {
+				t.Errorf("#%d %s: gotSent %s: %q want: %q", i, tt.name, k, got, want)
+			}
+		}
+		cr, err := ReadResponse(bufio.NewReader(&conn.w), req)
+		if err != nil {
+			t.Errorf("#%d %s: unexpected error: %v", i, tt.name, err)
+			continue
+		}
+		for k, want := range tt.relayed {
+			if got := strings.Join(cr.Header[k], "\n"); got != want {
+				t.Errorf("#%d %s: gotRelayed %s: %q want: %q", i, tt.name, k, got, want)
+			}
+		}
+	}
+}
+
+// activeWriter notes how many requests its upstream had in flight when the
+// proxy started writing the response.
+type activeWriter struct {
+	*Response
+	u      *Upstream
+	active int64
+}
+
+func (w *activeWriter) Write() error {
+	w.active = w.u.Active()
+	return w.Response.Write()
+}
+
+var reverseProxyReleaseTest = []struct {
+	upstream string // response the upstream sends before closing
+	fails    int
+}{
+	{"HTTP/1.1 200 OK\r\nContent-Length: 5\r\n\r\nhello", 0},
+	{"HTTP/1.1 200 OK\r\nContent-Length: 10\r\n\r\nhello", 1}, // body cut short
+	{"HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n", 1},
+}
+
+func TestReverseProxyReleasesAfterBody(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	responses := make(chan string)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			ReadRequest(bufio.NewReader(conn))
+			io.WriteString(conn, <-responses)
+			conn.Close()
+		}
+	}()
+
+	target, _ := url.Parse("http://" + ln.Addr().String())
+	for i, tt := range reverseProxyReleaseTest {
+		pool := NewUpstreamPool(RoundRobin, target)
+		p := NewLoadBalancingReverseProxy(pool)
+		p.Transport = &Transport{DisableCompression: true}
+		p.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+		go func() { responses <- tt.upstream }()
+
+		req, _ := NewRequest(MethodGet, "/a", nil)
+		req.RemoteAddr = "203.0.113.7:1234"
+		u := pool.Upstreams[0]
+		w := &activeWriter{Response: NewResponse(&bufConn{}, &Request{Method: MethodGet, Header: Header{}}), u: u}
+		p.ServeHTTP(w, req)
+
+		if w.active != 1 {
+			t.Errorf("#%d: gotActive while relaying: %d wantActive: 1", i, w.active)
+		}
+		if got := u.Active(); got != 0 {
+			t.Errorf("#%d: gotActive after: %d wantActive: 0", i, got)
+		}
+		if u.fails != tt.fails {
+			t.Errorf("#%d: gotFails: %d wantFails: %d", i, u.fails, tt.fails)
+		}
+	}
+}
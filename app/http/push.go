@@ -0,0 +1,50 @@
+package http
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotSupported is returned by Push when the connection can't push.
+var ErrNotSupported = errors.New("http: feature not supported")
+
+// Pusher is implemented by response writers whose connection supports
+// HTTP/2 server push. The server speaks only HTTP/1.1 for now, so none of
+// its writers do yet; handlers should call Push rather than assert it.
+type Pusher interface {
+	// Push starts sending target, an absolute path such as "/app.css",
+	// as though the client had requested it, before the response that
+	// references it.
+	Push(target string, opts *PushOptions) error
+}
+
+// PushOptions describes the request a pushed response answers.
+type PushOptions struct {
+	// Method is GET or HEAD; GET is used when empty.
+	Method string
+
+	// Header holds extra request headers, such as Accept-Encoding.
+	Header Header
+}
+
+// Push pushes target alongside the response being written to w, for
+// resources like the CSS and scripts an HTML document needs:
+//
+//	http.Push(w, "/static/app.css", nil)
+//
+// On connections without server push, including every HTTP/1.1 one, it
+// does nothing and returns ErrNotSupported, so handlers can push
+// unconditionally and ignore the error.
+func Push(w ResponseWriter, target string, opts *PushOptions) error {
+	if !strings.HasPrefix(target, "/") {
+		return errors.New("http: push target must be an absolute path")
+	}
+	if opts != nil && opts.Method != "" && opts.Method != MethodGet && opts.Method != MethodHead {
+		return errors.New("http: push method must be GET or HEAD")
+	}
+	p, ok := AsResponseWriter[Pusher](w)
+	if !ok {
+		return ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
@@ -0,0 +1,40 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+type recordingPusher struct {
+	http.BaseResponseWriter
+	pushed []string
+}
+
+func (p *recordingPusher) Push(target string, opts *http.PushOptions) error {
+	p.pushed = append(p.pushed, target)
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := http.Push(rec, "/app.css", nil); err != http.ErrNotSupported {
+		t.Errorf("on HTTP/1.1 gotErr: %v wantErr: %v", err, http.ErrNotSupported)
+	}
+
+	p := &recordingPusher{BaseResponseWriter: http.BaseResponseWriter{ResponseWriter: rec}}
+	wrapped := &statusRecorder{BaseResponseWriter: http.BaseResponseWriter{ResponseWriter: p}}
+	if err := http.Push(wrapped, "/app.css", &http.PushOptions{Method: http.MethodGet}); err != nil {
+		t.Errorf("through a wrapper gotErr: %v", err)
+	}
+	if err := http.Push(wrapped, "app.js", nil); err == nil {
+		t.Errorf("relative target accepted")
+	}
+	if err := http.Push(wrapped, "/form", &http.PushOptions{Method: http.MethodPost}); err == nil {
+		t.Errorf("POST push accepted")
+	}
+	if len(p.pushed) != 1 || p.pushed[0] != "/app.css" {
+		t.Errorf("gotPushed: %q", p.pushed)
+	}
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// checkSpace returns the status refusing a write that grows the files
+// under Root by grow bytes, net of any file it replaces, and puts written
+// bytes on disk, or 0 to go ahead: 507 when the write would pass Quota or
+// leave less than MinFreeSpace free. pending is how much of written is
+// already on disk in a temporary file, as for a chunked upload checked
+// once it has streamed in.
+func (h *FilesHandler) checkSpace(grow, written, pending int64) int {
+	if h.Quota > 0 && grow > 0 {
+		used, err := diskUsage(h.Root)
+		if err != nil {
+			h.logger().Error("measuring quota", "root", h.Root, "err", err)
+			return StatusInternalServerError
+		}
+		used -= pending
+		if used+grow > h.Quota {
+			h.logger().Warn("upload over quota", "root", h.Root, "used", used, "grow", grow, "quota", h.Quota)
+			return StatusInsufficientStorage
+		}
+	}
+	if h.MinFreeSpace > 0 {
+		if free, ok := freeSpace(h.Root); ok && free+pending-written < h.MinFreeSpace {
+			h.logger().Warn("upload would fill the disk", "root", h.Root, "free", free, "written", written, "min_free", h.MinFreeSpace)
+			return StatusInsufficientStorage
+		}
+	}
+	return 0
+}
+
+// diskUsage returns the total size of the regular files under root.
+func diskUsage(root string) (int64, error) {
+	var total int64
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				// removed since it was listed
+				return nil
+			}
+			total += fi.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// errUnsatisfiableRange is returned by parseRange when none of the bytes
+// asked for exist; the response is 416 Range Not Satisfiable.
+var errUnsatisfiableRange = errors.New("http: unsatisfiable range")
+
+// byteRange is the part of a representation a Range header selected.
+type byteRange struct {
+	start, length int64
+}
+
+// contentRange formats br as a Content-Range value for a representation
+// of size bytes.
+func (br byteRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(br.start, 10) + "-" + strconv.FormatInt(br.start+br.length-1, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+// parseRange parses the Range header s for a representation of size
+// bytes, per RFC 9110 section 14.2. It reports false when the whole
+// representation should be sent instead: when s is empty, malformed, in a
+// unit other than bytes or asks for more than one range, which servers
+// may answer in full. Ends past the last byte are clamped.
+func parseRange(s string, size int64) (byteRange, bool, error) {
+	spec, ok := strings.CutPrefix(s, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok || first == "" && last == "" || first != "" && !isDigits(first) || last != "" && !isDigits(last) {
+		return byteRange{}, false, nil
+	}
+	if first == "" {
+		// a suffix: the final n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil {
+			return byteRange{}, false, nil
+		}
+		if n == 0 || size == 0 {
+			return byteRange{}, false, errUnsatisfiableRange
+		}
+		n = min(n, size)
+		return byteRange{start: size - n, length: n}, true, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return byteRange{}, false, nil
+	}
+	end := size - 1
+	if last != "" {
+		if end, err = strconv.ParseInt(last, 10, 64); err != nil || end < start {
+			return byteRange{}, false, nil
+		}
+	}
+	if start >= size {
+		return byteRange{}, false, errUnsatisfiableRange
+	}
+	end = min(end, size-1)
+	return byteRange{start: start, length: end - start + 1}, true, nil
+}
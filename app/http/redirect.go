@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxRedirects is how many redirects a Client follows when neither
+// CheckRedirect nor MaxRedirects is set.
+const DefaultMaxRedirects = 10
+
+// ErrUseLastResponse can be returned by Client.CheckRedirect to stop
+// following redirects and return the most recent response unread.
+var ErrUseLastResponse = errors.New("http: use last response")
+
+// sensitiveHeaders are dropped when a redirect leaves the original origin,
+// scheme, host and port, so credentials meant for one origin are never
+// sent to another, nor in the clear after an https URL redirects to http.
+var sensitiveHeaders = []string{"Authorization", "Www-Authenticate", "Cookie", "Cookie2", "Proxy-Authorization"}
+
+// redirect builds the follow-up request for a 3xx response, or returns nil
+// when res is final.
+func (c *Client) redirect(req *Request, res *ClientResponse, via []*Request) (*Request, error) {
+	method, keepBody, ok := redirectBehavior(req.Method, res.StatusCode)
+	if !ok {
+		return nil, nil
+	}
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		// nothing to follow, hand the 3xx to the caller
+		return nil, nil
+	}
+	u, err := req.URL.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("http: failed to parse Location header %q: %w", loc, err)
+	}
+
+	next := req.Clone(req.Context())
+	next.Method = method
+	next.Path = u.RequestURI()
+	next.URL = u
+	next.Header.Del("Host")
+	if !keepBody {
+		next.Body = nil
+		next.Header.Del("Content-Length")
+		next.Header.Del("Content-Type")
+	}
+	if !sameOrigin(req.URL, u) {
+		for _, h := range sensitiveHeaders {
+			next.Header.Del(h)
+		}
+	}
+
+	via = append(via, req)
+	if c.CheckRedirect != nil {
+		return next, c.CheckRedirect(next, via)
+	}
+	max := c.MaxRedirects
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+	if len(via) > max {
+		return nil, fmt.Errorf("http: stopped after %d redirects", max)
+	}
+	return next, nil
+}
+
+// redirectBehavior reports whether code is a redirect to follow, and with
+// which method. 307 and 308 must repeat the request as sent; 301, 302 and
+// 303 switch to GET without a body, except that HEAD stays HEAD.
+func redirectBehavior(method string, code int) (newMethod string, keepBody, ok bool) {
+	switch code {
+	case StatusMovedPermanently, StatusFound, StatusSeeOther:
+		if method == MethodHead {
+			return MethodHead, false, true
+		}
+		return MethodGet, false, true
+	case StatusTemporaryRedirect, StatusPermanentRedirect:
+		return method, true, true
+	}
+	return "", false, false
+}
+
+// sameOrigin reports whether a and b have the same scheme, host and port,
+// default ports filled in.
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(hostPort(a), hostPort(b))
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+// Renderer renders html/template pages from a file system. Each page is
+// parsed together with the layout and partials once, then served from
+// cache; DevMode re-parses on every render so edits show up without a
+// restart.
+//
+// With a Layout, the layout is executed and pages provide the blocks it
+// references, typically {{define "content"}}. Without one, the page is
+// executed directly.
+type Renderer struct {
+	FS fs.FS
+
+	// Layout names the layout file in FS, e.g. "layouts/base.html".
+	Layout string
+
+	// Partials is a glob of shared templates in FS, e.g. "partials/*.html".
+	Partials string
+
+	// Funcs is made available to every template.
+	Funcs template.FuncMap
+
+	DevMode bool
+
+	mu    sync.RWMutex
+	cache map[string]*template.Template
+}
+
+// NewRenderer returns a Renderer reading templates from fsys, e.g.
+// os.DirFS("templates") or an embed.FS.
+func NewRenderer(fsys fs.FS) *Renderer {
+	return &Renderer{FS: fsys}
+}
+
+// Render executes the page template name with data and writes it with
+// the given status. The page renders into a buffer first, so a template
+// error answers 500 instead of sending a half-written page.
+func (rr *Renderer) Render(w ResponseWriter, status int, name string, data any) error {
+	t, err := rr.template(name)
+	if err == nil {
+		var b bytes.Buffer
+		if err = t.ExecuteTemplate(&b, rr.entry(name), data); err == nil {
+			w.SetStatus(status, StatusText(status))
+			w.SetHeader("Content-Type", "text/html; charset=utf-8")
+			w.SetBody(b.Bytes())
+			return w.Write()
+		}
+	}
+	w.SetStatus(StatusInternalServerError, StatusText(StatusInternalServerError))
+	w.SetBody([]byte(StatusText(StatusInternalServerError)))
+	w.Write()
+	return fmt.Errorf("http: render %s: %w", name, err)
+}
+
+// entry names the template to execute for page name.
+func (rr *Renderer) entry(name string) string {
+	if rr.Layout != "" {
+		return path.Base(rr.Layout)
+	}
+	return path.Base(name)
+}
+
+func (rr *Renderer) template(name string) (*template.Template, error) {
+	if rr.DevMode {
+		return rr.parse(name)
+	}
+
+	rr.mu.RLock()
+	t, ok := rr.cache[name]
+	rr.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := rr.parse(name)
+	if err != nil {
+		return nil, err
+	}
+	rr.mu.Lock()
+	if rr.cache == nil {
+		rr.cache = make(map[string]*template.Template)
+	}
+	rr.cache[name] = t
+	rr.mu.Unlock()
+	return t, nil
+}
+
+// parse builds the template set for one page: layout first, then the
+// partials, then the page so its blocks override the layout defaults.
+func (rr *Renderer) parse(name string) (*template.Template, error) {
+	var files []string
+	if rr.Layout != "" {
+		files = append(files, rr.Layout)
+	}
+	if rr.Partials != "" {
+		partials, err := fs.Glob(rr.FS, rr.Partials)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, partials...)
+	}
+	files = append(files, name)
+
+	var t *template.Template
+	for _, f := range files {
+		src, err := fs.ReadFile(rr.FS, f)
+		if err != nil {
+			return nil, err
+		}
+		var tt *template.Template
+		if t == nil {
+			t = template.New(path.Base(f)).Funcs(rr.Funcs)
+			tt = t
+		} else {
+			tt = t.New(path.Base(f))
+		}
+		if _, err := tt.Parse(string(src)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Reset drops every cached template, e.g. after deploying new templates.
+func (rr *Renderer) Reset() {
+	rr.mu.Lock()
+	rr.cache = nil
+	rr.mu.Unlock()
+}
@@ -0,0 +1,87 @@
+package http_test
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+var renderFS = fstest.MapFS{
+	"layouts/base.html":  {Data: []byte(`<title>{{block "title" .}}Site{{end}}</title>{{template "content" .}}{{template "nav.html"}}`)},
+	"partials/nav.html":  {Data: []byte(`<nav>home</nav>`)},
+	"pages/home.html":    {Data: []byte(`{{define "content"}}<p>{{.}}</p>{{end}}`)},
+	"pages/about.html":   {Data: []byte(`{{define "title"}}About{{end}}{{define "content"}}<p>{{upper .}}</p>{{end}}`)},
+	"pages/broken.html":  {Data: []byte(`{{define "content"}}<p>{{.Missing.Field}}</p>{{end}}`)},
+	"pages/invalid.html": {Data: []byte(`{{define "content"}}{{end`)},
+	"single.html":        {Data: []byte(`<p>{{.}}</p>`)},
+}
+
+var renderTest = []struct {
+	layout, partials string
+	page             string
+	data             any
+	code             int
+	body             string
+	err              bool
+}{
+	{"layouts/base.html", "partials/*.html", "pages/home.html", "<hi>", 200, `<title>Site</title><p>&lt;hi&gt;</p><nav>home</nav>`, false},
+	{"layouts/base.html", "partials/*.html", "pages/about.html", "us", 200, `<title>About</title><p>US</p><nav>home</nav>`, false},
+	{"", "", "single.html", "alone", 200, `<p>alone</p>`, false},
+	{"layouts/base.html", "partials/*.html", "pages/missing.html", nil, 500, "Internal Server Error", true},
+	{"layouts/base.html", "partials/*.html", "pages/invalid.html", nil, 500, "Internal Server Error", true},
+	{"layouts/base.html", "partials/*.html", "pages/broken.html", "no fields", 500, "Internal Server Error", true},
+	{"layouts/base.html", "", "pages/home.html", "x", 500, "Internal Server Error", true}, // nav.html is a partial
+}
+
+func TestRender(t *testing.T) {
+	for i, tt := range renderTest {
+		rr := http.NewRenderer(renderFS)
+		rr.Layout, rr.Partials = tt.layout, tt.partials
+		rr.Funcs = template.FuncMap{"upper": strings.ToUpper}
+		rec := httptest.NewRecorder()
+		err := rr.Render(rec, 200, tt.page, tt.data)
+		if (err != nil) != tt.err {
+			t.Errorf("#%d: %s gotErr: %v wantErr: %t", i, tt.page, err, tt.err)
+		}
+		if rec.Code != tt.code || string(rec.Body) != tt.body || rec.Writes != 1 {
+			t.Errorf("#%d: %s gotCode: %d gotBody: %q gotWrites: %d wantCode: %d wantBody: %q",
+				i, tt.page, rec.Code, rec.Body, rec.Writes, tt.code, tt.body)
+		}
+		if ct := rec.GetHeader("Content-Type"); !tt.err && ct != "text/html; charset=utf-8" {
+			t.Errorf("#%d: %s gotContentType: %q", i, tt.page, ct)
+		}
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		fsys := fstest.MapFS{"page.html": {Data: []byte("v1")}}
+		rr := http.NewRenderer(fsys)
+		rr.DevMode = dev
+		render := func() string {
+			rec := httptest.NewRecorder()
+			if err := rr.Render(rec, 200, "page.html", nil); err != nil {
+				t.Fatalf("dev %t: unexpected error: %v", dev, err)
+			}
+			return string(rec.Body)
+		}
+
+		render()
+		fsys["page.html"] = &fstest.MapFile{Data: []byte("v2")}
+		want := "v1" // served from cache
+		if dev {
+			want = "v2"
+		}
+		if got := render(); got != want {
+			t.Errorf("dev %t: gotBody: %q wantBody: %q", dev, got, want)
+		}
+		rr.Reset()
+		if got := render(); got != "v2" {
+			t.Errorf("dev %t: after Reset gotBody: %q wantBody: %q", dev, got, "v2")
+		}
+	}
+}
@@ -2,9 +2,12 @@ package http
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -19,12 +22,187 @@ type Request struct {
 	Path   string
 	Proto  string
 	Header Header
-	Body   []byte
+
+	// Body is the whole request body, read before the handler runs. It is
+	// empty for requests sending neither Content-Length nor a chunked
+	// body, and for handlers that stream the body, see BodyReader.
+	Body []byte
+
+	// URL is the parsed request target. For served requests it usually
+	// holds only the path and query; client requests need a scheme and
+	// host as well.
+	URL *url.URL
+
+	// RemoteAddr is the network address of the client that sent a served
+	// request. It is empty for client requests.
+	RemoteAddr string
+
+	// TLS describes the TLS connection a served request arrived on, and is
+	// nil for plaintext connections and client requests.
+	TLS *tls.ConnectionState
+
+	// Pattern is the ServeMux pattern that matched the request, set before
+	// the handler runs. It is empty when no route matched.
+	Pattern string
+
+	// RouteName identifies the matched route for metrics and logs: the
+	// name it was registered with by ServeMux.HandleNamed, such as
+	// "/files/{name}", or Pattern otherwise. Unlike Path it has one value
+	// per route, however many paths the route serves.
+	RouteName string
+
+	ctx context.Context
+
+	// body is the unread body of a request whose handler streams it
+	body *bodyStream
+
+	srv *Server // the server that read the request, nil for client requests
+
+	bytesRead int64 // wire size of the request, set by the server
+
+	active *activeRequest // the server's in-flight record, for InFlight
+
+	// headerVals backs the single-value slices in Header; pooled requests
+	// keep it to avoid reallocating on every request
+	headerVals []string
+}
+
+// Context returns the request's context. It is never nil; it defaults to
+// context.Background().
+func (r *Request) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
 }
 
-func badStringErr(what, val string) error { return fmt.Errorf("%s: %s", what, val) }
+// WithContext returns a shallow copy of r with its context changed to ctx.
+func (r *Request) WithContext(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.ctx = ctx
+	return r2
+}
 
-var ErrBodyTooLarge = fmt.Errorf("http: request body too large")
+// GetBody returns a new reader over the request body. Bodies are held in
+// memory, read in full by the server and by NewRequest, so every call
+// starts from the beginning; this is what lets redirects and retries
+// resend a POST. Use it to hand the body to APIs that want a reader; a
+// body the handler streams is only read through BodyReader.
+func (r *Request) GetBody() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(r.Body)), nil
+}
+
+// BodyReader returns a reader over the request body. Most handlers are
+// served with the body already read into Body, and the reader reads that;
+// for the few that take the body as it arrives, such as FilesHandler
+// uploads, it reads the body off the connection and Body stays empty.
+func (r *Request) BodyReader() io.Reader {
+	if r.body != nil {
+		return r.body
+	}
+	return bytes.NewReader(r.Body)
+}
+
+// Clone returns a deep copy of r with its context changed to ctx. The
+// header, URL and body are copied, so either request can be changed or
+// sent without affecting the other.
+func (r *Request) Clone(ctx context.Context) *Request {
+	if ctx == nil {
+		panic("nil context")
+	}
+	r2 := &Request{
+		Method:     r.Method,
+		Path:       r.Path,
+		Proto:      r.Proto,
+		Header:     r.Header.Clone(),
+		Body:       bytes.Clone(r.Body),
+		RemoteAddr: r.RemoteAddr,
+		TLS:        r.TLS,
+		Pattern:    r.Pattern,
+		RouteName:  r.RouteName,
+		ctx:        ctx,
+		srv:        r.srv,
+		active:     r.active,
+	}
+	if r.URL != nil {
+		u := *r.URL // Userinfo is immutable, so sharing it is safe
+		r2.URL = &u
+	}
+	return r2
+}
+
+// BytesRead returns how many bytes of the connection the request took up,
+// from the request line through the end of the body. It is zero for
+// requests not read by a Server.
+func (r *Request) BytesRead() int64 {
+	return r.bytesRead
+}
+
+// wantsKeepAlive reports whether the client expects the connection to stay
+// open after the response: HTTP/1.1 unless it sent Connection: close, and
+// HTTP/1.0 only if it sent Connection: keep-alive.
+func (r *Request) wantsKeepAlive() bool {
+	conn := r.Header["Connection"]
+	if r.Proto == "HTTP/1.0" {
+		return headerHasToken(conn, "keep-alive") && !headerHasToken(conn, "close")
+	}
+	return !headerHasToken(conn, "close")
+}
+
+// headerHasToken reports whether any of the comma-separated header values
+// contains token, ignoring case.
+func headerHasToken(values []string, token string) bool {
+	for _, v := range values {
+		if hasToken(v, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasToken(v, token string) bool {
+	for part := range strings.SplitSeq(v, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	errAmbiguousFraming error = &malformedError{"http: both Transfer-Encoding and Content-Length", "ambiguous_framing"}
+	errBadContentLength error = &malformedError{"http: invalid Content-Length", "invalid_content_length"}
+)
+
+// isDigits reports whether s is a non-empty run of ASCII digits, the only
+// form RFC 9110 section 8.6 gives Content-Length.
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// chunkedBodyReader decodes a chunked request body, failing with
+// ErrBodyTooLarge once more than n bytes have been decoded.
+type chunkedBodyReader struct {
+	r *chunkedReader
+	n int64 // bytes remaining allowed
+}
+
+func (c *chunkedBodyReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.n -= int64(n); c.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	return n, err
+}
 
 type maxByteReader struct {
 	r io.Reader // underlying reader(bufio)
@@ -53,58 +231,202 @@ func (l *maxByteReader) Read(p []byte) (n int, err error) {
 }
 
 func ReadRequest(b *bufio.Reader) (req *Request, err error) {
-	// textproto handle text which are basically in streams and parse accordingly with clrf
-	tp := textproto.NewReader(b)
-	req = new(Request)
-	requestLine, err := tp.ReadLine()
+	return readRequest(b, new(Request), readOptions{maxBody: MAX_BODY_SIZE})
+}
+
+// readOptions control how leniently readRequest parses.
+type readOptions struct {
+	maxBody     int64 // larger bodies are rejected with ErrBodyTooLarge
+	allowBareLF bool  // accept lines ending in LF alone
+	strict      bool  // reject anything ambiguous; see Server.StrictParsing
+
+	// requireLength rejects unframed bodies of methods that carry one;
+	// see Server.RequireContentLength
+	requireLength bool
+
+	audit      *BodyAudit // if set, tees bodies it wants
+	remoteAddr string     // the client, for audit records
+
+	// stream, if set, reports whether the handler for a request reads its
+	// body as it arrives; such bodies are left on the connection
+	stream func(*Request) bool
+}
+
+// bodyStream is a request body left on the connection for the handler to
+// read. A body ending before its Content-Length fails with
+// io.ErrUnexpectedEOF rather than passing for a shorter one.
+type bodyStream struct {
+	r    io.Reader
+	n    int64 // bytes still due, -1 for a chunked body
+	eof  bool
+	done func() // called once the whole body has been read
+}
+
+func (b *bodyStream) Read(p []byte) (int, error) {
+	if b.eof {
+		return 0, io.EOF
+	}
+	n, err := b.r.Read(p)
+	if b.n >= 0 {
+		b.n -= int64(n)
+	}
+	if err == io.EOF {
+		if b.n > 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		b.eof = true
+		b.done()
+	}
+	return n, err
+}
+
+// readRequest parses the next request from b into req, which must be
+// zeroed apart from an empty Header map and header value backing array
+// kept from a previous request.
+func readRequest(b *bufio.Reader, req *Request, opts readOptions) (*Request, error) {
+	line, err := readLine(b, opts.allowBareLF)
 	if err != nil {
 		return nil, err
 	}
+	requestLine := string(line)
 
 	var ok bool
 	req.Method, req.Path, req.Proto, ok = parseRequestLine(requestLine)
 	if !ok {
-		return nil, badStringErr("Malformed HTTP request", requestLine)
+		return nil, badRequestLine("Malformed HTTP request", requestLine)
 	}
 	// validate method
 	if valid := isValidMethod(req.Method); !valid {
-		return nil, badStringErr("Malformed HTTP request", requestLine)
+		return nil, badRequestLine("Malformed HTTP request", requestLine)
+	}
+	major, _, ok := parseHTTPVersion(req.Proto)
+	if !ok {
+		return nil, badRequestLine("Malformed HTTP version", req.Proto)
+	}
+	if major != 1 {
+		return nil, ErrUnsupportedVersion
+	}
+	// the asterisk-form target only exists for server-wide OPTIONS
+	if req.Path == "*" && req.Method != MethodOptions {
+		return nil, badRequestLine("Malformed HTTP request target", req.Path)
+	}
+	if req.URL, err = url.ParseRequestURI(req.Path); err != nil {
+		return nil, badRequestLine("Malformed HTTP request target", req.Path)
 	}
 
 	// PARSING HEADERs
-	mineHeaders, err := tp.ReadMIMEHeader()
-	if err != nil {
-		return nil, err
+	if req.Header == nil {
+		req.Header = make(Header, 8)
 	}
-	req.Header = Header(mineHeaders)
-	if len(req.Header["Host"]) > 1 {
-		return nil, fmt.Errorf("too many Host in header")
+	if req.headerVals, err = readHeader(b, req.Header, req.headerVals, opts.allowBareLF, opts.strict); err != nil {
+		return nil, err
 	}
 
-	contentLength := req.Header.Get("Content-Length")
-	contentLengthInt, _ := strconv.Atoi(contentLength)
-	fmt.Printf("content length: %v and max body size: %v\n", contentLengthInt, MAX_BODY_SIZE)
-	if contentLengthInt > MAX_BODY_SIZE {
-		return nil, ErrBodyTooLarge
+	var limitedReader io.Reader
+	n := int64(-1) // the body's Content-Length, if it has one
+	if te := req.Header["Transfer-Encoding"]; len(te) > 0 {
+		// chunked must be the only coding; Transfer-Encoding overrides
+		// Content-Length (RFC 9112 section 6.3), unless strict mode
+		// rejects the pair as the smuggling attempt it likely is
+		if len(te) > 1 || !strings.EqualFold(strings.TrimSpace(te[0]), "chunked") {
+			return nil, ErrUnsupportedTransferEncoding
+		}
+		if opts.strict && req.Header.Get("Content-Length") != "" {
+			return nil, errAmbiguousFraming
+		}
+		req.Header.Del("Content-Length")
+		limitedReader = &chunkedBodyReader{r: &chunkedReader{r: b, strict: opts.strict}, n: opts.maxBody}
+	} else {
+		contentLength := req.Header.Get("Content-Length")
+		// without either framing header the body is empty (RFC 9112
+		// section 6.3), unless the server insists on a length
+		if contentLength == "" && opts.requireLength && expectsBody(req.Method) {
+			return nil, ErrLengthRequired
+		}
+		// anything but plain digits, a sign included, leaves the body's
+		// end unknown
+		if contentLength != "" && !isDigits(contentLength) {
+			return nil, errBadContentLength
+		}
+		if contentLength != "" {
+			var err error
+			// digits that overflow are too large for any limit
+			if n, err = strconv.ParseInt(contentLength, 10, 64); err != nil || n > opts.maxBody {
+				return nil, ErrBodyTooLarge
+			}
+		}
+		if n > 0 {
+			limitedReader = &maxByteReader{
+				r: b,
+				n: n,
+			}
+		}
 	}
 
-	if contentLengthInt > 0 {
-		limitedReader := &maxByteReader{
-			r: b,
-			n: int64(contentLengthInt),
+	if limitedReader != nil {
+		audited := func() {}
+		if opts.audit != nil && opts.audit.wants(req.Header.Get("Content-Type")) {
+			limitedReader, audited = opts.audit.tee(limitedReader, req, opts.remoteAddr)
+		}
+		if opts.stream != nil && opts.stream(req) {
+			req.body = &bodyStream{r: limitedReader, n: n, done: audited}
+			return req, nil
 		}
 
 		buffer, err := io.ReadAll(limitedReader)
 		if err != nil {
-			fmt.Printf("error reading with limited reader: %s", err.Error())
 			return nil, err
 		}
 		req.Body = buffer
+		audited()
 	}
 
 	return req, nil
 }
 
+// expectsBody reports whether requests with method normally carry a body.
+func expectsBody(method string) bool {
+	return method == MethodPost || method == MethodPut || method == MethodPatch
+}
+
+// NewRequest returns a Request for method and target with every field
+// populated as if it had been parsed: URL, Host header, protocol, context
+// and body. target is either an absolute URL, for use with a Client, or a
+// path such as "/files/a.txt", for calling a Handler in tests; the Host
+// header of the latter defaults to "example.com".
+func NewRequest(method, target string, body io.Reader) (*Request, error) {
+	if method == "" {
+		method = MethodGet
+	}
+	if !isValidMethod(method) {
+		return nil, fmt.Errorf("http: invalid method %q", method)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if host == "" {
+		host = "example.com"
+	}
+
+	req := &Request{
+		Method: method,
+		Path:   u.RequestURI(),
+		Proto:  "HTTP/1.1",
+		Header: Header{"Host": {host}},
+		URL:    u,
+		ctx:    context.Background(),
+	}
+	if body != nil {
+		if req.Body, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Length", strconv.Itoa(len(req.Body)))
+	}
+	return req, nil
+}
+
 // parse request line to method, uri, proto
 func parseRequestLine(s string) (method, requestURI, proto string, ok bool) {
 	method, rest, ok1 := strings.Cut(s, " ")
@@ -115,6 +437,19 @@ func parseRequestLine(s string) (method, requestURI, proto string, ok bool) {
 	return method, requestURI, proto, true
 }
 
+// parseHTTPVersion parses an HTTP-version of the form "HTTP/" DIGIT "."
+// DIGIT (RFC 9112 section 2.3). Any 1.x minor version is served as 1.1.
+func parseHTTPVersion(proto string) (major, minor int, ok bool) {
+	if len(proto) != len("HTTP/1.1") || !strings.HasPrefix(proto, "HTTP/") || proto[6] != '.' {
+		return 0, 0, false
+	}
+	maj, mnr := proto[5], proto[7]
+	if maj < '0' || maj > '9' || mnr < '0' || mnr > '9' {
+		return 0, 0, false
+	}
+	return int(maj - '0'), int(mnr - '0'), true
+}
+
 // according to HTTP spec, methods can be extended.
 // the only restriction is that it should be valid token.
 // for easier implementation, httpguts is used.
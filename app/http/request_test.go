@@ -1,6 +1,11 @@
 package http
 
-import "testing"
+import (
+	"bufio"
+	"context"
+	"strings"
+	"testing"
+)
 
 var parseRequestLineTest = []struct {
 	line, method, path, proto string
@@ -27,3 +32,165 @@ func TestParseRequestLine(t *testing.T) {
 		}
 	}
 }
+
+var readHeaderTest = []struct {
+	raw, key, value string
+	err             bool
+}{
+	{"host: a\r\n\r\n", "Host", "a", false},
+	{"USER-AGENT:  curl/8.0 \r\n\r\n", "User-Agent", "curl/8.0", false},
+	{"x-custom-thing: v\n\n", "", "", true},
+	{"Host: a\r\n\n", "", "", true},
+	{"X-Fold: a\r\n  b\r\n\r\n", "X-Fold", "a b", false},
+	{"Host : a\r\n\r\n", "", "", true},
+	{"Bad Key: a\r\n\r\n", "", "", true},
+	{"no colon\r\n\r\n", "", "", true},
+	{" leading: fold\r\n\r\n", "", "", true},
+	{"Host: a\r\n", "", "", true},
+	{"Host: a\r\nHost: b\r\n\r\n", "", "", true},
+	{"Content-Length: 5\r\nContent-Length: 6\r\n\r\n", "", "", true},
+	{"Content-Length: 5\r\nContent-Length: 5\r\n\r\n", "Content-Length", "5", false},
+	{"Accept: text/html\r\naccept: */*\r\n\r\n", "Accept", "text/html, */*", false},
+	{"Cookie: a=1\r\nCookie: b=2\r\n\r\n", "Cookie", "a=1; b=2", false},
+	{"X-Multi: 1\r\nX-Multi: 2\r\n\r\n", "X-Multi", "1", false},
+}
+
+func TestReadHeader(t *testing.T) {
+	for i, tt := range readHeaderTest {
+		h := make(Header)
+		_, err := readHeader(bufio.NewReader(strings.NewReader(tt.raw)), h, nil, false, false)
+		if tt.err {
+			if err == nil {
+				t.Errorf("#%d: expected error, gotHeader: %v", i, h)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got := h.Get(tt.key); got != tt.value {
+			t.Errorf("#%d: gotValue: %q wantValue: %q", i, got, tt.value)
+		}
+	}
+}
+
+func TestReadRequestReusesHeader(t *testing.T) {
+	raw := "GET / HTTP/1.1\r\nHost: a\r\nX-Multi: a\r\nX-Multi: b\r\n\r\n" +
+		"GET / HTTP/1.1\r\nHost: b\r\n\r\n"
+	b := bufio.NewReader(strings.NewReader(raw))
+	req, err := readRequest(b, getRequest(), readOptions{maxBody: MAX_BODY_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header["X-Multi"]; len(got) != 2 || got[1] != "b" {
+		t.Errorf("gotXMulti: %q", got)
+	}
+	putRequest(req)
+	req, err = readRequest(b, getRequest(), readOptions{maxBody: MAX_BODY_SIZE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Get("Host"); got != "b" || len(req.Header) != 1 {
+		t.Errorf("gotHost: %q gotHeader: %v", got, req.Header)
+	}
+}
+
+var newRequestTest = []struct {
+	target, path, host string
+}{
+	{"http://localhost:4221/echo/abc?x=1", "/echo/abc?x=1", "localhost:4221"},
+	{"/files/a.txt", "/files/a.txt", "example.com"},
+	{"/", "/", "example.com"},
+}
+
+func TestNewRequest(t *testing.T) {
+	for i, tt := range newRequestTest {
+		req, err := NewRequest(MethodPost, tt.target, strings.NewReader("body"))
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if req.Path != tt.path {
+			t.Errorf("#%d: gotPath: %q wantPath: %q", i, req.Path, tt.path)
+		}
+		if got := req.Header.Get("Host"); got != tt.host {
+			t.Errorf("#%d: gotHost: %q wantHost: %q", i, got, tt.host)
+		}
+		if string(req.Body) != "body" || req.Header.Get("Content-Length") != "4" {
+			t.Errorf("#%d: gotBody: %q gotContentLength: %q", i, req.Body, req.Header.Get("Content-Length"))
+		}
+	}
+	if _, err := NewRequest("BAD METHOD", "/", nil); err == nil {
+		t.Errorf("expected error for invalid method")
+	}
+}
+
+func TestKey(t *testing.T) {
+	tenant := NewKey[string]("tenant")
+	other := NewKey[string]("tenant")
+	req, _ := NewRequest(MethodGet, "/", nil)
+
+	if _, ok := tenant.Get(req); ok {
+		t.Errorf("unset key reported a value")
+	}
+	tenant.Set(req, "acme")
+	if v, ok := tenant.Get(req); !ok || v != "acme" {
+		t.Errorf("gotValue: %q, %t wantValue: %q", v, ok, "acme")
+	}
+	if _, ok := other.Get(req); ok {
+		t.Errorf("keys with the same name collided")
+	}
+}
+
+func TestRequestClone(t *testing.T) {
+	type key struct{}
+	r, _ := NewRequest(MethodPost, "http://user:pw@example.com/a?x=1", strings.NewReader("body"))
+	r.Header.Add("X-Multi", "1")
+	ctx := context.WithValue(context.Background(), key{}, "v")
+	c := r.Clone(ctx)
+
+	c.Header.Add("X-Multi", "2")
+	c.Header.Set("Host", "other")
+	c.URL.Path = "/b"
+	c.Body[0] = 'B'
+	if got := r.Header["X-Multi"]; len(got) != 1 || r.Header.Get("Host") != "example.com" {
+		t.Errorf("clone shares the header: %v", r.Header)
+	}
+	if r.URL.Path != "/a" || string(r.Body) != "body" {
+		t.Errorf("clone shares the URL or body: %q %q", r.URL.Path, r.Body)
+	}
+	if c.Context().Value(key{}) != "v" || c.Method != MethodPost || c.URL.User.String() != "user:pw" {
+		t.Errorf("gotClone: %+v", c)
+	}
+}
+
+var parseRangeTest = []struct {
+	header        string
+	start, length int64
+	ok            bool
+	err           error
+}{
+	{"", 0, 0, false, nil},
+	{"bytes=0-9", 0, 10, true, nil},
+	{"bytes=990-", 990, 10, true, nil},
+	{"bytes=990-5000", 990, 10, true, nil},
+	{"bytes=-100", 900, 100, true, nil},
+	{"bytes=-5000", 0, 1000, true, nil},
+	{"bytes=1000-", 0, 0, false, errUnsatisfiableRange},
+	{"bytes=-0", 0, 0, false, errUnsatisfiableRange},
+	{"bytes=0-1,5-6", 0, 0, false, nil},
+	{"bytes=9-3", 0, 0, false, nil},
+	{"bytes=-", 0, 0, false, nil},
+	{"bytes=+1-2", 0, 0, false, nil},
+	{"items=0-9", 0, 0, false, nil},
+}
+
+func TestParseRange(t *testing.T) {
+	for i, tt := range parseRangeTest {
+		br, ok, err := parseRange(tt.header, 1000)
+		if ok != tt.ok || err != tt.err || ok && (br.start != tt.start || br.length != tt.length) {
+			t.Errorf("#%d: parseRange(%q): got: %+v %v %v want: {start:%d length:%d} %v %v", i, tt.header, br, ok, err, tt.start, tt.length, tt.ok, tt.err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDNSTTL is how long a CachingResolver keeps successful lookups
+	// when TTL is zero.
+	DefaultDNSTTL = 30 * time.Second
+
+	// DefaultDNSNegativeTTL is how long a CachingResolver remembers that a
+	// host does not exist when NegativeTTL is zero.
+	DefaultDNSNegativeTTL = 5 * time.Second
+)
+
+// maxDNSCacheEntries bounds a CachingResolver's cache; past it, expired
+// entries are swept on insert.
+const maxDNSCacheEntries = 1024
+
+// Resolver looks up the addresses a Transport dials for a host name.
+// *net.Resolver satisfies it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) (addrs []string, err error)
+}
+
+// CachingResolver is a Resolver that caches lookups, so a client talking
+// to the same hosts over fresh connections doesn't ask the OS resolver
+// every time. Hosts pins names to fixed addresses, for tests and static
+// service discovery.
+//
+//	tr := &http.Transport{Resolver: &http.CachingResolver{
+//		Hosts: map[string][]string{"api.internal": {"10.0.0.7"}},
+//	}}
+type CachingResolver struct {
+	// Resolver does the actual lookups. net.DefaultResolver is used when
+	// nil.
+	Resolver Resolver
+
+	// TTL is how long addresses are reused. DefaultDNSTTL is used when
+	// zero.
+	TTL time.Duration
+
+	// NegativeTTL is how long a "no such host" answer is reused.
+	// DefaultDNSNegativeTTL is used when zero, negative disables negative
+	// caching. Other failures, such as timeouts, are never cached.
+	NegativeTTL time.Duration
+
+	// Hosts maps host names to the addresses returned for them without a
+	// lookup. It must not be changed once the resolver is in use.
+	Hosts map[string][]string
+
+	mu    sync.Mutex
+	cache map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// LookupHost returns the addresses of host, from Hosts, the cache or a
+// fresh lookup.
+func (r *CachingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if addrs, ok := r.Hosts[host]; ok {
+		return addrs, nil
+	}
+	now := time.Now()
+	r.mu.Lock()
+	e, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.addrs, e.err
+	}
+
+	var lookup Resolver = net.DefaultResolver
+	if r.Resolver != nil {
+		lookup = r.Resolver
+	}
+	addrs, err := lookup.LookupHost(ctx, host)
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultDNSTTL
+	}
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound || r.NegativeTTL < 0 {
+			return nil, err
+		}
+		ttl = r.NegativeTTL
+		if ttl == 0 {
+			ttl = DefaultDNSNegativeTTL
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = make(map[string]dnsEntry)
+	}
+	if len(r.cache) >= maxDNSCacheEntries {
+		for h, e := range r.cache {
+			if !now.Before(e.expires) {
+				delete(r.cache, h)
+			}
+		}
+	}
+	r.cache[host] = dnsEntry{addrs: addrs, err: err, expires: now.Add(ttl)}
+	return addrs, err
+}
+
+// Flush drops every cached lookup.
+func (r *CachingResolver) Flush() {
+	r.mu.Lock()
+	r.cache = nil
+	r.mu.Unlock()
+}
@@ -3,9 +3,15 @@ package http
 import (
 	"bytes"
 	"compress/gzip"
-	"fmt"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
 	"net"
-	"strings"
+	"slices"
+	"strconv"
+	"sync"
+	"syscall"
 )
 
 type ResponseWriter interface {
@@ -23,35 +29,82 @@ type Response struct {
 	Headers    map[string]string
 	Body       []byte
 	conn       net.Conn
+
+	// added holds the further values AddHeader gave keys already in
+	// Headers, each sent as a line of its own
+	added []headerField
+
+	written int64 // bytes of status line, headers and body sent on conn
+
+	// set by SetBodyReader instead of Body
+	bodyReader io.Reader
+	bodySize   int64
+
+	head  bool // answering a HEAD request: headers as for GET, no body
+	wrote bool // Write was called
+
+	// varyEncoding adds Accept-Encoding to Vary on responses with a body,
+	// as whether they are compressed depends on it
+	varyEncoding bool
+
+	// broken is set when a streamed body failed, leaving the
+	// connection's framing unusable
+	broken bool
+
+	// reqBody is the request's body when its handler streams it
+	reqBody *bodyStream
+
+	// writeErr is the error Write got from the connection, and cancel
+	// ends the request's context when there is one
+	writeErr error
+	cancel   context.CancelFunc
+
+	logger *slog.Logger
 }
 
 func NewResponse(conn net.Conn, req *Request) *Response {
-	res := &Response{
-		StatusCode: 200,
-		StatusText: "OK",
-		Headers:    make(map[string]string),
-		conn:       conn,
-	}
+	res := &Response{Headers: make(map[string]string)}
+	res.reset(conn, req)
+	return res
+}
+
+// reset prepares r to answer req on conn, keeping the Headers map so a
+// pooled Response does not reallocate it.
+func (res *Response) reset(conn net.Conn, req *Request) {
+	clear(res.Headers)
+	res.added = res.added[:0]
+	res.StatusCode = 200
+	res.StatusText = "OK"
+	res.Body = nil
+	res.conn = conn
+	res.written = 0
+	res.closeBodyReader()
+	res.broken = false
+	res.writeErr = nil
+	res.reqBody = nil
+	res.cancel = nil
+	res.head = req != nil && req.Method == MethodHead
+	res.wrote = false
+	res.varyEncoding = req != nil
+	res.logger = nil
 
 	if req != nil {
-		if strings.ToLower(req.Header.Get("Connection")) == "close" {
-			res.SetHeader("Connection", "close")
-		} else {
+		if req.wantsKeepAlive() {
 			res.SetHeader("Connection", "keep-alive")
+		} else {
+			res.SetHeader("Connection", "close")
 		}
 
-		if req.Header.Get("Accept-Encoding") != "" {
-			ae := strings.Split(req.Header.Get("Accept-Encoding"), ",")
-			for _, v := range ae {
-				if strings.TrimSpace(v) == "gzip" {
-					res.SetHeader("Content-Encoding", "gzip")
-					break
-				}
-			}
+		if NegotiateEncoding(req.Header.Get("Accept-Encoding"), "gzip") == "gzip" {
+			res.SetHeader("Content-Encoding", "gzip")
 		}
 	}
+}
 
-	return res
+// closesConn reports whether the connection ends after this response,
+// either as the request asked or because the handler set Connection: close.
+func (r *Response) closesConn() bool {
+	return r.broken || hasToken(r.Headers["Connection"], "close")
 }
 
 // SetStatus sets the status code and text
@@ -60,12 +113,64 @@ func (r *Response) SetStatus(code int, text string) {
 	r.StatusText = text
 }
 
-// SetHeader sets a header in the response
+// SetHeader sets a header in the response. Once the response has been
+// written it does nothing but log a warning.
 func (r *Response) SetHeader(key, value string) {
+	if r.wrote {
+		loggerOrDefault(r.logger).Warn("SetHeader called after Write", "key", key)
+		return
+	}
 	if r.Headers == nil {
 		r.Headers = make(map[string]string)
 	}
 	r.Headers[key] = value
+	if len(r.added) > 0 {
+		r.added = slices.DeleteFunc(r.added, func(f headerField) bool { return f.key == key })
+	}
+}
+
+// AddHeader adds value to the response header key, sent as a line of its
+// own after any value the key already has. It is for fields such as
+// Set-Cookie whose values can't be joined into one line; SetHeader
+// replaces them all.
+func (r *Response) AddHeader(key, value string) {
+	if r.wrote {
+		loggerOrDefault(r.logger).Warn("AddHeader called after Write", "key", key)
+		return
+	}
+	if _, ok := r.Headers[key]; !ok {
+		r.SetHeader(key, value)
+		return
+	}
+	r.added = append(r.added, headerField{key, value})
+}
+
+type headerField struct {
+	key, value string
+}
+
+// GetHeader returns the value of the response header key, as set so far.
+func (r *Response) GetHeader(key string) string {
+	return r.Headers[key]
+}
+
+// Status returns the status code set so far, or the one that was sent once
+// Write has been called.
+func (r *Response) Status() int {
+	return r.StatusCode
+}
+
+// BytesWritten returns how many bytes of the response went out on the
+// connection, including the status line and headers and after any
+// compression. It is zero until Write is called.
+func (r *Response) BytesWritten() int64 {
+	return r.written
+}
+
+// Written reports whether Write has been called, for middleware that must
+// not add to a response that is already on the wire.
+func (r *Response) Written() bool {
+	return r.wrote
 }
 
 // GetBody returns the response body
@@ -74,19 +179,84 @@ func (r *Response) GetBody() []byte {
 }
 
 func (r *Response) SetBody(body []byte) {
+	r.closeBodyReader()
 	r.Body = body
 }
 
-func (r *Response) Write() error {
+// SetBodyReader makes the response body the next size bytes of body,
+// streamed when the response is written instead of held in memory. If body
+// is an io.Closer, such as a *SpillBuffer or *os.File, it is closed once
+// the response is finished. GetBody returns nil for such responses.
+//
+// It is not part of ResponseWriter; handlers that want it type-assert
+// for it and fall back to SetBody.
+func (r *Response) SetBodyReader(body io.Reader, size int64) {
+	r.closeBodyReader()
+	r.Body = nil
+	r.bodyReader = body
+	r.bodySize = size
+}
+
+func (r *Response) closeBodyReader() {
+	if c, ok := r.bodyReader.(io.Closer); ok {
+		c.Close()
+	}
+	r.bodyReader = nil
+	r.bodySize = 0
+}
+
+// bodyAllowed reports whether a response with the given status may carry a
+// body and the headers describing it, per RFC 9112 section 6.3.
+func bodyAllowed(code int) bool {
+	return !(code >= 100 && code < 200) && code != StatusNoContent && code != StatusNotModified
+}
+
+// Write sends the response. Only the first call does anything; a second
+// full response on the connection would desynchronize keep-alive, so later
+// calls log a warning and return nil. If the connection fails partway,
+// the request's context is canceled so the handler can stop early; see
+// IsClientAbort.
+func (r *Response) Write() (err error) {
+	if r.wrote {
+		loggerOrDefault(r.logger).Warn("Write called more than once", "status", r.StatusCode)
+		return nil
+	}
+	defer func() {
+		r.wrote = true
+		if err != nil {
+			r.writeFailed(err)
+		}
+	}()
+	// the unread rest of a streamed request body stands where the next
+	// request would start
+	if r.reqBody != nil && !r.reqBody.eof {
+		r.SetHeader("Connection", "close")
+	}
+	if !bodyAllowed(r.StatusCode) {
+		return r.writeBodiless()
+	}
+	// handlers set Content-Encoding: identity to opt a response out of
+	// compression, as for byte ranges, whose offsets refer to the raw body
+	if r.Headers["Content-Encoding"] == "identity" {
+		delete(r.Headers, "Content-Encoding")
+	}
 
 	if _, ok := r.Headers["Content-Type"]; !ok {
 		r.SetHeader("Content-Type", "text/plain")
 	}
 
+	if r.varyEncoding {
+		r.SetHeader("Vary", mergeVary(r.Headers["Vary"], "Accept-Encoding"))
+	}
+
 	if _, ok := r.Headers["Connection"]; !ok {
 		r.SetHeader("Connection", "keep-alive")
 	}
 
+	if r.bodyReader != nil {
+		return r.writeStream()
+	}
+
 	if r.Headers["Content-Encoding"] == "gzip" {
 		var b bytes.Buffer
 		w := gzip.NewWriter(&b)
@@ -95,25 +265,172 @@ func (r *Response) Write() error {
 		r.Body = b.Bytes()
 	}
 
-	r.SetHeader("Content-Length", fmt.Sprintf("%d", len(r.Body)))
+	r.SetHeader("Content-Length", strconv.Itoa(len(r.Body)))
+	body := r.Body
+	if r.head {
+		body = nil
+	}
 
-	// Build response string
-	headerString := fmt.Sprintf("HTTP/1.1 %d %s\r\n", r.StatusCode, r.StatusText)
+	hp := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(hp)
+	head := appendHead((*hp)[:0], r.StatusCode, r.StatusText, r.Headers, r.added)
 
-	// Add headers
-	for key, value := range r.Headers {
-		headerString += fmt.Sprintf("%s: %s\r\n", key, value)
+	// small bodies are cheaper to copy than to hand the kernel another
+	// iovec; anything larger goes out with writev, uncopied
+	if len(body) <= smallBodyLen {
+		head = append(head, body...)
+		var n int
+		n, err = r.conn.Write(head)
+		r.written += int64(n)
+	} else {
+		var n int64
+		n, err = writeBuffers(r.conn, net.Buffers{head, body})
+		r.written += n
 	}
+	*hp = head[:0]
+	return err
+}
+
+// writeFailed records that writing the response failed. However much went
+// out, the connection's framing can't be trusted, so it is closed after.
+func (r *Response) writeFailed(err error) {
+	r.writeErr = err
+	r.broken = true
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// IsClientAbort reports whether err, returned by Write, means the client
+// went away before the response was sent: it closed or reset the
+// connection. Such failures are the client's doing, not the server's, and
+// the server counts them in Stats.ClientAborts rather than logging errors.
+func IsClientAbort(err error) bool {
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, net.ErrClosed)
+}
+
+// writeBodiless writes a 1xx, 204 or 304 response: the status line and
+// headers only, without Content-Length or anything else describing a body
+// the status forbids.
+func (r *Response) writeBodiless() error {
+	delete(r.Headers, "Content-Length")
+	delete(r.Headers, "Transfer-Encoding")
+	delete(r.Headers, "Content-Encoding")
+	r.closeBodyReader()
+	if _, ok := r.Headers["Connection"]; !ok {
+		r.SetHeader("Connection", "keep-alive")
+	}
+
+	hp := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(hp)
+	head := appendHead((*hp)[:0], r.StatusCode, r.StatusText, r.Headers, r.added)
+	n, err := r.conn.Write(head)
+	*hp = head[:0]
+	r.written += int64(n)
+	return err
+}
 
-	// Add empty line and body
-	headerString += "\r\n"
+// writeStream writes a response whose body was set with SetBodyReader.
+// Compressed bodies are staged in a SpillBuffer, as their length is only
+// known once compression is done.
+func (r *Response) writeStream() (err error) {
+	defer func() {
+		if err != nil {
+			r.broken = true
+		}
+	}()
 
-	responseString := append([]byte(headerString), r.Body...)
+	body := io.LimitReader(r.bodyReader, r.bodySize)
+	size := r.bodySize
+	if r.Headers["Content-Encoding"] == "gzip" {
+		spill := new(SpillBuffer)
+		defer spill.Close()
+		zw := gzip.NewWriter(spill)
+		if _, err := io.Copy(zw, body); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		r.probeEOF()
+		body, size = spill, spill.Len()
+	}
+	r.SetHeader("Content-Length", strconv.FormatInt(size, 10))
 
-	// Write to connection
-	_, err := r.conn.Write([]byte(responseString))
-	if err != nil {
+	hp := headBufPool.Get().(*[]byte)
+	defer headBufPool.Put(hp)
+	head := appendHead((*hp)[:0], r.StatusCode, r.StatusText, r.Headers, r.added)
+	n, err := r.conn.Write(head)
+	*hp = head[:0]
+	r.written += int64(n)
+	if err != nil || r.head {
 		return err
 	}
-	return nil
+
+	copied, err := io.Copy(r.conn, body)
+	r.written += copied
+	if err == nil && copied < size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err == nil {
+		r.probeEOF()
+	}
+	return err
+}
+
+// probeEOF reads past the end of a fully copied body reader, so readers
+// that release resources at EOF, like a client response body returning
+// its connection to the pool, get to see it.
+func (r *Response) probeEOF() {
+	var p [1]byte
+	r.bodyReader.Read(p[:])
+}
+
+// smallBodyLen is the largest body copied in with the head rather than
+// written as its own buffer.
+const smallBodyLen = 1024
+
+var headBufPool = sync.Pool{New: func() any {
+	b := make([]byte, 0, 512)
+	return &b
+}}
+
+// appendHead appends the status line, headers and blank line of a
+// response to b, the added fields after those in headers.
+func appendHead(b []byte, code int, text string, headers map[string]string, added []headerField) []byte {
+	b = append(b, "HTTP/1.1 "...)
+	b = strconv.AppendInt(b, int64(code), 10)
+	b = append(b, ' ')
+	b = append(b, text...)
+	b = append(b, "\r\n"...)
+	for key, value := range headers {
+		b = append(b, key...)
+		b = append(b, ": "...)
+		b = append(b, value...)
+		b = append(b, "\r\n"...)
+	}
+	for _, f := range added {
+		b = append(b, f.key...)
+		b = append(b, ": "...)
+		b = append(b, f.value...)
+		b = append(b, "\r\n"...)
+	}
+	return append(b, "\r\n"...)
+}
+
+// buffersWriter is implemented by the server's connection wrappers so a
+// vectored write reaches the underlying TCP connection instead of being
+// split into one Write per buffer.
+type buffersWriter interface {
+	writeBuffers(bufs net.Buffers) (int64, error)
+}
+
+// writeBuffers writes bufs to w, using writev when w is, or wraps, a
+// connection that supports it.
+func writeBuffers(w io.Writer, bufs net.Buffers) (int64, error) {
+	if bw, ok := w.(buffersWriter); ok {
+		return bw.writeBuffers(bufs)
+	}
+	return bufs.WriteTo(w)
 }
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy retries failed requests with exponential backoff and full
+// jitter. Only idempotent requests are retried: GET, HEAD, OPTIONS, TRACE,
+// PUT and DELETE, or any request carrying an Idempotency-Key header.
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt; 3 is used when zero.
+	MaxAttempts int
+
+	// BaseDelay is the backoff ceiling before the first retry, doubling
+	// for each retry after it up to MaxDelay. 100ms and 10s are used when
+	// zero.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// ShouldRetry decides whether an attempt's outcome is worth retrying;
+	// res is nil when err is not. By default transport errors, 429, 502,
+	// 503 and 504 are retried.
+	ShouldRetry func(res *ClientResponse, err error) bool
+
+	// Budget, if set, bounds retries across all requests sharing it.
+	Budget *RetryBudget
+}
+
+// RetryBudget caps retries to a fraction of overall traffic, so retries
+// never multiply the load on an upstream that is already failing. Every
+// request earns Ratio tokens and every retry spends one.
+type RetryBudget struct {
+	Ratio float64 // e.g. 0.2 allows retries to add 20% on top of requests
+	Max   float64 // token cap, 10 is used when zero
+
+	mu     sync.Mutex
+	tokens float64
+	init   bool
+}
+
+func (b *RetryBudget) deposit() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fill()
+	b.tokens = min(b.tokens+b.Ratio, b.max())
+}
+
+func (b *RetryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fill()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// fill starts a new budget full, so a cold client may still retry.
+func (b *RetryBudget) fill() {
+	if !b.init {
+		b.init = true
+		b.tokens = b.max()
+	}
+}
+
+func (b *RetryBudget) max() float64 {
+	if b.Max > 0 {
+		return b.Max
+	}
+	return 10
+}
+
+// roundTrip sends req once, or several times under the client's retry
+// policy.
+func (c *Client) roundTrip(req *Request) (*ClientResponse, error) {
+	p := c.Retry
+	if p == nil || !isIdempotent(req) {
+		return c.roundTripper().RoundTrip(req)
+	}
+	if p.Budget != nil {
+		p.Budget.deposit()
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		res, err := c.roundTripper().RoundTrip(req)
+		if attempt >= p.maxAttempts() || !p.shouldRetry(res, err) || ctx.Err() != nil {
+			return res, err
+		}
+		if p.Budget != nil && !p.Budget.withdraw() {
+			return res, err
+		}
+
+		delay := p.backoff(attempt)
+		if res != nil {
+			if ra, ok := retryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				// a server-requested wait longer than we are willing to
+				// back off means giving up is the better answer
+				if ra > p.maxDelay() {
+					return res, err
+				}
+				delay = max(delay, ra)
+			}
+			io.Copy(io.Discard, io.LimitReader(res.Body, 2<<10))
+			res.Body.Close()
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctxErr(ctx, ctx.Err())
+		}
+	}
+}
+
+func isIdempotent(req *Request) bool {
+	switch req.Method {
+	case MethodGet, MethodHead, MethodOptions, MethodTrace, MethodPut, MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+func (p *RetryPolicy) shouldRetry(res *ClientResponse, err error) bool {
+	if p.ShouldRetry != nil {
+		return p.ShouldRetry(res, err)
+	}
+	if err != nil {
+		// dial failures and dropped connections are transient, a
+		// malformed response or unsupported scheme will not fix itself
+		var netErr net.Error
+		return errors.As(err, &netErr) || isConnClosed(err)
+	}
+	switch res.StatusCode {
+	case StatusTooManyRequests, StatusBadGateway, StatusServiceUnavailable, StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return 3
+}
+
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay > 0 {
+		return p.MaxDelay
+	}
+	return 10 * time.Second
+}
+
+// backoff returns a random delay in [0, BaseDelay*2^(attempt-1)], capped at
+// MaxDelay ("full jitter").
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	ceil := p.maxDelay()
+	if shift := attempt - 1; shift < 32 && base<<shift < ceil {
+		ceil = base << shift
+	}
+	return rand.N(ceil + 1)
+}
+
+// retryAfter parses a Retry-After value, either delay-seconds or an
+// HTTP-date, relative to now.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	t, err := time.Parse(TimeFormat, v)
+	if err != nil {
+		return 0, false
+	}
+	return max(t.Sub(now), 0), true
+}
@@ -0,0 +1,30 @@
+package http
+
+// A RoundTripper sends a single request and returns its response, as
+// Transport does. Client middleware wraps one RoundTripper in another to
+// log, authenticate, measure or cache outbound requests, much as server
+// middleware wraps a Handler. A RoundTripper must not modify req; clone it
+// to change headers.
+type RoundTripper interface {
+	RoundTrip(req *Request) (*ClientResponse, error)
+}
+
+// RoundTripperFunc adapts a function to a RoundTripper.
+type RoundTripperFunc func(*Request) (*ClientResponse, error)
+
+func (f RoundTripperFunc) RoundTrip(req *Request) (*ClientResponse, error) {
+	return f(req)
+}
+
+// ClientMiddleware wraps a RoundTripper in another.
+type ClientMiddleware func(next RoundTripper) RoundTripper
+
+// roundTripper returns the client's transport wrapped in its middleware,
+// the first listed outermost.
+func (c *Client) roundTripper() RoundTripper {
+	var rt RoundTripper = c.transport()
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		rt = c.Middleware[i](rt)
+	}
+	return rt
+}
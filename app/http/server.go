@@ -2,14 +2,25 @@ package http
 
 import (
 	"bufio"
-	"fmt"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// A Handler responds to an HTTP request. The Request and ResponseWriter
+// are recycled for later requests once ServeHTTP returns, so a handler
+// must not use or retain either after returning.
 type Handler interface {
 	ServeHTTP(ResponseWriter, *Request)
 }
@@ -24,50 +35,82 @@ type ServeMux struct {
 	mu sync.RWMutex
 	m  map[string]muxEntry
 	es []muxEntry // sorted from longest to shortest for prefix routes
+
+	// Logger receives route matching diagnostics at debug level.
+	// slog.Default() is used when nil.
+	Logger *slog.Logger
 }
 
 type muxEntry struct {
 	h       Handler
 	pattern string
+	name    string // given to HandleNamed, or the pattern
 }
 
 func (mux *ServeMux) ServeHTTP(w ResponseWriter, r *Request) {
-	h, _ := mux.findHandler(r)
-	fmt.Printf("found handler: %v\n", h)
-	if h == nil {
+	e, ok := mux.findHandler(r)
+	if !ok {
+		mux.logger().Debug("no route matched", "path", r.Path)
 		w.SetStatus(404, "Not Found")
 		w.SetBody([]byte("Not Found"))
 		w.Write()
 		return
 	}
-	h.ServeHTTP(w, r)
+	mux.logger().Debug("route matched", "path", r.Path, "pattern", e.pattern)
+	r.Pattern, r.RouteName = e.pattern, e.name
+	r.active.setRoute(e.name)
+	e.h.ServeHTTP(w, r)
+}
+
+func (mux *ServeMux) logger() *slog.Logger {
+	return loggerOrDefault(mux.Logger)
+}
+
+// Handler returns the handler that would serve r and the name of its
+// route, see Request.RouteName, or nil and "" if no route matches.
+func (mux *ServeMux) Handler(r *Request) (h Handler, routeName string) {
+	e, ok := mux.findHandler(r)
+	if !ok {
+		return nil, ""
+	}
+	return e.h, e.name
 }
 
-func (mux *ServeMux) findHandler(r *Request) (h Handler, pattern string) {
+func (mux *ServeMux) findHandler(r *Request) (muxEntry, bool) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
 	path := r.Path
 	// exact keyword match
-	fmt.Printf("before keyword match for path finding: %s\n", path)
-	v, ok := mux.m[path]
-	fmt.Printf("found in keyword match: %t\n", ok)
-	if ok {
-		return v.h, v.pattern
+	if e, ok := mux.m[path]; ok {
+		return e, true
 	}
 
 	for _, e := range mux.es {
-		fmt.Printf("matching with register route: %s\n", e.pattern)
 		// matches the longest parts first
 		if strings.HasPrefix(path, e.pattern) {
-			return e.h, e.pattern
+			return e, true
 		}
 	}
 
-	return nil, ""
+	return muxEntry{}, false
 }
 
 func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	mux.HandleNamed("", pattern, handler)
+}
+
+// HandleNamed registers handler for pattern like Handle, naming the route
+// so that metrics and access logs can aggregate by it rather than by raw
+// path; see Request.RouteName. Names are usually route templates:
+//
+//	mux.HandleNamed("/files/{name}", "/files/", files)
+//
+// An empty name means the pattern.
+func (mux *ServeMux) HandleNamed(name, pattern string, handler Handler) {
+	if name == "" {
+		name = pattern
+	}
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 	if _, exist := mux.m[pattern]; exist {
@@ -81,12 +124,13 @@ func (mux *ServeMux) Handle(pattern string, handler Handler) {
 	e := muxEntry{
 		h:       handler,
 		pattern: pattern,
+		name:    name,
 	}
 	mux.m[pattern] = e //single keyword matches
 
 	// matches with prefix
 	// prefix the routes ends in /, i.e /echo/
-	if len(pattern) > 1 && pattern[len(pattern)-1] == '/' {
+	if isPrefixPattern(pattern) {
 		mux.es = appendSorted(mux.es, e)
 	}
 
@@ -112,6 +156,36 @@ func appendSorted(es []muxEntry, e muxEntry) []muxEntry {
 	return es
 }
 
+// Route describes one registered ServeMux pattern.
+type Route struct {
+	Pattern string
+	Name    string // see HandleNamed; the pattern if none was given
+	Prefix  bool   // matches any path under Pattern, not only Pattern itself
+	Handler Handler
+}
+
+// Routes returns the registered routes sorted by pattern.
+func (mux *ServeMux) Routes() []Route {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	routes := make([]Route, 0, len(mux.m))
+	for _, e := range mux.m {
+		routes = append(routes, Route{
+			Pattern: e.pattern,
+			Name:    e.name,
+			Prefix:  isPrefixPattern(e.pattern),
+			Handler: e.h,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Pattern < routes[j].Pattern })
+	return routes
+}
+
+func isPrefixPattern(pattern string) bool {
+	return len(pattern) > 1 && pattern[len(pattern)-1] == '/'
+}
+
 func (mux *ServeMux) HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
 	if handler == nil {
 		panic("nil handler")
@@ -133,6 +207,16 @@ type serverHandler struct {
 }
 
 func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
+	if !sh.svr.methodAllowed(req.Method) {
+		rw.SetStatus(StatusNotImplemented, StatusText(StatusNotImplemented))
+		rw.SetBody([]byte(StatusText(StatusNotImplemented)))
+		rw.Write()
+		return
+	}
+	if req.Path == "*" {
+		sh.svr.serveOptionsAsterisk(rw)
+		return
+	}
 	handler := sh.svr.Handler
 	if handler == nil {
 		handler = DefaultServeMux
@@ -143,6 +227,179 @@ func (sh serverHandler) ServeHTTP(rw ResponseWriter, req *Request) {
 type Server struct {
 	Addr    string
 	Handler Handler
+
+	// Logger receives connection and request errors, plus per-request
+	// diagnostics at debug level. slog.Default() is used when nil.
+	Logger *slog.Logger
+
+	// Tracer, if set, starts a span around every request, except those
+	// whose traceparent says the caller didn't sample the trace. The
+	// incoming traceparent header is always propagated into the request
+	// context, and from there into the requests a Transport sends with it.
+	Tracer Tracer
+
+	// OnRequestStart is called once a request has been parsed, before the
+	// handler runs.
+	OnRequestStart func(*Request)
+	// OnRequestEnd is called after the handler returns.
+	OnRequestEnd func(*Request, RequestEnd)
+	// OnParseError is called when a request cannot be parsed and is
+	// rejected without reaching the handler.
+	OnParseError func(remote net.Addr, err error)
+	// OnServeError is called when ListenAndServe cannot bind addr or
+	// Serve stops on an error other than ErrServerClosed and
+	// ErrListenerDrained, for applications serving in the background.
+	OnServeError func(addr string, err error)
+
+	// Dump, if set, tees every exchange's raw bytes for debugging. Use
+	// SetDump to change it while the server is running.
+	Dump *WireDump
+
+	// Audit, if set, records request bodies for compliance logging.
+	Audit *BodyAudit
+
+	// ReadTimeout bounds reading each request, headers and body. Zero
+	// means no timeout.
+	ReadTimeout time.Duration
+
+	// MaxBodySize caps request bodies; larger ones are answered with 413.
+	// Zero means MAX_BODY_SIZE.
+	MaxBodySize int64
+
+	// TLSConfig is used by ListenAndServeTLS and ServeTLS; it may be nil.
+	TLSConfig *tls.Config
+
+	// AllowBareLF accepts request and header lines ending in LF alone, as
+	// sent by some hand-written clients. By default such requests get 400
+	// Bad Request, since every line must end in CRLF.
+	AllowBareLF bool
+
+	// StrictParsing rejects with 400 every request whose framing a proxy
+	// in front of the server might read differently, closing off request
+	// smuggling: Transfer-Encoding together with Content-Length, obsolete
+	// line folding, control characters in header values, chunk-size lines
+	// with whitespace, malformed or excessive chunk extensions, and lines
+	// not ending in CRLF, overriding AllowBareLF. A Content-Length that
+	// isn't plain digits is refused in every mode.
+	StrictParsing bool
+
+	// RequireContentLength answers 411 Length Required to POST, PUT and
+	// PATCH requests sending neither Content-Length nor a chunked body,
+	// for handlers that must tell an empty body from a client that forgot
+	// to frame one. By default such a request has an empty Body.
+	RequireContentLength bool
+
+	// RestrictMethods makes the server answer 501 Not Implemented, without
+	// calling Handler, for methods outside AllowedMethods. By default any
+	// method that is a valid token reaches the handler.
+	RestrictMethods bool
+
+	// AllowedMethods lists the methods accepted when RestrictMethods is set;
+	// StandardMethods() is used when it is empty. Methods are
+	// case-sensitive.
+	AllowedMethods []string
+
+	// UnwrittenStatus is sent, with an empty body, when a handler returns
+	// without calling Write. When zero the response is sent as the handler
+	// left it, which is 200 OK with an empty body if it set nothing.
+	UnwrittenStatus int
+
+	// DisableCompression stops responses from being gzipped for clients
+	// that accept it. A handler opts a single response out by setting
+	// Content-Encoding to "identity".
+	DisableCompression bool
+
+	// IdleTimeout is how long a keep-alive connection may wait for its
+	// next request before it is closed. ReadTimeout is used when zero.
+	// Besides the read deadline, a background reaper closes connections
+	// idle for longer, so lingering clients can't pin file descriptors
+	// even when a wrapped connection doesn't honour deadlines.
+	IdleTimeout time.Duration
+
+	// MaxRequestsPerConn, if positive, is how many requests a connection
+	// may carry; the last is answered with Connection: close and the
+	// connection closed after it, so no client holds a slot indefinitely.
+	MaxRequestsPerConn int
+
+	// DrainTimeout is how long Shutdown keeps idle keep-alive connections
+	// open, so requests already on their way get a 503 instead of a reset.
+	// One second is used when zero.
+	DrainTimeout time.Duration
+
+	// DrainReportInterval is how often Shutdown logs what it is still
+	// waiting for, to tell a drain stuck on one handler from a slow one.
+	// Five seconds is used when zero, negative disables the reports.
+	DrainReportInterval time.Duration
+
+	// SlowRequestThreshold, if positive, logs every request whose handler
+	// takes at least this long at warn level, with its route, duration,
+	// status and size, to spot pathological handlers without tracing.
+	SlowRequestThreshold time.Duration
+
+	// Abuse, if set, bans clients that keep sending bad requests, dropping
+	// or tarpitting their connections as they are accepted.
+	Abuse *AbuseGuard
+
+	// JSONParseErrors answers requests rejected as unparseable with a JSON
+	// body such as {"error":"malformed_request_line","detail":"..."} when
+	// their Accept header, as far as it was read, prefers
+	// application/json. The error names are those of ParseErrorCode.
+	JSONParseErrors bool
+
+	// AltSvc lists alternative services, such as HTTP/3 on another port,
+	// advertised in an Alt-Svc header on every response. Handlers may
+	// replace it by setting Alt-Svc themselves, to "clear" to withdraw it.
+	AltSvc []AltService
+
+	stats   serverStats
+	dump    atomic.Pointer[WireDump] // set by SetDump, overriding Dump
+	dumpSet atomic.Bool
+
+	mu            sync.Mutex
+	listeners     map[*net.Listener]bool // true once drained
+	conns         map[net.Conn]*connState
+	inShutdown    atomic.Bool
+	shutdownCh    chan struct{}
+	drainDeadline time.Time
+	boundAddr     net.Addr
+	startedCh     chan struct{}
+	onShutdown    []func()
+	reaperOnce    sync.Once
+}
+
+func (s *Server) methodAllowed(method string) bool {
+	if !s.RestrictMethods {
+		return true
+	}
+	allowed := s.AllowedMethods
+	if len(allowed) == 0 {
+		allowed = standardMethods
+	}
+	return slices.Contains(allowed, method)
+}
+
+// serveOptionsAsterisk answers "OPTIONS *", a question about the server
+// rather than any resource, with the methods it accepts.
+func (s *Server) serveOptionsAsterisk(w ResponseWriter) {
+	allowed := standardMethods
+	if s.RestrictMethods && len(s.AllowedMethods) > 0 {
+		allowed = s.AllowedMethods
+	}
+	w.SetHeader("Allow", strings.Join(allowed, ", "))
+	w.SetStatus(StatusNoContent, StatusText(StatusNoContent))
+	w.Write()
+}
+
+func (s *Server) logger() *slog.Logger {
+	return loggerOrDefault(s.Logger)
+}
+
+// loggerOrDefault lets a zero-value Server or ServeMux log without setup.
+func loggerOrDefault(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
+	}
+	return l
 }
 
 func (s *Server) ListenAndServe() error {
@@ -153,59 +410,380 @@ func (s *Server) ListenAndServe() error {
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		fmt.Printf("Failed to bind port %s", addr)
+		s.logger().Error("failed to bind", "addr", addr, "err", err)
+		s.serveFailed(addr, err)
 		return err
 	}
 	return s.Serve(ln)
 }
 
+// ListenAndServeTLS is ListenAndServe over TLS, with the certificate and
+// key loaded from PEM files. Either may be empty if TLSConfig already
+// provides certificates.
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":https"
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.logger().Error("failed to bind", "addr", addr, "err", err)
+		s.serveFailed(addr, err)
+		return err
+	}
+	return s.ServeTLS(ln, certFile, keyFile)
+}
+
+// ServeTLS serves TLS connections accepted from ln.
+func (s *Server) ServeTLS(ln net.Listener, certFile, keyFile string) error {
+	config := &tls.Config{}
+	if s.TLSConfig != nil {
+		config = s.TLSConfig.Clone()
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			s.serveFailed(ln.Addr().String(), err)
+			ln.Close()
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		ln.Close()
+		return errors.New("http: ServeTLS needs a certificate")
+	}
+	return s.Serve(tls.NewListener(ln, config))
+}
+
+func (s *Server) maxBodySize() int64 {
+	if s.MaxBodySize > 0 {
+		return s.MaxBodySize
+	}
+	return MAX_BODY_SIZE
+}
+
 func (s *Server) Serve(ln net.Listener) error {
 	defer ln.Close()
+	if !s.trackListener(&ln, true) {
+		return ErrServerClosed
+	}
+	defer s.trackListener(&ln, false)
+	s.startIdleReaper()
+	var backoff time.Duration
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				return ErrServerClosed
+			}
+			if s.listenerDrained(&ln) {
+				return ErrListenerDrained
+			}
+			if errors.Is(err, net.ErrClosed) {
+				// closed by the caller; retrying would spin forever
+				return err
+			}
 			if _, ok := err.(net.Error); ok {
+				// e.g. out of file descriptors: wait for some to free up
+				backoff = min(max(2*backoff, 5*time.Millisecond), time.Second)
+				s.logger().Warn("accept failed, retrying", "addr", ln.Addr().String(), "err", err, "delay", backoff)
+				time.Sleep(backoff)
 				continue
 			}
+			s.serveFailed(ln.Addr().String(), err)
 			return err
 		}
+		backoff = 0
+		if s.Abuse != nil && !s.Abuse.admit(conn) {
+			continue
+		}
 
 		go s.handleConn(conn)
 	}
 }
 
+// parseErrorBody is the JSON body sent for a parse error.
+type parseErrorBody struct {
+	Error  string `json:"error"`
+	Detail string `json:"detail"`
+}
+
+func (s *Server) serveFailed(addr string, err error) {
+	if s.OnServeError != nil {
+		s.OnServeError(addr, err)
+	}
+}
+
 func (s *Server) handleConn(conn net.Conn) error {
+	raw := conn
+	cs := s.trackConn(raw, true)
+	defer s.trackConn(raw, false)
+	s.stats.openConns.Add(1)
+	defer s.stats.openConns.Add(-1)
+	if ac, ok := conn.(*autoTLSConn); ok {
+		c, err := s.detectTLS(ac)
+		if err != nil {
+			ac.Close()
+			return nil
+		}
+		conn = c
+	}
+	tc, _ := conn.(*tls.Conn)
+	var tlsState *tls.ConnectionState
+	cc := &countingConn{Conn: conn, stats: &s.stats}
+	conn = cc
+	var dc *dumpConn
+	if d := s.wireDump(); d != nil {
+		dc = &dumpConn{Conn: conn, d: d}
+		conn = dc
+	}
 	defer conn.Close()
 
-	b := bufio.NewReader(conn)
+	b := newBufioReader(conn)
+	defer putBufioReader(b)
+	remoteAddr := conn.RemoteAddr().String()
 
-	for {
-		req, err := ReadRequest(b)
+	for served := 0; ; served++ {
+		if served > 0 {
+			if err := s.awaitRequest(conn, cs, b); err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					s.logger().Debug("idle timeout", "remote", remoteAddr)
+				}
+				return nil
+			}
+		}
+		readBefore := cc.read - int64(b.Buffered())
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.ReadTimeout))
+		} else if served > 0 {
+			conn.SetReadDeadline(time.Time{})
+		}
+		s.armDrain(conn)
+		pooled := getRequest()
+		req, err := readRequest(b, pooled, readOptions{
+			maxBody:       s.maxBodySize(),
+			allowBareLF:   s.AllowBareLF && !s.StrictParsing,
+			strict:        s.StrictParsing,
+			requireLength: s.RequireContentLength,
+			audit:         s.Audit,
+			remoteAddr:    remoteAddr,
+			stream:        s.streamsBody,
+		})
 		if err != nil {
+			accept := pooled.Header.Get("Accept") // as far as it was parsed
+			putRequest(pooled)
 			if err == io.EOF {
 				return nil
 			}
-			fmt.Printf("error reading request: %s", err.Error())
-			res := NewResponse(conn, req)
-			if err == ErrBodyTooLarge {
-				res.SetStatus(413, "Payload Too Large")
-				res.SetBody([]byte("Payload Too Large"))
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				s.logger().Debug("read timeout", "remote", remoteAddr)
+				return nil
+			}
+			if dc != nil {
+				defer func() { dc.flush(cc.read) }()
+			}
+			s.stats.parseErrors.Add(1)
+			s.parseError(conn, err)
+			if s.Abuse != nil && s.Abuse.recordParseError(remoteIP(raw.RemoteAddr())) {
+				s.logger().Warn("banning client", "remote", remoteAddr, "reason", "parse errors")
+			}
+			s.logger().Warn("error reading request", "remote", remoteAddr, "err", err)
+			// where the next request would start is unknown after a parse
+			// error, so nothing more is read from this connection
+			res := NewResponse(conn, nil)
+			res.SetHeader("Connection", "close")
+			code, text := parseErrorStatus(err)
+			res.SetStatus(code, text)
+			if s.JSONParseErrors && NegotiateContentType(accept, "text/plain", "application/json") == "application/json" {
+				body, _ := json.Marshal(parseErrorBody{ParseErrorCode(err), err.Error()})
+				res.SetHeader("Content-Type", "application/json")
+				res.SetBody(body)
 			} else {
-				res.SetStatus(400, "Bad Request")
-				res.SetBody([]byte("Bad Request"))
+				res.SetBody([]byte(text))
 			}
-			return res.Write()
+			err = res.Write()
+			closeAfterError(raw)
+			return err
 		}
 
-		res := NewResponse(conn, req)
-		serverHandler{svr: s}.ServeHTTP(res, req)
+		if s.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Time{})
+		}
+		req.RemoteAddr = remoteAddr
+		if tc != nil {
+			// the handshake is done once the first request has been read
+			if tlsState == nil {
+				st := tc.ConnectionState()
+				tlsState = &st
+			}
+			req.TLS = tlsState
+		}
+		req.srv = s
+		req.bytesRead = cc.read - int64(b.Buffered()) - readBefore
+		s.logger().Debug("request", "method", req.Method, "path", req.Path, "proto", req.Proto)
+		ctx, cancel := context.WithCancel(context.Background())
+		req.ctx = ctx
+		req, endSpan := s.startSpan(req)
+		hooks := s.beginRequest(req)
+		res := getResponse(conn, req)
+		res.logger = s.Logger
+		res.cancel = cancel
+		res.reqBody = req.body
+		if s.DisableCompression {
+			delete(res.Headers, "Content-Encoding")
+			res.varyEncoding = false
+		}
+		if limit := s.MaxRequestsPerConn; limit > 0 && served+1 >= limit {
+			res.SetHeader("Connection", "close")
+		}
+		if res.Headers["Connection"] == "keep-alive" {
+			s.setKeepAlive(res, served)
+		}
+		if len(s.AltSvc) > 0 {
+			res.SetHeader("Alt-Svc", s.altSvc())
+		}
+		if s.shuttingDown() {
+			s.refuseDraining(res)
+		} else {
+			s.stats.requests.Add(1)
+			s.stats.activeHandlers.Add(1)
+			cs.beginRequest(req)
+			serverHandler{svr: s}.ServeHTTP(res, req)
+			cs.endRequest()
+			s.stats.activeHandlers.Add(-1)
+			if !res.wrote {
+				s.writeUnwritten(res, req)
+			}
+		}
+		cancel()
+		if req.body != nil {
+			// the handler read the body, count it with the request
+			req.bytesRead = cc.read - int64(b.Buffered()) - readBefore
+		}
+		if err := res.writeErr; err != nil {
+			if IsClientAbort(err) {
+				s.stats.clientAborts.Add(1)
+				s.logger().Debug("client aborted", "remote", remoteAddr, "path", req.Path, "err", err)
+			} else {
+				s.logger().Warn("error writing response", "remote", remoteAddr, "path", req.Path, "err", err)
+			}
+		}
+		endSpan(res)
+		hooks.end(req, res)
+		if dc != nil {
+			dc.flush(req.bytesRead)
+		}
 
-		if strings.ToLower(req.Header.Get("Connection")) == "close" {
+		closeConn := res.closesConn() || req.body != nil && !req.body.eof
+		if s.Abuse != nil && s.Abuse.recordStatus(remoteIP(raw.RemoteAddr()), res.StatusCode) {
+			s.logger().Warn("banning client", "remote", remoteAddr, "reason", "client errors")
+			closeConn = true
+		}
+		putResponse(res)
+		putRequest(req)
+		if closeConn {
 			return nil
 		}
 	}
 }
 
+// bodyStreamer is implemented by handlers that read some requests' bodies
+// as they arrive, instead of from Body once the server has read them.
+type bodyStreamer interface {
+	streamsBody(r *Request) bool
+}
+
+// streamsBody reports whether the handler that will serve req streams its
+// body. Only the route's handler is asked, past any middleware wrapping it;
+// behind a TimeoutHandler, which may leave the handler running once the
+// connection has moved on, the body is always read first.
+func (s *Server) streamsBody(req *Request) bool {
+	h := s.Handler
+	if h == nil {
+		h = DefaultServeMux
+	}
+	if mux, ok := h.(*ServeMux); ok {
+		if h, _ = mux.Handler(req); h == nil {
+			return false
+		}
+	}
+	_, timeout, end := unwrapHandler(h)
+	if timeout > 0 {
+		return false
+	}
+	bs, ok := end.(bodyStreamer)
+	return ok && bs.streamsBody(req)
+}
+
+// idleTimeout returns how long a keep-alive connection may wait for its
+// next request.
+func (s *Server) idleTimeout() time.Duration {
+	if s.IdleTimeout > 0 {
+		return s.IdleTimeout
+	}
+	return s.ReadTimeout
+}
+
+// awaitRequest waits, for up to the idle timeout, until the first byte of
+// the connection's next request has arrived. A keep-alive connection is
+// counted as idle meanwhile, and may be closed by the idle reaper.
+func (s *Server) awaitRequest(conn net.Conn, cs *connState, b *bufio.Reader) error {
+	s.stats.idleConns.Add(1)
+	defer s.stats.idleConns.Add(-1)
+	cs.idle.Store(time.Now().UnixNano())
+	defer cs.idle.Store(0)
+	if idle := s.idleTimeout(); idle > 0 {
+		conn.SetReadDeadline(time.Now().Add(idle))
+	}
+	s.armDrain(conn)
+	_, err := b.Peek(1)
+	return err
+}
+
+// setKeepAlive advertises how long the connection stays open for the next
+// request and how many more requests it takes.
+func (s *Server) setKeepAlive(res *Response, served int) {
+	var params []string
+	if secs := int(s.idleTimeout() / time.Second); secs > 0 {
+		params = append(params, "timeout="+strconv.Itoa(secs))
+	}
+	if limit := s.MaxRequestsPerConn; limit > 0 {
+		params = append(params, "max="+strconv.Itoa(limit-served-1))
+	}
+	if len(params) > 0 {
+		res.SetHeader("Keep-Alive", strings.Join(params, ", "))
+	}
+}
+
+// writeUnwritten answers for a handler that never called Write, so the
+// client isn't left waiting for a response that never comes.
+func (s *Server) writeUnwritten(res *Response, req *Request) {
+	s.logger().Debug("handler returned without writing a response", "method", req.Method, "path", req.Path, "pattern", req.Pattern)
+	if code := s.UnwrittenStatus; code != 0 {
+		res.SetStatus(code, StatusText(code))
+		res.SetBody(nil)
+	}
+	res.Write()
+}
+
+// lingerTimeout is how long closeAfterError waits for the client to stop
+// sending.
+const lingerTimeout = 500 * time.Millisecond
+
+// closeAfterError half-closes conn after an error response and discards
+// what the client is still sending for a moment before the caller closes
+// it. Closing with unread input makes the kernel send a reset, which can
+// destroy the response before the client reads it.
+func closeAfterError(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+	conn.SetReadDeadline(time.Now().Add(lingerTimeout))
+	io.Copy(io.Discard, io.LimitReader(conn, 256<<10))
+}
+
 func ListenAndServe(addr string, handler Handler) error {
 	s := &Server{
 		Addr:    addr,
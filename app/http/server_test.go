@@ -0,0 +1,1131 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// benchConn replays a fixed stream of requests and discards everything
+// written, so benchmarks measure parsing and response assembly only.
+type benchConn struct {
+	r io.Reader
+}
+
+func (c *benchConn) Read(p []byte) (int, error)       { return c.r.Read(p) }
+func (c *benchConn) Write(p []byte) (int, error)      { return len(p), nil }
+func (c *benchConn) Close() error                     { return nil }
+func (c *benchConn) LocalAddr() net.Addr              { return benchAddr{} }
+func (c *benchConn) RemoteAddr() net.Addr             { return benchAddr{} }
+func (c *benchConn) SetDeadline(time.Time) error      { return nil }
+func (c *benchConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *benchConn) SetWriteDeadline(time.Time) error { return nil }
+
+type benchAddr struct{}
+
+func (benchAddr) Network() string { return "tcp" }
+func (benchAddr) String() string  { return "127.0.0.1:1234" }
+
+const benchRequest = "GET /echo/hello HTTP/1.1\r\nHost: localhost:4221\r\nUser-Agent: bench\r\nAccept: */*\r\n\r\n"
+
+func benchServer() *Server {
+	mux := NewServeMux()
+	mux.HandleFunc("/echo/", func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(strings.TrimPrefix(r.Path, "/echo/")))
+		w.Write()
+	})
+	return &Server{Handler: mux}
+}
+
+func BenchmarkReadRequest(b *testing.B) {
+	stream := strings.Repeat(benchRequest, b.N)
+	br := bufio.NewReader(strings.NewReader(stream))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReadRequest(br); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkServeKeepAlive serves b.N requests over one connection, the
+// path Request and Response pooling targets.
+func BenchmarkServeKeepAlive(b *testing.B) {
+	s := benchServer()
+	stream := bytes.Repeat([]byte(benchRequest), b.N)
+	b.ReportAllocs()
+	b.ResetTimer()
+	s.handleConn(&benchConn{r: bytes.NewReader(stream)})
+	if got := s.Stats().Requests; got != uint64(b.N) {
+		b.Fatalf("served %d requests, want %d", got, b.N)
+	}
+}
+
+func TestRequestByteAccounting(t *testing.T) {
+	requests := []string{
+		benchRequest,
+		"POST /echo/x HTTP/1.1\r\nHost: a\r\nContent-Length: 5\r\n\r\nhello",
+	}
+	type sizer interface {
+		Status() int
+		BytesWritten() int64
+	}
+	var seen []RequestEnd
+	s := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.SetStatus(201, "Created")
+			w.SetBody([]byte("abc"))
+			w.Write()
+			sz := w.(sizer)
+			seen = append(seen, RequestEnd{StatusCode: sz.Status(), BytesRead: r.BytesRead(), BytesWritten: sz.BytesWritten()})
+		}),
+		OnRequestEnd: func(_ *Request, end RequestEnd) {
+			end.Latency = 0
+			seen = append(seen, end)
+		},
+	}
+	s.handleConn(&benchConn{r: strings.NewReader(strings.Join(requests, ""))})
+
+	want := int64(len("HTTP/1.1 201 Created\r\nConnection: keep-alive\r\nContent-Type: text/plain\r\nVary: Accept-Encoding\r\nContent-Length: 3\r\n\r\nabc"))
+	if len(seen) != 2*len(requests) {
+		t.Fatalf("got %d records, want %d", len(seen), 2*len(requests))
+	}
+	for i, req := range requests {
+		for _, got := range seen[2*i : 2*i+2] {
+			if got.StatusCode != 201 || got.BytesRead != int64(len(req)) || got.BytesWritten != want {
+				t.Errorf("#%d: got: %+v wantBytesRead: %d wantBytesWritten: %d", i, got, len(req), want)
+			}
+		}
+	}
+}
+
+var parseErrorCloseTest = []struct {
+	stream, status string
+}{
+	{"BROKEN\r\n\r\n", "400 Bad Request"},
+	{"GET / HTTP/1.1\r\nBad Header\r\n\r\n", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: 99999999\r\n\r\n", "413 Payload Too Large"},
+	{"GET / HTTP/2.0\r\n\r\n", "505 HTTP Version Not Supported"},
+	{"GET / HTTP/0.9\r\n\r\n", "505 HTTP Version Not Supported"},
+	{"GET / HTTP/1\r\n\r\n", "400 Bad Request"},
+	{"GET / FTP/1.1\r\n\r\n", "400 Bad Request"},
+	{"GET / HTTP/1.1\r\nX: " + strings.Repeat("a", 5000) + "\r\n\r\n", "431 Request Header Fields Too Large"},
+	{"POST / HTTP/1.1\r\nTransfer-Encoding: gzip, chunked\r\n\r\n", "501 Not Implemented"},
+	{"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: abc\r\n\r\n", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: -1\r\n\r\n", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: +4\r\n\r\nabcd", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: 4, 4\r\n\r\nabcd", "400 Bad Request"},
+	{"POST / HTTP/1.1\r\nContent-Length: 99999999999999999999\r\n\r\n", "413 Payload Too Large"},
+}
+
+func TestParseErrorClosesConnection(t *testing.T) {
+	const next = "GET / HTTP/1.1\r\nHost: a\r\n\r\n"
+	for i, tt := range parseErrorCloseTest {
+		served := false
+		s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			served = true
+			w.Write()
+		})}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.stream + next)}}
+		s.handleConn(conn)
+
+		out := conn.w.String()
+		if !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") {
+			t.Errorf("#%d: gotResponse: %q wantStatus: %q", i, out, tt.status)
+		}
+		if !strings.Contains(out, "Connection: close\r\n") {
+			t.Errorf("#%d: response lacks Connection: close: %q", i, out)
+		}
+		if served || strings.Count(out, "HTTP/1.1 ") != 1 {
+			t.Errorf("#%d: bytes after the parse error were served: %q", i, out)
+		}
+	}
+}
+
+func TestChunkedRequest(t *testing.T) {
+	var bodies []string
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		bodies = append(bodies, string(r.Body))
+		w.Write()
+	}), MaxBodySize: 8}
+	stream := "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2;x=y\r\nde\r\n0\r\nX-Trailer: t\r\n\r\n" +
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n9\r\n123456789\r\n0\r\n\r\n"
+	conn := &bufConn{benchConn: benchConn{r: strings.NewReader(stream)}}
+	s.handleConn(conn)
+	if len(bodies) != 1 || bodies[0] != "abcde" {
+		t.Errorf("gotBodies: %q wantBodies: %q", bodies, []string{"abcde"})
+	}
+	if out := conn.w.String(); !strings.Contains(out, "HTTP/1.1 413 ") {
+		t.Errorf("oversized chunked body not refused: %q", out)
+	}
+}
+
+func TestErrorTaxonomy(t *testing.T) {
+	for _, err := range []error{badStringErr("Malformed HTTP request", "x"), errMalformedHeader, ErrMalformedChunk} {
+		if !errors.Is(err, ErrMalformedMessage) {
+			t.Errorf("%v does not match ErrMalformedMessage", err)
+		}
+	}
+	if errors.Is(ErrBodyTooLarge, ErrMalformedMessage) {
+		t.Error("ErrBodyTooLarge matches ErrMalformedMessage")
+	}
+}
+
+var jsonParseErrorTest = []struct {
+	stream, code string
+}{
+	{"GET / HTTP/1.1\r\nAccept: application/json\r\nBad Header\r\n\r\n", "malformed_header"},
+	{"GET / HTTP/1.1\r\nAccept: application/json\r\nContent-Length: 99999999\r\n\r\n", "body_too_large"},
+	{"POST / HTTP/1.1\r\nAccept: application/json\r\nTransfer-Encoding: chunked\r\n\r\nzz\r\n", "malformed_chunk"},
+	{"POST / HTTP/1.1\r\nAccept: application/json\r\nTransfer-Encoding: gzip\r\n\r\n", "unsupported_transfer_encoding"},
+	{"GET / HTTP/1.1\r\nAccept: text/html\r\nBad Header\r\n\r\n", ""},
+	{"BROKEN\r\nAccept: application/json\r\n\r\n", ""}, // never got as far as Accept
+}
+
+func TestJSONParseErrors(t *testing.T) {
+	for i, tt := range jsonParseErrorTest {
+		s := &Server{JSONParseErrors: true}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.stream)}}
+		s.handleConn(conn)
+		_, body, _ := strings.Cut(conn.w.String(), "\r\n\r\n")
+		if tt.code == "" {
+			if strings.HasPrefix(body, "{") {
+				t.Errorf("#%d: gotBody: %q, want plain text", i, body)
+			}
+			continue
+		}
+		var got parseErrorBody
+		if err := json.Unmarshal([]byte(body), &got); err != nil || got.Error != tt.code || got.Detail == "" {
+			t.Errorf("#%d: gotBody: %q wantError: %q", i, body, tt.code)
+		}
+	}
+	if got := ParseErrorCode(badRequestLine("Malformed HTTP request", "x")); got != "malformed_request_line" {
+		t.Errorf("gotCode: %q wantCode: malformed_request_line", got)
+	}
+}
+
+var keepAliveTest = []struct {
+	request    string
+	connection string
+	keepAlive  string
+	open       bool
+}{
+	{"GET / HTTP/1.1\r\n\r\n", "keep-alive", "timeout=5", true},
+	{"GET / HTTP/1.1\r\nConnection: close\r\n\r\n", "close", "", false},
+	{"GET / HTTP/1.1\r\nConnection: Upgrade, Close\r\n\r\n", "close", "", false},
+	{"GET / HTTP/1.0\r\n\r\n", "close", "", false},
+	{"GET / HTTP/1.0\r\nConnection: Keep-Alive\r\n\r\n", "keep-alive", "timeout=5", true},
+}
+
+func TestKeepAlive(t *testing.T) {
+	for i, tt := range keepAliveTest {
+		s := &Server{
+			Handler:     HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+			ReadTimeout: 5 * time.Second,
+		}
+		// a second request is only answered if the connection stayed open
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.request + "GET / HTTP/1.1\r\n\r\n")}}
+		s.handleConn(conn)
+
+		out := conn.w.String()
+		if !strings.Contains(out, "Connection: "+tt.connection+"\r\n") {
+			t.Errorf("#%d: gotResponse: %q wantConnection: %q", i, out, tt.connection)
+		}
+		want := "Keep-Alive: " + tt.keepAlive + "\r\n"
+		if tt.keepAlive == "" {
+			want = "Keep-Alive:"
+		}
+		if got := strings.Contains(out, want); got != (tt.keepAlive != "") {
+			t.Errorf("#%d: gotResponse: %q wantKeepAlive: %q", i, out, tt.keepAlive)
+		}
+		if got := strings.Count(out, "HTTP/1.1 200") == 2; got != tt.open {
+			t.Errorf("#%d: gotOpen: %v wantOpen: %v", i, got, tt.open)
+		}
+	}
+
+	// a handler can end the connection by setting Connection: close
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetHeader("Connection", "close")
+		w.Write()
+	})}
+	conn := &bufConn{benchConn: benchConn{r: strings.NewReader(strings.Repeat("GET / HTTP/1.1\r\n\r\n", 2))}}
+	s.handleConn(conn)
+	if n := strings.Count(conn.w.String(), "HTTP/1.1 200"); n != 1 {
+		t.Errorf("served %d requests after the handler closed the connection, want 1", n)
+	}
+}
+
+var responseFramingTest = []struct {
+	method string
+	code   int
+	stream bool
+	length string // wanted Content-Length, "" for none
+	body   string // wanted bytes after the headers
+}{
+	{MethodGet, 100, false, "", ""},
+	{MethodGet, 101, false, "", ""},
+	{MethodGet, 204, false, "", ""},
+	{MethodGet, 204, true, "", ""},
+	{MethodGet, 304, false, "", ""},
+	{MethodGet, 200, false, "5", "hello"},
+	{MethodGet, 200, true, "5", "hello"},
+	{MethodGet, 404, false, "5", "hello"},
+	{MethodHead, 200, false, "5", ""},
+	{MethodHead, 200, true, "5", ""},
+	{MethodHead, 204, false, "", ""},
+}
+
+func TestResponseFraming(t *testing.T) {
+	for i, tt := range responseFramingTest {
+		req, _ := NewRequest(tt.method, "/", nil)
+		conn := &bufConn{}
+		res := NewResponse(conn, req)
+		res.SetStatus(tt.code, StatusText(tt.code))
+		res.SetHeader("Content-Length", "999") // must not leak through
+		if tt.stream {
+			res.SetBodyReader(strings.NewReader("hello"), 5)
+		} else {
+			res.SetBody([]byte("hello"))
+		}
+		if err := res.Write(); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		head, body, _ := strings.Cut(conn.w.String(), "\r\n\r\n")
+		length := ""
+		for _, line := range strings.Split(head, "\r\n") {
+			if v, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+				length = v
+			}
+		}
+		if length != tt.length || body != tt.body {
+			t.Errorf("#%d: %s %d gotContentLength: %q gotBody: %q wantContentLength: %q wantBody: %q",
+				i, tt.method, tt.code, length, body, tt.length, tt.body)
+		}
+	}
+}
+
+func TestResponseAddHeader(t *testing.T) {
+	for i, set := range []bool{false, true} {
+		req, _ := NewRequest(MethodGet, "/", nil)
+		conn := &bufConn{}
+		res := NewResponse(conn, req)
+		res.AddHeader("Set-Cookie", "a=1; Path=/")
+		res.AddHeader("Set-Cookie", "b=2, 3")
+		want := []string{"a=1; Path=/", "b=2, 3"}
+		if set {
+			res.SetHeader("Set-Cookie", "c=4")
+			want = []string{"c=4"}
+		}
+		res.Write()
+		cr, err := ReadResponse(bufio.NewReader(&conn.w), req)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if got := cr.Header["Set-Cookie"]; !slices.Equal(got, want) {
+			t.Errorf("#%d: gotSetCookie: %q wantSetCookie: %q", i, got, want)
+		}
+	}
+}
+
+var unwrittenTest = []struct {
+	unwritten int
+	status    string
+	body      string
+}{
+	{0, "200 OK", ""},
+	{0, "201 Created", "made"},
+	{500, "500 Internal Server Error", ""},
+}
+
+func TestHandlerWithoutWrite(t *testing.T) {
+	for i, tt := range unwrittenTest {
+		s := &Server{
+			Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+				if r.Path == "/set" {
+					w.SetStatus(201, "Created")
+					w.SetBody([]byte("made"))
+				}
+			}),
+			UnwrittenStatus: tt.unwritten,
+		}
+		path := "/"
+		if tt.body != "" {
+			path = "/set"
+		}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader("GET " + path + " HTTP/1.1\r\n\r\n")}}
+		s.handleConn(conn)
+
+		out := conn.w.String()
+		_, body, _ := strings.Cut(out, "\r\n\r\n")
+		if !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") || body != tt.body {
+			t.Errorf("#%d: gotResponse: %q wantStatus: %q wantBody: %q", i, out, tt.status, tt.body)
+		}
+	}
+}
+
+func TestDoubleWrite(t *testing.T) {
+	var written []bool
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		res := w.(*Response)
+		written = append(written, res.Written())
+		w.SetBody([]byte("once"))
+		w.Write()
+		written = append(written, res.Written())
+		w.SetHeader("X-Late", "1")
+		w.SetBody([]byte("twice"))
+		w.Write()
+	})}
+	conn := &bufConn{benchConn: benchConn{r: strings.NewReader(strings.Repeat("GET / HTTP/1.1\r\n\r\n", 2))}}
+	s.handleConn(conn)
+
+	out := conn.w.String()
+	if n := strings.Count(out, "HTTP/1.1 200"); n != 2 {
+		t.Errorf("got %d responses for 2 requests: %q", n, out)
+	}
+	if strings.Contains(out, "twice") || strings.Contains(out, "X-Late") {
+		t.Errorf("second Write or late header reached the wire: %q", out)
+	}
+	if want := []bool{false, true, false, true}; fmt.Sprint(written) != fmt.Sprint(want) {
+		t.Errorf("gotWritten: %v wantWritten: %v", written, want)
+	}
+}
+
+var restrictMethodsTest = []struct {
+	restrict bool
+	allowed  []string
+	method   string
+	status   string
+}{
+	{false, nil, "PROPFIND", "200 OK"},
+	{true, nil, "GET", "200 OK"},
+	{true, nil, "OPTIONS", "200 OK"},
+	{true, nil, "PROPFIND", "501 Not Implemented"},
+	{true, nil, "get", "501 Not Implemented"},
+	{true, append(StandardMethods(), "PROPFIND"), "PROPFIND", "200 OK"},
+	{true, []string{"GET"}, "POST", "501 Not Implemented"},
+}
+
+func TestRestrictMethods(t *testing.T) {
+	for i, tt := range restrictMethodsTest {
+		s := &Server{
+			Handler:         HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+			RestrictMethods: tt.restrict,
+			AllowedMethods:  tt.allowed,
+		}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.method + " / HTTP/1.1\r\n\r\n")}}
+		s.handleConn(conn)
+		if out := conn.w.String(); !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") {
+			t.Errorf("#%d: %s gotResponse: %q wantStatus: %q", i, tt.method, out, tt.status)
+		}
+	}
+}
+
+var bareLFTest = []struct {
+	request     string
+	allowBareLF bool
+	status      string
+}{
+	{"GET / HTTP/1.1\r\nHost: a\r\n\r\n", false, "200 OK"},
+	{"GET / HTTP/1.1\nHost: a\r\n\r\n", false, "400 Bad Request"},
+	{"GET / HTTP/1.1\r\nHost: a\n\r\n", false, "400 Bad Request"},
+	{"GET / HTTP/1.1\r\nHost: a\r\n\n", false, "400 Bad Request"},
+	{"GET / HTTP/1.1\nHost: a\n\n", true, "200 OK"},
+	{"GET / HTTP/1.1\r\nHost: a\n\r\n", true, "200 OK"},
+}
+
+func TestBareLF(t *testing.T) {
+	for i, tt := range bareLFTest {
+		s := &Server{
+			Handler:     HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+			AllowBareLF: tt.allowBareLF,
+		}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.request)}}
+		s.handleConn(conn)
+		if out := conn.w.String(); !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") {
+			t.Errorf("#%d: %q gotResponse: %q wantStatus: %q", i, tt.request, out, tt.status)
+		}
+	}
+}
+
+var requireLengthTest = []struct {
+	require bool
+	request string
+	status  string
+	body    string
+}{
+	{false, "POST / HTTP/1.1\r\n\r\n", "200 OK", ""},
+	{false, "POST / HTTP/1.1\r\nContent-Length: 0\r\n\r\n", "200 OK", ""},
+	{true, "POST / HTTP/1.1\r\n\r\n", "411 Length Required", ""},
+	{true, "PUT / HTTP/1.1\r\n\r\n", "411 Length Required", ""},
+	{true, "POST / HTTP/1.1\r\nContent-Length: 0\r\n\r\n", "200 OK", ""},
+	{true, "POST / HTTP/1.1\r\nContent-Length: 2\r\n\r\nhi", "200 OK", "hi"},
+	{true, "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n2\r\nhi\r\n0\r\n\r\n", "200 OK", "hi"},
+	{true, "GET / HTTP/1.1\r\n\r\n", "200 OK", ""},
+	{true, "DELETE / HTTP/1.1\r\n\r\n", "200 OK", ""},
+}
+
+func TestRequireContentLength(t *testing.T) {
+	for i, tt := range requireLengthTest {
+		var body string
+		s := &Server{
+			Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+				body = string(r.Body)
+				w.Write()
+			}),
+			RequireContentLength: tt.require,
+		}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.request)}}
+		s.handleConn(conn)
+		if out := conn.w.String(); !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") || body != tt.body {
+			t.Errorf("#%d: %q gotResponse: %q gotBody: %q wantStatus: %q wantBody: %q", i, tt.request, out, body, tt.status, tt.body)
+		}
+	}
+}
+
+var optionsAsteriskTest = []struct {
+	s       *Server
+	request string
+	status  string
+	allow   string
+}{
+	{&Server{}, "OPTIONS * HTTP/1.1\r\n\r\n", "204 No Content", "GET, HEAD, POST, PUT, DELETE, PATCH, OPTIONS"},
+	{&Server{RestrictMethods: true, AllowedMethods: []string{"GET", "OPTIONS"}}, "OPTIONS * HTTP/1.1\r\n\r\n", "204 No Content", "GET, OPTIONS"},
+	{&Server{}, "GET * HTTP/1.1\r\n\r\n", "400 Bad Request", ""},
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	for i, tt := range optionsAsteriskTest {
+		called := false
+		tt.s.Handler = HandlerFunc(func(w ResponseWriter, r *Request) {
+			called = true
+			w.Write()
+		})
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.request)}}
+		tt.s.handleConn(conn)
+
+		out := conn.w.String()
+		if !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+"\r\n") || called {
+			t.Errorf("#%d: gotResponse: %q wantStatus: %q", i, out, tt.status)
+		}
+		if tt.allow != "" && !strings.Contains(out, "Allow: "+tt.allow+"\r\n") {
+			t.Errorf("#%d: gotResponse: %q wantAllow: %q", i, out, tt.allow)
+		}
+	}
+}
+
+func TestShutdownDrain(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{
+		Handler:      HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+		DrainTimeout: 5 * time.Second,
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.Serve(ln) }()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	roundTrip := func() string {
+		io.WriteString(conn, "GET / HTTP/1.1\r\nHost: a\r\n\r\n")
+		var head strings.Builder
+		for {
+			line, err := br.ReadString('\n')
+			head.WriteString(line)
+			if err != nil || line == "\r\n" {
+				return head.String()
+			}
+		}
+	}
+	if got := roundTrip(); !strings.HasPrefix(got, "HTTP/1.1 200 OK\r\n") {
+		t.Fatalf("before Shutdown gotResponse: %q", got)
+	}
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- s.Shutdown(context.Background()) }()
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve returned %v, want ErrServerClosed", err)
+	}
+
+	got := roundTrip()
+	for _, want := range []string{"HTTP/1.1 503 Service Unavailable\r\n", "Connection: close\r\n", "Retry-After: 5\r\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("during drain gotResponse: %q want: %q", got, want)
+		}
+	}
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Shutdown did not return after the last connection closed")
+	}
+}
+
+func TestMaxRequestsPerConn(t *testing.T) {
+	s := &Server{
+		Handler:            HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+		ReadTimeout:        5 * time.Second,
+		MaxRequestsPerConn: 2,
+	}
+	conn := &bufConn{benchConn: benchConn{r: strings.NewReader(strings.Repeat("GET / HTTP/1.1\r\n\r\n", 3))}}
+	s.handleConn(conn)
+
+	out := conn.w.String()
+	if n := strings.Count(out, "HTTP/1.1 200"); n != 2 {
+		t.Errorf("served %d requests, want 2: %q", n, out)
+	}
+	first, second, _ := strings.Cut(out, "\r\n\r\n")
+	if !strings.Contains(first+"\r\n", "Keep-Alive: timeout=5, max=1\r\n") {
+		t.Errorf("gotFirst: %q wantKeepAlive: %q", first, "timeout=5, max=1")
+	}
+	if !strings.Contains(second+"\r\n", "Connection: close\r\n") || strings.Contains(second, "Keep-Alive") {
+		t.Errorf("gotLast: %q want Connection: close without Keep-Alive", second)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	s := &Server{
+		Handler:     HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+		ReadTimeout: 10 * time.Second,
+		IdleTimeout: 50 * time.Millisecond,
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() { done <- s.handleConn(server) }()
+
+	go io.WriteString(client, "GET / HTTP/1.1\r\n\r\n")
+	br := bufio.NewReader(client)
+	if line, _ := br.ReadString('\n'); line != "HTTP/1.1 200 OK\r\n" {
+		t.Fatalf("gotStatusLine: %q", line)
+	}
+	go io.Copy(io.Discard, br)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("idle connection closed with %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("idle connection still open after IdleTimeout")
+	}
+	if n := s.Stats().IdleConns; n != 0 {
+		t.Errorf("gotIdleConns: %d wantIdleConns: 0", n)
+	}
+}
+
+func TestAbuseGuard(t *testing.T) {
+	g := &AbuseGuard{MaxClientErrors: 2, MaxParseErrors: 1}
+	s := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.SetStatus(StatusNotFound, StatusText(StatusNotFound))
+			w.Write()
+		}),
+		Abuse: g,
+	}
+	conn := &bufConn{benchConn: benchConn{r: strings.NewReader(strings.Repeat("GET /wp-admin HTTP/1.1\r\n\r\n", 3))}}
+	s.handleConn(conn)
+	if n := strings.Count(conn.w.String(), "HTTP/1.1 404"); n != 2 {
+		t.Errorf("served %d requests before the ban, want 2", n)
+	}
+	if !g.Banned("127.0.0.1") {
+		t.Fatalf("client not banned after %d client errors", g.MaxClientErrors)
+	}
+	if g.admit(&bufConn{}) {
+		t.Errorf("connection from a banned client admitted")
+	}
+
+	g = &AbuseGuard{MaxParseErrors: 1}
+	s.Abuse = g
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader("GARBAGE\r\n\r\n")}})
+	if !g.Banned("127.0.0.1") {
+		t.Errorf("client not banned after a parse error")
+	}
+	if g.Banned("127.0.0.2") {
+		t.Errorf("unrelated client banned")
+	}
+}
+
+var altSvcTest = []struct {
+	svc  []AltService
+	want string
+}{
+	{[]AltService{{Protocol: "h3", Port: 443}}, `h3=":443"`},
+	{[]AltService{{Protocol: "h3", Port: 8443, MaxAge: time.Hour}, {Protocol: "h2", Host: "alt.example.com", Port: 443}},
+		`h3=":8443"; ma=3600, h2="alt.example.com:443"`},
+	{[]AltService{{Protocol: "h2", Host: "::1", Port: 443}}, `h2="[::1]:443"`},
+}
+
+func TestAltSvc(t *testing.T) {
+	for i, tt := range altSvcTest {
+		s := &Server{
+			Handler: HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }),
+			AltSvc:  tt.svc,
+		}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader("GET / HTTP/1.1\r\nConnection: close\r\n\r\n")}}
+		s.handleConn(conn)
+		if out := conn.w.String(); !strings.Contains(out, "\r\nAlt-Svc: "+tt.want+"\r\n") {
+			t.Errorf("#%d: gotResponse: %q wantAltSvc: %q", i, out, tt.want)
+		}
+	}
+}
+
+func TestLongPollShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parked := make(chan struct{})
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(parked)
+		LongPoll(w, r, make(chan string), time.Minute, func(ResponseWriter, string) error { return nil })
+	})}
+	go s.Serve(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET /events HTTP/1.1\r\nHost: a\r\n\r\n")
+	<-parked
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go s.Shutdown(ctx)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "HTTP/1.1 204 No Content\r\n" {
+		t.Errorf("gotStatusLine: %q err: %v", line, err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("long poll took %v to resolve after Shutdown", d)
+	}
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	var logs bytes.Buffer
+	mux := NewServeMux()
+	mux.HandleFunc("/slow/", func(w ResponseWriter, r *Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write()
+	})
+	mux.HandleFunc("/fast", func(w ResponseWriter, r *Request) { w.Write() })
+	s := &Server{
+		Handler:              mux,
+		Logger:               slog.New(slog.NewTextHandler(&logs, nil)),
+		SlowRequestThreshold: 10 * time.Millisecond,
+	}
+	stream := "GET /fast HTTP/1.1\r\n\r\nGET /slow/x HTTP/1.1\r\nConnection: close\r\n\r\n"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+
+	got := logs.String()
+	if n := strings.Count(got, "slow request"); n != 1 {
+		t.Fatalf("logged %d slow requests, want 1: %s", n, got)
+	}
+	for _, want := range []string{"level=WARN", "path=/slow/x", "route=/slow/", "status=200", "duration="} {
+		if !strings.Contains(got, want) {
+			t.Errorf("gotLog: %q want: %q", got, want)
+		}
+	}
+}
+
+func TestHandleNamed(t *testing.T) {
+	var got []RequestEnd
+	mux := NewServeMux()
+	mux.HandleNamed("/files/{name}", "/files/", HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }))
+	mux.HandleFunc("/echo/", func(w ResponseWriter, r *Request) { w.Write() })
+	s := &Server{
+		Handler:      mux,
+		OnRequestEnd: func(r *Request, end RequestEnd) { got = append(got, end) },
+	}
+	stream := "GET /files/a.txt HTTP/1.1\r\n\r\nGET /files/b.txt HTTP/1.1\r\n\r\n" +
+		"GET /echo/x HTTP/1.1\r\n\r\nGET /nope HTTP/1.1\r\nConnection: close\r\n\r\n"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+
+	want := []string{"/files/{name}", "/files/{name}", "/echo/", ""}
+	if len(got) != len(want) {
+		t.Fatalf("got %d requests, want %d", len(got), len(want))
+	}
+	for i, end := range got {
+		if end.Route != want[i] {
+			t.Errorf("#%d: gotRoute: %q wantRoute: %q", i, end.Route, want[i])
+		}
+	}
+	for _, rt := range mux.Routes() {
+		if rt.Pattern == "/files/" && rt.Name != "/files/{name}" {
+			t.Errorf("gotName: %q wantName: %q", rt.Name, "/files/{name}")
+		}
+	}
+}
+
+func TestDrainListener(t *testing.T) {
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() })}
+	var addrs []string
+	served := make(chan error, 2)
+	for range 2 {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addrs = append(addrs, ln.Addr().String())
+		go func() { served <- s.Serve(ln) }()
+	}
+	for len(s.ListenerAddrs()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := s.DrainListener(addrs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != ErrListenerDrained {
+		t.Errorf("Serve returned %v, want ErrListenerDrained", err)
+	}
+	if got := s.ListenerAddrs(); len(got) != 1 || got[0] != addrs[1] {
+		t.Errorf("gotListeners: %q want: %q", got, addrs[1:])
+	}
+	if _, err := net.Dial("tcp", addrs[0]); err == nil {
+		t.Errorf("drained listener still accepts connections")
+	}
+	conn, err := net.Dial("tcp", addrs[1])
+	if err != nil {
+		t.Fatalf("other listener: %v", err)
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Shutdown(ctx)
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("after Shutdown Serve returned %v", err)
+	}
+}
+
+func TestBoundAddr(t *testing.T) {
+	s := &Server{Addr: "127.0.0.1:0", Handler: HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() })}
+	if s.BoundAddr() != nil {
+		t.Fatal("BoundAddr set before serving")
+	}
+	served := make(chan error, 1)
+	go func() { served <- s.ListenAndServe() }()
+	select {
+	case <-s.Started():
+	case err := <-served:
+		t.Fatal(err)
+	}
+	addr := s.BoundAddr().String()
+	if strings.HasSuffix(addr, ":0") {
+		t.Fatalf("gotAddr: %s, want the port picked", addr)
+	}
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.Shutdown(ctx)
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("ListenAndServe returned %v", err)
+	}
+}
+
+// flakyListener fails Accept with each of errs in turn.
+type flakyListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	err := l.errs[0]
+	if len(l.errs) > 1 {
+		l.errs = l.errs[1:]
+	}
+	return nil, err
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "accept timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestServeClosedListener(t *testing.T) {
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() })}
+	s.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	for i, flaky := range []bool{false, true} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var l net.Listener = ln
+		if flaky {
+			// timeouts are retried until the listener is closed
+			l = &flakyListener{Listener: ln, errs: []error{timeoutError{}, timeoutError{}, &net.OpError{Op: "accept", Net: "tcp", Err: net.ErrClosed}}}
+		} else {
+			ln.Close()
+		}
+		served := make(chan error, 1)
+		go func() { served <- s.Serve(l) }()
+		select {
+		case err := <-served:
+			if !errors.Is(err, net.ErrClosed) {
+				t.Errorf("#%d: Serve returned %v, want net.ErrClosed", i, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("#%d: Serve kept accepting on a closed listener", i)
+		}
+	}
+}
+
+func TestOnShutdownAndServeError(t *testing.T) {
+	var failedAddr string
+	s := &Server{Addr: "256.0.0.1:0", OnServeError: func(addr string, err error) { failedAddr = addr }}
+	if err := s.ListenAndServe(); err == nil || failedAddr != s.Addr {
+		t.Errorf("bind failure: gotErr: %v gotAddr: %q", err, failedAddr)
+	}
+
+	stopped := make(chan struct{})
+	s = &Server{}
+	s.RegisterOnShutdown(func() { close(stopped) })
+	s.Shutdown(context.Background())
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("OnShutdown func not called")
+	}
+	// a second Shutdown doesn't call it again, which would panic here
+	s.Shutdown(context.Background())
+}
+
+func TestDrainReport(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	mux := NewServeMux()
+	mux.HandleNamed("/export/{id}", "/export/", HandlerFunc(func(w ResponseWriter, r *Request) {
+		close(entered)
+		<-release
+		w.Write()
+	}))
+	var logs bytes.Buffer
+	s := &Server{Handler: mux, DrainReportInterval: 20 * time.Millisecond, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	served := make(chan struct{})
+	go func() {
+		s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader("GET /export/7 HTTP/1.1\r\nHost: x\r\n\r\n")}})
+		close(served)
+	}()
+	<-entered
+
+	st := s.Stats()
+	if len(st.InFlight) != 1 || st.Draining {
+		t.Fatalf("gotStats: %+v", st)
+	}
+	if f := st.InFlight[0]; f.Method != "GET" || f.Path != "/export/7" || f.Route != "/export/{id}" || f.RemoteAddr != "127.0.0.1:1234" {
+		t.Errorf("gotInFlight: %+v", f)
+	}
+
+	shut := make(chan error)
+	go func() { shut <- s.Shutdown(context.Background()) }()
+	time.Sleep(60 * time.Millisecond)
+	if !s.Stats().Draining {
+		t.Error("Stats.Draining false during Shutdown")
+	}
+	close(release)
+	if err := <-shut; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	<-served
+	if out := logs.String(); !strings.Contains(out, "msg=draining") || !strings.Contains(out, "route=/export/{id}") || !strings.Contains(out, "path=/export/7") {
+		t.Errorf("drain report missing from log:\n%s", out)
+	}
+	if n := len(s.InFlight()); n != 0 {
+		t.Errorf("got %d requests in flight after Shutdown, want 0", n)
+	}
+}
+
+// deafConn ignores deadlines, as some wrapped connections do.
+type deafConn struct {
+	net.Conn
+}
+
+func (deafConn) SetReadDeadline(time.Time) error { return nil }
+
+func TestIdleReaper(t *testing.T) {
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }), IdleTimeout: 30 * time.Millisecond}
+	s.startIdleReaper()
+	defer s.Shutdown(context.Background())
+	srv, client := net.Pipe()
+	go s.handleConn(deafConn{srv})
+
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, client)
+		close(closed)
+	}()
+	io.WriteString(client, "GET / HTTP/1.1\r\nHost: a\r\n\r\n")
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle connection was not reaped")
+	}
+	if st := s.Stats(); st.IdleReaped != 1 {
+		t.Errorf("gotIdleReaped: %d wantIdleReaped: 1", st.IdleReaped)
+	}
+}
+
+// selfSignedCert returns a throwaway certificate for 127.0.0.1.
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestAutoTLSListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte("hi"))
+		w.Write()
+	})}
+	go s.Serve(NewAutoTLSListener(ln, &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}))
+	defer s.Shutdown(context.Background())
+
+	get := func(c net.Conn, err error) string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		io.WriteString(c, "GET / HTTP/1.1\r\nHost: a\r\nConnection: close\r\n\r\n")
+		out, _ := io.ReadAll(c)
+		return string(out)
+	}
+	plain := get(net.Dial("tcp", ln.Addr().String()))
+	secure := get(tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true}))
+	for i, out := range []string{plain, secure} {
+		if !strings.HasPrefix(out, "HTTP/1.1 200 OK\r\n") || !strings.HasSuffix(out, "hi") {
+			t.Errorf("#%d: gotResponse: %q", i, out)
+		}
+	}
+	if st := s.Stats(); st.TLSDetected != 1 || st.PlainDetected != 1 {
+		t.Errorf("gotTLSDetected: %d gotPlainDetected: %d, want 1 each", st.TLSDetected, st.PlainDetected)
+	}
+}
+
+func TestBodyAudit(t *testing.T) {
+	var sink bytes.Buffer
+	var bodies []string
+	s := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			bodies = append(bodies, string(r.Body))
+			w.Write()
+		}),
+		Audit: &BodyAudit{W: &sink, MaxBody: 8, ContentTypes: []string{"application/json"}},
+	}
+	stream := "POST /a HTTP/1.1\r\nContent-Type: application/json; charset=utf-8\r\nContent-Length: 13\r\n\r\n{\"card\":\"4\"}\n" +
+		"POST /b HTTP/1.1\r\nContent-Type: text/plain\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /c HTTP/1.1\r\nContent-Type: application/json\r\nContent-Length: 2\r\nConnection: close\r\n\r\n{}"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+
+	if len(bodies) != 3 || bodies[0] != "{\"card\":\"4\"}\n" || bodies[1] != "hello" {
+		t.Errorf("handler gotBodies: %q", bodies)
+	}
+	var recs []AuditRecord
+	for line := range strings.Lines(sink.String()) {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad audit line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("got %d audit records, want 2: %s", len(recs), sink.String())
+	}
+	if r := recs[0]; r.Path != "/a" || r.Body != `{"card":` || !r.Truncated || r.Size != 13 || r.RemoteAddr != "127.0.0.1:1234" {
+		t.Errorf("gotRecord: %+v", r)
+	}
+	if r := recs[1]; r.Path != "/c" || r.Body != "{}" || r.Truncated {
+		t.Errorf("gotRecord: %+v", r)
+	}
+}
+
+// resetConn fails every write as a connection the client reset would.
+type resetConn struct {
+	bufConn
+}
+
+func (c *resetConn) Write(p []byte) (int, error) {
+	return 0, &net.OpError{Op: "write", Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+func TestClientAbort(t *testing.T) {
+	var writeErr, ctxErr error
+	var end RequestEnd
+	s := &Server{
+		Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			w.SetBody(bytes.Repeat([]byte("x"), 4<<10))
+			writeErr = w.Write()
+			ctxErr = r.Context().Err()
+		}),
+		OnRequestEnd: func(r *Request, e RequestEnd) { end = e },
+	}
+	conn := &resetConn{bufConn{benchConn: benchConn{r: strings.NewReader(strings.Repeat("GET / HTTP/1.1\r\n\r\n", 2))}}}
+	s.handleConn(conn)
+
+	if !IsClientAbort(writeErr) {
+		t.Errorf("gotWriteErr: %v, not classified as a client abort", writeErr)
+	}
+	if ctxErr != context.Canceled {
+		t.Errorf("gotCtxErr: %v wantCtxErr: %v", ctxErr, context.Canceled)
+	}
+	if end.StatusCode != StatusOK || !IsClientAbort(end.Err) {
+		t.Errorf("gotEnd: %+v", end)
+	}
+	if got := s.Stats(); got.ClientAborts != 1 || got.Requests != 1 {
+		t.Errorf("gotClientAborts: %d gotRequests: %d, want one of each", got.ClientAborts, got.Requests)
+	}
+	if IsClientAbort(io.ErrUnexpectedEOF) {
+		t.Errorf("io.ErrUnexpectedEOF classified as a client abort")
+	}
+}
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// defaultDrainTimeout is used when Server.DrainTimeout is zero.
+const defaultDrainTimeout = time.Second
+
+// drainRetryAfter is the Retry-After, in seconds, sent with the 503s
+// answering requests that arrive during Shutdown.
+const drainRetryAfter = "5"
+
+// Shutdown stops the server gracefully. It starts the functions given to
+// RegisterOnShutdown, closes the listeners, lets running handlers finish
+// and keeps keep-alive connections open for up to DrainTimeout, answering
+// any request that arrives on them with 503 Service Unavailable and
+// Connection: close. While it waits it logs, every DrainReportInterval,
+// the connections left and the requests still being handled; see
+// InFlight. Shutdown returns once every connection has closed, or closes
+// those left and returns ctx.Err() when ctx is done first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	drain := s.DrainTimeout
+	if drain <= 0 {
+		drain = defaultDrainTimeout
+	}
+
+	s.mu.Lock()
+	if !s.inShutdown.Swap(true) {
+		s.shutdownChLocked()
+		close(s.shutdownCh)
+		for _, f := range s.onShutdown {
+			go f()
+		}
+	}
+	s.drainDeadline = time.Now().Add(drain)
+	for ln, drained := range s.listeners {
+		if drained {
+			continue
+		}
+		(*ln).Close()
+	}
+	// wake connections parked waiting for their next request
+	for conn := range s.conns {
+		conn.SetReadDeadline(s.drainDeadline)
+	}
+	s.mu.Unlock()
+
+	started := time.Now()
+	var report <-chan time.Time
+	if interval := s.DrainReportInterval; interval >= 0 {
+		if interval == 0 {
+			interval = defaultDrainReportInterval
+		}
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		report = t.C
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		open := len(s.conns)
+		s.mu.Unlock()
+		if open == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			for conn := range s.conns {
+				conn.Close()
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		case <-report:
+			s.reportDrain(started)
+		case <-ticker.C:
+		}
+	}
+}
+
+// RegisterOnShutdown registers f to be called, in a goroutine of its own,
+// when Shutdown starts, to close resources that depend on the server such
+// as background workers or long-lived upstream connections. Shutdown does
+// not wait for f to return.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+func (s *Server) shuttingDown() bool {
+	return s.inShutdown.Load()
+}
+
+// shutdownDone returns a channel that is closed once Shutdown starts, for
+// handlers that park, such as LongPoll.
+func (s *Server) shutdownDone() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdownChLocked()
+}
+
+func (s *Server) shutdownChLocked() chan struct{} {
+	if s.shutdownCh == nil {
+		s.shutdownCh = make(chan struct{})
+	}
+	return s.shutdownCh
+}
+
+// trackListener records ln so Shutdown can close it. It reports false if
+// the server is already shutting down.
+func (s *Server) trackListener(ln *net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.listeners, ln)
+		return true
+	}
+	if s.shuttingDown() {
+		return false
+	}
+	if s.listeners == nil {
+		s.listeners = make(map[*net.Listener]bool)
+	}
+	s.listeners[ln] = false
+	if s.boundAddr == nil {
+		s.boundAddr = (*ln).Addr()
+		close(s.startedChLocked())
+	}
+	return true
+}
+
+// BoundAddr returns the address of the first listener the server started
+// serving on, which tells the port picked when Addr asked for ":0". It is
+// nil until then; wait on Started to be sure.
+func (s *Server) BoundAddr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.boundAddr
+}
+
+// Started returns a channel that is closed once the server accepts
+// connections on its first listener.
+//
+//	go srv.ListenAndServe()
+//	<-srv.Started()
+//	url := "http://" + srv.BoundAddr().String()
+func (s *Server) Started() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.startedChLocked()
+}
+
+func (s *Server) startedChLocked() chan struct{} {
+	if s.startedCh == nil {
+		s.startedCh = make(chan struct{})
+	}
+	return s.startedCh
+}
+
+// DrainListener stops accepting connections on the listener bound to
+// addr, as reported by its Addr method, while the server's other listeners
+// carry on. Connections already accepted are served as usual. The Serve
+// call for that listener returns ErrListenerDrained.
+func (s *Server) DrainListener(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ln, drained := range s.listeners {
+		if !drained && (*ln).Addr().String() == addr {
+			s.listeners[ln] = true
+			return (*ln).Close()
+		}
+	}
+	return fmt.Errorf("http: no listener on %s", addr)
+}
+
+// ListenerAddrs returns the addresses of the listeners still accepting
+// connections.
+func (s *Server) ListenerAddrs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var addrs []string
+	for ln, drained := range s.listeners {
+		if !drained {
+			addrs = append(addrs, (*ln).Addr().String())
+		}
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+func (s *Server) listenerDrained(ln *net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listeners[ln]
+}
+
+// trackConn records conn so Shutdown can wake and report on it, returning
+// its state, or forgets it again when add is false.
+func (s *Server) trackConn(conn net.Conn, add bool) *connState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !add {
+		delete(s.conns, conn)
+		return nil
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]*connState)
+	}
+	cs := newConnState(conn)
+	s.conns[conn] = cs
+	return cs
+}
+
+// armDrain bounds the wait for a connection's next request by the drain
+// deadline once Shutdown has started.
+func (s *Server) armDrain(conn net.Conn) {
+	if !s.shuttingDown() {
+		return
+	}
+	s.mu.Lock()
+	deadline := s.drainDeadline
+	s.mu.Unlock()
+	conn.SetReadDeadline(deadline)
+}
+
+// refuseDraining answers a request that arrived during Shutdown, closing
+// the connection after it.
+func (s *Server) refuseDraining(res *Response) {
+	delete(res.Headers, "Keep-Alive")
+	res.SetHeader("Connection", "close")
+	res.SetHeader("Retry-After", drainRetryAfter)
+	res.SetStatus(StatusServiceUnavailable, StatusText(StatusServiceUnavailable))
+	res.SetBody([]byte(StatusText(StatusServiceUnavailable)))
+	res.Write()
+}
@@ -0,0 +1,66 @@
+package http_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestSignedURLs(t *testing.T) {
+	signer := &http.SignedURLs{Key: []byte("0123456789abcdef0123456789abcdef")}
+	h := signer.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.SetBody([]byte("ok"))
+		w.Write()
+	}))
+	sign := func(method, target string, expires time.Time) string {
+		u, err := signer.Sign(method, target, expires)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	later, earlier := time.Now().Add(time.Hour), time.Now().Add(-time.Minute)
+	get := sign(http.MethodGet, "/files/a.txt?v=1", later)
+	forged, _ := (&http.SignedURLs{Key: []byte("another key")}).Sign(http.MethodGet, "/files/a.txt", later)
+
+	tests := []struct {
+		method, target string
+		code           int
+		body           string
+	}{
+		{http.MethodGet, get, 200, "ok"},
+		{http.MethodHead, get, 200, "ok"},
+		{http.MethodPut, get, 403, "Forbidden"},
+		{http.MethodGet, strings.Replace(get, "a.txt", "b.txt", 1), 403, "Forbidden"},
+		{http.MethodGet, strings.Replace(get, "v=1", "v=2", 1), 403, "Forbidden"},
+		{http.MethodGet, get + "&extra=1", 403, "Forbidden"},
+		{http.MethodGet, "/files/a.txt", 403, "Forbidden"},
+		{http.MethodGet, forged, 403, "Forbidden"},
+		{http.MethodGet, sign(http.MethodGet, "/files/a.txt", earlier), 403, "Link Expired"},
+		{http.MethodPut, sign(http.MethodPut, "http://example.com/files/up.bin", later), 200, "ok"},
+	}
+	for i, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.target, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.code || string(rec.Body) != tt.body {
+			t.Errorf("#%d: %s %s gotCode: %d gotBody: %q wantCode: %d wantBody: %q", i, tt.method, tt.target, rec.Code, rec.Body, tt.code, tt.body)
+		}
+	}
+	if _, err := (&http.SignedURLs{}).Sign(http.MethodGet, "/", later); err == nil {
+		t.Error("signing without a key: gotErr: nil")
+	}
+
+	signer.AllowUnsigned = true
+	for target, code := range map[string]int{"/files/a.txt": 200, get + "x": 403} {
+		req, _ := http.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != code {
+			t.Errorf("AllowUnsigned %s: gotCode: %d wantCode: %d", target, rec.Code, code)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"strings"
+	"testing"
+)
+
+// The smuggled request each vector hides behind its first one. A server
+// that reads the first request's framing differently from a proxy in
+// front of it would serve this as a request of its own.
+const smuggled = "GET /smuggled HTTP/1.1\r\nHost: a\r\n\r\n"
+
+var smugglingTest = []struct {
+	name   string
+	stream string
+	status string // the strict server's answer to the first request
+}{
+	{"CL.TE", "POST / HTTP/1.1\r\nContent-Length: 6\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n" + smuggled, "400"},
+	{"TE.CL", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nContent-Length: 3\r\n\r\n8\r\nSMUGGLED\r\n0\r\n\r\n" + smuggled, "400"},
+	{"TE obfuscated", "POST / HTTP/1.1\r\nTransfer-Encoding: xchunked\r\nContent-Length: 4\r\n\r\n0\r\n\r\n" + smuggled, "501"},
+	{"TE repeated", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\nTransfer-Encoding: identity\r\n\r\n0\r\n\r\n" + smuggled, "501"},
+	{"TE space before colon", "POST / HTTP/1.1\r\nTransfer-Encoding : chunked\r\nContent-Length: 4\r\n\r\n0\r\n\r\n" + smuggled, "400"},
+	{"TE folded", "POST / HTTP/1.1\r\nTransfer-Encoding:\r\n chunked\r\n\r\n0\r\n\r\n" + smuggled, "400"},
+	{"TE after bare CR", "POST / HTTP/1.1\r\nX: a\rTransfer-Encoding: chunked\r\nContent-Length: 4\r\n\r\n0\r\n\r\n" + smuggled, "400"},
+	{"CL signed", "POST / HTTP/1.1\r\nContent-Length: +4\r\n\r\nabcd" + smuggled, "400"},
+	{"CL list", "POST / HTTP/1.1\r\nContent-Length: 4, 40\r\n\r\nabcd" + smuggled, "400"},
+	{"CL conflicting", "POST / HTTP/1.1\r\nContent-Length: 4\r\nContent-Length: 40\r\n\r\nabcd" + smuggled, "400"},
+	{"chunk size space", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n0 \r\n\r\n" + smuggled, "400"},
+	{"chunk size hex prefix", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n0x0\r\n\r\n" + smuggled, "400"},
+	{"chunk size overflow", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n10000000000000000\r\n\r\n" + smuggled, "400"},
+	{"chunk bare LF", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\nabc\n0\n\n" + smuggled, "400"},
+	{"chunk ext syntax", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3;a b\r\nabc\r\n0\r\n\r\n" + smuggled, "400"},
+	{"chunk ext volume", "POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		strings.Repeat("1;"+strings.Repeat("x", 1000)+"\r\na\r\n", 5) + "0\r\n\r\n" + smuggled, "400"},
+	{"request line bare LF", "POST / HTTP/1.1\nContent-Length: 0\n\n" + smuggled, "400"},
+}
+
+// TestRequestSmuggling runs known request smuggling vectors against a
+// server in strict mode: each must be refused with the connection closed,
+// so nothing hidden in the stream is ever served.
+func TestRequestSmuggling(t *testing.T) {
+	for _, tt := range smugglingTest {
+		var served []string
+		s := &Server{StrictParsing: true, AllowBareLF: true, Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+			served = append(served, r.Path)
+			w.Write()
+		})}
+		conn := &bufConn{benchConn: benchConn{r: strings.NewReader(tt.stream)}}
+		s.handleConn(conn)
+
+		out := conn.w.String()
+		if !strings.HasPrefix(out, "HTTP/1.1 "+tt.status+" ") {
+			t.Errorf("%s: gotResponse: %q wantStatus: %s", tt.name, out, tt.status)
+		}
+		if len(served) > 0 {
+			t.Errorf("%s: served %q", tt.name, served)
+		}
+	}
+}
+
+// TestStrictParsingAccepts checks that strict mode still serves
+// well-formed pipelined requests.
+func TestStrictParsingAccepts(t *testing.T) {
+	var bodies []string
+	s := &Server{StrictParsing: true, Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		bodies = append(bodies, string(r.Body))
+		w.Write()
+	})}
+	stream := "POST / HTTP/1.1\r\nContent-Length: 3\r\nX: a\tb\r\n\r\nabc" +
+		"POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3;name=val;flag;q=\"x y\"\r\ndef\r\n0\r\n\r\n" +
+		"GET / HTTP/1.1\r\nConnection: close\r\n\r\n"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+	if got := strings.Join(bodies, ","); got != "abc,def," {
+		t.Errorf("gotBodies: %q wantBodies: %q", got, "abc,def,")
+	}
+}
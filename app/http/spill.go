@@ -0,0 +1,97 @@
+package http
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultSpillThreshold is how much a SpillBuffer holds in memory when its
+// Threshold is zero.
+const DefaultSpillThreshold = 1 << 20
+
+// SpillBuffer accumulates a body whose length must be known before it can
+// be sent, keeping up to Threshold bytes in memory and moving everything
+// to a temporary file beyond that. Close removes the file.
+//
+// Write everything first, then read it back; writing after the first Read
+// is not supported.
+type SpillBuffer struct {
+	Threshold int64  // zero means DefaultSpillThreshold
+	Dir       string // directory for the temporary file, os.TempDir if empty
+
+	mem bytes.Buffer
+	f   *os.File
+	n   int64
+	rd  io.Reader // set by the first Read
+}
+
+func (b *SpillBuffer) threshold() int64 {
+	if b.Threshold > 0 {
+		return b.Threshold
+	}
+	return DefaultSpillThreshold
+}
+
+func (b *SpillBuffer) Write(p []byte) (int, error) {
+	if b.f == nil && b.n+int64(len(p)) > b.threshold() {
+		f, err := os.CreateTemp(b.Dir, "http-spill-*")
+		if err != nil {
+			return 0, err
+		}
+		b.f = f
+		if _, err := b.mem.WriteTo(f); err != nil {
+			return 0, err
+		}
+		// don't keep the grown buffer around once on disk
+		b.mem = bytes.Buffer{}
+	}
+	var n int
+	var err error
+	if b.f != nil {
+		n, err = b.f.Write(p)
+	} else {
+		n, err = b.mem.Write(p)
+	}
+	b.n += int64(n)
+	return n, err
+}
+
+// Len returns the number of bytes written.
+func (b *SpillBuffer) Len() int64 {
+	return b.n
+}
+
+// Spilled reports whether the contents moved to a temporary file.
+func (b *SpillBuffer) Spilled() bool {
+	return b.f != nil
+}
+
+// Read reads back what was written, from the beginning.
+func (b *SpillBuffer) Read(p []byte) (int, error) {
+	if b.rd == nil {
+		if b.f != nil {
+			b.rd = io.NewSectionReader(b.f, 0, b.n)
+		} else {
+			b.rd = bytes.NewReader(b.mem.Bytes())
+		}
+	}
+	return b.rd.Read(p)
+}
+
+// Close releases the memory and removes the temporary file, if any.
+func (b *SpillBuffer) Close() error {
+	b.mem = bytes.Buffer{}
+	b.n = 0
+	b.rd = nil
+	if b.f == nil {
+		return nil
+	}
+	f := b.f
+	b.f = nil
+	err := f.Close()
+	if rerr := os.Remove(f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+var spillBufferTest = []struct {
+	threshold int64
+	writes    []string
+	spilled   bool
+}{
+	{0, []string{"hello", " world"}, false},
+	{8, []string{"hello", " world"}, true},
+	{4, []string{"hello"}, true},
+	{5, []string{"hello"}, false},
+}
+
+func TestSpillBuffer(t *testing.T) {
+	dir := t.TempDir()
+	for i, tt := range spillBufferTest {
+		b := &SpillBuffer{Threshold: tt.threshold, Dir: dir}
+		for _, w := range tt.writes {
+			b.Write([]byte(w))
+		}
+		want := strings.Join(tt.writes, "")
+		if b.Spilled() != tt.spilled || b.Len() != int64(len(want)) {
+			t.Errorf("#%d: gotSpilled: %v gotLen: %d wantSpilled: %v wantLen: %d", i, b.Spilled(), b.Len(), tt.spilled, len(want))
+		}
+		got, _ := io.ReadAll(b)
+		if string(got) != want {
+			t.Errorf("#%d: gotContents: %q wantContents: %q", i, got, want)
+		}
+		b.Close()
+		if files, _ := os.ReadDir(dir); len(files) != 0 {
+			t.Errorf("#%d: %d temporary files left after Close", i, len(files))
+		}
+	}
+}
+
+// bufConn is a benchConn that keeps what is written.
+type bufConn struct {
+	benchConn
+	w bytes.Buffer
+}
+
+func (c *bufConn) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+func TestSetBodyReader(t *testing.T) {
+	body := strings.Repeat("streamed body ", 1000)
+	for i, encoding := range []string{"", "gzip"} {
+		req, _ := NewRequest(MethodGet, "/", nil)
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		conn := &bufConn{}
+		res := NewResponse(conn, req)
+		res.SetBodyReader(strings.NewReader(body), int64(len(body)))
+		if err := res.Write(); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if res.BytesWritten() != int64(conn.w.Len()) {
+			t.Errorf("#%d: gotBytesWritten: %d wantBytesWritten: %d", i, res.BytesWritten(), conn.w.Len())
+		}
+
+		cr, err := ReadResponse(bufio.NewReader(&conn.w), req)
+		if err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		r := io.Reader(cr.Body)
+		if encoding == "gzip" {
+			if r, err = gzip.NewReader(r); err != nil {
+				t.Errorf("#%d: unexpected error: %v", i, err)
+				continue
+			}
+		}
+		if got, _ := io.ReadAll(r); string(got) != body {
+			t.Errorf("#%d: gotBody: %d bytes wantBody: %d bytes", i, len(got), len(body))
+		}
+	}
+
+	// a body shorter than promised must not leave the connection reusable
+	res := NewResponse(&bufConn{}, nil)
+	res.SetBodyReader(strings.NewReader("short"), 10)
+	if err := res.Write(); err != io.ErrUnexpectedEOF || !res.broken {
+		t.Errorf("gotErr: %v gotBroken: %v", err, res.broken)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := dir + "/a.txt"
+	write := func(s string, fail error) error {
+		return writeFileAtomic(name, 0640, func(w io.Writer) error {
+			io.WriteString(w, s)
+			return fail
+		})
+	}
+	if err := write("first", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := write("partial", io.ErrUnexpectedEOF); err != io.ErrUnexpectedEOF {
+		t.Errorf("gotErr: %v wantErr: %v", err, io.ErrUnexpectedEOF)
+	}
+	if b, _ := os.ReadFile(name); string(b) != "first" {
+		t.Errorf("failed write: gotContents: %q wantContents: %q", b, "first")
+	}
+
+	// readers racing a rewrite see one version or the other in full
+	long := strings.Repeat("x", 1<<20)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			if err := write(long, nil); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		if b, err := os.ReadFile(name); err != nil || (string(b) != "first" && string(b) != long) {
+			t.Fatalf("gotLen: %d gotErr: %v", len(b), err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("gotEntries: %v wantEntries: a.txt", entries)
+	}
+	if fi, _ := os.Stat(name); fi.Mode().Perm() != 0640 {
+		t.Errorf("gotMode: %v wantMode: %v", fi.Mode().Perm(), os.FileMode(0640))
+	}
+}
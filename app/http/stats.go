@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// Stats is a point-in-time snapshot of a Server's runtime counters.
+type Stats struct {
+	OpenConns      int64 // accepted connections not yet closed
+	IdleConns      int64 // keep-alive connections waiting for their next request
+	ActiveHandlers int64 // handlers currently running
+
+	Requests     uint64 // requests handed to the handler since start
+	ParseErrors  uint64 // requests rejected before reaching the handler
+	ClientAborts uint64 // responses cut short by the client going away
+	BytesRead    uint64 // bytes read from all connections
+	BytesWritten uint64 // bytes written to all connections
+	IdleReaped   uint64 // idle connections closed by the idle reaper
+
+	// connections accepted by a NewAutoTLSListener, by the protocol the
+	// client turned out to speak
+	TLSDetected   uint64
+	PlainDetected uint64
+
+	Draining bool       // Shutdown has started
+	InFlight []InFlight // requests being handled, oldest first
+}
+
+type serverStats struct {
+	openConns      atomic.Int64
+	idleConns      atomic.Int64
+	activeHandlers atomic.Int64
+	requests       atomic.Uint64
+	parseErrors    atomic.Uint64
+	clientAborts   atomic.Uint64
+	bytesRead      atomic.Uint64
+	bytesWritten   atomic.Uint64
+	idleReaped     atomic.Uint64
+	tlsDetected    atomic.Uint64
+	plainDetected  atomic.Uint64
+}
+
+// Stats returns a snapshot of the server's counters. It is safe to call
+// concurrently with Serve; the fields are read independently, so the
+// snapshot is not atomic as a whole.
+func (s *Server) Stats() Stats {
+	return Stats{
+		OpenConns:      s.stats.openConns.Load(),
+		IdleConns:      s.stats.idleConns.Load(),
+		ActiveHandlers: s.stats.activeHandlers.Load(),
+		Requests:       s.stats.requests.Load(),
+		ParseErrors:    s.stats.parseErrors.Load(),
+		ClientAborts:   s.stats.clientAborts.Load(),
+		BytesRead:      s.stats.bytesRead.Load(),
+		BytesWritten:   s.stats.bytesWritten.Load(),
+		IdleReaped:     s.stats.idleReaped.Load(),
+		TLSDetected:    s.stats.tlsDetected.Load(),
+		PlainDetected:  s.stats.plainDetected.Load(),
+		Draining:       s.shuttingDown(),
+		InFlight:       s.InFlight(),
+	}
+}
+
+// countingConn feeds the byte counters for every read and write on the
+// underlying connection, and keeps a per-connection read total so the
+// server can attribute bytes to individual requests.
+type countingConn struct {
+	net.Conn
+	stats *serverStats
+
+	// only touched by the goroutine serving the connection
+	read int64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read += int64(n)
+	c.stats.bytesRead.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.stats.bytesWritten.Add(uint64(n))
+	return n, err
+}
+
+func (c *countingConn) writeBuffers(bufs net.Buffers) (int64, error) {
+	n, err := writeBuffers(c.Conn, bufs)
+	c.stats.bytesWritten.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TimeoutHandler runs h with a time limit of dt. When h hasn't written
+// its response by then, its request context is cancelled and the client
+// gets 503 Service Unavailable with msg as the body, the status text when
+// msg is empty; writes h makes after that return ErrHandlerTimeout. The
+// response is held back until h returns, so it doesn't suit handlers that
+// stream through SetBodyReader.
+func TimeoutHandler(h Handler, dt time.Duration, msg string) Handler {
+	if msg == "" {
+		msg = StatusText(StatusServiceUnavailable)
+	}
+	m := &middlewareHandler{name: "TimeoutHandler", next: h, timeout: dt}
+	m.serve = func(w ResponseWriter, r *Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), dt)
+		defer cancel()
+		// h may keep running after we return, when r is recycled
+		r = r.Clone(ctx)
+
+		tw := &timeoutWriter{code: StatusOK, headers: map[string]string{}}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			h.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		case <-ctx.Done():
+		}
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		select {
+		case <-done:
+		default:
+			if !tw.wrote {
+				tw.timedOut = true
+				if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					w.SetStatus(StatusServiceUnavailable, StatusText(StatusServiceUnavailable))
+					w.SetBody([]byte(msg))
+					w.Write()
+				}
+				return
+			}
+			// written in time, just not returned yet
+			tw.timedOut = true
+		}
+		for k, v := range tw.headers {
+			w.SetHeader(k, v)
+		}
+		w.SetStatus(tw.code, tw.text)
+		w.SetBody(tw.body)
+		if tw.wrote {
+			w.Write()
+		}
+	}
+	return m
+}
+
+// timeoutWriter holds a TimeoutHandler's response until it is sent.
+type timeoutWriter struct {
+	mu       sync.Mutex
+	code     int
+	text     string
+	headers  map[string]string
+	body     []byte
+	wrote    bool
+	timedOut bool
+}
+
+func (tw *timeoutWriter) SetStatus(code int, text string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.timedOut {
+		tw.code, tw.text = code, text
+	}
+}
+
+func (tw *timeoutWriter) SetHeader(key, value string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.timedOut {
+		tw.headers[key] = value
+	}
+}
+
+func (tw *timeoutWriter) SetBody(body []byte) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.timedOut {
+		tw.body = body
+	}
+}
+
+func (tw *timeoutWriter) GetBody() []byte {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.body
+}
+
+func (tw *timeoutWriter) GetHeader(key string) string {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	return tw.headers[key]
+}
+
+func (tw *timeoutWriter) Write() error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return ErrHandlerTimeout
+	}
+	tw.wrote = true
+	return nil
+}
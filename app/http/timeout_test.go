@@ -0,0 +1,42 @@
+package http_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func TestTimeoutHandler(t *testing.T) {
+	release := make(chan struct{})
+	writeErr := make(chan error, 1)
+	h := http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Path == "/slow" {
+			<-r.Context().Done()
+			<-release
+		}
+		w.SetHeader("X-Handler", "1")
+		w.SetBody([]byte("done"))
+		writeErr <- w.Write()
+	}), 20*time.Millisecond, "too slow")
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/fast", nil)
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || string(rec.Body) != "done" || rec.GetHeader("X-Handler") != "1" || <-writeErr != nil {
+		t.Errorf("fast: gotCode: %d gotBody: %q", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/slow", nil)
+	h.ServeHTTP(rec, req)
+	close(release)
+	if rec.Code != http.StatusServiceUnavailable || string(rec.Body) != "too slow" || rec.GetHeader("X-Handler") != "" {
+		t.Errorf("slow: gotCode: %d gotBody: %q", rec.Code, rec.Body)
+	}
+	if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+		t.Errorf("slow: gotWriteErr: %v wantWriteErr: %v", err, http.ErrHandlerTimeout)
+	}
+}
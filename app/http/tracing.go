@@ -0,0 +1,142 @@
+package http
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Tracer starts spans around served requests. It mirrors the small part of
+// the OpenTelemetry trace API the server needs, so an otel Tracer can be
+// adapted in a few lines without this package depending on it. The
+// context Start returns should carry the new span's TraceContext, see
+// ContextWithTraceContext, so outbound requests name it as their parent.
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetName(name string)
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// TraceContext is the parsed form of a W3C traceparent header.
+// See: https://www.w3.org/TR/trace-context/#traceparent-header
+type TraceContext struct {
+	TraceID    [16]byte
+	ParentID   [8]byte
+	Flags      byte
+	TraceState string // raw tracestate header, passed through untouched
+}
+
+// Sampled reports whether the caller recorded the trace.
+func (tc TraceContext) Sampled() bool { return tc.Flags&0x01 != 0 }
+
+// String formats tc as a traceparent header value.
+func (tc TraceContext) String() string {
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(tc.TraceID[:]), hex.EncodeToString(tc.ParentID[:]), tc.Flags)
+}
+
+// ParseTraceparent parses a version 00 traceparent header value.
+// All-zero trace or parent ids are invalid per the spec.
+func ParseTraceparent(v string) (tc TraceContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return tc, false
+	}
+	// future versions may append fields, version 00 must have exactly four
+	if parts[0] == "00" && len(parts) != 4 {
+		return tc, false
+	}
+	if !decodeHex(tc.TraceID[:], parts[1]) || !decodeHex(tc.ParentID[:], parts[2]) {
+		return tc, false
+	}
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return tc, false
+	}
+	tc.Flags = flags[0]
+	if tc.TraceID == [16]byte{} || tc.ParentID == [8]byte{} {
+		return tc, false
+	}
+	return tc, true
+}
+
+// decodeHex decodes lowercase hex s into exactly len(dst) bytes.
+func decodeHex(dst []byte, s string) bool {
+	if len(s) != 2*len(dst) || strings.ToLower(s) != s {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
+
+type traceContextKey struct{}
+
+// ContextWithTraceContext returns a copy of ctx carrying tc.
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceContextFromContext returns the trace context propagated from the
+// incoming request, if any.
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// InjectTraceContext writes the trace context carried by ctx into h, so
+// outbound calls made while serving a request continue the same trace.
+// Transport does it for requests without a Traceparent of their own.
+func InjectTraceContext(ctx context.Context, h Header) {
+	tc, ok := TraceContextFromContext(ctx)
+	if !ok {
+		return
+	}
+	h.Set("Traceparent", tc.String())
+	if tc.TraceState != "" {
+		h.Set("Tracestate", tc.TraceState)
+	}
+}
+
+// startSpan propagates traceparent into the request context and, when a
+// Tracer is configured, starts the request span, unless the caller sent
+// the trace unsampled. The returned func ends the span once the handler
+// has produced res.
+func (s *Server) startSpan(req *Request) (*Request, func(res *Response)) {
+	ctx := req.Context()
+	tc, ok := ParseTraceparent(req.Header.Get("Traceparent"))
+	if ok {
+		tc.TraceState = req.Header.Get("Tracestate")
+		ctx = ContextWithTraceContext(ctx, tc)
+	}
+
+	// set in place rather than copying, the server owns req
+	if s.Tracer == nil || ok && !tc.Sampled() {
+		req.ctx = ctx
+		return req, func(*Response) {}
+	}
+
+	ctx, span := s.Tracer.Start(ctx, "HTTP "+req.Method)
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.path", req.Path)
+	span.SetAttribute("network.protocol.version", strings.TrimPrefix(req.Proto, "HTTP/"))
+	req.ctx = ctx
+
+	return req, func(res *Response) {
+		// the mux records the matched route on the request it was handed
+		if req.RouteName != "" {
+			span.SetName(req.Method + " " + req.RouteName)
+			span.SetAttribute("http.route", req.RouteName)
+		}
+		span.SetAttribute("http.response.status_code", res.StatusCode)
+		if res.StatusCode >= 500 {
+			span.RecordError(fmt.Errorf("http: handler responded %d %s", res.StatusCode, res.StatusText))
+		}
+		span.End()
+	}
+}
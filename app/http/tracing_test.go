@@ -0,0 +1,189 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var parseTraceparentTest = []struct {
+	header string
+	ok     bool
+}{
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+	{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+	{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+	{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+	{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+	{"", false},
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for i, tt := range parseTraceparentTest {
+		tc, ok := ParseTraceparent(tt.header)
+		if ok != tt.ok {
+			t.Errorf("#%d: gotOk: %t wantOk: %t", i, ok, tt.ok)
+			continue
+		}
+		if ok && tc.String() != tt.header {
+			t.Errorf("#%d: gotString: %q wantString: %q", i, tc.String(), tt.header)
+		}
+	}
+}
+
+// recordingTracer records the spans it starts. Each span continues the
+// trace in its context, or starts one, and carries its own TraceContext
+// in the context it returns, as a Tracer adapter should.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpan struct {
+	name   string
+	tc     TraceContext
+	parent [8]byte
+	attrs  map[string]any
+	errs   []error
+	ended  bool
+}
+
+func (rt *recordingTracer) Start(ctx context.Context, spanName string) (context.Context, Span) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	sp := &recordedSpan{name: spanName, attrs: map[string]any{}}
+	sp.tc.Flags = 0x01
+	sp.tc.TraceID = [16]byte{0xaa}
+	if parent, ok := TraceContextFromContext(ctx); ok {
+		sp.tc = parent
+		sp.parent = parent.ParentID
+	}
+	sp.tc.ParentID = [8]byte{0xbb, byte(len(rt.spans) + 1)}
+	rt.spans = append(rt.spans, sp)
+	return ContextWithTraceContext(ctx, sp.tc), sp
+}
+
+func (sp *recordedSpan) SetName(name string)                { sp.name = name }
+func (sp *recordedSpan) SetAttribute(key string, value any) { sp.attrs[key] = value }
+func (sp *recordedSpan) RecordError(err error)              { sp.errs = append(sp.errs, err) }
+func (sp *recordedSpan) End()                               { sp.ended = true }
+
+func TestTracerSpans(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleNamed("/items/{id}", "/items/", HandlerFunc(func(w ResponseWriter, r *Request) { w.Write() }))
+	mux.HandleFunc("/fail", func(w ResponseWriter, r *Request) {
+		w.SetStatus(StatusBadGateway, StatusText(StatusBadGateway))
+		w.Write()
+	})
+	tracer := &recordingTracer{}
+	s := &Server{Handler: mux, Tracer: tracer}
+	stream := "GET /items/7 HTTP/1.1\r\n\r\nPOST /fail HTTP/1.1\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"
+	s.handleConn(&bufConn{benchConn: benchConn{r: strings.NewReader(stream)}})
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("gotSpans: %d wantSpans: 2", len(tracer.spans))
+	}
+	get, fail := tracer.spans[0], tracer.spans[1]
+	if get.name != "GET /items/{id}" || get.attrs["http.route"] != "/items/{id}" || get.attrs["url.path"] != "/items/7" ||
+		get.attrs["http.response.status_code"] != 200 || len(get.errs) != 0 || !get.ended {
+		t.Errorf("gotSpan: %+v", get)
+	}
+	if fail.name != "POST /fail" || fail.attrs["http.response.status_code"] != StatusBadGateway || len(fail.errs) != 1 || !fail.ended {
+		t.Errorf("gotSpan: %+v", fail)
+	}
+}
+
+const (
+	testTraceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID = "00f067aa0ba902b7"
+)
+
+var tracePropagationTest = []struct {
+	tracer    bool
+	sent      string // the incoming traceparent, "" for none
+	spans     int
+	forwarded string // the traceparent the outbound request carries
+}{
+	{false, "", 0, ""},
+	{false, "00-" + testTraceID + "-" + testParentID + "-01", 0, "00-" + testTraceID + "-" + testParentID + "-01"},
+	{true, "00-" + testTraceID + "-" + testParentID + "-01", 1, "00-" + testTraceID + "-bb01000000000000-01"},
+	{true, "", 1, "00-aa000000000000000000000000000000-bb01000000000000-01"},
+	// unsampled: no span, and the caller's decision is passed on
+	{true, "00-" + testTraceID + "-" + testParentID + "-00", 0, "00-" + testTraceID + "-" + testParentID + "-00"},
+}
+
+func TestTracePropagation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &Server{Handler: HandlerFunc(func(w ResponseWriter, r *Request) {
+		w.SetBody([]byte(r.Header.Get("Traceparent")))
+		w.Write()
+	})}
+	go upstream.Serve(ln)
+	defer func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		upstream.Shutdown(ctx)
+	}()
+
+	target, _ := url.Parse("http://" + ln.Addr().String())
+	proxy := NewSingleHostReverseProxy(target)
+	proxy.Transport = &Transport{}
+	mux := NewServeMux()
+	mux.HandleFunc("/call", func(w ResponseWriter, r *Request) {
+		out, _ := NewRequest(MethodGet, target.String()+"/", nil)
+		res, err := (&Client{Transport: &Transport{}}).Do(out.WithContext(r.Context()))
+		if err != nil {
+			w.SetStatus(StatusBadGateway, err.Error())
+			w.Write()
+			return
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		w.SetBody(body)
+		w.Write()
+	})
+	mux.Handle("/proxy/", proxy)
+
+	for i, tt := range tracePropagationTest {
+		for _, path := range []string{"/call", "/proxy/"} {
+			tracer := &recordingTracer{}
+			s := &Server{Handler: mux}
+			if tt.tracer {
+				s.Tracer = tracer
+			}
+			req := "GET " + path + " HTTP/1.1\r\nConnection: close\r\n"
+			if tt.sent != "" {
+				req += "Traceparent: " + tt.sent + "\r\n"
+			}
+			conn := &bufConn{benchConn: benchConn{r: strings.NewReader(req + "\r\n")}}
+			s.handleConn(conn)
+
+			res, err := ReadResponse(bufio.NewReader(&conn.w), &Request{Method: MethodGet})
+			if err != nil {
+				t.Errorf("#%d %s: unexpected error: %v", i, path, err)
+				continue
+			}
+			body, _ := io.ReadAll(res.Body)
+			if string(body) != tt.forwarded {
+				t.Errorf("#%d %s: gotForwarded: %q wantForwarded: %q", i, path, body, tt.forwarded)
+			}
+			if len(tracer.spans) != tt.spans {
+				t.Errorf("#%d %s: gotSpans: %d wantSpans: %d", i, path, len(tracer.spans), tt.spans)
+			}
+			if tt.spans > 0 && tt.sent != "" && hex.EncodeToString(tracer.spans[0].parent[:]) != testParentID {
+				t.Errorf("#%d %s: gotParent: %x wantParent: %s", i, path, tracer.spans[0].parent, testParentID)
+			}
+		}
+	}
+}
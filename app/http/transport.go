@@ -0,0 +1,377 @@
+package http
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxIdleConnsPerHost is used when Transport.MaxIdleConnsPerHost is
+// zero.
+const DefaultMaxIdleConnsPerHost = 2
+
+// DefaultTransport is used by clients without a Transport of their own.
+var DefaultTransport = &Transport{IdleConnTimeout: 90 * time.Second}
+
+// Transport sends requests over pooled keep-alive connections. Idle
+// connections are kept per scheme and host, so repeated calls to the same
+// upstream reuse a connection instead of dialing each time.
+type Transport struct {
+	// TLSConfig is used for https URLs. A nil config uses the defaults.
+	TLSConfig *tls.Config
+
+	// MaxIdleConnsPerHost caps the idle connections kept per host.
+	// DefaultMaxIdleConnsPerHost is used when zero.
+	MaxIdleConnsPerHost int
+
+	// IdleConnTimeout closes connections that stay idle longer than this.
+	// Zero means no limit.
+	IdleConnTimeout time.Duration
+
+	// DisableKeepAlives uses a fresh connection for every request.
+	DisableKeepAlives bool
+
+	// DisableCompression stops the transport from requesting gzip. When
+	// it asked for gzip itself, the transport decodes the body and the
+	// caller only ever sees the uncompressed bytes.
+	DisableCompression bool
+
+	// Resolver, if set, looks up host names instead of the OS resolver;
+	// see CachingResolver. Hosts given as IP addresses are dialed as is.
+	Resolver Resolver
+
+	// FallbackDelay is how long a connection attempt gets before the next
+	// address is tried alongside it, racing IPv6 and IPv4 addresses as in
+	// RFC 8305. DefaultFallbackDelay is used when zero, negative tries the
+	// addresses one after another.
+	FallbackDelay time.Duration
+
+	mu   sync.Mutex
+	idle map[string][]*persistConn // most recently used last
+}
+
+// persistConn is a connection the transport may reuse once the response
+// it carries has been fully read.
+type persistConn struct {
+	key    string
+	conn   net.Conn
+	br     *bufio.Reader
+	idleAt time.Time
+	reused bool
+}
+
+// RoundTrip sends a single request and returns its response. It does not
+// follow redirects. Cancelling the request's context aborts dialing,
+// writing and reading, including reads of the returned body.
+func (t *Transport) RoundTrip(req *Request) (*ClientResponse, error) {
+	ctx := req.Context()
+	key := connKey(req.URL)
+
+	extra := make(Header)
+	if t.DisableKeepAlives {
+		extra.Set("Connection", "close")
+	}
+	// only decode what we asked for; a caller that set Accept-Encoding
+	// itself gets the body as sent
+	requestedGzip := false
+	if !t.DisableCompression && req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" && req.Method != MethodHead {
+		requestedGzip = true
+		extra.Set("Accept-Encoding", "gzip")
+	}
+	// continue the trace of the request being served, if any
+	if req.Header.Get("Traceparent") == "" {
+		InjectTraceContext(ctx, extra)
+	}
+
+	for {
+		pc, err := t.getConn(ctx, key, req.URL)
+		if err != nil {
+			return nil, ctxErr(ctx, err)
+		}
+		res, sent, err := t.roundTrip(ctx, pc, req, extra)
+		if err != nil {
+			pc.conn.Close()
+			// the server may have closed an idle connection just as we
+			// picked it. Nothing was answered, so try again on a new one,
+			// unless the server may have acted on a request that isn't
+			// safe to repeat: once any of a POST is written, it isn't
+			// sent again
+			if pc.reused && isConnClosed(err) && ctx.Err() == nil && (!sent || isIdempotent(req)) {
+				continue
+			}
+			return nil, ctxErr(ctx, err)
+		}
+		if requestedGzip && strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			res.Uncompressed = true
+			res.Body = &gzipBody{body: res.Body}
+		}
+		return res, nil
+	}
+}
+
+// roundTrip sends req on pc and reads the response head. sent reports
+// whether any of the request was written, even when it failed.
+func (t *Transport) roundTrip(ctx context.Context, pc *persistConn, req *Request, extra Header) (res *ClientResponse, sent bool, err error) {
+	// cancelling ctx unblocks whatever I/O is in flight on the connection
+	if d, ok := ctx.Deadline(); ok {
+		pc.conn.SetDeadline(d)
+	}
+	stop := context.AfterFunc(ctx, func() { pc.conn.SetDeadline(aLongTimeAgo) })
+
+	cw := &countingWriter{w: pc.conn}
+	err = req.write(cw, extra)
+	sent = cw.n > 0
+	if err != nil {
+		stop()
+		return nil, sent, err
+	}
+	res, err = ReadResponse(pc.br, req)
+	if err != nil {
+		stop()
+		return nil, sent, err
+	}
+
+	framed := res.ContentLength >= 0 || isChunked(res.TransferEncoding)
+	reusable := !t.DisableKeepAlives && framed &&
+		!strings.EqualFold(res.Header.Get("Connection"), "close") &&
+		!strings.EqualFold(req.Header.Get("Connection"), "close")
+
+	body := &pooledBody{r: res.Body, t: t, pc: pc, ctx: ctx, stop: stop, reusable: reusable}
+	if res.ContentLength == 0 {
+		body.finish(true)
+		res.Body = noBody{}
+		return res, sent, nil
+	}
+	res.Body = body
+	return res, sent, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// aLongTimeAgo is a deadline in the past, for interrupting blocked I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// ctxErr reports I/O failures caused by a cancelled or expired context as
+// the context's error, so callers can errors.Is them against
+// context.DeadlineExceeded and context.Canceled.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+		return fmt.Errorf("http: request aborted: %w (%w)", ctx.Err(), cause)
+	}
+	return fmt.Errorf("http: request aborted: %w", ctx.Err())
+}
+
+func isConnClosed(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed)
+}
+
+func connKey(u *url.URL) string {
+	return u.Scheme + "://" + hostPort(u)
+}
+
+// getConn returns the most recently used idle connection for key, or
+// dials a new one.
+func (t *Transport) getConn(ctx context.Context, key string, u *url.URL) (*persistConn, error) {
+	t.mu.Lock()
+	for conns := t.idle[key]; len(conns) > 0; conns = t.idle[key] {
+		pc := conns[len(conns)-1]
+		t.idle[key] = conns[:len(conns)-1]
+		if t.IdleConnTimeout > 0 && time.Since(pc.idleAt) > t.IdleConnTimeout {
+			pc.conn.Close()
+			continue
+		}
+		t.mu.Unlock()
+		pc.reused = true
+		return pc, nil
+	}
+	t.mu.Unlock()
+
+	conn, err := t.dial(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	return &persistConn{key: key, conn: conn, br: bufio.NewReader(conn)}, nil
+}
+
+// release returns pc to the idle pool, or closes it when it cannot carry
+// another request or the pool for its host is full.
+func (t *Transport) release(pc *persistConn, reusable bool) {
+	if !reusable {
+		pc.conn.Close()
+		return
+	}
+	max := t.MaxIdleConnsPerHost
+	if max == 0 {
+		max = DefaultMaxIdleConnsPerHost
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.idle[pc.key]) >= max {
+		pc.conn.Close()
+		return
+	}
+	if t.idle == nil {
+		t.idle = make(map[string][]*persistConn)
+	}
+	pc.idleAt = time.Now()
+	t.idle[pc.key] = append(t.idle[pc.key], pc)
+}
+
+// CloseIdleConnections closes every pooled connection not currently
+// carrying a response.
+func (t *Transport) CloseIdleConnections() {
+	t.mu.Lock()
+	idle := t.idle
+	t.idle = nil
+	t.mu.Unlock()
+
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+}
+
+func (t *Transport) dial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("http: unsupported protocol scheme %q", u.Scheme)
+	}
+	conn, err := t.dialTCP(ctx, u)
+	if err != nil || u.Scheme == "http" {
+		return conn, err
+	}
+	// the name to verify is the URL's host, not the address dialed
+	cfg := t.TLSConfig.Clone()
+	if cfg == nil {
+		cfg = &tls.Config{}
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+	tc := tls.Client(conn, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
+// hostPort returns u's host with the scheme's default port filled in.
+func hostPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}
+
+// pooledBody hands its connection back to the transport once the body has
+// been read to EOF. Closing it early closes the connection, since the
+// unread remainder would corrupt the next response.
+type pooledBody struct {
+	r        io.ReadCloser
+	t        *Transport
+	pc       *persistConn
+	ctx      context.Context
+	stop     func() bool // unregisters the context cancellation
+	reusable bool
+	done     bool
+}
+
+func (b *pooledBody) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, io.EOF
+	}
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		b.finish(true)
+	} else if err != nil {
+		b.finish(false)
+		err = ctxErr(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *pooledBody) Close() error {
+	if !b.done {
+		b.finish(false)
+	}
+	return nil
+}
+
+// finish releases the connection. It is only reused when the body was
+// read completely and the context never interrupted it.
+func (b *pooledBody) finish(complete bool) {
+	b.done = true
+	interrupted := !b.stop()
+	if complete && b.reusable && !interrupted {
+		b.pc.conn.SetDeadline(time.Time{})
+		b.t.release(b.pc, true)
+		return
+	}
+	b.t.release(b.pc, false)
+}
+
+// gzipBody lazily decompresses a response body. Once the gzip stream ends
+// it reads the body to EOF so the connection can return to the pool.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (gz *gzipBody) Read(p []byte) (int, error) {
+	if gz.err != nil {
+		return 0, gz.err
+	}
+	if gz.zr == nil {
+		zr, err := gzip.NewReader(gz.body)
+		if err != nil {
+			gz.err = err
+			return 0, err
+		}
+		// a body is one gzip member; anything after it is not ours
+		zr.Multistream(false)
+		gz.zr = zr
+	}
+	n, err := gz.zr.Read(p)
+	if err == io.EOF {
+		io.Copy(io.Discard, gz.body)
+	}
+	if err != nil {
+		gz.err = err
+	}
+	return n, err
+}
+
+func (gz *gzipBody) Close() error {
+	return gz.body.Close()
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoUpstream is returned when every upstream in a pool is ejected or at
+// its connection limit.
+var ErrNoUpstream = errors.New("http: no upstream available")
+
+// BalancePolicy selects how an UpstreamPool spreads requests.
+type BalancePolicy int
+
+const (
+	RoundRobin BalancePolicy = iota
+	LeastConnections
+)
+
+// Upstream is one backend of an UpstreamPool.
+type Upstream struct {
+	URL *url.URL
+
+	// MaxConns caps the requests in flight to this upstream; zero means
+	// no limit.
+	MaxConns int
+
+	active atomic.Int64
+
+	// guarded by the pool's mu
+	fails        int
+	ejectedUntil time.Time
+}
+
+// Active returns the number of requests in flight to u.
+func (u *Upstream) Active() int64 { return u.active.Load() }
+
+// UpstreamPool balances ReverseProxy requests over several upstreams and
+// passively health checks them: an upstream failing MaxFails times in a
+// row is ejected for EjectFor, then given another chance.
+type UpstreamPool struct {
+	Upstreams []*Upstream
+	Policy    BalancePolicy
+
+	// MaxFails consecutive failures (transport errors, 502, 503, 504)
+	// eject an upstream; 3 is used when zero.
+	MaxFails int
+	// EjectFor is how long an ejected upstream is skipped; 30s when zero.
+	EjectFor time.Duration
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewUpstreamPool returns a pool over targets without connection limits.
+func NewUpstreamPool(policy BalancePolicy, targets ...*url.URL) *UpstreamPool {
+	p := &UpstreamPool{Policy: policy}
+	for _, t := range targets {
+		p.Upstreams = append(p.Upstreams, &Upstream{URL: t})
+	}
+	return p
+}
+
+// NewLoadBalancingReverseProxy returns a ReverseProxy spreading requests
+// over pool, with X-Forwarded-* headers set.
+func NewLoadBalancingReverseProxy(pool *UpstreamPool) *ReverseProxy {
+	return &ReverseProxy{
+		Upstreams: pool,
+		Rewrite:   func(pr *ProxyRequest) { pr.SetXForwarded() },
+	}
+}
+
+// acquire picks an upstream under the pool's policy and counts the request
+// against it. The caller must pass it to release.
+func (p *UpstreamPool) acquire() (*Upstream, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	n := len(p.Upstreams)
+	var best *Upstream
+	for i := 0; i < n; i++ {
+		u := p.Upstreams[(p.next+i)%n]
+		if now.Before(u.ejectedUntil) {
+			continue
+		}
+		if u.MaxConns > 0 && u.active.Load() >= int64(u.MaxConns) {
+			continue
+		}
+		if p.Policy == RoundRobin {
+			best = u
+			p.next = (p.next + i + 1) % n
+			break
+		}
+		if best == nil || u.active.Load() < best.active.Load() {
+			best = u
+		}
+	}
+	if best == nil {
+		return nil, ErrNoUpstream
+	}
+	if p.Policy == LeastConnections {
+		// rotate the starting point so ties don't all land on one upstream
+		p.next = (p.next + 1) % n
+	}
+	best.active.Add(1)
+	return best, nil
+}
+
+// release ends the request on u and records whether it succeeded.
+func (p *UpstreamPool) release(u *Upstream, failed bool) {
+	u.active.Add(-1)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !failed {
+		u.fails = 0
+		return
+	}
+	u.fails++
+	maxFails := p.MaxFails
+	if maxFails <= 0 {
+		maxFails = 3
+	}
+	if u.fails >= maxFails {
+		ejectFor := p.EjectFor
+		if ejectFor <= 0 {
+			ejectFor = 30 * time.Second
+		}
+		u.ejectedUntil = time.Now().Add(ejectFor)
+		u.fails = 0
+	}
+}
+
+// upstreamFailed reports whether a round trip counts against the
+// upstream's health.
+func upstreamFailed(res *ClientResponse, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case StatusBadGateway, StatusServiceUnavailable, StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/url"
+	"testing"
+)
+
+func testPool(policy BalancePolicy) *UpstreamPool {
+	var targets []*url.URL
+	for _, h := range []string{"a", "b", "c"} {
+		targets = append(targets, &url.URL{Scheme: "http", Host: h})
+	}
+	return NewUpstreamPool(policy, targets...)
+}
+
+func TestUpstreamPoolRoundRobin(t *testing.T) {
+	p := testPool(RoundRobin)
+	var got string
+	for i := 0; i < 6; i++ {
+		u, err := p.acquire()
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		got += u.URL.Host
+		p.release(u, false)
+	}
+	if got != "abcabc" {
+		t.Errorf("gotOrder: %q wantOrder: %q", got, "abcabc")
+	}
+}
+
+func TestUpstreamPoolEjectsFailing(t *testing.T) {
+	p := testPool(RoundRobin)
+	p.MaxFails = 2
+	b := p.Upstreams[1]
+	for i := 0; i < p.MaxFails; i++ {
+		b.active.Add(1)
+		p.release(b, true)
+	}
+	for i := 0; i < 4; i++ {
+		u, _ := p.acquire()
+		if u == b {
+			t.Errorf("#%d: ejected upstream was picked", i)
+		}
+		p.release(u, false)
+	}
+}
+
+func TestUpstreamPoolLeastConnections(t *testing.T) {
+	p := testPool(LeastConnections)
+	p.Upstreams[0].MaxConns = 1
+	held := []*Upstream{}
+	for i := 0; i < 3; i++ {
+		u, _ := p.acquire()
+		held = append(held, u)
+	}
+	for i, u := range held {
+		if u.Active() != 1 {
+			t.Errorf("#%d: %s gotActive: %d wantActive: 1", i, u.URL.Host, u.Active())
+		}
+	}
+	// a is at its limit, b and c share the next two
+	u, _ := p.acquire()
+	if u == p.Upstreams[0] {
+		t.Errorf("upstream at MaxConns was picked")
+	}
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"context"
+	"fmt"
+)
+
+// Key is a typed handle for a request-scoped value, such as the
+// authenticated principal, tenant or locale. Middleware sets it and
+// handlers read it back without type assertions:
+//
+//	var UserKey = http.NewKey[*User]("user")
+//
+//	UserKey.Set(r, user)         // in middleware
+//	user, ok := UserKey.Get(r)   // in the handler
+//
+// Values live in the request's context, so they also reach code that
+// only receives r.Context().
+type Key[T any] struct {
+	name string
+}
+
+// NewKey returns a new Key. Keys are compared by identity, so two keys
+// with the same name never collide.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string { return "http.Key(" + k.name + ")" }
+
+// Set stores v on r in place, so handlers further down the chain see it
+// without the middleware having to pass on a new *Request.
+func (k *Key[T]) Set(r *Request, v T) {
+	r.ctx = context.WithValue(r.Context(), k, v)
+}
+
+// Get returns the value stored on r, if any.
+func (k *Key[T]) Get(r *Request) (T, bool) {
+	return k.FromContext(r.Context())
+}
+
+// MustGet returns the value stored on r and panics if there is none, for
+// handlers that are only ever mounted behind the middleware setting it.
+func (k *Key[T]) MustGet(r *Request) T {
+	v, ok := k.Get(r)
+	if !ok {
+		panic(fmt.Sprintf("http: %s not set on request", k))
+	}
+	return v
+}
+
+// FromContext returns the value stored in ctx, if any.
+func (k *Key[T]) FromContext(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k).(T)
+	return v, ok
+}
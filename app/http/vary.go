@@ -0,0 +1,57 @@
+package http
+
+import "strings"
+
+// AddVary adds fields to the response's Vary header, keeping any already
+// listed there, so a shared cache keys the response on them. Use it
+// whenever a handler picks a representation from request headers:
+//
+//	AddVary(w, "Accept-Language")
+//
+// Fields already present, compared case-insensitively, are not repeated,
+// and a Vary of "*" is left alone. Merging needs a writer that can report
+// the current value, as *Response and the package's middleware can; on
+// other writers the header is set to fields alone.
+func AddVary(w ResponseWriter, fields ...string) {
+	current := responseHeader(w, "Vary")
+	if merged := mergeVary(current, fields...); merged != current {
+		w.SetHeader("Vary", merged)
+	}
+}
+
+// mergeVary returns the Vary value vary extended by fields.
+func mergeVary(vary string, fields ...string) string {
+	for _, field := range fields {
+		if strings.TrimSpace(vary) == "*" {
+			return vary
+		}
+		if field == "*" {
+			vary = "*"
+			continue
+		}
+		if hasToken(vary, field) {
+			continue
+		}
+		if strings.TrimSpace(vary) == "" {
+			vary = field
+		} else {
+			vary += ", " + field
+		}
+	}
+	return vary
+}
+
+// headerGetter is implemented by writers that can report a response
+// header set so far. It is not part of ResponseWriter; see responseHeader.
+type headerGetter interface {
+	GetHeader(key string) string
+}
+
+// responseHeader returns the value of the response header key set on w or
+// a writer it wraps, or "" if none can report it.
+func responseHeader(w ResponseWriter, key string) string {
+	if hg, ok := AsResponseWriter[headerGetter](w); ok {
+		return hg.GetHeader(key)
+	}
+	return ""
+}
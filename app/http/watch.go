@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher polls when its Interval is
+// zero.
+const DefaultWatchInterval = 2 * time.Second
+
+// FileOp is what happened to a file between two polls of a Watcher.
+type FileOp int
+
+const (
+	FileCreated FileOp = iota + 1
+	FileRemoved
+	FileModified
+)
+
+func (op FileOp) String() string {
+	switch op {
+	case FileCreated:
+		return "created"
+	case FileRemoved:
+		return "removed"
+	case FileModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// FileChange reports a file added, removed or rewritten under a watched
+// directory.
+type FileChange struct {
+	Path string // as found walking the watched directory, e.g. "public/css/app.css"
+	Op   FileOp
+}
+
+// Watcher polls directory trees for files being added, removed or
+// modified, so in-memory state derived from them, such as asset hashes or
+// cached responses, can be rebuilt without a restart:
+//
+//	w := &http.Watcher{Dirs: []string{"public"}, OnChange: func([]http.FileChange) {
+//		assets.Scan()
+//		cache.InvalidatePrefix("/assets/")
+//	}}
+//	go w.Run(ctx)
+//
+// It compares sizes and modification times rather than relying on OS
+// notifications, so it works the same on every platform and network file
+// system, at the cost of a walk per poll. Dotfiles and dot directories
+// are skipped.
+type Watcher struct {
+	Dirs []string
+
+	// Interval is the time between polls; DefaultWatchInterval when zero.
+	Interval time.Duration
+
+	// OnChange is called from Run with the changes of one poll, sorted by
+	// path, whenever there are any.
+	OnChange func([]FileChange)
+
+	// Logger receives errors walking the directories. slog.Default() is
+	// used when nil.
+	Logger *slog.Logger
+
+	files map[string]fileStamp
+}
+
+// fileStamp is what a Watcher remembers of a file to tell it changed.
+type fileStamp struct {
+	size int64
+	mod  time.Time
+}
+
+// Run polls until ctx is done. The first poll records the directories as
+// they are; changes are reported from the second on.
+func (w *Watcher) Run(ctx context.Context) {
+	w.Poll()
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if changes := w.Poll(); len(changes) > 0 && w.OnChange != nil {
+			w.OnChange(changes)
+		}
+	}
+}
+
+// Poll walks the directories once and returns what changed since the
+// previous call; the first call returns nothing. A directory that can't
+// be read counts as empty, so removing one reports its files removed.
+// Poll must not be called concurrently with itself or Run.
+func (w *Watcher) Poll() []FileChange {
+	files := make(map[string]fileStamp)
+	for _, dir := range w.Dirs {
+		err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if name != dir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			fi, err := d.Info()
+			if err != nil {
+				// removed since it was listed
+				return nil
+			}
+			files[name] = fileStamp{size: fi.Size(), mod: fi.ModTime()}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			loggerOrDefault(w.Logger).Error("watching files", "dir", dir, "err", err)
+		}
+	}
+
+	prev := w.files
+	w.files = files
+	if prev == nil {
+		return nil
+	}
+	var changes []FileChange
+	for name, st := range files {
+		old, ok := prev[name]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{name, FileCreated})
+		case old.size != st.size || !old.mod.Equal(st.mod):
+			changes = append(changes, FileChange{name, FileModified})
+		}
+	}
+	for name := range prev {
+		if _, ok := files[name]; !ok {
+			changes = append(changes, FileChange{name, FileRemoved})
+		}
+	}
+	slices.SortFunc(changes, func(a, b FileChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes
+}
@@ -0,0 +1,46 @@
+package http_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, body string) {
+		os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755)
+		os.WriteFile(filepath.Join(root, name), []byte(body), 0644)
+	}
+	write("keep.txt", "a")
+	write("edit.txt", "a")
+	write("gone/old.txt", "a")
+	w := &http.Watcher{Dirs: []string{root}}
+	if changes := w.Poll(); changes != nil {
+		t.Fatalf("first poll: gotChanges: %v", changes)
+	}
+
+	write("edit.txt", "ab")
+	write("css/new.css", "a")
+	write(".swap", "a")
+	os.RemoveAll(filepath.Join(root, "gone"))
+	want := []http.FileChange{
+		{filepath.Join(root, "css/new.css"), http.FileCreated},
+		{filepath.Join(root, "edit.txt"), http.FileModified},
+		{filepath.Join(root, "gone/old.txt"), http.FileRemoved},
+	}
+	changes := w.Poll()
+	if len(changes) != len(want) {
+		t.Fatalf("gotChanges: %v wantChanges: %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("#%d: gotChange: %v wantChange: %v", i, changes[i], want[i])
+		}
+	}
+	if changes := w.Poll(); len(changes) != 0 {
+		t.Errorf("unchanged: gotChanges: %v", changes)
+	}
+}
@@ -0,0 +1,61 @@
+package http
+
+// BaseResponseWriter is meant to be embedded by middleware that wraps the
+// ResponseWriter it is handed, such as compression or metrics. It passes
+// every call through to the wrapped writer, so a wrapper only overrides the
+// methods it intercepts, and it reports that writer from Unwrap so the
+// optional capabilities of the writer underneath stay reachable with
+// AsResponseWriter:
+//
+//	type statusRecorder struct {
+//		http.BaseResponseWriter
+//		code int
+//	}
+//
+//	func (sr *statusRecorder) SetStatus(code int, text string) {
+//		sr.code = code
+//		sr.BaseResponseWriter.SetStatus(code, text)
+//	}
+//
+//	next.ServeHTTP(&statusRecorder{BaseResponseWriter: http.BaseResponseWriter{ResponseWriter: w}}, r)
+//
+// Since SetStatus, SetHeader, SetBody and Write all go through the wrapper,
+// it sees the whole response before it reaches the connection.
+// SetBodyReader is deliberately not passed through: a streamed body would
+// bypass a wrapper that intercepts SetBody, so handlers fall back to
+// buffering unless the wrapper implements it too.
+type BaseResponseWriter struct {
+	ResponseWriter
+}
+
+// Unwrap returns the wrapped writer.
+func (b *BaseResponseWriter) Unwrap() ResponseWriter {
+	return b.ResponseWriter
+}
+
+// AsResponseWriter finds the first writer in w's chain of wrappers,
+// starting with w itself and following Unwrap, that implements T, the way
+// errors.As walks wrapped errors. Use it to reach optional capabilities
+// that a middleware in between doesn't re-implement:
+//
+//	if s, ok := http.AsResponseWriter[interface{ Status() int }](w); ok {
+//		code = s.Status()
+//	}
+//
+// Only look up capabilities that report on the response or leave its
+// bytes alone; use a plain type assertion for ones that write it, so a
+// wrapper that doesn't implement them isn't skipped.
+func AsResponseWriter[T any](w ResponseWriter) (T, bool) {
+	for w != nil {
+		if t, ok := w.(T); ok {
+			return t, true
+		}
+		u, ok := w.(interface{ Unwrap() ResponseWriter })
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,43 @@
+package http_test
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+type statusRecorder struct {
+	http.BaseResponseWriter
+	code int
+}
+
+func (sr *statusRecorder) SetStatus(code int, text string) {
+	sr.code = code
+	sr.BaseResponseWriter.SetStatus(code, text)
+}
+
+func TestBaseResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	inner := &statusRecorder{BaseResponseWriter: http.BaseResponseWriter{ResponseWriter: rec}}
+	outer := &statusRecorder{BaseResponseWriter: http.BaseResponseWriter{ResponseWriter: inner}}
+
+	outer.SetStatus(http.StatusCreated, "Created")
+	outer.SetHeader("Vary", "Accept")
+	http.AddVary(outer, "Accept-Encoding")
+	outer.Write()
+
+	if outer.code != http.StatusCreated || inner.code != http.StatusCreated {
+		t.Errorf("gotCodes: %d %d wantCode: %d", outer.code, inner.code, http.StatusCreated)
+	}
+	if got := rec.GetHeader("Vary"); got != "Accept, Accept-Encoding" {
+		t.Errorf("gotVary: %q", got)
+	}
+	w, ok := http.AsResponseWriter[interface{ Written() bool }](outer)
+	if !ok || !w.Written() {
+		t.Errorf("gotWritten: %t, %t", ok, ok && w.Written())
+	}
+	if _, ok := http.AsResponseWriter[interface{ Hijack() }](outer); ok {
+		t.Errorf("found a capability no writer has")
+	}
+}
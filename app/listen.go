@@ -0,0 +1,22 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/config"
+)
+
+// listen binds l on its network, "tcp" unless it names a family, setting
+// IPV6_V6ONLY on IPv6 sockets when asked to.
+func listen(l config.Listener) (net.Listener, error) {
+	network := l.Network
+	if network == "" {
+		network = "tcp"
+	}
+	var lc net.ListenConfig
+	if l.V6Only {
+		lc.Control = setV6Only
+	}
+	return lc.Listen(context.Background(), network, l.Addr)
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setV6Only(network, address string, c syscall.RawConn) error {
+	return errors.New("v6only is not supported on this platform")
+}
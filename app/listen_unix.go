@@ -0,0 +1,20 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// setV6Only is a net.ListenConfig.Control func setting IPV6_V6ONLY on IPv6
+// sockets; IPv4 sockets are left alone.
+func setV6Only(network, address string, c syscall.RawConn) error {
+	if network != "tcp6" {
+		return nil
+	}
+	var err error
+	if cerr := c.Control(func(fd uintptr) {
+		err = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 1)
+	}); cerr != nil {
+		return cerr
+	}
+	return err
+}
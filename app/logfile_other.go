@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "github.com/codecrafters-io/http-server-starter-go/app/http"
+
+// reopenOnSignal does nothing where there is no SIGUSR1.
+func reopenOnSignal(lf *http.RotatingFile) {}
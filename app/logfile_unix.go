@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// reopenOnSignal reopens lf on every SIGUSR1, which logrotate sends after
+// moving the file aside.
+func reopenOnSignal(lf *http.RotatingFile) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	for range c {
+		if err := lf.Reopen(); err != nil {
+			ErrorLogger.Println("reopening log file:", err)
+		}
+	}
+}
@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"net"
 	"os"
-	"path/filepath"
-	"strconv"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/codecrafters-io/http-server-starter-go/app/config"
 	"github.com/codecrafters-io/http-server-starter-go/app/http"
 )
 
@@ -16,68 +24,175 @@ var (
 	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 )
 
-var FileDirectory = "/temp/"
+// defaultDirectory is served under /files/ when no directory is given.
+const defaultDirectory = "/temp/"
 
-func getDirectoryFlag(args []string) (string, bool) {
-	for i, arg := range args {
-		if arg == "--directory" {
-			return args[i+1], true
-		}
-	}
-	return "", false
-}
+// LogLevel controls the http package logger; raise it to slog.LevelDebug to
+// trace routing and per-request diagnostics.
+var LogLevel = new(slog.LevelVar)
 
 func main() {
-	InfoLogger.Println("Logs from your program will appear here!")
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		os.Exit(runBench(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "static" {
+		os.Exit(runStatic(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sign" {
+		os.Exit(runSign(os.Args[2:], os.Getenv(envName("signing-key")), os.Stdout))
+	}
 
-	if filePath, ok := getDirectoryFlag(os.Args[1:]); ok {
-		absolutePath, err := os.Getwd()
-		if err != nil {
-			ErrorLogger.Printf("error getting current directory: %s\n", err.Error())
+	opts, err := loadOptions(os.Args[1:], os.LookupEnv, os.Stderr)
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
 		}
-		InfoLogger.Printf("current path: %s\n", absolutePath)
+		ErrorLogger.Println(err)
+		os.Exit(2)
+	}
 
-		// If filePath is absolute, use it directly; otherwise, concatenate with current directory
-		if strings.HasPrefix(filePath, "/") {
-			FileDirectory = filePath
-		} else {
-			FileDirectory = absolutePath + "/" + filePath
+	InfoLogger.Println("Logs from your program will appear here!")
+	LogLevel.Set(opts.LogLevel)
+
+	handlerOpts := &slog.HandlerOptions{Level: LogLevel}
+	var logOut io.Writer = os.Stderr
+	if opts.LogFile != "" {
+		lf := &http.RotatingFile{Path: opts.LogFile, MaxSize: opts.LogMaxSize, MaxAge: opts.LogMaxAge, MaxBackups: opts.LogMaxBackups}
+		if err := lf.Reopen(); err != nil {
+			ErrorLogger.Println(err)
+			os.Exit(1)
 		}
+		defer lf.Close()
+		go reopenOnSignal(lf)
+		logOut = lf
+	}
+	var logger *slog.Logger
+	if opts.LogFormat == "json" {
+		logger = slog.New(slog.NewJSONHandler(logOut, handlerOpts))
+	} else {
+		logger = slog.New(slog.NewTextHandler(logOut, handlerOpts))
 	}
 
-	InfoLogger.Printf("directory: %s\n", FileDirectory)
-
-	// if err := os.MkdirAll(FileDirectory, 0755); err != nil {
-	// 	ErrorLogger.Printf("error creating directory: %s\n", err.Error())
-	// 	os.Exit(1)
-	// }
-
-	serveMux := registerServeMux()
-	server := http.Server{
-		Addr:    ":4221",
-		Handler: serveMux,
+	rl, err := newReloader(opts, logger)
+	if err != nil {
+		ErrorLogger.Println(err)
+		os.Exit(1)
+	}
+	go rl.watch()
+
+	server := &http.Server{
+		Handler:              rl,
+		Logger:               logger,
+		ReadTimeout:          opts.ReadTimeout,
+		IdleTimeout:          opts.IdleTimeout,
+		SlowRequestThreshold: opts.SlowRequest,
+		MaxBodySize:          opts.MaxBodySize,
+		DisableCompression:   opts.DisableCompression,
+		JSONParseErrors:      true,
+		StrictParsing:        opts.StrictParsing,
+		RequireContentLength: opts.RequireLength,
+	}
+	if opts.AccessLog {
+		al := &http.AccessLog{Logger: logger, Exclude: opts.AccessExclude, Samples: opts.AccessSamples}
+		server.OnRequestEnd = al.Log
+	}
+	if opts.AdminAddr != "" {
+		admin := &http.Admin{Server: server, Token: opts.AdminToken, LogLevel: LogLevel, DumpTo: os.Stderr, Bulkhead: rl.bulkhead}
+		go func() {
+			if err := http.ListenAndServeAdmin(opts.AdminAddr, admin); err != nil {
+				logger.Error("admin API stopped", "addr", opts.AdminAddr, "err", err)
+			}
+		}()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		shutdownOnSignal(server, logger)
+		close(stopped)
+	}()
+	if err := serve(server, opts.Listeners, rl.certs, logger); err != http.ErrServerClosed && err != http.ErrListenerDrained {
+		log.Fatal(err)
 	}
+	<-stopped
+}
 
-	fmt.Printf("server mux : %v", serveMux)
+// shutdownTimeout bounds how long an interrupted server waits for
+// in-flight requests before closing their connections.
+const shutdownTimeout = 10 * time.Second
+
+// shutdownOnSignal shuts server down gracefully on SIGINT or SIGTERM.
+func shutdownOnSignal(server *http.Server, logger *slog.Logger) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-c
+	logger.Info("shutting down", "signal", sig.String())
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("shutdown timed out, connections closed", "err", err)
+	}
+}
 
-	log.Fatal(server.ListenAndServe())
+// serve binds every listener before serving any, so a bad address fails
+// at startup, then returns the first error from serving other than a
+// listener being drained, or http.ErrListenerDrained once all have been.
+// TLS listeners present the certificate in their slot of certs, and
+// accept plaintext too when AutoTLS is set. The
+// address actually bound is logged, which tells the port when ":0" asked
+// for any.
+func serve(server *http.Server, listeners []config.Listener, certs []*certSlot, logger *slog.Logger) error {
+	lns := make([]net.Listener, len(listeners))
+	for i, l := range listeners {
+		ln, err := listen(l)
+		if err != nil {
+			return err
+		}
+		if certs[i] != nil {
+			tc := &tls.Config{GetCertificate: certs[i].getCertificate}
+			if l.AutoTLS {
+				ln = http.NewAutoTLSListener(ln, tc)
+			} else {
+				ln = tls.NewListener(ln, tc)
+			}
+		}
+		logger.Info("listening", "addr", ln.Addr().String(), "network", ln.Addr().Network(), "tls", certs[i] != nil, "auto_tls", l.AutoTLS)
+		lns[i] = ln
+	}
+	errc := make(chan error, len(lns))
+	for _, ln := range lns {
+		go func() { errc <- server.Serve(ln) }()
+	}
+	// a drained listener stops only its own Serve
+	var err error
+	for range lns {
+		if err = <-errc; err != http.ErrListenerDrained {
+			return err
+		}
+	}
+	return err
 }
 
-func registerServeMux() *http.ServeMux {
+// registerServeMux returns the application's routes, with dir served under
+// /files/ and each of opts' mounts under its own path, using the media
+// presets of http.NewMediaHandler when opts.Media is set and the cache
+// policies of opts.CachePolicies. Its prefix routes must stay in step with
+// config.BuiltinRoutes, which keeps mounts off them; a mount that still
+// lands on a registered route is an error rather than a panic.
+func registerServeMux(dir string, opts *options, logger *slog.Logger) (*http.ServeMux, error) {
 	serveMux := http.NewServeMux()
+	serveMux.Logger = logger
 	serveMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.SetStatus(200, "OK")
 		w.SetBody([]byte(""))
 		w.Write()
 	})
 
-	serveMux.HandleFunc("/echo/", func(w http.ResponseWriter, r *http.Request) {
+	serveMux.HandleNamed("/echo/{text}", "/echo/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Printf("echo route: %s", r.Path)
 		echoText := strings.TrimPrefix(r.Path, "/echo/")
 		w.SetStatus(200, "OK")
 		w.SetBody([]byte(echoText))
 		w.Write()
-	})
+	}))
 
 	serveMux.HandleFunc("/echo/david", func(w http.ResponseWriter, r *http.Request) {
 		w.SetStatus(200, "OK")
@@ -87,44 +202,41 @@ func registerServeMux() *http.ServeMux {
 
 	serveMux.HandleFunc("/user-agent", func(w http.ResponseWriter, r *http.Request) {
 		userAgent := r.Header.Get("User-Agent")
-		w.SetStatus(200, "OK")
-		w.SetBody([]byte(userAgent))
-		w.Write()
+		http.WriteTextOrJSON(w, r, 200, userAgent, struct {
+			UserAgent string `json:"user_agent"`
+		}{userAgent})
 	})
 
-	serveMux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			fileName := strings.TrimPrefix(r.Path, "/files/")
-			path := fmt.Sprintf("%s/%s", FileDirectory, fileName)
-			fmt.Printf("path: %s", path)
-			contents, err := os.ReadFile(path)
-			if err != nil {
-				ErrorLogger.Printf("reading file error: %s", err.Error())
-				w.SetStatus(404, "Not Found")
-				w.Write()
-			}
-
-			w.SetStatus(200, "OK")
-			w.SetHeader("Content-Length", strconv.Itoa(len(contents)))
-			w.SetHeader("Content-Type", "application/octet-stream")
-			w.SetBody([]byte(contents))
-			w.Write()
-
-		case "POST":
-			fileName := strings.TrimPrefix(r.Path, "/files/")
-			path := filepath.Join(FileDirectory, fileName)
-			err := os.WriteFile(path, r.Body, 0644)
-			if err != nil {
-				fmt.Printf("err: %s", err.Error())
-				w.SetStatus(500, "Internal Server Error")
-				w.Write()
-			}
-			w.SetStatus(201, "Created")
-			fmt.Println("before response write")
-			w.Write()
+	newFiles := func(prefix, root string, quota int64) *http.FilesHandler {
+		h := http.NewFilesHandler(prefix, root)
+		if opts.Media {
+			h = http.NewMediaHandler(prefix, root)
 		}
-	})
+		h.CachePolicies = opts.CachePolicies
+		h.ListAPI = true
+		h.Quota = quota
+		h.MinFreeSpace = opts.MinFreeSpace
+		h.Logger = logger
+		return h
+	}
+	signed := func(h http.Handler) http.Handler {
+		if opts.SigningKey == "" {
+			return h
+		}
+		return (&http.SignedURLs{Key: []byte(opts.SigningKey)}).Handler(h)
+	}
+	serveMux.HandleNamed("/files/{name}", "/files/", signed(newFiles("/files/", dir, opts.FilesQuota)))
+	routed := map[string]bool{}
+	for _, route := range serveMux.Routes() {
+		routed[route.Pattern] = true
+	}
+	for _, m := range opts.Mounts {
+		if routed[m.Path] {
+			return nil, fmt.Errorf("mount %s: path is already routed", m.Path)
+		}
+		routed[m.Path] = true
+		serveMux.Handle(m.Path, signed(newFiles(m.Path, m.Dir, int64(m.Quota))))
+	}
 
-	return serveMux
+	return serveMux, nil
 }
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/config"
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// reloader serves through a mux that is rebuilt when the configuration is
+// reloaded, guarded by a bulkhead that outlives the rebuilds, and holds the
+// certificates TLS listeners present, so both can change without
+// rebinding.
+type reloader struct {
+	logger   *slog.Logger
+	bulkhead *http.Bulkhead
+
+	mu    sync.Mutex // serializes reloads
+	opts  *options
+	mux   atomic.Pointer[http.ServeMux]
+	certs []*certSlot // one per listener, nil for plaintext ones
+}
+
+// certSlot is the certificate of one TLS listener.
+type certSlot struct {
+	cert atomic.Pointer[tls.Certificate]
+}
+
+func (c *certSlot) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+func newReloader(opts *options, logger *slog.Logger) (*reloader, error) {
+	rl := &reloader{
+		logger:   logger,
+		bulkhead: &http.Bulkhead{MaxFailures: opts.RouteMaxFailures, Logger: logger},
+		opts:     opts,
+	}
+	certs, err := loadCerts(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range certs {
+		var slot *certSlot
+		if cert != nil {
+			slot = new(certSlot)
+			slot.cert.Store(cert)
+		}
+		rl.certs = append(rl.certs, slot)
+	}
+	mux, err := rl.buildMux(opts)
+	if err != nil {
+		return nil, err
+	}
+	rl.mux.Store(mux)
+	return rl, nil
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rl.bulkhead.Handler(rl.mux.Load()).ServeHTTP(w, r)
+}
+
+func (rl *reloader) buildMux(opts *options) (*http.ServeMux, error) {
+	dir := opts.Directory
+	if dir == "" {
+		dir = defaultDirectory
+	}
+	InfoLogger.Printf("directory: %s\n", dir)
+	return registerServeMux(dir, opts, rl.logger)
+}
+
+// loadCerts reads the key pair of every TLS listener, in listener order.
+func loadCerts(opts *options) ([]*tls.Certificate, error) {
+	certs := make([]*tls.Certificate, len(opts.Listeners))
+	for i, l := range opts.Listeners {
+		if l.TLSCert == "" {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(l.TLSCert, l.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %v", l.Addr, err)
+		}
+		certs[i] = &cert
+	}
+	return certs, nil
+}
+
+// watch reloads on every SIGHUP.
+func (rl *reloader) watch() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := rl.reload(); err != nil {
+			rl.logger.Error("config reload rejected, keeping the running config", "err", err)
+		}
+	}
+}
+
+// reload re-reads the command line, environment and config file and
+// applies them.
+func (rl *reloader) reload() error {
+	next, err := loadOptions(os.Args[1:], os.LookupEnv, io.Discard)
+	if err != nil {
+		return err
+	}
+	return rl.apply(next)
+}
+
+// apply switches to next. Log level, directory, mounts, the other
+// settings of file serving and TLS certificates are applied; settings that need a restart are logged and
+// otherwise ignored. Nothing is applied if next is invalid, including a
+// mux that can't be built from it.
+func (rl *reloader) apply(next *options) error {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	old := rl.opts
+	var err error
+
+	var restart []string
+	if !slices.EqualFunc(old.Listeners, next.Listeners, func(a, b config.Listener) bool {
+		return a.Addr == b.Addr && a.Network == b.Network && a.V6Only == b.V6Only && a.AutoTLS == b.AutoTLS && (a.TLSCert == "") == (b.TLSCert == "")
+	}) {
+		restart = append(restart, "listeners")
+	}
+	if old.LogFormat != next.LogFormat {
+		restart = append(restart, "log format")
+	}
+	if old.LogFile != next.LogFile || old.LogMaxSize != next.LogMaxSize || old.LogMaxAge != next.LogMaxAge || old.LogMaxBackups != next.LogMaxBackups {
+		restart = append(restart, "log file")
+	}
+	if old.ReadTimeout != next.ReadTimeout {
+		restart = append(restart, "read timeout")
+	}
+	if old.IdleTimeout != next.IdleTimeout {
+		restart = append(restart, "idle timeout")
+	}
+	if old.AdminAddr != next.AdminAddr || old.AdminToken != next.AdminToken {
+		restart = append(restart, "admin API")
+	}
+	if old.RouteMaxFailures != next.RouteMaxFailures {
+		restart = append(restart, "route max failures")
+	}
+	if old.StrictParsing != next.StrictParsing {
+		restart = append(restart, "strict parsing")
+	}
+	if old.RequireLength != next.RequireLength {
+		restart = append(restart, "require content length")
+	}
+	if old.AccessLog != next.AccessLog || !slices.Equal(old.AccessExclude, next.AccessExclude) || !slices.Equal(old.AccessSamples, next.AccessSamples) {
+		restart = append(restart, "access log")
+	}
+	if old.SlowRequest != next.SlowRequest {
+		restart = append(restart, "slow request threshold")
+	}
+	if old.MaxBodySize != next.MaxBodySize {
+		restart = append(restart, "max body size")
+	}
+	if old.DisableCompression != next.DisableCompression {
+		restart = append(restart, "compression")
+	}
+
+	// certificates are reread even when their paths are unchanged, which
+	// is how rotated files on disk get picked up
+	var certs []*tls.Certificate
+	if !slices.Contains(restart, "listeners") {
+		if certs, err = loadCerts(next); err != nil {
+			return err
+		}
+	}
+
+	var mux *http.ServeMux
+	if old.Directory != next.Directory || !slices.Equal(old.Mounts, next.Mounts) ||
+		old.FilesQuota != next.FilesQuota || old.MinFreeSpace != next.MinFreeSpace || old.SigningKey != next.SigningKey ||
+		old.Media != next.Media || !slices.EqualFunc(old.CachePolicies, next.CachePolicies, equalCachePolicy) {
+		if err := (&config.Config{Mounts: next.Mounts}).Validate(); err != nil {
+			return err
+		}
+		if mux, err = rl.buildMux(next); err != nil {
+			return err
+		}
+	}
+
+	var changes []any
+	if old.LogLevel != next.LogLevel {
+		LogLevel.Set(next.LogLevel)
+		changes = append(changes, "log_level", fmt.Sprintf("%s -> %s", old.LogLevel, next.LogLevel))
+	}
+	if mux != nil {
+		rl.mux.Store(mux)
+		if old.Directory != next.Directory {
+			changes = append(changes, "directory", fmt.Sprintf("%s -> %s", old.Directory, next.Directory))
+		}
+		if !slices.Equal(old.Mounts, next.Mounts) {
+			changes = append(changes, "mounts", fmt.Sprintf("%v -> %v", old.Mounts, next.Mounts))
+		}
+		if old.FilesQuota != next.FilesQuota {
+			changes = append(changes, "files_quota", fmt.Sprintf("%d -> %d", old.FilesQuota, next.FilesQuota))
+		}
+		if old.MinFreeSpace != next.MinFreeSpace {
+			changes = append(changes, "min_free_space", fmt.Sprintf("%d -> %d", old.MinFreeSpace, next.MinFreeSpace))
+		}
+		if old.SigningKey != next.SigningKey {
+			changes = append(changes, "signing_key", "changed")
+		}
+		if old.Media != next.Media {
+			changes = append(changes, "media", fmt.Sprintf("%t -> %t", old.Media, next.Media))
+		}
+		if !slices.EqualFunc(old.CachePolicies, next.CachePolicies, equalCachePolicy) {
+			changes = append(changes, "cache_policies", fmt.Sprintf("%d -> %d", len(old.CachePolicies), len(next.CachePolicies)))
+		}
+	}
+	for i, cert := range certs {
+		if cert != nil && rl.certs[i] != nil {
+			rl.certs[i].cert.Store(cert)
+			changes = append(changes, "tls_cert", next.Listeners[i].Addr)
+		}
+	}
+	if len(restart) > 0 {
+		changes = append(changes, "needs_restart", restart)
+	}
+
+	// keep what was applied, and the old values of what needs a restart,
+	// so the next diff is against what is actually running
+	applied := *old
+	applied.LogLevel = next.LogLevel
+	applied.Directory = next.Directory
+	applied.Mounts = next.Mounts
+	applied.FilesQuota = next.FilesQuota
+	applied.MinFreeSpace = next.MinFreeSpace
+	applied.SigningKey = next.SigningKey
+	applied.Media = next.Media
+	applied.CachePolicies = next.CachePolicies
+	if certs != nil {
+		applied.Listeners = next.Listeners
+	}
+	rl.opts = &applied
+	rl.logger.Info("config reloaded", changes...)
+	return nil
+}
+
+func equalCachePolicy(a, b http.CachePolicy) bool {
+	return a.Pattern == b.Pattern && maps.Equal(a.CacheControl, b.CacheControl)
+}
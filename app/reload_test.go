@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/config"
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+	"github.com/codecrafters-io/http-server-starter-go/app/http/httptest"
+)
+
+func newTestReloader(t *testing.T) *reloader {
+	t.Helper()
+	opts := &options{
+		Listeners: []config.Listener{{Addr: defaultAddr}},
+		Directory: t.TempDir(),
+		LogLevel:  slog.LevelInfo,
+		LogFormat: "text",
+	}
+	rl, err := newReloader(opts, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+var reloadMountTest = []struct {
+	mounts []config.Mount
+}{
+	{[]config.Mount{{Path: "/files/"}}},
+	{[]config.Mount{{Path: "/echo/"}}},
+	{[]config.Mount{{Path: "/m/"}, {Path: "/m/"}}},
+}
+
+func TestReloadRejectsMountCollision(t *testing.T) {
+	for i, tt := range reloadMountTest {
+		rl := newTestReloader(t)
+		for j := range tt.mounts {
+			tt.mounts[j].Dir = t.TempDir()
+		}
+		mux, opts := rl.mux.Load(), rl.opts
+		next := *opts
+		next.Mounts = tt.mounts
+		if err := rl.apply(&next); err == nil {
+			t.Errorf("#%d: gotErr: nil wantErr: mount collision", i)
+		}
+		if rl.mux.Load() != mux || rl.opts != opts {
+			t.Errorf("#%d: the running mux or options were replaced", i)
+		}
+	}
+}
+
+// filesStatus is the status rl answers an unsigned GET /files/missing with.
+func filesStatus(rl *reloader) int {
+	req, _ := http.NewRequest(http.MethodGet, "/files/missing", nil)
+	w := httptest.NewRecorder()
+	rl.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestReloadSigningKey(t *testing.T) {
+	rl := newTestReloader(t)
+	for i, key := range []string{"0123456789abcdef", ""} {
+		next := *rl.opts
+		next.SigningKey = key
+		if err := rl.apply(&next); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		want := http.StatusNotFound
+		if key != "" {
+			want = http.StatusForbidden
+		}
+		if got := filesStatus(rl); got != want {
+			t.Errorf("#%d: signing key %q: gotStatus: %d wantStatus: %d", i, key, got, want)
+		}
+	}
+}
+
+func TestReloadQuotas(t *testing.T) {
+	rl := newTestReloader(t)
+	for i, size := range []int64{1 << 20, 0} {
+		next := *rl.opts
+		next.FilesQuota, next.MinFreeSpace = size, size
+		if err := rl.apply(&next); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		if rl.opts.FilesQuota != size || rl.opts.MinFreeSpace != size {
+			t.Errorf("#%d: gotFilesQuota: %d gotMinFreeSpace: %d want: %d", i, rl.opts.FilesQuota, rl.opts.MinFreeSpace, size)
+		}
+	}
+}
+
+func TestReloadMedia(t *testing.T) {
+	rl := newTestReloader(t)
+	if err := os.WriteFile(filepath.Join(rl.opts.Directory, "a.mp4"), []byte("movie"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i, media := range []bool{true, false} {
+		next := *rl.opts
+		next.Media = media
+		if err := rl.apply(&next); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/files/a.mp4", nil)
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, req)
+		want := ""
+		if media {
+			want = "bytes"
+		}
+		if got := w.GetHeader("Accept-Ranges"); got != want {
+			t.Errorf("#%d: media %t: gotAcceptRanges: %q wantAcceptRanges: %q", i, media, got, want)
+		}
+	}
+}
+
+func TestReloadCachePolicies(t *testing.T) {
+	rl := newTestReloader(t)
+	if err := os.WriteFile(filepath.Join(rl.opts.Directory, "a.html"), []byte("<p>"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for i, control := range []string{"no-cache", "max-age=60", ""} {
+		next := *rl.opts
+		next.CachePolicies = nil
+		if control != "" {
+			next.CachePolicies = []http.CachePolicy{{Pattern: "*.html", CacheControl: http.ParseCacheControl(control)}}
+		}
+		if err := rl.apply(&next); err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/files/a.html", nil)
+		w := httptest.NewRecorder()
+		rl.ServeHTTP(w, req)
+		if got := w.GetHeader("Cache-Control"); got != control {
+			t.Errorf("#%d: gotCacheControl: %q wantCacheControl: %q", i, got, control)
+		}
+	}
+}
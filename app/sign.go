@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// runSign implements the sign subcommand, which prints a signed URL
+// granting temporary access to one path of a server run with the same
+// -signing-key:
+//
+//	./your_program.sh sign -ttl 1h -base http://localhost:4221 /files/report.pdf
+//	./your_program.sh sign -method POST /files/upload.bin
+//
+// The key defaults to envKey, the server's signing key variable.
+func runSign(args []string, envKey string, out io.Writer) int {
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	key := fs.String("key", envKey, "signing `key`, as given to the server (default $"+envName("signing-key")+")")
+	method := fs.String("method", http.MethodGet, "request `method` the URL is good for")
+	ttl := fs.Duration("ttl", time.Hour, "how long the URL stays valid")
+	base := fs.String("base", "", "scheme and host to put before PATH, e.g. http://localhost:4221")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: sign [flags] PATH")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if len(*key) < minSigningKeyLen {
+		ErrorLogger.Printf("sign: the signing key must be at least %d bytes", minSigningKeyLen)
+		return 2
+	}
+	signer := &http.SignedURLs{Key: []byte(*key)}
+	u, err := signer.Sign(*method, *base+fs.Arg(0), time.Now().Add(*ttl))
+	if err != nil {
+		ErrorLogger.Printf("sign: %s", err)
+		return 1
+	}
+	fmt.Fprintln(out, u)
+	return 0
+}
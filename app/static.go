@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/http-server-starter-go/app/http"
+)
+
+// runStatic implements the static subcommand, which serves a directory as
+// a read-only static site with no further setup: index files, content
+// types by extension, ETags, gzip, Cache-Control by path, directory
+// listings, a single-page app fallback, an access log line per request,
+// and optionally a log of files changing on disk:
+//
+//	./your_program.sh static -addr :8080 -list -cache-control "public, max-age=300" ./public
+func runStatic(args []string) int {
+	fs := flag.NewFlagSet("static", flag.ContinueOnError)
+	addr := fs.String("addr", ":8080", "`address` to listen on")
+	index := fs.String("index", "index.html", "`file` served for directory requests, empty for none")
+	list := fs.Bool("list", false, "list the contents of directories that have no index file")
+	spa := fs.Bool("spa", false, "serve the -index file for paths naming no file, for client-side routed apps")
+	spaExclude := fs.String("spa-exclude", "", "comma-separated path `prefixes` that get 404 rather than the -spa fallback, e.g. /api/")
+	cacheControl := fs.String("cache-control", "", "Cache-Control `directives` sent with files no policy matches, e.g. \"public, max-age=300\"")
+	var policies cachePolicyFlag
+	fs.Var(&policies, "cache-policy", "Cache-Control for matching files as 'pattern=directives', e.g. 'assets/*=max-age=600'; may be repeated, the first match wins")
+	presets := fs.Bool("cache-presets", true, "after any -cache-policy, cache fingerprinted assets for a year and revalidate HTML")
+	gzip := fs.Bool("gzip", true, "gzip responses for clients that accept it")
+	accessLog := fs.Bool("access-log", true, "log every request")
+	accessExclude := fs.String("access-log-exclude", "", "comma-separated path `prefixes` left out of the access log")
+	var samples sampleRateFlag
+	fs.Var(&samples, "access-log-sample", "log only a fraction of the requests under a path prefix, as '/prefix=rate'; may be repeated")
+	logFormat := fs.String("log-format", "text", "log `format`: text or json")
+	watch := fs.Duration("watch", 0, "poll DIR at this `interval` and log files added, removed or modified; 0 disables")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: static [flags] DIR")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	dir, err := filepath.Abs(fs.Arg(0))
+	if err == nil {
+		var fi os.FileInfo
+		if fi, err = os.Stat(dir); err == nil && !fi.IsDir() {
+			err = fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	if err != nil {
+		ErrorLogger.Printf("static: %s", err)
+		return 2
+	}
+
+	var logger *slog.Logger
+	switch *logFormat {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	default:
+		ErrorLogger.Printf("static: unknown log format %q", *logFormat)
+		return 2
+	}
+
+	files := &http.FilesHandler{
+		Root:         dir,
+		Prefix:       "/",
+		ReadOnly:     true,
+		Index:        *index,
+		ListDirs:     *list,
+		ContentTypes: true,
+		Logger:       logger,
+	}
+	if *spa {
+		if *index == "" {
+			ErrorLogger.Print("static: -spa needs an -index file")
+			return 2
+		}
+		files.Fallback = *index
+		files.FallbackExclude = splitList(*spaExclude)
+	}
+	if *cacheControl != "" {
+		files.CacheControl = http.ParseCacheControl(*cacheControl)
+	}
+	files.CachePolicies = policies
+	if *presets {
+		files.CachePolicies = append(files.CachePolicies, http.StaticCachePolicies()...)
+	}
+	server := &http.Server{
+		Addr:               *addr,
+		Handler:            http.ETag(files),
+		Logger:             logger,
+		DisableCompression: !*gzip,
+	}
+	if *accessLog {
+		al := &http.AccessLog{Logger: logger, Exclude: splitList(*accessExclude), Samples: samples}
+		server.OnRequestEnd = al.Log
+	}
+
+	if *watch > 0 {
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		w := &http.Watcher{Dirs: []string{dir}, Interval: *watch, Logger: logger, OnChange: func(changes []http.FileChange) {
+			for _, c := range changes {
+				logger.Info("file "+c.Op.String(), "path", c.Path)
+			}
+		}}
+		go w.Run(ctx)
+	}
+
+	go shutdownOnSignal(server, logger)
+	logger.Info("serving static site", "dir", dir, "addr", *addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		ErrorLogger.Printf("static: %s", err)
+		return 1
+	}
+	return 0
+}
+
+// cachePolicyFlag collects -cache-policy values.
+type cachePolicyFlag []http.CachePolicy
+
+func (f *cachePolicyFlag) String() string { return "" }
+
+func (f *cachePolicyFlag) Set(v string) error {
+	pattern, directives, ok := strings.Cut(v, "=")
+	if !ok || pattern == "" || strings.TrimSpace(directives) == "" {
+		return fmt.Errorf("cache policy %q is not of the form 'pattern=directives'", v)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("cache policy %q: %v", v, err)
+	}
+	*f = append(*f, http.CachePolicy{Pattern: pattern, CacheControl: http.ParseCacheControl(directives)})
+	return nil
+}